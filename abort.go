@@ -0,0 +1,29 @@
+package httpfly
+
+import "net/http"
+
+// Abort ends the request with an error response of status and msg, an
+// *HTTPError written through the error handler, and skips the middleware
+// and handler that would run after the caller. An empty msg means the
+// status text. Only the first call of Abort or AbortWithError responds.
+func (r *RequestBody) Abort(status int, msg string) {
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	r.AbortWithError(&HTTPError{Status: status, Code: statusCode(status), Message: msg})
+}
+
+// AbortWithError is like Abort, answering the request for err as Fail
+// does, e.g. with an *HTTPError or a *Problem.
+func (r *RequestBody) AbortWithError(err error) {
+	if r.aborted {
+		return
+	}
+	r.aborted = true
+	r.Fail(err)
+}
+
+// Aborted reports whether the request has been aborted.
+func (r *RequestBody) Aborted() bool {
+	return r.aborted
+}
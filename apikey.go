@@ -0,0 +1,43 @@
+package httpfly
+
+import "net/http"
+
+// APIKeyProvider authenticates requests with an API key sent in a header or
+// a query parameter.
+type APIKeyProvider struct {
+	header string
+	query  string
+	lookup func(key string) (map[string]string, bool)
+}
+
+// NewAPIKeyProvider creates an API-key provider that reads the key from the
+// given header, or from the query parameter when the header is absent.
+// Either name may be empty to disable that source. lookup returns the
+// claims of a valid key.
+func NewAPIKeyProvider(header, query string, lookup func(key string) (map[string]string, bool)) *APIKeyProvider {
+	return &APIKeyProvider{header: header, query: query, lookup: lookup}
+}
+
+// Authenticate implements AuthProvider.
+func (p *APIKeyProvider) Authenticate(req *http.Request) (map[string]string, error) {
+	var key string
+	if p.header != "" {
+		key = req.Header.Get(p.header)
+	}
+	if key == "" && p.query != "" {
+		key = req.URL.Query().Get(p.query)
+	}
+
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	claims, ok := p.lookup(key)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	if claims == nil {
+		claims = map[string]string{}
+	}
+	return claims, nil
+}
@@ -0,0 +1,54 @@
+package httpfly
+
+import (
+	"net/http"
+	"time"
+)
+
+// AuditDecision is the outcome of an auth check.
+type AuditDecision string
+
+const (
+	// AuditAllow records an accepted request.
+	AuditAllow AuditDecision = "allow"
+	// AuditDeny records a rejected request.
+	AuditDeny AuditDecision = "deny"
+)
+
+// AuditEvent describes an authentication or authorization decision.
+type AuditEvent struct {
+	Time       time.Time
+	Method     string
+	Route      string
+	Path       string
+	RemoteAddr string
+	// ClientIP is the client address resolved through trusted proxies.
+	ClientIP string
+	Decision AuditDecision
+	Reason   string
+	// Subject is the "sub" claim of the authenticated subject, if any.
+	Subject string
+}
+
+// AuditHook, when set, receives an event for every auth decision of the
+// default router.
+var AuditHook func(AuditEvent)
+
+// emitAudit reports an auth decision to hook.
+func (r *Router) emitAudit(hook func(AuditEvent), v *RouteInfo, req *http.Request, decision AuditDecision, reason string, claims map[string]string) {
+	if hook == nil {
+		return
+	}
+
+	hook(AuditEvent{
+		Time:       time.Now(),
+		Method:     req.Method,
+		Route:      v.Endpoint,
+		Path:       req.URL.Path,
+		RemoteAddr: req.RemoteAddr,
+		ClientIP:   r.clientIP(req),
+		Decision:   decision,
+		Reason:     reason,
+		Subject:    claims["sub"],
+	})
+}
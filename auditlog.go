@@ -0,0 +1,139 @@
+package httpfly
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a handled request for the audit log: who made it,
+// what it did, when, and with which result.
+type AuditRecord struct {
+	Time      time.Time         `json:"time"`
+	RequestID string            `json:"request_id,omitempty"`
+	Method    string            `json:"method"`
+	Route     string            `json:"route"`
+	Path      string            `json:"path"`
+	ClientIP  string            `json:"client_ip"`
+	Subject   string            `json:"subject,omitempty"`
+	Claims    map[string]string `json:"claims,omitempty"`
+	// BodyDigest is the hex SHA-256 of the request body, empty without a
+	// body.
+	BodyDigest string `json:"body_digest,omitempty"`
+	// Body is the redacted JSON request body, recorded only with
+	// AuditLogConfig.RecordBody.
+	Body     json.RawMessage `json:"body,omitempty"`
+	Status   int             `json:"status"`
+	Duration time.Duration   `json:"duration"`
+}
+
+// AuditSink stores audit records.
+type AuditSink interface {
+	WriteAudit(rec AuditRecord) error
+}
+
+// AuditSinkFunc adapts a function to AuditSink.
+type AuditSinkFunc func(rec AuditRecord) error
+
+// WriteAudit implements AuditSink.
+func (f AuditSinkFunc) WriteAudit(rec AuditRecord) error { return f(rec) }
+
+// AuditLogConfig configures request audit logging.
+type AuditLogConfig struct {
+	Sink AuditSink
+	// AllRoutes audits every matched route instead of only the routes
+	// registered with Audited.
+	AllRoutes bool
+	// RecordBody records JSON request bodies, with the fields in Redact
+	// masked. See RedactJSON.
+	RecordBody bool
+	Redact     []string
+}
+
+// Audited selects a route for the audit log.
+func Audited() RouteOption {
+	return func(ri *RouteInfo) {
+		ri.audited = true
+	}
+}
+
+// UseAuditLog records requests of the default router in an audit log. See
+// Router.UseAuditLog.
+func UseAuditLog(cfg AuditLogConfig) {
+	defaultRouter.UseAuditLog(cfg)
+}
+
+// UseAuditLog records every request to a route registered with Audited, or
+// to any route with cfg.AllRoutes, in cfg.Sink once it has been handled,
+// including requests rejected by authentication. Sink errors are logged.
+func (r *Router) UseAuditLog(cfg AuditLogConfig) {
+	r.AfterResponse(func(rb *RequestBody, status int, duration time.Duration) {
+		if rb.route == nil || !(cfg.AllRoutes || rb.route.audited) {
+			return
+		}
+
+		rec := AuditRecord{
+			Time:      time.Now().Add(-duration),
+			RequestID: rb.RequestID(),
+			Method:    rb.req.Method,
+			Route:     rb.route.Endpoint,
+			Path:      rb.req.URL.Path,
+			ClientIP:  rb.ClientIP(),
+			Subject:   rb.ClaimString("sub"),
+			Claims:    rb.Claims.Strings(),
+			Status:    status,
+			Duration:  duration,
+		}
+
+		if len(rb.JsonData) > 0 {
+			sum := sha256.Sum256(rb.JsonData)
+			rec.BodyDigest = hex.EncodeToString(sum[:])
+
+			if cfg.RecordBody && json.Valid(rb.JsonData) {
+				rec.Body = RedactJSON(rb.JsonData, cfg.Redact)
+			}
+		}
+
+		if err := cfg.Sink.WriteAudit(rec); err != nil && r.logger != nil {
+			r.logger.Error("audit sink failed", "error", err.Error(), "method", rec.Method, "path", rec.Path)
+		}
+	})
+}
+
+// FileAuditSink appends audit records to a file as JSON lines.
+type FileAuditSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileAuditSink opens path for appending, creating it with mode 0600 if
+// needed.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{f: f}, nil
+}
+
+// WriteAudit implements AuditSink.
+func (s *FileAuditSink) WriteAudit(rec AuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.f.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (s *FileAuditSink) Close() error {
+	return s.f.Close()
+}
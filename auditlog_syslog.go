@@ -0,0 +1,37 @@
+//go:build !windows && !plan9
+
+package httpfly
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+// SyslogAuditSink sends audit records as JSON to the system logger.
+type SyslogAuditSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogAuditSink connects to the local syslog daemon, logging with the
+// given tag under the auth facility.
+func NewSyslogAuditSink(tag string) (*SyslogAuditSink, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogAuditSink{w: w}, nil
+}
+
+// WriteAudit implements AuditSink.
+func (s *SyslogAuditSink) WriteAudit(rec AuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.w.Info(string(line))
+}
+
+// Close closes the connection to the system logger.
+func (s *SyslogAuditSink) Close() error {
+	return s.w.Close()
+}
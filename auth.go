@@ -0,0 +1,92 @@
+package httpfly
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrNoCredentials is returned by an AuthProvider when the request carries
+// no credentials at all.
+var ErrNoCredentials = errors.New("no credentials")
+
+// ErrNoAuthProvider is the audit reason of requests to routes that need
+// authentication when no AuthProvider is set.
+var ErrNoAuthProvider = errors.New("no auth provider")
+
+// AuthProvider authenticates requests to routes mapped with UseAuth.
+type AuthProvider interface {
+	// Authenticate returns the claims of the authenticated subject, or an
+	// error describing why the request is rejected. Providers that also
+	// implement ClaimsProvider keep the types of their claims.
+	Authenticate(req *http.Request) (map[string]string, error)
+}
+
+// Challenger is implemented by providers that tell clients how to
+// authenticate. Its result is sent in the WWW-Authenticate header of 401
+// responses.
+type Challenger interface {
+	Challenge() string
+}
+
+// WithAuthProvider authenticates the route with p instead of the router's
+// provider, and requires authentication for it.
+func WithAuthProvider(p AuthProvider) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.AuthRequired = true
+		ri.authProvider = p
+	}
+}
+
+// SetAuthProvider sets the provider used for routes mapped with UseAuth.
+// Requests it rejects are answered with 401 Unauthorized; accepted requests
+// get their claims in RequestBody.Claims. Without a provider, requests to
+// UseAuth routes are always rejected with 401.
+func SetAuthProvider(p AuthProvider) {
+	defaultRouter.SetAuthProvider(p)
+}
+
+// SetAuthProvider sets the provider used for UseAuth routes of the router.
+func (r *Router) SetAuthProvider(p AuthProvider) {
+	r.authProvider = p
+}
+
+// authenticate runs the auth provider for a route and reports the decision
+// to the audit hook. It returns false if the request was rejected, in which
+// case the 401 response has been written.
+func (r *Router) authenticate(v *RouteInfo, rb *RequestBody, w http.ResponseWriter, req *http.Request, hook func(AuditEvent)) bool {
+	provider := r.authProvider
+	if v.authProvider != nil {
+		provider = v.authProvider
+	}
+
+	if v.AuthRequired && r.dev != nil {
+		rb.Claims = r.dev.devClaims()
+		r.emitAudit(hook, v, req, AuditAllow, "development mode", rb.Claims.Strings())
+		return true
+	}
+
+	if !v.AuthRequired {
+		return true
+	}
+
+	if provider == nil {
+		r.emitAudit(hook, v, req, AuditDeny, ErrNoAuthProvider.Error(), nil)
+		r.frameworkError(w, req, http.StatusUnauthorized, "")
+		return false
+	}
+
+	claims, err := authenticateClaims(provider, req)
+
+	if err != nil {
+		r.emitAudit(hook, v, req, AuditDeny, err.Error(), nil)
+		if c, ok := provider.(Challenger); ok {
+			w.Header().Set("WWW-Authenticate", c.Challenge())
+		}
+		r.frameworkError(w, req, http.StatusUnauthorized, "")
+		return false
+	}
+
+	rb.Claims = claims
+	r.emitAudit(hook, v, req, AuditAllow, "authenticated", claims.Strings())
+	return true
+}
@@ -0,0 +1,105 @@
+package httpfly
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type tokenProvider string
+
+func (p tokenProvider) Authenticate(req *http.Request) (map[string]string, error) {
+	switch req.Header.Get("Authorization") {
+	case "":
+		return nil, ErrNoCredentials
+	case "Bearer " + string(p):
+		return map[string]string{"sub": "alice"}, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func TestAuthenticateDenyAudit(t *testing.T) {
+	var events []AuditEvent
+
+	r := NewRouter()
+	r.AuditHook = func(e AuditEvent) { events = append(events, e) }
+	r.SetAuthProvider(tokenProvider("secret"))
+	r.MapGet("/private", UseAuth, func(rb *RequestBody) {})
+
+	c := NewTestClient(r)
+	if res := c.Get("/api/private"); res.Status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", res.Status)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("got %d audit events, want 1", len(events))
+	}
+	e := events[0]
+	if e.Decision != AuditDeny || e.Reason != ErrNoCredentials.Error() || e.Route != "/api/private" {
+		t.Errorf("event = %+v, want deny for no credentials on /api/private", e)
+	}
+
+	c.Header.Set("Authorization", "Bearer secret")
+	if res := c.Get("/api/private"); res.Status != http.StatusOK {
+		t.Fatalf("authenticated status = %d, want 200", res.Status)
+	}
+	if e := events[len(events)-1]; e.Decision != AuditAllow || e.Subject != "alice" {
+		t.Errorf("event = %+v, want allow for alice", e)
+	}
+}
+
+func TestAuthenticateWithoutProviderFailsClosed(t *testing.T) {
+	var events []AuditEvent
+
+	r := NewRouter()
+	r.AuditHook = func(e AuditEvent) { events = append(events, e) }
+	r.MapGet("/private", UseAuth, func(rb *RequestBody) {
+		t.Error("handler ran without an auth provider")
+	})
+
+	if res := NewTestClient(r).Get("/api/private"); res.Status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", res.Status)
+	}
+	if len(events) != 1 || events[0].Decision != AuditDeny || events[0].Reason != ErrNoAuthProvider.Error() {
+		t.Errorf("events = %+v, want one deny for no auth provider", events)
+	}
+}
+
+func TestUseAuthRoutesAlwaysChecked(t *testing.T) {
+	r := NewRouter()
+	r.MapGet("/own", NoAuth, func(rb *RequestBody) {}, WithAuthProvider(tokenProvider("secret")))
+	r.Group("/admin", GroupAuth(UseAuth)).MapGet("/stats", NoAuth, func(rb *RequestBody) {})
+
+	c := NewTestClient(r)
+	if res := c.Get("/api/admin/stats"); res.Status != http.StatusUnauthorized {
+		t.Errorf("group route status = %d, want 401", res.Status)
+	}
+	if res := c.Get("/api/own"); res.Status != http.StatusUnauthorized {
+		t.Errorf("route provider status = %d, want 401", res.Status)
+	}
+
+	c.Header.Set("Authorization", "Bearer secret")
+	if res := c.Get("/api/own"); res.Status != http.StatusOK {
+		t.Errorf("route provider status = %d, want 200", res.Status)
+	}
+}
+
+func TestDevModeBypassesAuth(t *testing.T) {
+	r := NewRouter()
+	r.SetAuthProvider(tokenProvider("secret"))
+	r.EnableDevMode(DevConfig{FakeClaims: map[string]string{"sub": "dev", "role": "admin"}})
+	r.MapGet("/me", UseAuth, func(rb *RequestBody) {
+		rb.Claims["sub"] = "changed"
+		rb.Text(http.StatusOK, rb.ClaimString("role"))
+	})
+
+	c := NewTestClient(r)
+	for range 2 {
+		if res := c.Get("/api/me"); res.Status != http.StatusOK || res.String() != "admin" {
+			t.Fatalf("status %d, body %q; want the fake claims", res.Status, res.String())
+		}
+	}
+	if sub := r.dev.FakeClaims["sub"]; sub != "dev" {
+		t.Errorf("handler changed the fake claims to %q", sub)
+	}
+}
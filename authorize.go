@@ -0,0 +1,92 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Authorizer decides whether an authenticated request may use a route that
+// requires roles.
+type Authorizer interface {
+	// Authorize reports whether claims grant access to a route that
+	// requires one of roles.
+	Authorize(claims map[string]string, roles []string) bool
+}
+
+// AuthorizerFunc adapts a function to Authorizer.
+type AuthorizerFunc func(claims map[string]string, roles []string) bool
+
+// Authorize implements Authorizer.
+func (f AuthorizerFunc) Authorize(claims map[string]string, roles []string) bool {
+	return f(claims, roles)
+}
+
+// RequireRoles requires authentication for the route and lets only
+// subjects with at least one of roles through. Other requests are answered
+// with 403 Forbidden.
+func RequireRoles(roles ...string) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.AuthRequired = true
+		ri.roles = roles
+	}
+}
+
+// SetAuthorizer sets the authorizer of the default router. See
+// DefaultAuthorizer.
+func SetAuthorizer(a Authorizer) {
+	defaultRouter.SetAuthorizer(a)
+}
+
+// SetAuthorizer sets the authorizer used for routes with RequireRoles.
+func (r *Router) SetAuthorizer(a Authorizer) {
+	r.authorizer = a
+}
+
+// DefaultAuthorizer grants access when the "roles", "role" or "scope" claim
+// names one of the required roles. Claims may hold a JSON array or a
+// space- or comma-separated list.
+var DefaultAuthorizer Authorizer = AuthorizerFunc(func(claims map[string]string, roles []string) bool {
+	for _, name := range []string{"roles", "role", "scope"} {
+		for _, have := range claimList(claims[name]) {
+			for _, want := range roles {
+				if have == want {
+					return true
+				}
+			}
+		}
+	}
+	return false
+})
+
+// claimList splits a claim holding a JSON array or a list of words.
+func claimList(v string) []string {
+	var list []string
+	if strings.HasPrefix(v, "[") && json.Unmarshal([]byte(v), &list) == nil {
+		return list
+	}
+
+	return strings.FieldsFunc(v, func(c rune) bool { return c == ' ' || c == ',' })
+}
+
+// authorize checks the roles of a route. It returns false if the request
+// was rejected, in which case the 403 response has been written.
+func (r *Router) authorize(v *RouteInfo, rb *RequestBody, w http.ResponseWriter, req *http.Request, hook func(AuditEvent)) bool {
+	if len(v.roles) == 0 {
+		return true
+	}
+
+	a := r.authorizer
+	if a == nil {
+		a = DefaultAuthorizer
+	}
+
+	claims := rb.Claims.Strings()
+	if !a.Authorize(claims, v.roles) {
+		r.emitAudit(hook, v, req, AuditDeny, "missing role", claims)
+		r.frameworkError(w, req, http.StatusForbidden, "")
+		return false
+	}
+
+	return true
+}
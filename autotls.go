@@ -0,0 +1,41 @@
+package httpfly
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+)
+
+// CertManager obtains TLS certificates automatically. It matches the
+// methods of *autocert.Manager from golang.org/x/crypto/acme/autocert, so a
+// Let's Encrypt manager can be used without httpfly depending on it:
+//
+//	m := &autocert.Manager{
+//		Prompt:     autocert.AcceptTOS,
+//		HostPolicy: autocert.HostWhitelist("example.com"),
+//		Cache:      autocert.DirCache("/var/cache/certs"),
+//	}
+//	httpfly.StartHTTPServerAutoTLS(m)
+type CertManager interface {
+	// TLSConfig returns a TLS configuration that fetches certificates on
+	// demand.
+	TLSConfig() *tls.Config
+	// HTTPHandler answers HTTP-01 challenges and passes other requests to
+	// fallback, or redirects them to HTTPS when fallback is nil.
+	HTTPHandler(fallback http.Handler) http.Handler
+}
+
+// StartHTTPServerAutoTLS runs the startup hooks and serves the default
+// router over HTTPS on :443 with certificates from m. HTTP-01 challenges
+// are answered on :80, which redirects everything else to HTTPS.
+func StartHTTPServerAutoTLS(m CertManager) error {
+	return defaultRouter.StartAutoTLS(m)
+}
+
+// StartAutoTLS serves the router over HTTPS on :443 with certificates from
+// m. See StartHTTPServerAutoTLS.
+func (r *Router) StartAutoTLS(m CertManager) error {
+	s := NewServer(":443", r)
+	s.CertManager = m
+	return s.Start(context.Background())
+}
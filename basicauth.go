@@ -0,0 +1,44 @@
+package httpfly
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// ErrInvalidCredentials is returned by the Basic and API-key providers when
+// the credentials are wrong.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// BasicAuthProvider authenticates requests with HTTP Basic credentials.
+type BasicAuthProvider struct {
+	realm string
+	check func(username, password string) bool
+}
+
+// NewBasicAuthProvider creates a Basic auth provider. check reports whether
+// the credentials are valid; it should compare passwords in constant time,
+// e.g. with crypto/subtle. Accepted requests get the claim "sub" set to the
+// username.
+func NewBasicAuthProvider(realm string, check func(username, password string) bool) *BasicAuthProvider {
+	return &BasicAuthProvider{realm: realm, check: check}
+}
+
+// Authenticate implements AuthProvider.
+func (p *BasicAuthProvider) Authenticate(req *http.Request) (map[string]string, error) {
+	user, pass, ok := req.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	if !p.check(user, pass) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return map[string]string{"sub": user}, nil
+}
+
+// Challenge implements Challenger.
+func (p *BasicAuthProvider) Challenge() string {
+	return "Basic realm=" + strconv.Quote(p.realm) + `, charset="UTF-8"`
+}
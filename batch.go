@@ -0,0 +1,129 @@
+package httpfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// BatchRequest is a single sub-request of a batch call.
+type BatchRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResponse is the result of a single sub-request of a batch call.
+type BatchResponse struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// DefaultMaxBatchSize is the default limit on the number of sub-requests of
+// a batch call.
+const DefaultMaxBatchSize = 100
+
+// BatchHandler maps a POST route that accepts a JSON array of sub-requests,
+// dispatches each of them through the router and responds with an array of
+// sub-responses in the same order. Sub-requests carry the context, headers
+// and remote address of the batch request, so they are authenticated as
+// it is; sub-requests routed to the batch route itself get 400. Batches
+// with more sub-requests than Options.MaxBatchSize get 413.
+func BatchHandler(path string) {
+	defaultRouter.BatchHandler(path)
+}
+
+// BatchHandler maps a POST route that dispatches batched sub-requests. See
+// the package-level BatchHandler.
+func (r *Router) BatchHandler(path string) {
+	var self *RouteInfo
+
+	self = r.MapPost(path, NoAuth, func(rb *RequestBody) {
+		var batch []BatchRequest
+
+		if err := json.Unmarshal(rb.JsonData, &batch); err != nil {
+			r.frameworkError(rb.ResponseW, rb.req, http.StatusBadRequest, "invalid batch body")
+			return
+		}
+
+		max := r.options().MaxBatchSize
+		if max == 0 {
+			max = DefaultMaxBatchSize
+		}
+		if max > 0 && len(batch) > max {
+			r.frameworkError(rb.ResponseW, rb.req, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("batch has %d sub-requests, the limit is %d", len(batch), max))
+			return
+		}
+
+		result := make([]BatchResponse, len(batch))
+
+		for i, sub := range batch {
+			result[i] = r.dispatchBatch(rb.req, sub, self)
+		}
+
+		out, err := json.Marshal(result)
+
+		if err != nil {
+			rb.ResponseW.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rb.ResponseW.Header().Set("Content-Type", "application/json")
+		rb.ResponseW.Write(out)
+	})
+}
+
+// dispatchBatch runs one sub-request of parent in isolation, turning a
+// panic into a 500 sub-response rendered like any other internal error.
+// Sub-requests matching the batch route self are rejected.
+func (r *Router) dispatchBatch(parent *http.Request, sub BatchRequest, self *RouteInfo) (res BatchResponse) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			buf := newResponseBuffer()
+			internalError(r.logger, &ResponseRecorder{ResponseWriter: buf}, parent, rec, debug.Stack(), r.options().Production, r.problems)
+			res = BatchResponse{Status: buf.Status(), Body: batchBody(buf.body.Bytes())}
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(parent.Context(), sub.Method, sub.Path, bytes.NewReader(sub.Body))
+
+	if err != nil {
+		return BatchResponse{Status: http.StatusBadRequest, Body: batchBody([]byte(err.Error()))}
+	}
+
+	req.Header = parent.Header.Clone()
+	for _, h := range []string{"Content-Length", "Content-Type", "Content-Encoding", "Accept-Encoding"} {
+		req.Header.Del(h)
+	}
+	if len(sub.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Host, req.RemoteAddr, req.TLS = parent.Host, parent.RemoteAddr, parent.TLS
+
+	if v, _ := matchMethod(r.currentRoutes(), req.Method, req, r.options()); v == self {
+		return BatchResponse{Status: http.StatusBadRequest}
+	}
+
+	buf := newResponseBuffer()
+	r.ServeHTTP(buf, req)
+
+	return BatchResponse{Status: buf.Status(), Body: batchBody(buf.body.Bytes())}
+}
+
+// batchBody embeds a sub-response body as-is when it is JSON, or as a JSON
+// string otherwise.
+func batchBody(b []byte) json.RawMessage {
+	if len(b) == 0 {
+		return nil
+	}
+
+	if json.Valid(b) {
+		return b
+	}
+
+	s, _ := json.Marshal(string(b))
+	return s
+}
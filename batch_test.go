@@ -0,0 +1,99 @@
+package httpfly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type batchCtxKey struct{}
+
+func TestBatch(t *testing.T) {
+	r := NewRouter()
+	r.CaseInsensitivePaths = true
+	r.SetAuthProvider(tokenProvider("secret"))
+	r.BatchHandler("/batch")
+	r.MapGet("/ping", NoAuth, func(rb *RequestBody) {
+		rb.JSON(http.StatusOK, map[string]any{"remote": rb.Request().RemoteAddr, "ctx": rb.Context().Value(batchCtxKey{})})
+	})
+	r.MapGet("/me", UseAuth, func(rb *RequestBody) {
+		rb.JSON(http.StatusOK, map[string]string{"sub": rb.ClaimString("sub")})
+	})
+
+	subs := []BatchRequest{
+		{Method: "GET", Path: "/api/ping"},
+		{Method: "GET", Path: "/api/me"},
+		{Method: "POST", Path: "/api/batch?x=1", Body: []byte("[]")},
+		{Method: "POST", Path: "/API/Batch", Body: []byte("[]")},
+	}
+	body, _ := json.Marshal(subs)
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req = req.WithContext(context.WithValue(req.Context(), batchCtxKey{}, "parent"))
+	res := NewTestClient(r).Send(req)
+	if res.Status != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", res.Status, res.String())
+	}
+
+	var out []struct {
+		Status int
+		Body   map[string]string
+	}
+	if err := res.JSON(&out); err != nil || len(out) != len(subs) {
+		t.Fatalf("got %d sub-responses (%v), want %d", len(out), err, len(subs))
+	}
+
+	if out[0].Status != http.StatusOK || out[0].Body["remote"] != req.RemoteAddr || out[0].Body["ctx"] != "parent" {
+		t.Errorf("ping = %+v, want the remote address and context of the batch", out[0])
+	}
+	if out[1].Status != http.StatusOK || out[1].Body["sub"] != "alice" {
+		t.Errorf("me = %+v, want the claims of the batch credentials", out[1])
+	}
+	for _, o := range out[2:] {
+		if o.Status != http.StatusBadRequest {
+			t.Errorf("nested batch status = %d, want 400", o.Status)
+		}
+	}
+}
+
+func TestBatchLimitAndPanics(t *testing.T) {
+	r := NewRouter()
+	r.MaxBatchSize = 2
+	r.BatchHandler("/batch")
+	r.MapGet("/ping", NoAuth, func(rb *RequestBody) {})
+	r.AfterResponse(func(rb *RequestBody, status int, duration time.Duration) {
+		if rb.Request().URL.Path == "/api/ping" {
+			panic("secret detail")
+		}
+	})
+
+	c := NewTestClient(r)
+	ping := BatchRequest{Method: "GET", Path: "/api/ping"}
+
+	if res := c.Post("/api/batch", []BatchRequest{ping, ping, ping}); res.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized batch: status = %d, want 413", res.Status)
+	}
+
+	res := c.Post("/api/batch", []BatchRequest{ping})
+	var out []BatchResponse
+	if err := res.JSON(&out); err != nil || len(out) != 1 {
+		t.Fatalf("got %s (%v), want one sub-response", res.String(), err)
+	}
+	if out[0].Status != http.StatusInternalServerError {
+		t.Errorf("panicking sub-request: status = %d, want 500", out[0].Status)
+	}
+	if body := string(out[0].Body); !strings.Contains(body, "error_id") || strings.Contains(body, "secret detail") {
+		t.Errorf("panicking sub-request body = %s, want an error id without the detail", body)
+	}
+
+	r.MaxBatchSize = -1
+	if res := c.Post("/api/batch", []BatchRequest{ping, ping, ping}); res.Status != http.StatusOK {
+		t.Errorf("disabled limit: status = %d, want 200", res.Status)
+	}
+}
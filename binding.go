@@ -0,0 +1,183 @@
+package httpfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ErrNotJSONArray is returned by BindJSONArray when the body is not a JSON
+// array.
+var ErrNotJSONArray = errors.New("request body is not a JSON array")
+
+// BindJSON decodes the JSON request body into v and validates it. Any JSON
+// value is accepted, including top-level arrays when v points to a slice.
+func (r *RequestBody) BindJSON(v any) error {
+	if err := json.Unmarshal(r.JsonData, v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+// BindJSONArray decodes the request body into v, which should point to a
+// slice, and fails with ErrNotJSONArray unless the body is a JSON array.
+func (r *RequestBody) BindJSONArray(v any) error {
+	if trimmed := bytes.TrimSpace(r.JsonData); len(trimmed) == 0 || trimmed[0] != '[' {
+		return ErrNotJSONArray
+	}
+
+	return r.BindJSON(v)
+}
+
+// SniffBodies lets Bind on the default router pick a decoder from the body
+// itself when the request has no Content-Type or declares
+// application/octet-stream: a body starting with '{' or '[' is decoded as
+// JSON and one starting with '<' as XML.
+var SniffBodies = false
+
+// ErrUnsupportedMediaType is returned by Bind when no decoder matches the
+// request body.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// BindXML decodes the XML request body into v and validates it.
+func (r *RequestBody) BindXML(v any) error {
+	if err := xml.Unmarshal(r.JsonData, v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+// Bind decodes the request body into v using the decoder selected by the
+// Content-Type header, or by sniffing the body when SniffBodies is set:
+// BindJSON, BindXML, or BindForm for URL-encoded and multipart forms.
+func (r *RequestBody) Bind(v any) error {
+	if err := r.decodeBody(v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+// BindAll populates the struct v points to from the request body, if any,
+// then from the query, headers and path parameters named by the query,
+// header and path tags of its fields, e.g. `path:"id"`, `query:"page"` or
+// `header:"X-Tenant"`, and validates it. Later sources win, so a path
+// parameter overrides a body field of the same struct field. Tagged fields
+// support the types of BindForm; malformed values fail with 400 Bad
+// Request.
+func (r *RequestBody) BindAll(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("httpfly: BindAll needs a pointer to a struct")
+	}
+
+	if len(bytes.TrimSpace(r.JsonData)) > 0 {
+		if err := r.decodeBody(v); err != nil {
+			return err
+		}
+	}
+
+	query := r.Queries()
+	sources := []struct {
+		tag    string
+		lookup func(name string) []string
+	}{
+		{"query", func(name string) []string { return query[name] }},
+		{"header", func(name string) []string {
+			if r.req == nil {
+				return nil
+			}
+			return r.req.Header.Values(name)
+		}},
+		{"path", func(name string) []string {
+			if p, ok := r.Params[name]; ok {
+				return []string{string(p)}
+			}
+			return nil
+		}},
+	}
+
+	rv = rv.Elem()
+	for _, src := range sources {
+		if err := bindTagged(rv, src.tag, src.lookup); err != nil {
+			return err
+		}
+	}
+	return Validate(v)
+}
+
+// bindTagged sets the fields of the struct rv carrying tag from the values
+// lookup returns for the tag value. Missing values leave the field alone.
+func bindTagged(rv reflect.Value, tag string, lookup func(name string) []string) error {
+	t := rv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get(tag)
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		values := lookup(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setFormField(rv.Field(i), values); err != nil {
+			return &HTTPError{Status: http.StatusBadRequest, Code: "invalid_" + tag, Message: fmt.Sprintf("%s %q: %v", tag, name, err)}
+		}
+	}
+	return nil
+}
+
+// decodeBody decodes the request body into v without validating it, see
+// Bind.
+func (r *RequestBody) decodeBody(v any) error {
+	var contentType string
+	if r.req != nil {
+		contentType = r.req.Header.Get("Content-Type")
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	if r.router != nil && r.router.options().SniffBodies && (mediaType == "" || mediaType == "application/octet-stream") {
+		mediaType = sniffMediaType(r.JsonData)
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return json.Unmarshal(r.JsonData, v)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return xml.Unmarshal(r.JsonData, v)
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		if r.form == nil {
+			r.form = r.parseForm()
+		}
+		return decodeForm(r.form, v)
+	}
+
+	return ErrUnsupportedMediaType
+}
+
+// sniffMediaType guesses the media type of a body from its first
+// non-whitespace byte.
+func sniffMediaType(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return ""
+	}
+
+	switch trimmed[0] {
+	case '{', '[':
+		return "application/json"
+	case '<':
+		return "application/xml"
+	}
+
+	return ""
+}
@@ -0,0 +1,78 @@
+package httpfly
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestBindJSONArray(t *testing.T) {
+	type item struct {
+		ID int `json:"id"`
+	}
+
+	rb := &RequestBody{JsonData: []byte(` [{"id":1},{"id":2}]`)}
+	var items []item
+	if err := rb.BindJSONArray(&items); err != nil || len(items) != 2 || items[1].ID != 2 {
+		t.Errorf("items = %v, %v; want both elements", items, err)
+	}
+
+	items = nil
+	if err := rb.BindJSON(&items); err != nil || len(items) != 2 {
+		t.Errorf("BindJSON into a slice = %v, %v", items, err)
+	}
+
+	for _, body := range []string{`{"id":1}`, ``, `"[1]"`} {
+		rb := &RequestBody{JsonData: []byte(body)}
+		if err := rb.BindJSONArray(&items); !errors.Is(err, ErrNotJSONArray) {
+			t.Errorf("body %q: err = %v, want ErrNotJSONArray", body, err)
+		}
+	}
+}
+
+func TestBindSniffing(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	r := NewRouter()
+	r.MapPost("/users", NoAuth, func(rb *RequestBody) {
+		var u user
+		if err := rb.Bind(&u); err != nil {
+			rb.Text(http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		rb.Text(http.StatusOK, u.Name)
+	})
+
+	c := NewTestClient(r)
+	for _, sniff := range []bool{true, false} {
+		r.SniffBodies = sniff
+		for _, tt := range []struct {
+			contentType, body string
+		}{
+			{"", `{"name":"alice"}`},
+			{"", "  <user><name>alice</name></user>"},
+			{"application/octet-stream", `{"name":"alice"}`},
+			{"application/octet-stream", "<user><name>alice</name></user>"},
+		} {
+			c.Header = http.Header{}
+			if tt.contentType != "" {
+				c.Header.Set("Content-Type", tt.contentType)
+			}
+			resp := c.Post("/api/users", tt.body)
+			if sniff && (resp.Status != http.StatusOK || resp.String() != "alice") {
+				t.Errorf("sniffing %q as %q = %d %q, want alice", tt.body, tt.contentType, resp.Status, resp.String())
+			}
+			if !sniff && resp.Status != http.StatusUnsupportedMediaType {
+				t.Errorf("without sniffing %q as %q = %d %q, want an unsupported media type", tt.body, tt.contentType, resp.Status, resp.String())
+			}
+		}
+	}
+
+	r.SniffBodies = true
+	c.Header = http.Header{}
+	if resp := c.Post("/api/users", "name=alice"); resp.Status != http.StatusUnsupportedMediaType {
+		t.Errorf("unrecognizable body = %d %q, want an unsupported media type", resp.Status, resp.String())
+	}
+}
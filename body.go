@@ -0,0 +1,178 @@
+package httpfly
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+type bodyPolicy int
+
+const (
+	bodyOptional bodyPolicy = iota
+	bodyRequired
+	bodyForbidden
+)
+
+// RequireBody rejects requests to the route that have an empty body with
+// 400 Bad Request.
+func RequireBody() RouteOption {
+	return func(ri *RouteInfo) {
+		ri.body = bodyRequired
+	}
+}
+
+// ForbidBody rejects requests to the route that carry a body with 400 Bad
+// Request.
+func ForbidBody() RouteOption {
+	return func(ri *RouteInfo) {
+		ri.body = bodyForbidden
+	}
+}
+
+// WithMaxBody limits the size of request bodies of the route to n bytes,
+// replacing the limit of the router. Larger bodies are answered with 413
+// Request Entity Too Large before the handler runs. A negative n disables
+// the limit, e.g. for large uploads.
+func WithMaxBody(n int64) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.maxBody = n
+	}
+}
+
+// WithContentTypes restricts the media types of request bodies accepted by
+// the route, e.g. WithContentTypes("application/json"). Requests carrying a
+// body of another type are answered with 415 Unsupported Media Type before
+// the handler runs. Requests without a body are not checked.
+func WithContentTypes(types ...string) RouteOption {
+	return func(ri *RouteInfo) {
+		for _, t := range types {
+			ri.mediaTypes = append(ri.mediaTypes, strings.ToLower(t))
+		}
+	}
+}
+
+// acceptsMediaType reports whether the route accepts the body of req.
+func (ri *RouteInfo) acceptsMediaType(req *http.Request) bool {
+	if len(ri.mediaTypes) == 0 || (req.ContentLength == 0 && len(req.TransferEncoding) == 0) {
+		return true
+	}
+
+	mt, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	for _, t := range ri.mediaTypes {
+		if t == mt {
+			return true
+		}
+	}
+	return false
+}
+
+// check validates a request body against the policy.
+func (p bodyPolicy) check(body []byte) error {
+	switch {
+	case p == bodyRequired && len(body) == 0:
+		return errors.New("request body is required")
+	case p == bodyForbidden && len(body) > 0:
+		return errors.New("request body is not allowed")
+	}
+
+	return nil
+}
+
+// DefaultMaxBodySize is the default limit of request bodies, the same as
+// DefaultMaxUploadMemory.
+const DefaultMaxBodySize = 32 << 20
+
+// SetMaxBodySize limits the size of request bodies of the default router.
+// Larger bodies are answered with 413 Request Entity Too Large. Zero means
+// DefaultMaxBodySize; a negative value disables the limit.
+func SetMaxBodySize(n int64) {
+	defaultRouter.MaxBodySize = n
+}
+
+// StreamBody leaves the request body of the route unread, so the handler
+// can consume it as it arrives through Body or NDJSONDecoder instead of
+// having it buffered in JsonData. The size limit and decompression still
+// apply while it is read; RequireBody, ForbidBody, body hooks, the
+// sanitizer and multipart checks do not.
+func StreamBody() RouteOption {
+	return func(ri *RouteInfo) {
+		ri.streamBody = true
+	}
+}
+
+// readBody reads the whole request body, failing with *http.MaxBytesError
+// once it exceeds max bytes, or DefaultMaxBodySize when max is zero; a
+// negative max disables the limit. Compressed bodies are decompressed,
+// failing the same way once they exceed maxDecoded bytes.
+func readBody(w http.ResponseWriter, req *http.Request, max, maxDecoded int64) ([]byte, error) {
+	body, err := bodyReader(w, req, max, maxDecoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(body)
+}
+
+// bodyReader returns a reader over the decoded request body that enforces
+// the limits of readBody.
+func bodyReader(w http.ResponseWriter, req *http.Request, max, maxDecoded int64) (io.Reader, error) {
+	var body io.Reader = req.Body
+
+	if max == 0 {
+		max = DefaultMaxBodySize
+	}
+	if max > 0 {
+		if req.ContentLength > max {
+			return nil, &http.MaxBytesError{Limit: max}
+		}
+		body = http.MaxBytesReader(w, req.Body, max)
+	}
+
+	return decodeContentEncoding(req, body, maxDecoded)
+}
+
+// Body returns a reader over the request body. For routes with StreamBody
+// it reads from the connection and can only be consumed once.
+func (r *RequestBody) Body() io.Reader {
+	if r.stream != nil {
+		return r.stream
+	}
+	return bytes.NewReader(r.JsonData)
+}
+
+// RawBody returns the request body as it was read, after decompression but
+// before body hooks and the sanitizer change it, and rewinds the Body of
+// the http.Request to its start, so middleware such as signature checks
+// and the handler can each read it in full. The Body is rewound again
+// before the handler runs. For routes with StreamBody it first reads the
+// rest of the stream into memory, after which Body and JsonData serve the
+// buffer.
+func (r *RequestBody) RawBody() ([]byte, error) {
+	if r.stream != nil {
+		raw, err := io.ReadAll(r.stream)
+		if err != nil {
+			return nil, err
+		}
+		r.stream = nil
+		r.raw, r.JsonData = raw, raw
+	}
+
+	r.rewindBody()
+	return r.raw, nil
+}
+
+// rewindBody replaces the Body of the request with a reader over the raw
+// body, unless the body is streamed.
+func (r *RequestBody) rewindBody() {
+	if r.req != nil && r.stream == nil {
+		r.req.Body = io.NopCloser(bytes.NewReader(r.raw))
+	}
+}
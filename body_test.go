@@ -0,0 +1,61 @@
+package httpfly
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestMaxBodySizeDefault(t *testing.T) {
+	r := NewRouter()
+	r.MapPost("/upload", NoAuth, func(rb *RequestBody) {})
+	r.MapPost("/unlimited", NoAuth, func(rb *RequestBody) {}, WithMaxBody(-1))
+
+	c := NewTestClient(r)
+	big := bytes.Repeat([]byte("a"), DefaultMaxBodySize+1)
+
+	if res := c.Post("/api/upload", big); res.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("default limit: status = %d, want 413", res.Status)
+	}
+	if res := c.Post("/api/upload", big[:DefaultMaxBodySize]); res.Status != http.StatusOK {
+		t.Errorf("body at the limit: status = %d, want 200", res.Status)
+	}
+	if res := c.Post("/api/unlimited", big); res.Status != http.StatusOK {
+		t.Errorf("disabled limit: status = %d, want 200", res.Status)
+	}
+
+	r.MaxBodySize = -1
+	if res := c.Post("/api/upload", big); res.Status != http.StatusOK {
+		t.Errorf("negative MaxBodySize: status = %d, want 200", res.Status)
+	}
+}
+
+func TestBodyPolicies(t *testing.T) {
+	r := NewRouter()
+	handled := 0
+	r.MapPost("/required", NoAuth, func(rb *RequestBody) { handled++ }, RequireBody())
+	r.MapPost("/forbidden", NoAuth, func(rb *RequestBody) { handled++ }, ForbidBody())
+
+	c := NewTestClient(r)
+	tests := []struct {
+		path string
+		body any
+		want int
+	}{
+		{"/api/required", nil, http.StatusBadRequest},
+		{"/api/required", `{"a":1}`, http.StatusOK},
+		{"/api/forbidden", `{"a":1}`, http.StatusBadRequest},
+		{"/api/forbidden", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		before := handled
+		res := c.Post(tt.path, tt.body)
+		if res.Status != tt.want {
+			t.Errorf("%s with body %v: status = %d, want %d", tt.path, tt.body, res.Status, tt.want)
+		}
+		if ran := handled > before; ran != (tt.want == http.StatusOK) {
+			t.Errorf("%s with body %v: handler ran = %v", tt.path, tt.body, ran)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package httpfly
+
+import (
+	"net/http"
+)
+
+// BodyHook transforms a request or response body, e.g. to decrypt or
+// encrypt fields, redact personal data or wrap the body in an envelope.
+type BodyHook func(body []byte) ([]byte, error)
+
+// OnRequestBody registers a hook that transforms request bodies of the
+// default router. See Router.OnRequestBody.
+func OnRequestBody(f BodyHook) {
+	defaultRouter.OnRequestBody(f)
+}
+
+// OnRequestBody registers a hook that transforms the body of matched
+// requests before the body policy and handlers see it. Hooks run in the
+// order they were added. A hook error is passed to the error handler;
+// return an *HTTPError to choose the status. Hooks must not modify body in
+// place, as RawBody keeps returning it.
+func (r *Router) OnRequestBody(f BodyHook) {
+	r.requestBodyHooks = append(r.requestBodyHooks, f)
+}
+
+// OnResponseBody registers a hook that transforms response bodies of the
+// default router. See Router.OnResponseBody.
+func OnResponseBody(f BodyHook) {
+	defaultRouter.OnResponseBody(f)
+}
+
+// OnResponseBody registers a hook that transforms response bodies before
+// they are sent. Hooks run in the order they were added. Registering one
+// buffers all responses as with BufferResponses. A hook error is logged
+// and answered with 500 instead of the response.
+func (r *Router) OnResponseBody(f BodyHook) {
+	r.responseBodyHooks = append(r.responseBodyHooks, f)
+}
+
+// transformRequestBody runs the request body hooks.
+func (r *Router) transformRequestBody(body []byte) ([]byte, error) {
+	return runBodyHooks(r.requestBodyHooks, body)
+}
+
+// flushResponse runs the response body hooks on a buffered response and
+// sends it.
+func (r *Router) flushResponse(buf *responseBuffer, w http.ResponseWriter, req *http.Request, production bool) {
+	status := buf.Status()
+	if len(r.responseBodyHooks) == 0 || status == http.StatusNoContent || status == http.StatusNotModified || req.Method == http.MethodHead {
+		buf.flushTo(w)
+		return
+	}
+
+	body, err := runBodyHooks(r.responseBodyHooks, buf.body.Bytes())
+	if err != nil {
+		internalError(r.logger, &ResponseRecorder{ResponseWriter: w}, req, err, nil, production, r.problems)
+		return
+	}
+
+	buf.body.Reset()
+	buf.body.Write(body)
+	buf.header.Del("Content-Length")
+	buf.flushTo(w)
+}
+
+func runBodyHooks(hooks []BodyHook, body []byte) ([]byte, error) {
+	for _, f := range hooks {
+		var err error
+		if body, err = f(body); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
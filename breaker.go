@@ -0,0 +1,182 @@
+package httpfly
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults of CircuitBreakerConfig.
+const (
+	DefaultBreakerFailureRatio = 0.5
+	DefaultBreakerMinRequests  = 10
+	DefaultBreakerWindow       = 10 * time.Second
+	DefaultBreakerOpenTimeout  = 30 * time.Second
+)
+
+// ErrCircuitOpen is the error answered while the circuit of a route is
+// open.
+var ErrCircuitOpen = &HTTPError{Status: http.StatusServiceUnavailable, Code: "circuit_open", Message: "service temporarily unavailable"}
+
+// CircuitBreakerConfig configures a circuit breaker. Zero fields take the
+// defaults above.
+type CircuitBreakerConfig struct {
+	// FailureRatio is the share of failed requests within Window that opens
+	// the circuit of a route, once it has seen MinRequests requests.
+	FailureRatio float64
+	MinRequests  int
+	Window       time.Duration
+	// OpenTimeout is how long a circuit stays open before a probe request
+	// is let through. A successful probe closes it, a failed one opens it
+	// again.
+	OpenTimeout time.Duration
+	// IsFailure decides whether a response status counts as a failure. Nil
+	// counts 5xx responses, including handler timeouts, and panics.
+	IsFailure func(status int) bool
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuit is the breaker state of a single route.
+type circuit struct {
+	state       breakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openUntil   time.Time
+	probing     bool
+}
+
+// UseCircuitBreaker adds a circuit breaker to every route of the default
+// router. See CircuitBreaker.
+func UseCircuitBreaker(cfg CircuitBreakerConfig) {
+	defaultRouter.UseCircuitBreaker(cfg)
+}
+
+// UseCircuitBreaker adds a circuit breaker to every route of the router.
+func (r *Router) UseCircuitBreaker(cfg CircuitBreakerConfig) {
+	r.Use(CircuitBreaker(cfg))
+}
+
+// CircuitBreaker returns chain middleware that tracks the failures of each
+// route and opens its circuit when they exceed the configured ratio. While
+// a circuit is open, requests to the route are answered with ErrCircuitOpen
+// and a Retry-After header without running the handler. After OpenTimeout a
+// single probe request is let through to decide whether the circuit closes.
+func CircuitBreaker(cfg CircuitBreakerConfig) ChainMiddleware {
+	if cfg.FailureRatio <= 0 {
+		cfg.FailureRatio = DefaultBreakerFailureRatio
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = DefaultBreakerMinRequests
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultBreakerWindow
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = DefaultBreakerOpenTimeout
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(status int) bool { return status >= 500 }
+	}
+
+	var mu sync.Mutex
+	circuits := map[string]*circuit{}
+
+	return func(rb *RequestBody, next Handler) {
+		key := string(rb.route.Method) + " " + rb.route.Endpoint
+
+		mu.Lock()
+		c := circuits[key]
+		if c == nil {
+			c = &circuit{}
+			circuits[key] = c
+		}
+		probe, wait := c.allow(time.Now())
+		mu.Unlock()
+
+		if wait > 0 {
+			rb.ResponseW.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			rb.Fail(ErrCircuitOpen)
+			return
+		}
+
+		failed := true
+		defer func() {
+			mu.Lock()
+			c.record(time.Now(), failed, probe, cfg)
+			mu.Unlock()
+		}()
+
+		next(rb)
+
+		status := rb.w.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		failed = cfg.IsFailure(status)
+	}
+}
+
+// allow reports whether a request may pass and whether it is the probe of a
+// half-open circuit. A rejected request gets the time until the next probe.
+func (c *circuit) allow(now time.Time) (probe bool, wait time.Duration) {
+	switch c.state {
+	case breakerOpen:
+		if now.Before(c.openUntil) {
+			return false, c.openUntil.Sub(now)
+		}
+		c.state = breakerHalfOpen
+		fallthrough
+	case breakerHalfOpen:
+		if c.probing {
+			return false, time.Second
+		}
+		c.probing = true
+		return true, 0
+	}
+	return false, 0
+}
+
+// record accounts the outcome of a request.
+func (c *circuit) record(now time.Time, failed, probe bool, cfg CircuitBreakerConfig) {
+	if probe {
+		c.probing = false
+		if failed {
+			c.open(now, cfg)
+		} else {
+			*c = circuit{}
+		}
+		return
+	}
+
+	if c.state != breakerClosed {
+		return
+	}
+
+	if now.Sub(c.windowStart) > cfg.Window {
+		c.windowStart, c.requests, c.failures = now, 0, 0
+	}
+
+	c.requests++
+	if failed {
+		c.failures++
+	}
+
+	if c.requests >= cfg.MinRequests && float64(c.failures)/float64(c.requests) >= cfg.FailureRatio {
+		c.open(now, cfg)
+	}
+}
+
+func (c *circuit) open(now time.Time, cfg CircuitBreakerConfig) {
+	c.state = breakerOpen
+	c.openUntil = now.Add(cfg.OpenTimeout)
+}
@@ -0,0 +1,73 @@
+package httpfly
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// responseBuffer is an http.ResponseWriter that keeps the status, headers
+// and body in memory until they are flushed to a real writer.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: http.Header{}}
+}
+
+// Header returns the buffered header map.
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+// Write appends data to the buffered body.
+func (b *responseBuffer) Write(data []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(data)
+}
+
+// WriteHeader records the status code. Unlike a real writer, later calls
+// overwrite earlier ones.
+func (b *responseBuffer) WriteHeader(status int) {
+	b.status = status
+}
+
+// reset discards the buffered status, headers and body.
+func (b *responseBuffer) reset() {
+	b.header = http.Header{}
+	b.status = 0
+	b.body.Reset()
+}
+
+// Status returns the buffered status code, defaulting to 200.
+func (b *responseBuffer) Status() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
+
+// flushTo writes the buffered response to w. Content-Length is set from the
+// buffered body unless the handler already set it or asked for chunked
+// transfer encoding.
+func (b *responseBuffer) flushTo(w http.ResponseWriter) error {
+	dst := w.Header()
+	for k, v := range b.header {
+		dst[k] = v
+	}
+
+	if dst.Get("Content-Length") == "" && !strings.EqualFold(dst.Get("Transfer-Encoding"), "chunked") {
+		dst.Set("Content-Length", strconv.Itoa(b.body.Len()))
+	}
+
+	w.WriteHeader(b.Status())
+
+	_, err := w.Write(b.body.Bytes())
+	return err
+}
@@ -0,0 +1,49 @@
+package httpfly
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestBufferedStatusWins(t *testing.T) {
+	r := NewRouter()
+	r.BufferResponses = true
+	r.MapGet("/late", NoAuth, func(rb *RequestBody) {
+		rb.ResponseW.Write([]byte(`{"partial":true}`))
+		rb.ResponseW.WriteHeader(http.StatusInternalServerError)
+	})
+
+	res := NewTestClient(r).Get("/api/late")
+	if res.Status != http.StatusInternalServerError {
+		t.Errorf("status = %d, want the late 500", res.Status)
+	}
+	if res.String() != `{"partial":true}` {
+		t.Errorf("body = %q", res.String())
+	}
+}
+
+func TestBufferedContentLength(t *testing.T) {
+	const body = "hello, buffered world"
+
+	r := NewRouter()
+	r.BufferResponses = true
+	r.MapGet("/hello", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, body) })
+
+	c := NewTestClient(r)
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		res := c.Do(method, "/api/hello", nil)
+
+		if got := res.Header.Get("Content-Length"); got != strconv.Itoa(len(body)) {
+			t.Errorf("%s: Content-Length = %q, want %d", method, got, len(body))
+		}
+
+		want := body
+		if method == http.MethodHead {
+			want = ""
+		}
+		if res.String() != want {
+			t.Errorf("%s: body = %q, want %q", method, res.String(), want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package httpfly
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// ErrOverloaded is the error answered to requests rejected by a concurrency
+// limit.
+var ErrOverloaded = &HTTPError{Status: http.StatusServiceUnavailable, Code: "overloaded", Message: "too many concurrent requests"}
+
+// bulkhead caps the requests in flight of one or more routes.
+type bulkhead struct {
+	slots  chan struct{}
+	queue  int64
+	queued atomic.Int64
+}
+
+func newBulkhead(n, queue int) *bulkhead {
+	if n <= 0 {
+		panic("httpfly: concurrency limit must be positive")
+	}
+	return &bulkhead{slots: make(chan struct{}, n), queue: int64(max(queue, 0))}
+}
+
+// WithMaxConcurrent limits the route to n requests in flight. Up to queue
+// more requests wait for a free slot until their context ends; requests
+// beyond that are answered with ErrOverloaded. Routes mapped with the same
+// option value share the limit. It panics if n is not positive.
+func WithMaxConcurrent(n, queue int) RouteOption {
+	b := newBulkhead(n, queue)
+	return func(ri *RouteInfo) {
+		ri.bulkhead = b
+	}
+}
+
+// GroupMaxConcurrent limits the routes of the group together to n requests
+// in flight. See WithMaxConcurrent.
+func GroupMaxConcurrent(n, queue int) GroupOption {
+	b := newBulkhead(n, queue)
+	return func(g *RouteGroup) {
+		g.bulkhead = b
+	}
+}
+
+// acquire takes a slot, waiting in the queue if there is room. It reports
+// whether a slot was taken; the caller must release it.
+func (b *bulkhead) acquire(ctx context.Context) bool {
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if b.queued.Add(1) > b.queue {
+		b.queued.Add(-1)
+		return false
+	}
+	defer b.queued.Add(-1)
+
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.slots
+}
@@ -0,0 +1,301 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long cached responses are kept when CacheConfig
+// sets no TTL.
+const DefaultCacheTTL = time.Minute
+
+// CacheStore keeps cached responses.
+type CacheStore interface {
+	// Get returns the value stored under key, if it has not expired.
+	Get(key string) ([]byte, bool, error)
+	// Set stores value under key for ttl.
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// CacheConfig configures response caching.
+type CacheConfig struct {
+	// TTL is how long responses are cached unless the route or a max-age in
+	// the response says otherwise. Defaults to DefaultCacheTTL.
+	TTL time.Duration
+	// KeyFunc derives the cache key of a request. The default key is the
+	// host, request URI and Accept header.
+	KeyFunc func(rb *RequestBody) string
+	// Store keeps the responses. Defaults to a MemoryCacheStore.
+	Store CacheStore
+	// OptIn caches only routes registered with WithCache.
+	OptIn bool
+}
+
+// WithCache caches the responses of a route for ttl, including routes that
+// require authentication or are not cached otherwise because of OptIn.
+func WithCache(ttl time.Duration) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.cacheTTL = ttl
+	}
+}
+
+// NoCache excludes a route from response caching.
+func NoCache() RouteOption {
+	return func(ri *RouteInfo) {
+		ri.cacheTTL = -1
+	}
+}
+
+// UseCache caches GET responses of the default router. See Cache.
+func UseCache(cfg CacheConfig) {
+	defaultRouter.UseCache(cfg)
+}
+
+// UseCache caches GET responses of the router.
+func (r *Router) UseCache(cfg CacheConfig) {
+	r.Use(Cache(cfg))
+}
+
+// Cache returns chain middleware that caches full 200 responses of GET
+// routes and replays them, marked X-Cache: HIT, until they expire. Routes
+// that require authentication are only cached when registered with
+// WithCache. Requests with Cache-Control no-cache skip the lookup and
+// no-store bypasses the cache; responses are not cached when they set
+// cookies, vary on everything, or carry Cache-Control no-store, no-cache or
+// private, and a max-age in the response overrides the TTL.
+func Cache(cfg CacheConfig) ChainMiddleware {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultCacheTTL
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = defaultCacheKey
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryCacheStore()
+	}
+
+	return func(rb *RequestBody, next Handler) {
+		ttl, ok := cfg.routeTTL(rb.route)
+		directives := cacheDirectives(rb.req.Header)
+		if !ok || rb.req.Method != http.MethodGet || directives.has("no-store") {
+			next(rb)
+			return
+		}
+
+		key := cfg.KeyFunc(rb)
+
+		if !directives.has("no-cache") {
+			if data, found, err := cfg.Store.Get(key); err == nil && found {
+				var c cachedResponse
+				if json.Unmarshal(data, &c) == nil {
+					c.writeTo(rb.ResponseW)
+					return
+				}
+			}
+		}
+
+		dst := rb.ResponseW
+		buf := newResponseBuffer()
+		rb.ResponseW = buf
+
+		next(rb)
+
+		rb.ResponseW = dst
+
+		if ttl, ok := responseTTL(buf, ttl); ok {
+			c := cachedResponse{Status: buf.Status(), Header: buf.header.Clone(), Body: buf.body.Bytes(), Stored: time.Now()}
+			if data, err := json.Marshal(c); err == nil {
+				cfg.Store.Set(key, data, ttl)
+			}
+		}
+
+		buf.header.Set("X-Cache", "MISS")
+		buf.flushTo(dst)
+	}
+}
+
+// routeTTL returns how long responses of route are cached, or false if the
+// route is not cached.
+func (cfg CacheConfig) routeTTL(route *RouteInfo) (time.Duration, bool) {
+	switch {
+	case route == nil || route.cacheTTL < 0:
+		return 0, false
+	case route.cacheTTL > 0:
+		return route.cacheTTL, true
+	case cfg.OptIn || route.AuthRequired:
+		return 0, false
+	}
+	return cfg.TTL, true
+}
+
+// defaultCacheKey keys a request by host, URI and Accept header.
+func defaultCacheKey(rb *RequestBody) string {
+	return rb.req.Host + rb.req.URL.RequestURI() + "\x00" + rb.req.Header.Get("Accept")
+}
+
+// responseTTL returns how long a buffered response may be cached, or false
+// if it may not be.
+func responseTTL(buf *responseBuffer, ttl time.Duration) (time.Duration, bool) {
+	if buf.Status() != http.StatusOK || buf.header.Get("Set-Cookie") != "" || buf.header.Get("Vary") == "*" {
+		return 0, false
+	}
+
+	directives := cacheDirectives(buf.header)
+	if directives.has("no-store") || directives.has("no-cache") || directives.has("private") {
+		return 0, false
+	}
+
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[name]; ok {
+			secs, err := strconv.Atoi(v)
+			if err != nil || secs <= 0 {
+				return 0, false
+			}
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	return ttl, true
+}
+
+// cacheControl holds the directives of Cache-Control headers.
+type cacheControl map[string]string
+
+func (c cacheControl) has(name string) bool {
+	_, ok := c[name]
+	return ok
+}
+
+// cacheDirectives parses the Cache-Control headers of h.
+func cacheDirectives(h http.Header) cacheControl {
+	c := cacheControl{}
+	for _, line := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if name != "" {
+				c[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return c
+}
+
+// cachedResponse is a stored response.
+type cachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	Stored time.Time   `json:"stored"`
+}
+
+// writeTo replays the response with an Age header.
+func (c cachedResponse) writeTo(w http.ResponseWriter) {
+	h := w.Header()
+	h.Set("Age", strconv.Itoa(int(time.Since(c.Stored).Seconds())))
+	h.Set("X-Cache", "HIT")
+	c.replay(w)
+}
+
+// replay writes the stored status, headers and body.
+func (c cachedResponse) replay(w http.ResponseWriter) {
+	h := w.Header()
+	for k, v := range c.Header {
+		h[k] = v
+	}
+	h.Set("Content-Length", strconv.Itoa(len(c.Body)))
+
+	w.WriteHeader(c.Status)
+	w.Write(c.Body)
+}
+
+// MemoryCacheStore is an in-process CacheStore.
+type MemoryCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	sets    int
+}
+
+type memoryCacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCacheStore creates an empty in-process store.
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{entries: map[string]memoryCacheEntry{}}
+}
+
+// Get implements CacheStore.
+func (s *MemoryCacheStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(e.expires) {
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+// Set implements CacheStore. Expired entries are swept every 1024 sets.
+func (s *MemoryCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.entries[key] = memoryCacheEntry{value: value, expires: now.Add(ttl)}
+
+	s.sets++
+	if s.sets%1024 == 0 {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return nil
+}
+
+// RedisCacheStore keeps cached responses in Redis, so they are shared
+// between instances.
+type RedisCacheStore struct {
+	RedisConfig
+	// Prefix is prepended to cache keys; default "cache:".
+	Prefix string
+
+	pool redisPool
+}
+
+// NewRedisCacheStore creates a store for the Redis server at addr.
+func NewRedisCacheStore(addr string) *RedisCacheStore {
+	return &RedisCacheStore{RedisConfig: RedisConfig{Addr: addr}}
+}
+
+// Get implements CacheStore.
+func (s *RedisCacheStore) Get(key string) ([]byte, bool, error) {
+	reply, err := s.pool.do(s.RedisConfig, "GET", s.key(key))
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	return []byte(reply.(string)), true, nil
+}
+
+// Set implements CacheStore.
+func (s *RedisCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := s.pool.do(s.RedisConfig, "SET", s.key(key), string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+func (s *RedisCacheStore) key(key string) string {
+	if s.Prefix == "" {
+		return "cache:" + key
+	}
+	return s.Prefix + key
+}
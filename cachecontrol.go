@@ -0,0 +1,75 @@
+package httpfly
+
+import "net/http"
+
+// WithCacheControl sets the Cache-Control header of successful responses of
+// a route, e.g. "public, max-age=300", unless the handler sets one itself.
+// Responses with other statuses keep whatever the handler sets. Response
+// caching with Cache honors it like any Cache-Control from the handler.
+func WithCacheControl(value string) RouteOption {
+	return func(ri *RouteInfo) {
+		next := ri.HandlerF
+
+		ri.HandlerF = func(rb *RequestBody) {
+			dst := rb.ResponseW
+			cw := &cacheControlWriter{ResponseWriter: dst, value: value}
+			rb.ResponseW = cw
+
+			defer func() { rb.ResponseW = dst }()
+			next(rb)
+
+			// A handler that writes nothing answers with an empty 200.
+			if !cw.written && (rb.w == nil || !rb.w.written()) && dst.Header().Get("Cache-Control") == "" {
+				dst.Header().Set("Cache-Control", value)
+			}
+		}
+	}
+}
+
+// NoStore marks successful responses of a route with Cache-Control
+// no-store, so neither browsers nor shared caches keep them.
+func NoStore() RouteOption {
+	return WithCacheControl("no-store")
+}
+
+// cacheControlWriter sets Cache-Control when a successful status is
+// written.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	value   string
+	written bool
+}
+
+// WriteHeader sets Cache-Control for 2xx and 304 responses.
+func (w *cacheControlWriter) WriteHeader(status int) {
+	if !w.written {
+		w.written = true
+
+		h := w.Header()
+		success := status >= 200 && status < 300 || status == http.StatusNotModified
+		if success && h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", w.value)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write sends the implicit 200 first.
+func (w *cacheControlWriter) Write(data []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush implements http.Flusher when the underlying writer does.
+func (w *cacheControlWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,146 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Claims are the claims of an authenticated subject. Values keep the type
+// given by the provider; JWT claims are strings, json.Number, bool,
+// []any or map[string]any.
+type Claims map[string]any
+
+// ClaimsProvider is implemented by auth providers that return typed claims.
+// The router prefers it over Authenticate.
+type ClaimsProvider interface {
+	AuthenticateClaims(req *http.Request) (Claims, error)
+}
+
+// String returns the claim as a string. Values that are not strings are
+// converted to their JSON text, so numbers keep their literal form.
+func (c Claims) String(key string) string {
+	switch v := c[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// Strings returns the claims converted with String, the representation
+// used by providers returning map[string]string and by Authorizer.
+func (c Claims) Strings() map[string]string {
+	if c == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(c))
+	for k := range c {
+		out[k] = c.String(k)
+	}
+	return out
+}
+
+// Int64 returns a numeric claim. It reports false if the claim is missing
+// or not an integer.
+func (c Claims) Int64(key string) (int64, bool) {
+	switch v := c[key].(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, true
+		}
+		f, err := v.Float64()
+		return int64(f), err == nil && f == math.Trunc(f)
+	case float64:
+		return int64(v), v == math.Trunc(v)
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Time returns a time claim, given as seconds since the epoch like "exp"
+// and "iat", or as an RFC 3339 string. It reports false if the claim is
+// missing or not a time.
+func (c Claims) Time(key string) (time.Time, bool) {
+	switch v := c[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, true
+		}
+	}
+
+	var secs float64
+	switch v := c[key].(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		secs = f
+	case float64:
+		secs = v
+	case int:
+		secs = float64(v)
+	case int64:
+		secs = float64(v)
+	default:
+		return time.Time{}, false
+	}
+
+	sec, frac := math.Modf(secs)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+// Claim returns the claim called key, or nil.
+func (r *RequestBody) Claim(key string) any {
+	return r.Claims[key]
+}
+
+// ClaimString returns the claim called key as a string. See Claims.String.
+func (r *RequestBody) ClaimString(key string) string {
+	return r.Claims.String(key)
+}
+
+// ClaimInt64 returns the integer claim called key. See Claims.Int64.
+func (r *RequestBody) ClaimInt64(key string) (int64, bool) {
+	return r.Claims.Int64(key)
+}
+
+// ClaimTime returns the time claim called key. See Claims.Time.
+func (r *RequestBody) ClaimTime(key string) (time.Time, bool) {
+	return r.Claims.Time(key)
+}
+
+// authenticateClaims runs p, preferring typed claims.
+func authenticateClaims(p AuthProvider, req *http.Request) (Claims, error) {
+	if cp, ok := p.(ClaimsProvider); ok {
+		return cp.AuthenticateClaims(req)
+	}
+
+	m, err := p.Authenticate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(Claims, len(m))
+	for k, v := range m {
+		claims[k] = v
+	}
+	return claims, nil
+}
@@ -0,0 +1,271 @@
+// Package client is an HTTP client for calls between services served by
+// httpfly. It retries failed calls with backoff, propagates the request ID
+// and trace context of the calling request, and encodes and decodes JSON.
+//
+//	c := client.New("http://users.internal")
+//
+//	func getUser(r *httpfly.RequestBody) {
+//		var u User
+//		if err := c.GetJSON(r.Context(), "/users/"+id, &u); err != nil {
+//			...
+//		}
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/burakturkerdev/httpfly"
+)
+
+// Defaults of New.
+const (
+	DefaultTimeout    = 30 * time.Second
+	DefaultMaxRetries = 2
+	DefaultBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// maxErrorBody bounds the response body kept in a StatusError.
+const maxErrorBody = 64 << 10
+
+// Client wraps an http.Client. The zero value is not usable; create clients
+// with New.
+type Client struct {
+	// HTTP sends the requests. Its Timeout bounds every attempt.
+	HTTP *http.Client
+	// BaseURL is prepended to the paths given to the JSON helpers.
+	BaseURL string
+	// Header is added to every request.
+	Header http.Header
+
+	// MaxRetries is how often a failed call is retried. Only requests with
+	// idempotent methods, or a replayable body and an Idempotency-Key
+	// header, are retried.
+	MaxRetries int
+	// Backoff is the delay before the first retry; it doubles with every
+	// retry up to MaxBackoff, with jitter.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retry decides whether a call is retried. Nil retries network errors,
+	// 429 Too Many Requests and 502, 503 and 504.
+	Retry func(resp *http.Response, err error) bool
+
+	// Logger, when set, logs retries.
+	Logger httpfly.Logger
+}
+
+// New creates a client for the service at baseURL with the default timeout
+// and retry policy.
+func New(baseURL string) *Client {
+	return &Client{
+		HTTP:       &http.Client{Timeout: DefaultTimeout},
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Header:     http.Header{},
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// StatusError is returned by the JSON helpers for responses outside the
+// 2xx range.
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	body := bytes.TrimSpace(e.Body)
+	if len(body) == 0 {
+		return fmt.Sprintf("client: unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("client: unexpected status %d: %s", e.StatusCode, body)
+}
+
+// Do sends req, retrying it according to the retry policy. The request ID
+// and trace context of req.Context(), as set by httpfly.UseRequestID and
+// httpfly.UseTracing, are sent along.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for k, v := range c.Header {
+		if req.Header.Get(k) == "" {
+			req.Header[k] = v
+		}
+	}
+	if id := httpfly.RequestIDFromContext(ctx); id != "" && req.Header.Get(httpfly.RequestIDHeader) == "" {
+		req.Header.Set(httpfly.RequestIDHeader, id)
+	}
+	httpfly.InjectTraceContext(ctx, req.Header)
+
+	retries := c.MaxRetries
+	if !c.retryable(req) {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if attempt >= retries || ctx.Err() != nil || !c.shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := c.backoff(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+		}
+
+		if c.Logger != nil {
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			c.Logger.Info("retrying request", "method", req.Method, "url", req.URL.Redacted(),
+				"attempt", attempt+1, "status", status, "error", errString(err), "delay", delay)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// retryable reports whether req may be sent more than once.
+func (c *Client) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// shouldRetry applies the retry policy to the outcome of an attempt.
+func (c *Client) shouldRetry(resp *http.Response, err error) bool {
+	if c.Retry != nil {
+		return c.Retry(resp, err)
+	}
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before retry attempt+1, honoring Retry-After.
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	max := c.MaxBackoff
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, max)
+		}
+	}
+
+	d := c.Backoff
+	if d <= 0 {
+		d = DefaultBackoff
+	}
+	d <<= attempt
+	if d <= 0 || d > max {
+		d = max
+	}
+
+	// Jitter over the upper half spreads the retries of many callers.
+	return d/2 + rand.N(d/2+1)
+}
+
+// GetJSON sends a GET request to path and decodes the JSON response into
+// out.
+func (c *Client) GetJSON(ctx context.Context, path string, out any) error {
+	return c.DoJSON(ctx, http.MethodGet, path, nil, out)
+}
+
+// PostJSON sends in as JSON to path and decodes the JSON response into out.
+func (c *Client) PostJSON(ctx context.Context, path string, in, out any) error {
+	return c.DoJSON(ctx, http.MethodPost, path, in, out)
+}
+
+// DoJSON sends a request with in encoded as JSON, unless in is nil, and
+// decodes the JSON response into out, unless out is nil or the response has
+// no content. Responses outside the 2xx range are returned as *StatusError.
+func (c *Client) DoJSON(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &StatusError{StatusCode: resp.StatusCode, Body: data}
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/burakturkerdev/httpfly"
+)
+
+// flakyServer answers the first failures requests with status and every
+// later one with {"ok":true}.
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if calls.Add(1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetriesIdempotentCalls(t *testing.T) {
+	srv, calls := flakyServer(t, 2, http.StatusServiceUnavailable)
+
+	c := New(srv.URL)
+	c.Backoff = time.Millisecond
+
+	var out struct{ OK bool }
+	if err := c.GetJSON(context.Background(), "/", &out); err != nil || !out.OK {
+		t.Fatalf("GetJSON = %v, %+v; want success after retries", err, out)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("server saw %d calls, want 3", n)
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	srv, calls := flakyServer(t, 10, http.StatusBadGateway)
+
+	c := New(srv.URL)
+	c.Backoff = time.Millisecond
+
+	var se *StatusError
+	if err := c.GetJSON(context.Background(), "/", nil); !errors.As(err, &se) || se.StatusCode != http.StatusBadGateway {
+		t.Fatalf("GetJSON = %v, want a 502 StatusError", err)
+	}
+	if n := calls.Load(); n != int32(DefaultMaxRetries+1) {
+		t.Errorf("server saw %d calls, want %d", n, DefaultMaxRetries+1)
+	}
+}
+
+func TestPostRetriedOnlyWithIdempotencyKey(t *testing.T) {
+	srv, calls := flakyServer(t, 1, http.StatusServiceUnavailable)
+
+	c := New(srv.URL)
+	c.Backoff = time.Millisecond
+
+	if err := c.PostJSON(context.Background(), "/", map[string]int{"n": 1}, nil); err == nil {
+		t.Error("POST without Idempotency-Key was retried")
+	}
+
+	calls.Store(0)
+	c.Header.Set("Idempotency-Key", "k1")
+	if err := c.PostJSON(context.Background(), "/", map[string]int{"n": 1}, nil); err != nil {
+		t.Errorf("POST with Idempotency-Key = %v, want success after a retry", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server saw %d calls, want 2", n)
+	}
+}
+
+func TestStatusErrorKeepsBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "no such user", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	err := New(srv.URL).GetJSON(context.Background(), "/users/1", nil)
+
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound || !strings.Contains(err.Error(), "no such user") {
+		t.Errorf("err = %v, want a 404 StatusError with the body", err)
+	}
+}
+
+func TestPropagatesRequestID(t *testing.T) {
+	var got atomic.Value
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got.Store(req.Header.Get(httpfly.RequestIDHeader))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	c := New(upstream.URL)
+
+	r := httpfly.NewRouter()
+	r.UseRequestID()
+	r.MapGet("/proxy", httpfly.NoAuth, func(rb *httpfly.RequestBody) {
+		if err := c.GetJSON(rb.Context(), "/", nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/proxy", nil)
+	req.Header.Set(httpfly.RequestIDHeader, "req-42")
+	httpfly.NewTestClient(r).Send(req)
+
+	if id, _ := got.Load().(string); id != "req-42" {
+		t.Errorf("upstream saw request ID %q, want req-42", id)
+	}
+}
+
+func TestCanceledContextStopsRetrying(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.Backoff = time.Hour
+	c.MaxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := c.GetJSON(ctx, "/", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetJSON = %v, want the context error", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("server saw %d calls, want 1", n)
+	}
+}
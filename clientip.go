@@ -0,0 +1,159 @@
+package httpfly
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// SetTrustedProxies sets the proxies whose forwarding headers the default
+// router believes. See Router.SetTrustedProxies.
+func SetTrustedProxies(cidrs []string) {
+	defaultRouter.SetTrustedProxies(cidrs)
+}
+
+// SetTrustedProxies sets the proxies, as CIDRs or single addresses, whose
+// forwarding header the router believes when resolving ClientIP. It panics
+// on a malformed entry.
+func (r *Router) SetTrustedProxies(cidrs []string) {
+	r.proxies.prefixes = mustParsePrefixes(cidrs)
+}
+
+// SetClientIPHeader sets the forwarding header of the default router's
+// trusted proxies.
+func SetClientIPHeader(name string) {
+	defaultRouter.SetClientIPHeader(name)
+}
+
+// SetClientIPHeader sets the forwarding header set by the trusted proxies:
+// "X-Forwarded-For" (the default) or "X-Real-IP".
+func (r *Router) SetClientIPHeader(name string) {
+	r.proxies.header = name
+}
+
+// ClientIP returns the address of the client. Behind trusted proxies it is
+// taken from their forwarding header, otherwise it is RemoteIP.
+func (r *RequestBody) ClientIP() string {
+	if r.req == nil {
+		return ""
+	}
+	if r.router == nil {
+		return r.RemoteIP()
+	}
+	return r.router.clientIP(r.req)
+}
+
+// isHTTPS reports whether req reached the client side over TLS, directly or
+// through a trusted proxy that sets X-Forwarded-Proto.
+func (r *Router) isHTTPS(req *http.Request) bool {
+	if req.TLS != nil {
+		return true
+	}
+
+	peer := parseAddr(req.RemoteAddr)
+	return containsAddr(r.proxies.prefixes, peer) && strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// clientIP resolves the client address of req with the router's trusted
+// proxies.
+func (r *Router) clientIP(req *http.Request) string {
+	if ip := r.proxies.clientIP(req); ip.IsValid() {
+		return ip.String()
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// trustedProxies resolves client addresses behind trusted proxies.
+type trustedProxies struct {
+	prefixes []netip.Prefix
+	header   string
+}
+
+// clientIP returns the client address of req. The forwarding header is only
+// consulted when the peer is a trusted proxy; X-Forwarded-For is read from
+// the right, skipping trusted proxies, so clients cannot spoof entries. The
+// zero Addr is returned if no valid address is found.
+func (p trustedProxies) clientIP(req *http.Request) netip.Addr {
+	peer := parseAddr(req.RemoteAddr)
+	if !peer.IsValid() || !containsAddr(p.prefixes, peer) {
+		return peer
+	}
+
+	if strings.EqualFold(p.header, "X-Real-IP") {
+		if ip := parseAddr(strings.TrimSpace(req.Header.Get("X-Real-IP"))); ip.IsValid() {
+			return ip
+		}
+		return peer
+	}
+
+	var hops []string
+	for _, line := range req.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(line, ",")...)
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseAddr(strings.TrimSpace(hops[i]))
+		if !ip.IsValid() {
+			break
+		}
+		client = ip
+		if !containsAddr(p.prefixes, ip) {
+			break
+		}
+	}
+	return client
+}
+
+// parseAddr parses an address with or without a port.
+func parseAddr(s string) netip.Addr {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap()
+	}
+	if ip, err := netip.ParseAddr(s); err == nil {
+		return ip.Unmap()
+	}
+	return netip.Addr{}
+}
+
+// mustParsePrefixes parses CIDRs and single addresses, panicking on
+// malformed entries.
+func mustParsePrefixes(list []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+
+		if p, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+
+		ip, err := netip.ParseAddr(s)
+		if err != nil {
+			panic(fmt.Sprintf("httpfly: invalid IP or CIDR %q", s))
+		}
+		ip = ip.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return prefixes
+}
+
+// containsAddr reports whether ip is inside one of prefixes.
+func containsAddr(prefixes []netip.Prefix, ip netip.Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
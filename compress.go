@@ -0,0 +1,280 @@
+package httpfly
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultCompressMinSize is the default size below which responses are sent
+// uncompressed.
+const DefaultCompressMinSize = 1024
+
+// CompressEncoder returns a writer that compresses what is written to it
+// into w. The writer is closed at the end of the response and flushed with
+// it when it has a Flush() error method.
+type CompressEncoder func(w io.Writer) io.WriteCloser
+
+// CompressConfig configures response compression.
+type CompressConfig struct {
+	// MinSize is the smallest body, in bytes, that gets compressed. Zero
+	// means DefaultCompressMinSize.
+	MinSize int
+	// Level is the gzip level. Zero means gzip.DefaultCompression.
+	Level int
+	// Encoders adds content codings by Accept-Encoding token. The standard
+	// library has no brotli encoder, so brotli is enabled by registering
+	// one, e.g. from github.com/andybalholm/brotli:
+	//
+	//	Encoders: map[string]httpfly.CompressEncoder{
+	//		"br": func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+	//	}
+	//
+	// Added codings are preferred over gzip when the client accepts them
+	// equally.
+	Encoders map[string]CompressEncoder
+}
+
+// UseCompression compresses responses of the default router for clients
+// that accept it. See Compress.
+func UseCompression(cfg CompressConfig) {
+	defaultRouter.UseCompression(cfg)
+}
+
+// UseCompression compresses responses of the router for clients that
+// accept it.
+func (r *Router) UseCompression(cfg CompressConfig) {
+	r.Use(Compress(cfg))
+}
+
+// Compress returns chain middleware that compresses responses with gzip,
+// or a coding of cfg.Encoders, picked by the Accept-Encoding of the client.
+// Everything written after it runs is compressed, including the output of
+// plain middleware and error responses. Bodies smaller than cfg.MinSize,
+// responses that already have a Content-Encoding and content types that
+// are compressed already, such as images and archives, are sent as is.
+func Compress(cfg CompressConfig) ChainMiddleware {
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = DefaultCompressMinSize
+	}
+	if cfg.Level == 0 {
+		cfg.Level = gzip.DefaultCompression
+	}
+
+	encoders := map[string]CompressEncoder{
+		"gzip": func(w io.Writer) io.WriteCloser {
+			gz, _ := gzip.NewWriterLevel(w, cfg.Level)
+			return gz
+		},
+	}
+	var codings []string
+	for name, enc := range cfg.Encoders {
+		name = strings.ToLower(name)
+		encoders[name] = enc
+		if name != "gzip" {
+			codings = append(codings, name)
+		}
+	}
+	slices.Sort(codings)
+	codings = append(codings, "gzip")
+
+	return func(rb *RequestBody, next Handler) {
+		rb.ResponseW.Header().Add("Vary", "Accept-Encoding")
+
+		coding := negotiateEncoding(rb.Header("Accept-Encoding"), codings)
+		if coding == "" || rb.req.Method == http.MethodHead {
+			next(rb)
+			return
+		}
+
+		// The compressor sits below the recorder, so middleware writing to
+		// the recorder is compressed and sees the response as written even
+		// while its start is held back.
+		rec := rb.w
+		cw := &compressWriter{ResponseWriter: rec.ResponseWriter, cfg: cfg, coding: coding, encoder: encoders[coding]}
+		rec.ResponseWriter = cw
+
+		completed := false
+		defer func() {
+			rec.ResponseWriter = cw.ResponseWriter
+
+			// A panic drops held-back output in favor of the error
+			// response.
+			if !completed && !cw.decided {
+				rec.status, rec.size = 0, 0
+			}
+		}()
+
+		next(rb)
+		completed = true
+		cw.close()
+	}
+}
+
+// negotiateEncoding returns the coding of codings, in order of preference,
+// that an Accept-Encoding header ranks highest, or "" if it accepts none.
+func negotiateEncoding(header string, codings []string) string {
+	quality := map[string]float64{}
+	wildcard := -1.0
+
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+
+		q := 1.0
+		k, v, _ := strings.Cut(strings.TrimSpace(params), "=")
+		if strings.EqualFold(k, "q") {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		if coding == "*" {
+			wildcard = q
+		} else if coding != "" {
+			quality[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, c := range codings {
+		q, ok := quality[c]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// the body is large enough to compress.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg     CompressConfig
+	coding  string
+	encoder CompressEncoder
+
+	status  int
+	pending []byte
+	decided bool
+	enc     io.WriteCloser
+}
+
+// WriteHeader defers the status until the encoding has been decided.
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write buffers data up to the size threshold, then streams it through gzip
+// or as is.
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		return w.write(data)
+	}
+
+	w.pending = append(w.pending, data...)
+	if len(w.pending) < w.cfg.MinSize {
+		return len(data), nil
+	}
+
+	if err := w.decide(true); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Flush sends what has been written so far.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.pending) >= w.cfg.MinSize)
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide picks the encoding, writes the status and the pending data.
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	h := w.Header()
+
+	if large && w.compressible() {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.coding)
+		w.enc = w.encoder(w.ResponseWriter)
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	pending := w.pending
+	w.pending = nil
+
+	if len(pending) == 0 {
+		return nil
+	}
+	_, err := w.write(pending)
+	return err
+}
+
+func (w *compressWriter) write(data []byte) (int, error) {
+	if w.enc != nil {
+		return w.enc.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// compressible reports whether the response may be compressed.
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+
+	ct := strings.ToLower(h.Get("Content-Type"))
+	if ct == "" {
+		ct = http.DetectContentType(w.pending)
+	}
+
+	for _, prefix := range []string{"image/", "video/", "audio/", "font/woff", "application/zip",
+		"application/gzip", "application/x-gzip", "application/x-bzip2", "application/x-7z", "application/zstd"} {
+		if strings.HasPrefix(ct, prefix) && ct != "image/svg+xml" {
+			return false
+		}
+	}
+	return true
+}
+
+// close flushes whatever is still held back and finishes the compressed
+// stream.
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
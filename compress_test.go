@@ -0,0 +1,126 @@
+package httpfly
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// decodeBody decompresses res according to its Content-Encoding.
+func decodeBody(t *testing.T, res *TestResponse) string {
+	t.Helper()
+
+	var r io.Reader = bytes.NewReader(res.Body)
+	switch res.Header.Get("Content-Encoding") {
+	case "gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case "deflate":
+		r = flate.NewReader(r)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	r := NewRouter()
+	r.UseCompression(CompressConfig{Encoders: map[string]CompressEncoder{
+		"deflate": func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		},
+	}})
+	big := strings.Repeat("a", 2*DefaultCompressMinSize)
+	r.MapGet("/big", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, big) })
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"gzip", "gzip"},
+		{"gzip, deflate", "deflate"},
+		{"deflate;q=0.5, gzip", "gzip"},
+		{"br", ""},
+		{"gzip;q=0", ""},
+		{"*", "deflate"},
+		{"*, deflate;q=0", "gzip"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		c := NewTestClient(r)
+		c.Header.Set("Accept-Encoding", tt.accept)
+		res := c.Get("/api/big")
+
+		if got := res.Header.Get("Content-Encoding"); got != tt.want {
+			t.Errorf("Accept-Encoding %q: coding = %q, want %q", tt.accept, got, tt.want)
+			continue
+		}
+		if body := decodeBody(t, res); body != big {
+			t.Errorf("Accept-Encoding %q: body of %d bytes, want %d", tt.accept, len(body), len(big))
+		}
+	}
+}
+
+func TestCompressCoversPlainMiddleware(t *testing.T) {
+	r := NewRouter()
+	r.UseCompression(CompressConfig{})
+	big := strings.Repeat("denied ", DefaultCompressMinSize)
+	r.AddMiddleware(func(rb *RequestBody, w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Has("deny") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(big))
+		}
+	})
+	r.AddMiddleware(func(rb *RequestBody, w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Has("short") {
+			w.Write([]byte("short"))
+		}
+	})
+
+	handled := false
+	r.MapGet("/x", NoAuth, func(rb *RequestBody) { handled = true })
+
+	c := NewTestClient(r)
+	c.Header.Set("Accept-Encoding", "gzip")
+
+	res := c.Get("/api/x?deny")
+	if res.Status != http.StatusForbidden || res.Header.Get("Content-Encoding") != "gzip" || decodeBody(t, res) != big {
+		t.Errorf("middleware response: status %d, coding %q", res.Status, res.Header.Get("Content-Encoding"))
+	}
+
+	// Held-back output still counts as written.
+	res = c.Get("/api/x?short")
+	if handled || res.String() != "short" || res.Header.Get("Content-Encoding") != "" {
+		t.Errorf("handled = %v, body %q, coding %q; want the short middleware body only", handled, res.String(), res.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestCompressPanicDropsHeldBackOutput(t *testing.T) {
+	r := NewRouter()
+	r.UseCompression(CompressConfig{})
+	r.MapGet("/boom", NoAuth, func(rb *RequestBody) {
+		rb.ResponseW.Write([]byte("partial"))
+		panic("boom")
+	})
+
+	c := NewTestClient(r)
+	c.Header.Set("Accept-Encoding", "gzip")
+	res := c.Get("/api/boom")
+
+	var body internalErrorBody
+	if err := res.JSON(&body); err != nil || res.Status != http.StatusInternalServerError || body.Code != "internal_error" {
+		t.Errorf("status %d, body %q, want the 500 error body", res.Status, res.String())
+	}
+}
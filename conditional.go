@@ -0,0 +1,56 @@
+package httpfly
+
+import (
+	"net/http"
+	"strings"
+)
+
+// IfMatch returns the raw If-Match header of the request, if present.
+func (r *RequestBody) IfMatch() (string, bool) {
+	if r.req == nil {
+		return "", false
+	}
+
+	v := r.req.Header.Get("If-Match")
+	return v, v != ""
+}
+
+// CheckIfMatch compares the If-Match header against the current ETag of the
+// resource. When the header is present and does not match, it responds with
+// 412 Precondition Failed and returns false; the handler should then stop.
+// Requests without If-Match always pass.
+func (r *RequestBody) CheckIfMatch(etag string) bool {
+	header, ok := r.IfMatch()
+	if !ok || etagListMatches(header, etag, false) {
+		return true
+	}
+
+	r.router.frameworkError(r.ResponseW, r.req, http.StatusPreconditionFailed, "")
+	return false
+}
+
+// etagListMatches reports whether etag appears in a comma separated list of
+// entity tags. "*" matches anything. With weak set, W/ prefixes are ignored;
+// otherwise weak tags never match.
+func etagListMatches(list string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(candidate, "W/") && candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package httpfly
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	version := `"v2"`
+	updated := 0
+
+	r := NewRouter()
+	r.MapPut("/doc", NoAuth, func(rb *RequestBody) {
+		if !rb.CheckIfMatch(version) {
+			return
+		}
+		updated++
+		rb.Text(http.StatusOK, "updated")
+	})
+
+	tests := []struct {
+		ifMatch string
+		want    int
+	}{
+		{`"v1"`, http.StatusPreconditionFailed},
+		{`W/"v2"`, http.StatusPreconditionFailed},
+		{`"v2"`, http.StatusOK},
+		{`"v1", "v2"`, http.StatusOK},
+		{`*`, http.StatusOK},
+		{``, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		c := NewTestClient(r)
+		if tt.ifMatch != "" {
+			c.Header.Set("If-Match", tt.ifMatch)
+		}
+
+		before := updated
+		res := c.Put("/api/doc", "{}")
+		if res.Status != tt.want {
+			t.Errorf("If-Match %s: status = %d, want %d", tt.ifMatch, res.Status, tt.want)
+		}
+		if ran := updated > before; ran != (tt.want == http.StatusOK) {
+			t.Errorf("If-Match %s: update ran = %v", tt.ifMatch, ran)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigEnvPrefix prefixes the environment variables read by LoadConfig.
+const ConfigEnvPrefix = "HTTPFLY_"
+
+// Config is the deployment configuration of a server, loaded with
+// LoadConfig. Every field can be overridden by an environment variable
+// named after its path in the file, e.g. HTTPFLY_ADDR,
+// HTTPFLY_TLS_CERT_FILE, HTTPFLY_TIMEOUTS_READ or
+// HTTPFLY_CORS_ALLOWED_ORIGINS (comma-separated).
+type Config struct {
+	// Addr is the TCP address to listen on, e.g. ":8080".
+	Addr        string      `json:"addr"`
+	TLS         TLSFiles    `json:"tls"`
+	RoutePrefix *string     `json:"route_prefix"`
+	Timeouts    Timeouts    `json:"timeouts"`
+	Connections Connections `json:"connections"`
+	CORS        CORSFile    `json:"cors"`
+	RateLimit   RateFile    `json:"rate_limit"`
+	Log         LogSettings `json:"log"`
+}
+
+// TLSFiles names the certificate and key of a TLS server.
+type TLSFiles struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// RedirectAddr, when set, is redirected to HTTPS, see
+	// Server.RedirectAddr.
+	RedirectAddr string `json:"redirect_addr"`
+}
+
+// Timeouts are the server and handler timeouts of a Config.
+type Timeouts struct {
+	Read       Duration `json:"read"`
+	ReadHeader Duration `json:"read_header"`
+	Write      Duration `json:"write"`
+	Idle       Duration `json:"idle"`
+	Shutdown   Duration `json:"shutdown"`
+	// Handler is the default route timeout, see UseTimeout.
+	Handler Duration `json:"handler"`
+}
+
+// Connections are the connection limits of a Config. See ServerConfig.
+type Connections struct {
+	Max               int  `json:"max"`
+	DisableKeepAlives bool `json:"disable_keep_alives"`
+}
+
+// CORSFile is the CORS section of a Config. CORS is enabled when
+// AllowedOrigins is not empty.
+type CORSFile struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           Duration `json:"max_age"`
+}
+
+// RateFile is the rate limit section of a Config. Rate limiting is enabled
+// when RPS is positive.
+type RateFile struct {
+	RPS   float64 `json:"rps"`
+	Burst int     `json:"burst"`
+}
+
+// LogSettings is the logging section of a Config.
+type LogSettings struct {
+	// Level is "info" (the default), "error" to drop access logs, or "off".
+	Level string `json:"level"`
+}
+
+// Duration is a time.Duration read from configuration as a string such as
+// "30s" or a number of seconds.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+		return nil
+	case string:
+		return d.set(v)
+	case nil:
+		*d = 0
+		return nil
+	}
+	return fmt.Errorf("httpfly: invalid duration %s", b)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) set(s string) error {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("httpfly: invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadConfig reads a JSON (.json) or YAML (.yaml, .yml) configuration file
+// and applies environment overrides. An empty path reads the environment
+// only. The YAML support covers nested mappings, scalars, comments and
+// lists written as "- item" or [a, b].
+//
+//	cfg, err := httpfly.LoadConfig("config.yaml")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	cfg.Apply(nil)
+//	// map routes ...
+//	log.Fatal(cfg.Server(nil).Start(ctx))
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			err = json.Unmarshal(data, cfg)
+		case ".yaml", ".yml":
+			err = unmarshalYAML(data, cfg)
+		default:
+			return nil, fmt.Errorf("httpfly: unsupported config format %q", filepath.Ext(path))
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("httpfly: %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), ConfigEnvPrefix); err != nil {
+		return nil, err
+	}
+
+	if cfg.CORS.AllowCredentials && slices.Contains(cfg.CORS.AllowedOrigins, "*") {
+		return nil, errors.New(`httpfly: cors.allow_credentials cannot be combined with the "*" origin`)
+	}
+	return cfg, nil
+}
+
+// Apply configures r with the route prefix, handler timeout, CORS, rate
+// limit and log level of the configuration. A nil r means the default
+// router. Call it before mapping routes, since the prefix is applied when
+// routes are registered.
+func (c *Config) Apply(r *Router) {
+	if r == nil {
+		r = defaultRouter
+	}
+
+	if c.RoutePrefix != nil {
+		if r == defaultRouter {
+			RoutePrefix = *c.RoutePrefix
+		} else {
+			r.Prefix = *c.RoutePrefix
+		}
+	}
+
+	if c.Timeouts.Handler > 0 {
+		r.UseTimeout(time.Duration(c.Timeouts.Handler))
+	}
+
+	if len(c.CORS.AllowedOrigins) > 0 {
+		r.UseCORS(CORSConfig{
+			AllowedOrigins:   c.CORS.AllowedOrigins,
+			AllowedMethods:   c.CORS.AllowedMethods,
+			AllowedHeaders:   c.CORS.AllowedHeaders,
+			ExposedHeaders:   c.CORS.ExposedHeaders,
+			AllowCredentials: c.CORS.AllowCredentials,
+			MaxAge:           time.Duration(c.CORS.MaxAge),
+		})
+	}
+
+	if c.RateLimit.RPS > 0 {
+		burst := c.RateLimit.Burst
+		if burst <= 0 {
+			burst = int(c.RateLimit.RPS) + 1
+		}
+		r.UseRateLimit(RateLimitConfig{RPS: c.RateLimit.RPS, Burst: burst})
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "off", "none":
+		r.SetLogger(nil)
+	case "error":
+		if r.logger != nil {
+			r.SetLogger(errorLogger{r.logger})
+		}
+	}
+}
+
+// Server returns a server for r with the address, TLS files, timeouts and
+// connection limits of the configuration. A nil r means the default router.
+func (c *Config) Server(r *Router) *Server {
+	s := NewServer(c.Addr, r)
+	s.CertFile, s.KeyFile = c.TLS.CertFile, c.TLS.KeyFile
+	s.RedirectAddr = c.TLS.RedirectAddr
+	s.ReadTimeout = time.Duration(c.Timeouts.Read)
+	s.ReadHeaderTimeout = time.Duration(c.Timeouts.ReadHeader)
+	s.WriteTimeout = time.Duration(c.Timeouts.Write)
+	s.IdleTimeout = time.Duration(c.Timeouts.Idle)
+	s.ShutdownTimeout = time.Duration(c.Timeouts.Shutdown)
+	s.MaxConnections = c.Connections.Max
+	s.DisableKeepAlives = c.Connections.DisableKeepAlives
+	return s
+}
+
+// errorLogger drops info logs.
+type errorLogger struct{ Logger }
+
+func (errorLogger) Info(string, ...any) {}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// applyEnv overrides the fields of v from environment variables named
+// prefix plus the upper-cased JSON path of each field.
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := prefix + strings.ToUpper(tag)
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, name+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := setEnvField(field, s); err != nil {
+			return fmt.Errorf("httpfly: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setEnvField parses s into a configuration field.
+func setEnvField(field reflect.Value, s string) error {
+	if field.Type() == durationType {
+		return field.Addr().Interface().(*Duration).set(s)
+	}
+
+	switch field.Kind() {
+	case reflect.Pointer:
+		p := reflect.New(field.Type().Elem())
+		if err := setEnvField(p.Elem(), s); err != nil {
+			return err
+		}
+		field.Set(p)
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package httpfly
+
+import "context"
+
+// ContextKey is a typed key for storing request-scoped values on a
+// RequestBody. Keys are compared by identity, so two keys created with the
+// same name never collide.
+type ContextKey[T any] struct {
+	id *contextKeyID
+}
+
+type contextKeyID struct {
+	name string
+}
+
+// NewContextKey creates a new typed key. The name is only used for
+// debugging.
+func NewContextKey[T any](name string) ContextKey[T] {
+	return ContextKey[T]{&contextKeyID{name}}
+}
+
+// String returns the name the key was created with.
+func (k ContextKey[T]) String() string {
+	return k.id.name
+}
+
+// Set stores value on the request under the key.
+func (k ContextKey[T]) Set(rb *RequestBody, value T) {
+	if rb.values == nil {
+		rb.values = map[any]any{}
+	}
+	rb.values[k.id] = value
+}
+
+// Get returns the value stored on the request under the key, if any.
+func (k ContextKey[T]) Get(rb *RequestBody) (T, bool) {
+	v, ok := rb.values[k.id].(T)
+	return v, ok
+}
+
+// Locals returns a scratch map scoped to the current request, created on
+// first use. Middleware can use it to pass values to the handler; it is
+// cleared once the request has been handled.
+func (r *RequestBody) Locals() map[string]any {
+	if r.locals == nil {
+		r.locals = map[string]any{}
+	}
+	return r.locals
+}
+
+// Context returns the context of the request. It is canceled when the client
+// goes away or the server shuts down.
+func (r *RequestBody) Context() context.Context {
+	return r.req.Context()
+}
+
+// WithValue attaches a value to the request context. Later middleware and
+// the handler see it through Context.
+func (r *RequestBody) WithValue(key, val any) {
+	r.req = r.req.WithContext(context.WithValue(r.req.Context(), key, val))
+}
+
+// onDone registers f to run when the request has been handled, before
+// panic recovery and the after-response hooks.
+func (r *RequestBody) onDone(f func()) {
+	r.done = append(r.done, f)
+}
+
+// runDone runs the functions registered with onDone in reverse order.
+func (r *RequestBody) runDone() {
+	for i := len(r.done) - 1; i >= 0; i-- {
+		r.done[i]()
+	}
+}
@@ -0,0 +1,64 @@
+package httpfly
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestContextKeysAreTypedAndDistinct(t *testing.T) {
+	userID := NewContextKey[int]("user")
+	userName := NewContextKey[string]("user")
+	other := NewContextKey[int]("user")
+
+	rb := &RequestBody{}
+	if _, ok := userID.Get(rb); ok {
+		t.Fatal("Get on an empty request found a value")
+	}
+
+	userID.Set(rb, 42)
+	userName.Set(rb, "alice")
+
+	if v, ok := userID.Get(rb); !ok || v != 42 {
+		t.Errorf("int key = %v, %v, want 42", v, ok)
+	}
+	if v, ok := userName.Get(rb); !ok || v != "alice" {
+		t.Errorf("string key = %q, %v, want alice", v, ok)
+	}
+	if _, ok := other.Get(rb); ok {
+		t.Error("a key with the same name and type read another key's value")
+	}
+	if userID.String() != "user" {
+		t.Errorf("String = %q", userID.String())
+	}
+}
+
+func TestLocalsPerRequest(t *testing.T) {
+	r := NewRouter()
+	r.AddMiddleware(func(rb *RequestBody, w http.ResponseWriter, req *http.Request) {
+		if _, ok := rb.Locals()["n"]; ok {
+			t.Error("locals leaked from another request")
+		}
+		rb.Locals()["n"] = req.URL.Query().Get("n")
+	})
+	r.MapGet("/echo", NoAuth, func(rb *RequestBody) {
+		// Give concurrent requests a chance to interleave.
+		time.Sleep(time.Millisecond)
+		rb.Text(http.StatusOK, rb.Locals()["n"].(string))
+	})
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := strconv.Itoa(i)
+			if got := NewTestClient(r).Get("/api/echo?n=" + n).String(); got != n {
+				t.Errorf("request %s read %q", n, got)
+			}
+		}()
+	}
+	wg.Wait()
+}
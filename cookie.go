@@ -0,0 +1,228 @@
+package httpfly
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cookie errors.
+var (
+	ErrInvalidCookie = errors.New("httpfly: invalid or expired cookie")
+	ErrNoCookieKeys  = errors.New("httpfly: no cookie keys configured")
+)
+
+// CookieOptions configures a cookie set with RequestBody.SetCookie.
+type CookieOptions struct {
+	Path     string
+	Domain   string
+	MaxAge   time.Duration
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+	// Sign authenticates the value with HMAC-SHA256, so it can be read back
+	// with GetSignedCookie but not altered by the client.
+	Sign bool
+	// Encrypt encrypts and authenticates the value with AES-GCM, so the
+	// client can neither read nor alter it.
+	Encrypt bool
+}
+
+// cookieKey holds the keys derived from one secret.
+type cookieKey struct {
+	mac  []byte
+	aead cipher.AEAD
+}
+
+// SetCookieKeys sets the secrets of the default router for signed and
+// encrypted cookies. See Router.SetCookieKeys.
+func SetCookieKeys(secrets ...[]byte) {
+	defaultRouter.SetCookieKeys(secrets...)
+}
+
+// SetCookieKeys sets the secrets for signed and encrypted cookies. The
+// first one signs and encrypts new cookies; all of them are tried when
+// reading, so a key can be rotated by prepending its successor and dropping
+// it once cookies issued with it have expired.
+func (r *Router) SetCookieKeys(secrets ...[]byte) {
+	keys := make([]cookieKey, 0, len(secrets))
+	for _, s := range secrets {
+		keys = append(keys, newCookieKey(s))
+	}
+	r.cookieKeys = keys
+}
+
+// newCookieKey derives separate signing and encryption keys from secret.
+func newCookieKey(secret []byte) cookieKey {
+	derive := func(label string) []byte {
+		m := hmac.New(sha256.New, secret)
+		m.Write([]byte(label))
+		return m.Sum(nil)
+	}
+
+	block, err := aes.NewCipher(derive("httpfly cookie encryption"))
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	return cookieKey{mac: derive("httpfly cookie signing"), aead: aead}
+}
+
+// SetCookie sets a response cookie, signed or encrypted as opts says. The
+// value is bound to the cookie name and, with a MaxAge, expires with the
+// cookie, so it cannot be replayed under another name or after expiry.
+func (r *RequestBody) SetCookie(name, value string, opts CookieOptions) error {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   int(opts.MaxAge / time.Second),
+		Secure:   opts.Secure,
+		HttpOnly: opts.HttpOnly,
+		SameSite: opts.SameSite,
+	}
+
+	if opts.Sign || opts.Encrypt {
+		keys := r.cookieKeys()
+		if len(keys) == 0 {
+			return ErrNoCookieKeys
+		}
+		c.Value = encodeCookie(keys, name, []byte(value), opts.MaxAge, opts.Encrypt)
+	}
+
+	setCookie(r.ResponseW, c)
+	return nil
+}
+
+// GetSignedCookie returns the value of a cookie set with Sign or Encrypt,
+// or ErrInvalidCookie if it was altered, has expired or was not issued
+// with one of the router's keys.
+func (r *RequestBody) GetSignedCookie(name string) (string, error) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+
+	keys := r.cookieKeys()
+	if len(keys) == 0 {
+		return "", ErrNoCookieKeys
+	}
+
+	value, err := decodeCookie(keys, name, c.Value)
+	return string(value), err
+}
+
+// encodeCookie signs value or, with encrypt, encrypts it with the first
+// key. The value is bound to the cookie name and, unless maxAge is zero,
+// expires after maxAge.
+func encodeCookie(keys []cookieKey, name string, value []byte, maxAge time.Duration, encrypt bool) string {
+	var expires int64
+	if maxAge > 0 {
+		expires = time.Now().Add(maxAge).Unix()
+	}
+	payload := binary.BigEndian.AppendUint64(nil, uint64(expires))
+	payload = append(payload, value...)
+
+	if encrypt {
+		return "e." + keys[0].seal(name, payload)
+	}
+	return "s." + keys[0].sign(name, payload)
+}
+
+// decodeCookie returns the value of a cookie encoded with one of keys, or
+// ErrInvalidCookie if it was altered or has expired.
+func decodeCookie(keys []cookieKey, name, value string) ([]byte, error) {
+	for _, k := range keys {
+		var payload []byte
+		var ok bool
+
+		if data, found := strings.CutPrefix(value, "e."); found {
+			payload, ok = k.open(name, data)
+		} else if data, found := strings.CutPrefix(value, "s."); found {
+			payload, ok = k.verify(name, data)
+		}
+		if !ok || len(payload) < 8 {
+			continue
+		}
+
+		expires := int64(binary.BigEndian.Uint64(payload))
+		if expires != 0 && time.Now().Unix() > expires {
+			return nil, ErrInvalidCookie
+		}
+		return payload[8:], nil
+	}
+
+	return nil, ErrInvalidCookie
+}
+
+// cookieKeys returns the cookie keys of the request's router.
+func (r *RequestBody) cookieKeys() []cookieKey {
+	if r.router == nil {
+		return nil
+	}
+	return r.router.cookieKeys
+}
+
+// sign returns the payload and its MAC, bound to the cookie name.
+func (k cookieKey) sign(name string, payload []byte) string {
+	m := hmac.New(sha256.New, k.mac)
+	m.Write([]byte(name + "\x00"))
+	m.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// verify checks a signed value and returns its payload.
+func (k cookieKey) verify(name, value string) ([]byte, bool) {
+	data, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return nil, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, false
+	}
+
+	m := hmac.New(sha256.New, k.mac)
+	m.Write([]byte(name + "\x00"))
+	m.Write(payload)
+	return payload, hmac.Equal(mac, m.Sum(nil))
+}
+
+// seal encrypts the payload with the cookie name as additional data.
+func (k cookieKey) seal(name string, payload []byte) string {
+	nonce := make([]byte, k.aead.NonceSize())
+	rand.Read(nonce)
+
+	return base64.RawURLEncoding.EncodeToString(k.aead.Seal(nonce, nonce, payload, []byte(name)))
+}
+
+// open decrypts a sealed value and returns its payload.
+func (k cookieKey) open(name, value string) ([]byte, bool) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	n := k.aead.NonceSize()
+	if err != nil || len(sealed) < n {
+		return nil, false
+	}
+
+	payload, err := k.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+	return payload, err == nil
+}
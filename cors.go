@@ -0,0 +1,170 @@
+package httpfly
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures cross-origin resource sharing.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the API, e.g.
+	// "https://app.example.com". "*" allows any origin, and a "*." prefix
+	// as in "https://*.example.com" allows any subdomain.
+	AllowedOrigins []string
+	// AllowedMethods lists the methods allowed in preflight requests. Empty
+	// means the methods registered for the requested path.
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers allowed in preflight
+	// requests. Empty means the headers the client asks for.
+	AllowedHeaders []string
+	// ExposedHeaders lists response headers readable by the client.
+	ExposedHeaders []string
+	// AllowCredentials lets the allowed origins send cookies and
+	// credentials. It cannot be combined with the "*" origin.
+	AllowCredentials bool
+	// MaxAge is how long clients may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// UseCORS enables CORS for every route of the default router. Preflight
+// OPTIONS requests are answered automatically with 204. Routes can override
+// the configuration with WithCORS.
+func UseCORS(cfg CORSConfig) {
+	defaultRouter.UseCORS(cfg)
+}
+
+// UseCORS enables CORS for every route of the router. It panics if cfg
+// allows credentials from any origin.
+func (r *Router) UseCORS(cfg CORSConfig) {
+	cfg.check()
+	r.cors = &cfg
+}
+
+// WithCORS sets the CORS configuration of a single route, replacing the one
+// given to UseCORS.
+func WithCORS(cfg CORSConfig) RouteOption {
+	cfg.check()
+	return func(ri *RouteInfo) {
+		ri.cors = &cfg
+	}
+}
+
+// check panics if c would let any site make credentialed requests.
+func (c *CORSConfig) check() {
+	if c.AllowCredentials && c.allowsAny() {
+		panic(`httpfly: CORS AllowCredentials cannot be combined with the "*" origin`)
+	}
+}
+
+// corsFor returns the CORS configuration that applies to a route.
+func (r *Router) corsFor(v *RouteInfo) *CORSConfig {
+	if v != nil && v.cors != nil {
+		return v.cors
+	}
+	return r.cors
+}
+
+// handleCORS adds the CORS headers for a cross-origin request matched to v.
+// It returns true if the request was a preflight request and has been
+// answered.
+func (r *Router) handleCORS(table *routeTable, v *RouteInfo, w http.ResponseWriter, req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	requested := req.Header.Get("Access-Control-Request-Method")
+
+	if req.Method != http.MethodOptions || requested == "" {
+		if cfg := r.corsFor(v); cfg != nil {
+			cfg.setOrigin(w, origin)
+			if len(cfg.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+			}
+		}
+		return false
+	}
+
+	path := req.URL.EscapedPath()
+	target, _ := table.match(requested, path, requestVersion(req), req.Host)
+
+	cfg := r.corsFor(target)
+	if cfg == nil {
+		return false
+	}
+
+	if !cfg.setOrigin(w, origin) {
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = table.allowed(path, req.Host)
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+	if len(cfg.AllowedHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+	} else if h := req.Header.Get("Access-Control-Request-Headers"); h != "" {
+		w.Header().Set("Access-Control-Allow-Headers", h)
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if cfg.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge/time.Second)))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// setOrigin sets the allow-origin headers if origin is allowed and reports
+// whether it is.
+func (c *CORSConfig) setOrigin(w http.ResponseWriter, origin string) bool {
+	w.Header().Add("Vary", "Origin")
+
+	if !c.originAllowed(origin) {
+		return false
+	}
+
+	// A wildcard never reflects the origin, so it never carries
+	// credentials.
+	if c.allowsAny() {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return true
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	return true
+}
+
+func (c *CORSConfig) allowsAny() bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSConfig) originAllowed(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+
+		scheme, host, ok := strings.Cut(o, "*.")
+		if ok && strings.HasPrefix(strings.ToLower(origin), strings.ToLower(scheme)) &&
+			strings.HasSuffix(strings.ToLower(origin), "."+strings.ToLower(host)) {
+			return true
+		}
+	}
+	return false
+}
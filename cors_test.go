@@ -0,0 +1,52 @@
+package httpfly
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCORSRejectsCredentialedWildcard(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("UseCORS accepted AllowCredentials with the \"*\" origin")
+		}
+	}()
+
+	NewRouter().UseCORS(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestCORSOrigin(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         CORSConfig
+		origin      string
+		allow       string
+		credentials string
+	}{
+		{"wildcard", CORSConfig{AllowedOrigins: []string{"*"}}, "https://evil.example", "*", ""},
+		{"listed with credentials", CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, "https://app.example.com", "https://app.example.com", "true"},
+		{"unlisted", CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, "https://evil.example", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter()
+			r.UseCORS(tt.cfg)
+			r.MapGet("/x", NoAuth, func(rb *RequestBody) {})
+
+			c := NewTestClient(r)
+			c.Header.Set("Origin", tt.origin)
+			res := c.Get("/api/x")
+
+			if got := res.Header.Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allow)
+			}
+			if got := res.Header.Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.credentials)
+			}
+			if res.Status != http.StatusOK {
+				t.Errorf("status = %d", res.Status)
+			}
+		})
+	}
+}
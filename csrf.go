@@ -0,0 +1,124 @@
+package httpfly
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// CSRFConfig configures CSRF protection. Zero fields take their defaults.
+type CSRFConfig struct {
+	// CookieName defaults to "csrf_token".
+	CookieName string
+	// HeaderName defaults to "X-CSRF-Token".
+	HeaderName string
+	// FormField defaults to "csrf_token".
+	FormField string
+	Path      string
+	Domain    string
+	Secure    bool
+	SameSite  http.SameSite
+}
+
+// ErrCSRFToken is the error passed to the error handler when an unsafe
+// request lacks a valid CSRF token.
+var ErrCSRFToken = &HTTPError{Status: http.StatusForbidden, Code: "csrf_token_invalid", Message: "missing or invalid CSRF token"}
+
+var csrfKey = NewContextKey[string]("csrf")
+
+// csrfSessionKey is the session value holding the synchronizer token.
+const csrfSessionKey = "_csrf"
+
+// UseCSRF protects every route of the default router. See CSRF.
+func UseCSRF(cfg CSRFConfig) {
+	defaultRouter.UseCSRF(cfg)
+}
+
+// UseCSRF protects every route of the router. Register it after
+// UseSessions to keep tokens in the session.
+func (r *Router) UseCSRF(cfg CSRFConfig) {
+	r.AddMiddleware(CSRF(cfg))
+}
+
+// CSRF returns a middleware that issues a CSRF token per client and rejects
+// POST, PUT, PATCH and DELETE requests whose X-CSRF-Token header or
+// csrf_token form field does not match it, with 403 Forbidden through the
+// error handler. The token is kept in the session when the Sessions
+// middleware ran first (synchronizer token), and in a cookie otherwise
+// (double-submit cookie).
+func CSRF(cfg CSRFConfig) MiddlewareFunc {
+	if cfg.CookieName == "" {
+		cfg.CookieName = "csrf_token"
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "X-CSRF-Token"
+	}
+	if cfg.FormField == "" {
+		cfg.FormField = "csrf_token"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+
+	return func(rb *RequestBody, response http.ResponseWriter, request *http.Request) {
+		token := ""
+		session := rb.Session()
+
+		if session != nil {
+			if v, ok := session.Get(csrfSessionKey); ok {
+				token, _ = v.(string)
+			}
+		} else if c, err := request.Cookie(cfg.CookieName); err == nil {
+			token = c.Value
+		}
+
+		if !csrfSafeMethod(request.Method) {
+			sent := request.Header.Get(cfg.HeaderName)
+			if sent == "" {
+				sent = rb.FormValue(cfg.FormField)
+			}
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				rb.Fail(ErrCSRFToken)
+				return
+			}
+		}
+
+		if token == "" {
+			token = randomHex(32)
+
+			if session != nil {
+				if err := session.Set(csrfSessionKey, token); err != nil {
+					rb.Fail(err)
+					return
+				}
+			} else {
+				http.SetCookie(response, &http.Cookie{
+					Name:     cfg.CookieName,
+					Value:    token,
+					Path:     cfg.Path,
+					Domain:   cfg.Domain,
+					Secure:   cfg.Secure,
+					SameSite: cfg.SameSite,
+				})
+			}
+		}
+
+		csrfKey.Set(rb, token)
+	}
+}
+
+// CSRFToken returns the CSRF token of the request, for embedding in forms
+// and templates, or "" if the CSRF middleware is not in use.
+func (r *RequestBody) CSRFToken() string {
+	token, _ := csrfKey.Get(r)
+	return token
+}
+
+// csrfSafeMethod reports whether method cannot change state.
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runtimeSnapshot is the body of the runtime debug endpoint.
+type runtimeSnapshot struct {
+	Time        time.Time `json:"time"`
+	GoVersion   string    `json:"go_version"`
+	NumCPU      int       `json:"num_cpu"`
+	GOMAXPROCS  int       `json:"gomaxprocs"`
+	Goroutines  int       `json:"goroutines"`
+	HeapAlloc   uint64    `json:"heap_alloc"`
+	HeapInuse   uint64    `json:"heap_inuse"`
+	HeapObjects uint64    `json:"heap_objects"`
+	HeapSys     uint64    `json:"heap_sys"`
+	TotalAlloc  uint64    `json:"total_alloc"`
+	Sys         uint64    `json:"sys"`
+	NumGC       uint32    `json:"num_gc"`
+	LastGC      time.Time `json:"last_gc"`
+	PauseTotal  string    `json:"pause_total"`
+}
+
+// UseDebugEndpoints maps the runtime debug endpoints below prefix on the
+// default router. See Router.UseDebugEndpoints.
+func UseDebugEndpoints(prefix string, auth AuthRequire) {
+	defaultRouter.UseDebugEndpoints(prefix, auth)
+}
+
+// UseDebugEndpoints maps the runtime debug endpoints below prefix, e.g.
+// "/debug", on the router's own listener:
+//
+//	/pprof/          profile index, named profiles, CPU profile and trace
+//	/vars            command line and memory statistics, as expvar shows them
+//	/runtime         JSON snapshot of goroutines, heap and GC
+//
+// The endpoints are served with runtime/pprof and runtime/trace, so unlike
+// importing net/http/pprof or expvar nothing is registered on
+// http.DefaultServeMux. Variables published with expvar are not listed;
+// mount expvar.Handler() to serve them. With UseAuth the endpoints require
+// authentication and answer 401 while no auth provider is set. CPU
+// profiles and traces run for their seconds parameter, so the server's
+// write timeout must allow for it.
+func (r *Router) UseDebugEndpoints(prefix string, auth AuthRequire) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/pprof", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Location", "pprof/")
+		w.WriteHeader(http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/pprof/", func(w http.ResponseWriter, req *http.Request) {
+		name := strings.TrimPrefix(req.URL.Path, "/pprof/")
+		var err error
+
+		switch name {
+		case "":
+			serveProfileIndex(w)
+		case "cmdline":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(strings.Join(os.Args, "\x00")))
+		case "profile":
+			err = serveCPUProfile(w, req)
+		case "trace":
+			err = serveTrace(w, req)
+		default:
+			p := pprof.Lookup(name)
+			if p == nil {
+				r.frameworkError(w, req, http.StatusNotFound, "unknown profile")
+				return
+			}
+			if name == "heap" && req.FormValue("gc") != "" {
+				runtime.GC()
+			}
+			debugLevel, _ := strconv.Atoi(req.FormValue("debug"))
+			if debugLevel == 0 {
+				w.Header().Set("Content-Type", "application/octet-stream")
+				w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+			} else {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			}
+			err = p.WriteTo(w, debugLevel)
+		}
+
+		if err != nil {
+			r.frameworkError(w, req, http.StatusInternalServerError, err.Error())
+		}
+	})
+	mux.HandleFunc("/vars", serveVars)
+	mux.HandleFunc("/runtime", serveRuntimeSnapshot)
+
+	var opts []RouteOption
+	if auth {
+		opts = append(opts, RequireAuth())
+	}
+	r.Mount(prefix, mux, opts...)
+}
+
+// serveProfileIndex lists the available profiles.
+func serveProfileIndex(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	var b strings.Builder
+	b.WriteString("<html><head><title>profiles</title></head><body><table>\n")
+	for _, p := range pprof.Profiles() {
+		name := html.EscapeString(p.Name())
+		fmt.Fprintf(&b, "<tr><td>%d</td><td><a href=\"%s?debug=1\">%s</a></td></tr>\n", p.Count(), name, name)
+	}
+	b.WriteString("<tr><td></td><td><a href=\"profile?seconds=30\">profile</a> (CPU)</td></tr>\n")
+	b.WriteString("<tr><td></td><td><a href=\"trace?seconds=1\">trace</a></td></tr>\n")
+	b.WriteString("</table></body></html>\n")
+
+	w.Write([]byte(b.String()))
+}
+
+// profileSeconds returns the seconds parameter of a profile request.
+func profileSeconds(req *http.Request, def int) time.Duration {
+	sec, err := strconv.Atoi(req.FormValue("seconds"))
+	if err != nil || sec <= 0 {
+		sec = def
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// serveCPUProfile records a CPU profile for the seconds parameter.
+func serveCPUProfile(w http.ResponseWriter, req *http.Request) error {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+
+	if err := pprof.StartCPUProfile(w); err != nil {
+		w.Header().Del("Content-Disposition")
+		return err
+	}
+	sleepFor(req, profileSeconds(req, 30))
+	pprof.StopCPUProfile()
+	return nil
+}
+
+// serveTrace records an execution trace for the seconds parameter.
+func serveTrace(w http.ResponseWriter, req *http.Request) error {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+
+	if err := trace.Start(w); err != nil {
+		w.Header().Del("Content-Disposition")
+		return err
+	}
+	sleepFor(req, profileSeconds(req, 1))
+	trace.Stop()
+	return nil
+}
+
+// sleepFor waits for d or until the client goes away.
+func sleepFor(req *http.Request, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-req.Context().Done():
+	}
+}
+
+// serveVars answers with the variables expvar publishes by default.
+func serveVars(w http.ResponseWriter, req *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	out, _ := json.Marshal(map[string]any{"cmdline": os.Args, "memstats": m})
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(out)
+}
+
+// serveRuntimeSnapshot answers with the current runtime statistics.
+func serveRuntimeSnapshot(w http.ResponseWriter, req *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	snap := runtimeSnapshot{
+		Time:        time.Now().UTC(),
+		GoVersion:   runtime.Version(),
+		NumCPU:      runtime.NumCPU(),
+		GOMAXPROCS:  runtime.GOMAXPROCS(0),
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   m.HeapAlloc,
+		HeapInuse:   m.HeapInuse,
+		HeapObjects: m.HeapObjects,
+		HeapSys:     m.HeapSys,
+		TotalAlloc:  m.TotalAlloc,
+		Sys:         m.Sys,
+		NumGC:       m.NumGC,
+		PauseTotal:  time.Duration(m.PauseTotalNs).String(),
+	}
+	if m.LastGC != 0 {
+		snap.LastGC = time.Unix(0, int64(m.LastGC)).UTC()
+	}
+
+	out, _ := json.Marshal(snap)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(out)
+}
@@ -0,0 +1,53 @@
+package httpfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugEndpointsLeaveDefaultServeMuxAlone(t *testing.T) {
+	NewRouter().UseDebugEndpoints("/debug", NoAuth)
+
+	for _, path := range []string{"/debug/pprof/", "/debug/vars"} {
+		if _, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, path, nil)); pattern != "" {
+			t.Errorf("%s is registered on http.DefaultServeMux as %q", path, pattern)
+		}
+	}
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	r := NewRouter()
+	r.UseDebugEndpoints("/debug", UseAuth)
+	c := NewTestClient(r)
+
+	if res := c.Get("/api/debug/pprof/goroutine?debug=1"); res.Status != http.StatusUnauthorized {
+		t.Fatalf("status without provider = %d, want 401", res.Status)
+	}
+
+	r.SetAuthProvider(tokenProvider("secret"))
+	c.Header.Set("Authorization", "Bearer secret")
+
+	res := c.Get("/api/debug/pprof/goroutine?debug=1")
+	if res.Status != http.StatusOK || !strings.Contains(res.String(), "goroutine profile") {
+		t.Errorf("goroutine profile = %d %q", res.Status, res.String())
+	}
+
+	res = c.Get("/api/debug/pprof/")
+	if res.Status != http.StatusOK || !strings.Contains(res.String(), "heap") {
+		t.Errorf("index = %d %q", res.Status, res.String())
+	}
+
+	if res := c.Get("/api/debug/pprof/nope"); res.Status != http.StatusNotFound {
+		t.Errorf("unknown profile status = %d, want 404", res.Status)
+	}
+
+	var vars struct {
+		Cmdline  []string       `json:"cmdline"`
+		Memstats map[string]any `json:"memstats"`
+	}
+	if err := c.Get("/api/debug/vars").JSON(&vars); err != nil || len(vars.Cmdline) == 0 || vars.Memstats["HeapAlloc"] == nil {
+		t.Errorf("vars = %+v, %v", vars, err)
+	}
+}
@@ -0,0 +1,108 @@
+package httpfly
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxDecompressedSize is the default limit of decompressed request
+// bodies.
+const DefaultMaxDecompressedSize = 32 << 20
+
+// ErrUnsupportedEncoding is returned for request bodies with a
+// Content-Encoding other than gzip, deflate or identity. Such requests are
+// answered with 415 Unsupported Media Type.
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// SetMaxDecompressedSize limits the decompressed size of compressed request
+// bodies of the default router. See Options.MaxDecompressedSize.
+func SetMaxDecompressedSize(n int64) {
+	defaultRouter.MaxDecompressedSize = n
+}
+
+// decodeContentEncoding wraps body in decompressors for the
+// Content-Encoding of req, limited to max decompressed bytes, and removes
+// the header so the rest of the request sees a plain body. Brotli is not
+// supported by the standard library and is rejected.
+func decodeContentEncoding(req *http.Request, body io.Reader, max int64) (io.Reader, error) {
+	var codings []string
+	for _, line := range req.Header.Values("Content-Encoding") {
+		for _, c := range strings.Split(line, ",") {
+			if c = strings.ToLower(strings.TrimSpace(c)); c != "" && c != "identity" {
+				codings = append(codings, c)
+			}
+		}
+	}
+
+	if len(codings) == 0 || req.ContentLength == 0 {
+		return body, nil
+	}
+
+	// Codings are listed in the order they were applied.
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		switch codings[i] {
+		case "gzip", "x-gzip":
+			body, err = gzip.NewReader(body)
+		case "deflate":
+			body, err = newDeflateReader(body)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, codings[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+
+	if max == 0 {
+		max = DefaultMaxDecompressedSize
+	}
+	if max < 0 {
+		return body, nil
+	}
+	return &limitedReader{r: body, n: max}, nil
+}
+
+// newDeflateReader reads "deflate" bodies, which should be zlib streams but
+// are raw deflate data from some clients.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// limitedReader fails with *http.MaxBytesError once more than n bytes are
+// read.
+type limitedReader struct {
+	r    io.Reader
+	n    int64
+	read int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.n {
+		return n, &http.MaxBytesError{Limit: l.n}
+	}
+	return n, err
+}
@@ -0,0 +1,66 @@
+package httpfly
+
+import "net/http"
+
+// SetDefaultHeaders sets headers added to every response of the default
+// router. See Router.SetDefaultHeaders.
+func SetDefaultHeaders(headers map[string]string) {
+	defaultRouter.SetDefaultHeaders(headers)
+}
+
+// SetDefaultHeaders sets headers added to every response of the router,
+// including 404s, e.g. Server, an API version or a cache policy. Routes
+// and groups add to them with WithHeaders and GroupHeaders, and handlers
+// can still replace single headers. It replaces earlier defaults.
+func (r *Router) SetDefaultHeaders(headers map[string]string) {
+	r.headers = cloneHeaders(nil, headers)
+}
+
+// WithHeaders adds headers to the responses of a route, on top of the
+// router defaults. An empty value removes a default header.
+func WithHeaders(headers map[string]string) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.headers = cloneHeaders(ri.headers, headers)
+	}
+}
+
+// GroupHeaders adds headers to the responses of the routes of a group, see
+// WithHeaders. Nested groups inherit them.
+func GroupHeaders(headers map[string]string) GroupOption {
+	return func(g *RouteGroup) {
+		g.headers = cloneHeaders(g.headers, headers)
+	}
+}
+
+// setDefaultHeaders adds the default and route headers to a response.
+func (r *Router) setDefaultHeaders(v *RouteInfo, w http.ResponseWriter) {
+	h := w.Header()
+
+	for k, value := range r.headers {
+		h.Set(k, value)
+	}
+	if v == nil {
+		return
+	}
+
+	for k, value := range v.headers {
+		if value == "" {
+			h.Del(k)
+		} else {
+			h.Set(k, value)
+		}
+	}
+}
+
+// cloneHeaders returns a copy of dst with headers merged in, with
+// canonical names.
+func cloneHeaders(dst, headers map[string]string) map[string]string {
+	out := make(map[string]string, len(dst)+len(headers))
+	for k, v := range dst {
+		out[k] = v
+	}
+	for k, v := range headers {
+		out[http.CanonicalHeaderKey(k)] = v
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package httpfly
+
+// DevConfig configures development mode, see EnableDevMode.
+type DevConfig struct {
+	// FakeClaims are the claims of every request to a UseAuth route.
+	FakeClaims map[string]string
+	// VerboseErrors includes error details and stacks in 500 responses,
+	// overriding Production.
+	VerboseErrors bool
+}
+
+// EnableDevMode puts the default router in development mode. See
+// Router.EnableDevMode.
+func EnableDevMode(cfg DevConfig) {
+	defaultRouter.EnableDevMode(cfg)
+}
+
+// EnableDevMode puts the router in development mode: routes mapped with
+// UseAuth accept every request without running the auth provider, with
+// cfg.FakeClaims as claims, so frontends can be developed without an
+// identity provider. Roles are still checked against the fake claims. It
+// logs a warning, since it must never be enabled in production.
+func (r *Router) EnableDevMode(cfg DevConfig) {
+	r.dev = &cfg
+	if r.logger != nil {
+		r.logger.Error("development mode enabled: authentication is bypassed")
+	}
+}
+
+// devClaims returns the fake claims of development mode as Claims.
+func (c *DevConfig) devClaims() Claims {
+	claims := make(Claims, len(c.FakeClaims))
+	for k, v := range c.FakeClaims {
+		claims[k] = v
+	}
+	return claims
+}
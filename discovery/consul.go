@@ -0,0 +1,108 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultConsulAddr is the address of the local Consul agent.
+const DefaultConsulAddr = "http://127.0.0.1:8500"
+
+// Consul registers services with the HTTP API of a Consul agent. Services
+// get a TTL check passed by the heartbeats, and routes are added to their
+// tags as "route:GET /api/users/{id}".
+type Consul struct {
+	// Addr is the URL of the agent. Defaults to DefaultConsulAddr.
+	Addr string
+	// Token is the ACL token, if any.
+	Token string
+	// DeregisterAfter makes Consul remove a service whose check has been
+	// critical for that long, e.g. after a crash. Defaults to a minute.
+	DeregisterAfter time.Duration
+	// Client defaults to a client with a 10 second timeout.
+	Client *http.Client
+}
+
+// NewConsul returns a Consul registry for the agent at addr.
+func NewConsul(addr string) *Consul {
+	return &Consul{Addr: addr}
+}
+
+// consulService is the body of the service registration endpoint.
+type consulService struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	Tags    []string
+	Check   consulCheck
+}
+
+// consulCheck is the TTL check of a registration.
+type consulCheck struct {
+	CheckID                        string
+	TTL                            string
+	DeregisterCriticalServiceAfter string
+}
+
+// Register implements Registry.
+func (c *Consul) Register(ctx context.Context, s *Service) error {
+	deregister := c.DeregisterAfter
+	if deregister <= 0 {
+		deregister = time.Minute
+	}
+
+	tags := append([]string(nil), s.Tags...)
+	for _, route := range s.Routes {
+		tags = append(tags, "route:"+route)
+	}
+
+	body := consulService{
+		ID:      s.ID,
+		Name:    s.Name,
+		Address: s.Address,
+		Port:    s.Port,
+		Tags:    tags,
+		Check: consulCheck{
+			CheckID:                        consulCheckID(s),
+			TTL:                            s.TTL.String(),
+			DeregisterCriticalServiceAfter: deregister.String(),
+		},
+	}
+	if err := c.call(ctx, "/v1/agent/service/register", body); err != nil {
+		return err
+	}
+	return c.Heartbeat(ctx, s)
+}
+
+// Heartbeat implements Registry by passing the TTL check.
+func (c *Consul) Heartbeat(ctx context.Context, s *Service) error {
+	return c.call(ctx, "/v1/agent/check/pass/"+url.PathEscape(consulCheckID(s)), nil)
+}
+
+// Deregister implements Registry.
+func (c *Consul) Deregister(ctx context.Context, s *Service) error {
+	return c.call(ctx, "/v1/agent/service/deregister/"+url.PathEscape(s.ID), nil)
+}
+
+// call sends a PUT request to the agent.
+func (c *Consul) call(ctx context.Context, path string, in any) error {
+	addr := c.Addr
+	if addr == "" {
+		addr = DefaultConsulAddr
+	}
+
+	header := http.Header{}
+	if c.Token != "" {
+		header.Set("X-Consul-Token", c.Token)
+	}
+	return call(ctx, c.Client, http.MethodPut, strings.TrimSuffix(addr, "/")+path, header, in, nil)
+}
+
+// consulCheckID names the TTL check of s.
+func consulCheckID(s *Service) string {
+	return "service:" + s.ID
+}
@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConsul(t *testing.T) {
+	var paths []string
+	var registered consulService
+
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut || req.Header.Get("X-Consul-Token") != "secret" {
+			t.Errorf("%s %s with token %q", req.Method, req.URL.Path, req.Header.Get("X-Consul-Token"))
+		}
+		paths = append(paths, req.URL.EscapedPath())
+
+		if req.URL.Path == "/v1/agent/service/register" {
+			json.NewDecoder(req.Body).Decode(&registered)
+		}
+		if req.URL.Path == "/v1/agent/service/deregister/gone" {
+			http.Error(w, "unknown service", http.StatusNotFound)
+		}
+	}))
+	defer agent.Close()
+
+	c := NewConsul(agent.URL + "/")
+	c.Token = "secret"
+
+	s := &Service{ID: "users 1", Name: "users", Address: "10.0.0.1", Port: 8080, Tags: []string{"v1"}, Routes: []string{"GET /api/users"}, TTL: 30 * time.Second}
+	if err := c.Register(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Deregister(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/v1/agent/service/register", "/v1/agent/check/pass/service:users%201", "/v1/agent/service/deregister/users%201"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+	if !slices.Equal(registered.Tags, []string{"v1", "route:GET /api/users"}) || registered.Check.TTL != "30s" || registered.Check.DeregisterCriticalServiceAfter != "1m0s" {
+		t.Errorf("registered %+v", registered)
+	}
+
+	if err := c.Deregister(context.Background(), &Service{ID: "gone"}); err == nil {
+		t.Error("Deregister ignored a 404 of the agent")
+	}
+}
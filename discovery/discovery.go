@@ -0,0 +1,263 @@
+// Package discovery announces an httpfly application to a service registry
+// such as Consul or etcd. The service is registered with its advertised
+// address and route list once the server listens, kept alive with TTL
+// heartbeats and deregistered on graceful shutdown.
+//
+//	discovery.Register(r, discovery.Config{
+//		Registry: discovery.NewConsul("http://127.0.0.1:8500"),
+//		Name:     "users",
+//	})
+//	log.Fatal(r.Start(":8080"))
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/burakturkerdev/httpfly"
+)
+
+// DefaultTTL is the default time a registration lives without heartbeats.
+const DefaultTTL = 30 * time.Second
+
+// Service is a registration of an application instance.
+type Service struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	Tags    []string
+	// Routes are the routes of the router, e.g. "GET /api/users/{id}".
+	Routes []string
+	// TTL is how long the registry keeps the service without a heartbeat.
+	TTL time.Duration
+}
+
+// Registry is a service registry.
+type Registry interface {
+	// Register adds or replaces the service.
+	Register(ctx context.Context, s *Service) error
+	// Heartbeat renews the TTL of a registered service. An error makes
+	// the service register again.
+	Heartbeat(ctx context.Context, s *Service) error
+	// Deregister removes the service.
+	Deregister(ctx context.Context, s *Service) error
+}
+
+// Config configures Register.
+type Config struct {
+	Registry Registry
+	// Name is the service name.
+	Name string
+	// ID identifies the instance. Defaults to Name, the host name and the
+	// port.
+	ID string
+	// Address is the advertised host or IP. Defaults to the IP the server
+	// listens on, or the host name when it listens on all interfaces.
+	Address string
+	// Port is the advertised port. Defaults to the port the server listens
+	// on.
+	Port int
+	Tags []string
+	// TTL defaults to DefaultTTL. Heartbeats are sent every third of it.
+	TTL time.Duration
+	// OnError receives registration and heartbeat errors. Defaults to
+	// logging them with slog.
+	OnError func(err error)
+}
+
+// Register registers the service with cfg.Registry when a server of r
+// starts listening and deregisters it when the server shuts down
+// gracefully. Registration errors do not stop the server; they are
+// reported to OnError and retried with the next heartbeat.
+func Register(r *httpfly.Router, cfg Config) {
+	if cfg.Registry == nil || cfg.Name == "" {
+		panic("discovery: Registry and Name are required")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(err error) {
+			slog.Error("service discovery", "error", err.Error())
+		}
+	}
+
+	a := &announcer{cfg: cfg, router: r}
+	r.OnStart(a.start)
+	r.OnShutdown(a.stop)
+}
+
+// announcer keeps the registration of one router.
+type announcer struct {
+	cfg    Config
+	router *httpfly.Router
+
+	mu      sync.Mutex
+	service *Service
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// start registers the service for the listener at addr and starts the
+// heartbeats. Only the first server of the router is announced.
+func (a *announcer) start(addr net.Addr) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.service != nil {
+		return
+	}
+	a.service = a.newService(addr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel, a.done = cancel, make(chan struct{})
+
+	if err := a.cfg.Registry.Register(ctx, a.service); err != nil {
+		a.cfg.OnError(fmt.Errorf("register %s: %w", a.service.ID, err))
+	}
+	go a.heartbeat(ctx)
+}
+
+// heartbeat renews the registration until ctx is canceled, registering
+// again when a heartbeat fails.
+func (a *announcer) heartbeat(ctx context.Context) {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.cfg.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := a.cfg.Registry.Heartbeat(ctx, a.service)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		if err = a.cfg.Registry.Register(ctx, a.service); err != nil && ctx.Err() == nil {
+			a.cfg.OnError(fmt.Errorf("register %s: %w", a.service.ID, err))
+		}
+	}
+}
+
+// stop ends the heartbeats and deregisters the service.
+func (a *announcer) stop(ctx context.Context) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.service == nil {
+		return
+	}
+
+	a.cancel()
+	<-a.done
+
+	if err := a.cfg.Registry.Deregister(ctx, a.service); err != nil {
+		a.cfg.OnError(fmt.Errorf("deregister %s: %w", a.service.ID, err))
+	}
+	a.service = nil
+}
+
+// newService describes the router served on addr.
+func (a *announcer) newService(addr net.Addr) *Service {
+	host, portText, _ := net.SplitHostPort(addr.String())
+
+	address := a.cfg.Address
+	if address == "" {
+		if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+			address = host
+		} else {
+			address, _ = os.Hostname()
+		}
+	}
+
+	port := a.cfg.Port
+	if port == 0 {
+		port, _ = strconv.Atoi(portText)
+	}
+
+	id := a.cfg.ID
+	if id == "" {
+		hostname, _ := os.Hostname()
+		id = a.cfg.Name + "-" + hostname + "-" + strconv.Itoa(port)
+	}
+
+	var routes []string
+	for _, ri := range a.router.Routes() {
+		routes = append(routes, string(ri.Method)+" "+ri.Endpoint)
+	}
+
+	return &Service{
+		ID:      id,
+		Name:    a.cfg.Name,
+		Address: address,
+		Port:    port,
+		Tags:    a.cfg.Tags,
+		Routes:  routes,
+		TTL:     a.cfg.TTL,
+	}
+}
+
+// httpClient is the client of registries without their own.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// call sends a JSON request to a registry and decodes the JSON answer
+// into out, if it is not nil.
+func call(ctx context.Context, client *http.Client, method, url string, header http.Header, in, out any) error {
+	if client == nil {
+		client = httpClient
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, bytes.TrimSpace(data))
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
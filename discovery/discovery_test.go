@@ -0,0 +1,114 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/burakturkerdev/httpfly"
+)
+
+// fakeRegistry records the calls of an announcer. Heartbeats fail while
+// failing is set.
+type fakeRegistry struct {
+	mu      sync.Mutex
+	calls   []string
+	service *Service
+	failing bool
+
+	heartbeats chan struct{}
+}
+
+func (f *fakeRegistry) record(call string, s *Service) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+	f.service = s
+}
+
+func (f *fakeRegistry) Register(ctx context.Context, s *Service) error {
+	f.record("register", s)
+	return nil
+}
+
+func (f *fakeRegistry) Heartbeat(ctx context.Context, s *Service) error {
+	f.record("heartbeat", s)
+
+	f.mu.Lock()
+	failing := f.failing
+	f.failing = false
+	f.mu.Unlock()
+
+	select {
+	case f.heartbeats <- struct{}{}:
+	default:
+	}
+	if failing {
+		return errors.New("lease expired")
+	}
+	return nil
+}
+
+func (f *fakeRegistry) Deregister(ctx context.Context, s *Service) error {
+	f.record("deregister", s)
+	return nil
+}
+
+func TestRegisterFollowsServerLifecycle(t *testing.T) {
+	reg := &fakeRegistry{failing: true, heartbeats: make(chan struct{}, 1)}
+
+	r := httpfly.NewRouter()
+	r.MapGet("/users/{id}", httpfly.NoAuth, func(rb *httpfly.RequestBody) {})
+	Register(r, Config{Registry: reg, Name: "users", Tags: []string{"v1"}, TTL: 30 * time.Millisecond})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- httpfly.NewServer("", r).Serve(ctx, ln) }()
+
+	// The first heartbeat fails and registers again, the second passes.
+	<-reg.heartbeats
+	<-reg.heartbeats
+	cancel()
+	if err := <-served; err != nil {
+		t.Fatalf("Serve = %v", err)
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	calls := slices.Compact(slices.Clone(reg.calls))
+	want := []string{"register", "heartbeat", "register", "heartbeat", "deregister"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	s := reg.service
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	if s.Name != "users" || s.Address != "127.0.0.1" || port == "0" || !slices.Contains(s.Routes, "GET /api/users/{id}") || !slices.Equal(s.Tags, []string{"v1"}) {
+		t.Errorf("registered %+v", s)
+	}
+}
+
+func TestNewServiceDefaults(t *testing.T) {
+	a := &announcer{cfg: Config{Name: "users", Address: "users.internal", Port: 443}, router: httpfly.NewRouter()}
+
+	s := a.newService(&net.TCPAddr{IP: net.IPv4zero, Port: 8080})
+	if s.Address != "users.internal" || s.Port != 443 {
+		t.Errorf("advertised %s:%d, want the configured address", s.Address, s.Port)
+	}
+
+	a.cfg.Address, a.cfg.Port = "", 0
+	s = a.newService(&net.TCPAddr{IP: net.IPv4zero, Port: 8080})
+	if s.Address == "" || s.Address == "0.0.0.0" || s.Port != 8080 {
+		t.Errorf("advertised %s:%d, want the host name and the listening port", s.Address, s.Port)
+	}
+}
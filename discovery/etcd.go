@@ -0,0 +1,150 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Defaults of Etcd.
+const (
+	DefaultEtcdEndpoint = "http://127.0.0.1:2379"
+	DefaultEtcdPrefix   = "/services/"
+)
+
+// Etcd registers services in etcd through its v3 JSON gateway. A service
+// is stored as JSON under Prefix + name + "/" + id, attached to a lease
+// with the TTL of the service that the heartbeats keep alive.
+type Etcd struct {
+	// Endpoint is the URL of an etcd member. Defaults to
+	// DefaultEtcdEndpoint.
+	Endpoint string
+	// Prefix is prepended to the keys. Defaults to DefaultEtcdPrefix.
+	Prefix string
+	// Client defaults to a client with a 10 second timeout.
+	Client *http.Client
+
+	mu     sync.Mutex
+	leases map[string]string
+}
+
+// NewEtcd returns an etcd registry for the member at endpoint.
+func NewEtcd(endpoint string) *Etcd {
+	return &Etcd{Endpoint: endpoint}
+}
+
+// errNotRegistered is returned by heartbeats of services without a live
+// lease.
+var errNotRegistered = errors.New("discovery: service is not registered")
+
+// etcdLease is the lease part of etcd answers. IDs and TTLs are int64
+// values, encoded as strings.
+type etcdLease struct {
+	ID  string `json:"ID,omitempty"`
+	TTL string `json:"TTL,omitempty"`
+}
+
+// Register implements Registry.
+func (e *Etcd) Register(ctx context.Context, s *Service) error {
+	ttl := int64(s.TTL.Seconds())
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	var lease etcdLease
+	if err := e.call(ctx, "/v3/lease/grant", map[string]any{"TTL": ttl}, &lease); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	put := map[string]string{
+		"key":   base64.StdEncoding.EncodeToString([]byte(e.key(s))),
+		"value": base64.StdEncoding.EncodeToString(value),
+		"lease": lease.ID,
+	}
+	if err := e.call(ctx, "/v3/kv/put", put, nil); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	if e.leases == nil {
+		e.leases = map[string]string{}
+	}
+	old := e.leases[s.ID]
+	e.leases[s.ID] = lease.ID
+	e.mu.Unlock()
+
+	if old != "" {
+		e.call(ctx, "/v3/lease/revoke", etcdLease{ID: old}, nil)
+	}
+	return nil
+}
+
+// Heartbeat implements Registry by renewing the lease of s.
+func (e *Etcd) Heartbeat(ctx context.Context, s *Service) error {
+	id := e.lease(s)
+	if id == "" {
+		return errNotRegistered
+	}
+
+	var resp struct {
+		Result etcdLease `json:"result"`
+	}
+	if err := e.call(ctx, "/v3/lease/keepalive", etcdLease{ID: id}, &resp); err != nil {
+		return err
+	}
+
+	// An expired lease is answered with no TTL.
+	if ttl, _ := strconv.ParseInt(resp.Result.TTL, 10, 64); ttl <= 0 {
+		return errNotRegistered
+	}
+	return nil
+}
+
+// Deregister implements Registry by revoking the lease of s, which deletes
+// its key.
+func (e *Etcd) Deregister(ctx context.Context, s *Service) error {
+	e.mu.Lock()
+	id := e.leases[s.ID]
+	delete(e.leases, s.ID)
+	e.mu.Unlock()
+
+	if id == "" {
+		return nil
+	}
+	return e.call(ctx, "/v3/lease/revoke", etcdLease{ID: id}, nil)
+}
+
+// key returns the key of s.
+func (e *Etcd) key(s *Service) string {
+	prefix := e.Prefix
+	if prefix == "" {
+		prefix = DefaultEtcdPrefix
+	}
+	return prefix + s.Name + "/" + s.ID
+}
+
+// lease returns the lease of s, or "".
+func (e *Etcd) lease(s *Service) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leases[s.ID]
+}
+
+// call sends a POST request to the gateway.
+func (e *Etcd) call(ctx context.Context, path string, in, out any) error {
+	endpoint := e.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEtcdEndpoint
+	}
+	return call(ctx, e.Client, http.MethodPost, strings.TrimSuffix(endpoint, "/")+path, nil, in, out)
+}
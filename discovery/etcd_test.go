@@ -0,0 +1,113 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEtcd is the part of the etcd v3 JSON gateway used by Etcd.
+type fakeEtcd struct {
+	mu     sync.Mutex
+	next   int
+	leases map[string]bool
+	kv     map[string]string
+	keyOf  map[string]string
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var in map[string]any
+	json.NewDecoder(req.Body).Decode(&in)
+	id, _ := in["ID"].(string)
+
+	switch req.URL.Path {
+	case "/v3/lease/grant":
+		f.next++
+		id := strconv.Itoa(f.next)
+		f.leases[id] = true
+		json.NewEncoder(w).Encode(etcdLease{ID: id, TTL: "30"})
+
+	case "/v3/kv/put":
+		key, _ := base64.StdEncoding.DecodeString(in["key"].(string))
+		value, _ := base64.StdEncoding.DecodeString(in["value"].(string))
+		f.kv[string(key)] = string(value)
+		f.keyOf[in["lease"].(string)] = string(key)
+		w.Write([]byte("{}"))
+
+	case "/v3/lease/keepalive":
+		res := etcdLease{ID: id}
+		if f.leases[id] {
+			res.TTL = "30"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"result": res})
+
+	case "/v3/lease/revoke":
+		delete(f.leases, id)
+		delete(f.kv, f.keyOf[id])
+		w.Write([]byte("{}"))
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestEtcd(t *testing.T) {
+	fake := &fakeEtcd{leases: map[string]bool{}, kv: map[string]string{}, keyOf: map[string]string{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	e := NewEtcd(srv.URL)
+	s := &Service{ID: "users-1", Name: "users", Port: 8080, TTL: 30 * time.Second}
+	ctx := context.Background()
+
+	if err := e.Heartbeat(ctx, s); !errors.Is(err, errNotRegistered) {
+		t.Errorf("Heartbeat before Register = %v, want errNotRegistered", err)
+	}
+
+	if err := e.Register(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	var stored Service
+	if err := json.Unmarshal([]byte(fake.kv["/services/users/users-1"]), &stored); err != nil || stored.Port != 8080 {
+		t.Fatalf("stored %q, %v", fake.kv["/services/users/users-1"], err)
+	}
+	if err := e.Heartbeat(ctx, s); err != nil {
+		t.Errorf("Heartbeat = %v", err)
+	}
+
+	// Registering again moves the key to a new lease and revokes the old.
+	if err := e.Register(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if fake.leases["1"] || !fake.leases["2"] {
+		t.Errorf("leases = %v, want only the second", fake.leases)
+	}
+
+	// An expired lease makes the announcer register again.
+	fake.mu.Lock()
+	delete(fake.leases, "2")
+	fake.mu.Unlock()
+	if err := e.Heartbeat(ctx, s); !errors.Is(err, errNotRegistered) {
+		t.Errorf("Heartbeat of an expired lease = %v, want errNotRegistered", err)
+	}
+
+	if err := e.Register(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Deregister(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.kv) != 0 || len(fake.leases) != 0 {
+		t.Errorf("after Deregister: keys %v, leases %v", fake.kv, fake.leases)
+	}
+}
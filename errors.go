@@ -0,0 +1,109 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrHandled signals that a handler has already written its response and
+// that no error response should be rendered for it.
+var ErrHandled = errors.New("response already written")
+
+// Production hides error details from clients of the default router, and
+// is on by default. Internal errors are answered with an opaque body
+// carrying only an error id, while the full detail is logged under the
+// same id. Set it to false during development to include the detail and
+// stack in the response.
+var Production = true
+
+// internalErrorBody is the JSON body of a 500 response, an HTTPError body
+// with the error id and, outside production, the detail.
+type internalErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	ErrorID string `json:"error_id"`
+	Detail  string `json:"detail,omitempty"`
+	Stack   string `json:"stack,omitempty"`
+}
+
+// discardBuffered drops a buffered response so that an error response can
+// replace it. Unbuffered responses that have been sent are left alone.
+func discardBuffered(w *ResponseRecorder) {
+	if buf, ok := w.ResponseWriter.(*responseBuffer); ok {
+		buf.reset()
+		w.status, w.size = 0, 0
+	}
+}
+
+// internalError logs err with the request ID, or a fresh error id, and
+// answers the request with 500, unless a response has already been sent.
+// With problem set, the body is a problem with the same members.
+func internalError(logger Logger, w *ResponseRecorder, req *http.Request, err any, stack []byte, production, problem bool) {
+	id := RequestIDFromContext(req.Context())
+	if id == "" {
+		id = randomHex(8)
+	}
+	if logger != nil {
+		logger.Error("internal error", "error_id", id, "method", req.Method, "path", req.URL.Path, "error", fmt.Sprint(err), "stack", string(stack))
+	}
+
+	if w.written() {
+		return
+	}
+
+	body := internalErrorBody{Code: "internal_error", Message: http.StatusText(http.StatusInternalServerError), ErrorID: id}
+	if !production {
+		body.Detail = fmt.Sprint(err)
+		body.Stack = string(stack)
+	}
+
+	if problem {
+		p := NewProblem(http.StatusInternalServerError, "", body.Detail)
+		p.Instance = req.URL.Path
+		p.Extensions = map[string]any{"code": body.Code, "error_id": id}
+		if body.Stack != "" {
+			p.Extensions["stack"] = body.Stack
+		}
+		writeProblem(w, p)
+		return
+	}
+
+	out, _ := json.Marshal(body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(out)
+}
+
+// OnPanic registers a hook that runs when a handler or middleware of the
+// default router panics. A hook that writes a response replaces the default
+// 500 body; the panic is logged either way.
+func OnPanic(f func(rb *RequestBody, recovered any)) {
+	defaultRouter.OnPanic(f)
+}
+
+// OnPanic registers a panic hook on the router.
+func (r *Router) OnPanic(f func(rb *RequestBody, recovered any)) {
+	r.panicHooks = append(r.panicHooks, f)
+}
+
+// recoverPanic runs the panic hooks and renders the 500 response for a
+// recovered panic.
+func (r *Router) recoverPanic(rb *RequestBody, w *ResponseRecorder, rec any, stack []byte, production bool) {
+	discardBuffered(w)
+	rb.ResponseW = w
+
+	if err, ok := rec.(error); ok {
+		rb.err = fmt.Errorf("panic: %w", err)
+	} else {
+		rb.err = fmt.Errorf("panic: %v", rec)
+	}
+
+	for _, f := range r.panicHooks {
+		f(rb, rec)
+	}
+
+	internalError(r.logger, w, rb.req, rec, stack, production, r.problems)
+}
@@ -0,0 +1,95 @@
+package httpfly
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordLogger keeps the key-value pairs of every log line.
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []map[string]any
+}
+
+func (l *recordLogger) Info(msg string, args ...any)  { l.add(msg, args) }
+func (l *recordLogger) Error(msg string, args ...any) { l.add(msg, args) }
+
+func (l *recordLogger) add(msg string, args []any) {
+	line := map[string]any{"msg": msg}
+	for i := 0; i+1 < len(args); i += 2 {
+		line[args[i].(string)] = args[i+1]
+	}
+
+	l.mu.Lock()
+	l.lines = append(l.lines, line)
+	l.mu.Unlock()
+}
+
+// find returns the first line with msg, or nil.
+func (l *recordLogger) find(msg string) map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, line := range l.lines {
+		if line["msg"] == msg {
+			return line
+		}
+	}
+	return nil
+}
+
+func TestInternalErrorBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		production bool
+		verbose    bool
+	}{
+		{"production", true, false},
+		{"development", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &recordLogger{}
+			r := NewRouter()
+			r.SetLogger(log)
+			r.Production = tt.production
+			r.MapGet("/boom", NoAuth, func(rb *RequestBody) { panic("secret detail") })
+
+			res := NewTestClient(r).Get("/api/boom")
+			if res.Status != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want 500", res.Status)
+			}
+
+			var body internalErrorBody
+			if err := res.JSON(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != "internal_error" || body.ErrorID == "" {
+				t.Errorf("body = %+v, want internal_error with an error id", body)
+			}
+			if shown := strings.Contains(res.String(), "secret detail") || body.Stack != ""; shown != tt.verbose {
+				t.Errorf("details in body = %v, want %v: %s", shown, tt.verbose, res.String())
+			}
+
+			line := log.find("internal error")
+			if line == nil {
+				t.Fatal("internal error was not logged")
+			}
+			if line["error_id"] != body.ErrorID {
+				t.Errorf("logged error id %v, response %q", line["error_id"], body.ErrorID)
+			}
+			if line["error"] != "secret detail" {
+				t.Errorf("logged error = %v, want the panic value", line["error"])
+			}
+		})
+	}
+}
+
+func TestProductionIsDefault(t *testing.T) {
+	if !Production || !NewRouter().Production {
+		t.Error("error details are shown to clients by default")
+	}
+}
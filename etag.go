@@ -0,0 +1,125 @@
+package httpfly
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// UseETags adds ETags to GET and HEAD responses of the default router. See
+// ETags.
+func UseETags() {
+	defaultRouter.UseETags()
+}
+
+// UseETags adds ETags to GET and HEAD responses of the router.
+func (r *Router) UseETags() {
+	r.Use(ETags())
+}
+
+// ETags returns chain middleware that buffers successful GET and HEAD
+// responses, tags them with a hash of the body unless the handler set an
+// ETag itself, and answers 304 Not Modified when If-None-Match, or
+// If-Modified-Since against a Last-Modified header, shows the client's copy
+// is current. Buffering means these responses cannot be streamed.
+func ETags() ChainMiddleware {
+	return func(rb *RequestBody, next Handler) {
+		if rb.req.Method != http.MethodGet && rb.req.Method != http.MethodHead {
+			next(rb)
+			return
+		}
+
+		dst := rb.ResponseW
+		buf := newResponseBuffer()
+		rb.ResponseW = buf
+
+		next(rb)
+
+		rb.ResponseW = dst
+
+		if buf.Status() != http.StatusOK {
+			buf.flushTo(dst)
+			return
+		}
+
+		if buf.header.Get("ETag") == "" {
+			buf.header.Set("ETag", bodyETag(buf.body.Bytes()))
+		}
+
+		if notModified(rb.req, buf.header) {
+			writeNotModified(dst, buf.header)
+			return
+		}
+
+		buf.flushTo(dst)
+	}
+}
+
+// JSONCached writes v as JSON like JSON, with an ETag derived from the
+// encoded body. If the request's If-None-Match already names that ETag, it
+// answers 304 Not Modified without a body instead.
+func (r *RequestBody) JSONCached(status int, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	h := r.ResponseW.Header()
+	h.Set("ETag", bodyETag(b))
+
+	if status == http.StatusOK && notModified(r.req, h) {
+		writeNotModified(r.ResponseW, nil)
+		return nil
+	}
+
+	h.Set("Content-Type", "application/json")
+	r.writeHeader(status)
+	_, err = r.ResponseW.Write(b)
+	return err
+}
+
+// bodyETag returns a strong entity tag for body.
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when the
+// request has no If-None-Match, against the response headers h.
+func notModified(req *http.Request, h http.Header) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		return etag != "" && etagListMatches(inm, etag, true)
+	}
+
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+
+	return !lm.Truncate(time.Second).After(ims)
+}
+
+// writeNotModified answers 304 with the validator and caching headers of h,
+// if given.
+func writeNotModified(w http.ResponseWriter, h http.Header) {
+	dst := w.Header()
+	for _, k := range []string{"ETag", "Last-Modified", "Cache-Control", "Expires", "Vary", "Content-Location"} {
+		if h != nil {
+			if v := h.Values(k); len(v) > 0 {
+				dst[k] = v
+			}
+		}
+	}
+
+	dst.Del("Content-Type")
+	dst.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,138 @@
+package httpfly
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// BindForm decodes the fields of a URL-encoded or multipart form body into
+// the struct v points to and validates it. Fields are matched by their
+// form tag, e.g. `form:"email"`, or by their name; `form:"-"` skips a
+// field. Strings, bools, numbers, time.Time (RFC 3339), pointers to those
+// and slices of them for repeated fields are supported. Missing fields keep
+// their value.
+func (r *RequestBody) BindForm(v any) error {
+	if r.form == nil {
+		r.form = r.parseForm()
+	}
+
+	if err := decodeForm(r.form, v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+// decodeForm sets the fields of the struct v points to from form.
+func decodeForm(form url.Values, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("httpfly: BindForm needs a pointer to a struct")
+	}
+
+	rv = rv.Elem()
+	t := rv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		values, ok := form[name]
+		if !ok || len(values) == 0 {
+			continue
+		}
+
+		if err := setFormField(rv.Field(i), values); err != nil {
+			return &HTTPError{Status: http.StatusBadRequest, Code: "invalid_form", Message: fmt.Sprintf("form field %q: %v", name, err)}
+		}
+	}
+	return nil
+}
+
+// setFormField parses values into field.
+func setFormField(field reflect.Value, values []string) error {
+	switch field.Kind() {
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(values[0]))
+			return nil
+		}
+
+		s := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setFormValue(s.Index(i), v); err != nil {
+				return err
+			}
+		}
+		field.Set(s)
+		return nil
+
+	case reflect.Pointer:
+		p := reflect.New(field.Type().Elem())
+		if err := setFormValue(p.Elem(), values[0]); err != nil {
+			return err
+		}
+		field.Set(p)
+		return nil
+	}
+
+	return setFormValue(field, values[0])
+}
+
+// setFormValue parses a single value into v.
+func setFormValue(v reflect.Value, s string) error {
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
@@ -1,3 +1,3 @@
 module github.com/burakturkerdev/httpfly
 replace "github.com/burakturkerdev/httpfly" => "../httpfly"
-go 1.22.4
+go 1.24
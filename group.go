@@ -0,0 +1,156 @@
+package httpfly
+
+// RouteGroup maps routes under a shared path prefix, with a default auth
+// requirement and middleware that only run for the group's routes.
+type RouteGroup struct {
+	router      *Router
+	routePrefix string
+	prefix      string
+	auth        AuthRequire
+	middlewares []MiddlewareFunc
+	provider    AuthProvider
+	bulkhead    *bulkhead
+	host        string
+	headers     map[string]string
+}
+
+// GroupOption configures a RouteGroup.
+type GroupOption func(*RouteGroup)
+
+// GroupAuth requires authentication for every route of the group, whatever
+// the auth argument of the individual Map calls.
+func GroupAuth(auth AuthRequire) GroupOption {
+	return func(g *RouteGroup) {
+		g.auth = g.auth || auth
+	}
+}
+
+// GroupMiddleware adds middleware that runs for the routes of the group,
+// after the global middleware.
+func GroupMiddleware(m ...MiddlewareFunc) GroupOption {
+	return func(g *RouteGroup) {
+		g.middlewares = append(g.middlewares, m...)
+	}
+}
+
+// GroupAuthProvider authenticates the routes of the group with p instead of
+// the router's provider, and requires authentication for them.
+func GroupAuthProvider(p AuthProvider) GroupOption {
+	return func(g *RouteGroup) {
+		g.auth = true
+		g.provider = p
+	}
+}
+
+// GroupRoutePrefix maps the routes of the group below routePrefix instead
+// of the route prefix of the router, e.g. "/internal" for a subsystem
+// next to "/api". Nested groups inherit it.
+func GroupRoutePrefix(routePrefix string) GroupOption {
+	return func(g *RouteGroup) {
+		g.routePrefix = routePrefix
+	}
+}
+
+// Group creates a route group of the default router. Its routes are mapped
+// at RoutePrefix + prefix + path, with RoutePrefix as it was when the group
+// was created.
+func Group(prefix string, opts ...GroupOption) *RouteGroup {
+	return defaultRouter.Group(prefix, opts...)
+}
+
+// Group creates a route group of the router. The group keeps the route
+// prefix of the router at this point, so later changes to it do not move
+// the routes of the group apart.
+func (r *Router) Group(prefix string, opts ...GroupOption) *RouteGroup {
+	g := &RouteGroup{router: r, routePrefix: r.options().Prefix, prefix: prefix}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// Group creates a nested group that inherits the prefixes, host, auth
+// requirement, headers and middleware of g.
+func (g *RouteGroup) Group(prefix string, opts ...GroupOption) *RouteGroup {
+	sub := &RouteGroup{
+		router:      g.router,
+		routePrefix: g.routePrefix,
+		prefix:      g.prefix + prefix,
+		auth:        g.auth,
+		middlewares: append([]MiddlewareFunc(nil), g.middlewares...),
+		provider:    g.provider,
+		bulkhead:    g.bulkhead,
+		host:        g.host,
+		headers:     g.headers,
+	}
+
+	for _, opt := range opts {
+		opt(sub)
+	}
+
+	return sub
+}
+
+// add registers a route of the group.
+func (g *RouteGroup) add(method RequestMethod, path string, auth AuthRequire, f Handler, opts []RouteOption) *RouteInfo {
+	middlewares, provider, bulkhead, host, headers := g.middlewares, g.provider, g.bulkhead, g.host, g.headers
+	opts = append([]RouteOption{func(ri *RouteInfo) {
+		if host != "" {
+			WithHost(host)(ri)
+		}
+		if headers != nil {
+			ri.headers = headers
+		}
+		ri.middlewares = append(append([]MiddlewareFunc(nil), middlewares...), ri.middlewares...)
+		if provider != nil {
+			ri.authProvider = provider
+		}
+		if bulkhead != nil {
+			ri.bulkhead = bulkhead
+		}
+	}}, opts...)
+
+	return g.router.addRouteAt(g.routePrefix, method, g.prefix+path, auth || g.auth, f, opts)
+}
+
+// MapGet maps a GET route in the group.
+func (g *RouteGroup) MapGet(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.add(MethodGet, path, auth, f, opts)
+}
+
+// MapPost maps a POST route in the group.
+func (g *RouteGroup) MapPost(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.add(MethodPost, path, auth, f, opts)
+}
+
+// MapPut maps a PUT route in the group.
+func (g *RouteGroup) MapPut(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.add(MethodPut, path, auth, f, opts)
+}
+
+// MapDelete maps a DELETE route in the group.
+func (g *RouteGroup) MapDelete(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.add(MethodDelete, path, auth, f, opts)
+}
+
+// MapPatch maps a PATCH route in the group.
+func (g *RouteGroup) MapPatch(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.add(MethodPatch, path, auth, f, opts)
+}
+
+// MapOptions maps an OPTIONS route in the group.
+func (g *RouteGroup) MapOptions(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.add(MethodOptions, path, auth, f, opts)
+}
+
+// MapHead maps a HEAD route in the group.
+func (g *RouteGroup) MapHead(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.add(MethodHead, path, auth, f, opts)
+}
+
+// Map maps a route for any request method in the group.
+func (g *RouteGroup) Map(method RequestMethod, path string, auth AuthRequire, f Handler, opts ...RouteOption) *RouteInfo {
+	return g.add(method, path, auth, f, opts)
+}
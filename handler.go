@@ -0,0 +1,145 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// HTTPError is an error with the status and body to answer it with.
+type HTTPError struct {
+	Status int `json:"-"`
+	// Code is a stable machine-readable error code, e.g. "user_not_found".
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *HTTPError) Error() string {
+	if e.Code != "" {
+		return e.Code + ": " + e.Message
+	}
+	return e.Message
+}
+
+// ErrorHandlerFunc turns an error returned by a handler into a response.
+type ErrorHandlerFunc func(rb *RequestBody, err error)
+
+// HandleError adapts a handler that returns an error. A non-nil error is
+// passed to the router's error handler, except ErrHandled, which means the
+// handler has already responded, and errors of aborted requests.
+func HandleError(f func(r *RequestBody) error) Handler {
+	return func(r *RequestBody) {
+		if err := f(r); err != nil && !r.aborted {
+			r.router.handleError(r, err)
+		}
+	}
+}
+
+// SetErrorHandler replaces the error handler of the default router. See
+// DefaultErrorHandler for the default mapping.
+func SetErrorHandler(f ErrorHandlerFunc) {
+	defaultRouter.SetErrorHandler(f)
+}
+
+// SetErrorHandler replaces the error handler of the router.
+func (r *Router) SetErrorHandler(f ErrorHandlerFunc) {
+	r.errorHandler = f
+}
+
+// SetNotFoundHandler sets the handler for requests of the default router
+// that match no route.
+func SetNotFoundHandler(h Handler) {
+	defaultRouter.SetNotFoundHandler(h)
+}
+
+// SetNotFoundHandler sets the handler for requests that match no route. It
+// runs without middleware; if it writes nothing, the response is a bare
+// 404.
+func (r *Router) SetNotFoundHandler(h Handler) {
+	r.notFound = h
+}
+
+// SetMethodNotAllowedHandler sets the handler for requests of the default
+// router whose path is mapped only for other methods.
+func SetMethodNotAllowedHandler(h Handler) {
+	defaultRouter.SetMethodNotAllowedHandler(h)
+}
+
+// SetMethodNotAllowedHandler sets the handler for requests whose path is
+// mapped only for other methods. The Allow header is already set when it
+// runs; if it writes nothing, the response is a bare 405.
+func (r *Router) SetMethodNotAllowedHandler(h Handler) {
+	r.methodNotAllowed = h
+}
+
+// handleError passes err to the router's error handler.
+func (r *Router) handleError(rb *RequestBody, err error) {
+	if errors.Is(err, ErrHandled) {
+		return
+	}
+
+	rb.err = err
+
+	if span := SpanFromContext(rb.Context()); span != nil {
+		span.RecordError(err)
+	}
+
+	if r != nil && r.errorHandler != nil {
+		r.errorHandler(rb, err)
+		return
+	}
+
+	DefaultErrorHandler(rb, err)
+}
+
+// DefaultErrorHandler answers *HTTPError with its status and a JSON body,
+// ValidationErrors with 422 and the failing fields, and any other error with
+// a logged 500 Internal Server Error.
+func DefaultErrorHandler(rb *RequestBody, err error) {
+	var httpErr *HTTPError
+	var verrs ValidationErrors
+	var problem *Problem
+
+	if rb.router != nil && rb.router.problems {
+		if p := problemFor(err); p != nil {
+			if p.Instance == "" && rb.req != nil {
+				p.Instance = rb.req.URL.Path
+			}
+			writeProblem(rb.ResponseW, p)
+			return
+		}
+	}
+
+	switch {
+	case errors.As(err, &problem):
+		writeProblem(rb.ResponseW, problem)
+
+	case errors.As(err, &verrs):
+		writeJSONError(rb.ResponseW, http.StatusUnprocessableEntity, validationErrorBody{Error: "validation failed", Fields: verrs})
+
+	case errors.As(err, &httpErr):
+		writeJSONError(rb.ResponseW, httpErr.Status, httpErr)
+
+	default:
+		var logger Logger = slogDefault{}
+		production := Production
+		if rb.router != nil {
+			logger, production = rb.router.logger, rb.router.options().Production
+		}
+
+		w, ok := rb.ResponseW.(*ResponseRecorder)
+		if !ok {
+			w = &ResponseRecorder{ResponseWriter: rb.ResponseW}
+		}
+		internalError(logger, w, rb.req, err, nil, production, rb.router != nil && rb.router.problems)
+	}
+}
+
+// writeJSONError writes body as a JSON error response.
+func writeJSONError(w http.ResponseWriter, status int, body any) {
+	out, _ := json.Marshal(body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(out)
+}
@@ -0,0 +1,32 @@
+package httpfly
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrHandledWritesNothingMore(t *testing.T) {
+	r := NewRouter()
+	called := false
+	r.SetErrorHandler(func(rb *RequestBody, err error) { called = true })
+	r.MapGet("/done", NoAuth, HandleError(func(rb *RequestBody) error {
+		rb.Text(http.StatusAccepted, "queued")
+		return ErrHandled
+	}))
+	r.MapGet("/wrapped", NoAuth, HandleError(func(rb *RequestBody) error {
+		rb.Text(http.StatusAccepted, "queued")
+		return fmt.Errorf("enqueue: %w", ErrHandled)
+	}))
+
+	c := NewTestClient(r)
+	for _, path := range []string{"/api/done", "/api/wrapped"} {
+		res := c.Get(path)
+		if res.Status != http.StatusAccepted || res.String() != "queued" {
+			t.Errorf("%s: status %d, body %q; want only the handler's response", path, res.Status, res.String())
+		}
+	}
+	if called {
+		t.Error("error handler ran for ErrHandled")
+	}
+}
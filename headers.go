@@ -0,0 +1,21 @@
+package httpfly
+
+import "net/http"
+
+// MaxHeaderBytes is the maximum size of the request line and headers for the
+// default router. Larger requests are answered with 431 Request Header
+// Fields Too Large.
+var MaxHeaderBytes = http.DefaultMaxHeaderBytes
+
+// headerSize approximates the wire size of the request line and headers.
+func headerSize(req *http.Request) int {
+	size := len(req.Method) + len(req.RequestURI) + len(req.Proto) + 4
+
+	for k, vs := range req.Header {
+		for _, v := range vs {
+			size += len(k) + len(v) + 4
+		}
+	}
+
+	return size
+}
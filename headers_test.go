@@ -0,0 +1,29 @@
+package httpfly
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOversizedHeaders(t *testing.T) {
+	r := NewRouter()
+	r.MaxHeaderBytes = 1 << 10
+	r.MapGet("/x", NoAuth, func(rb *RequestBody) {})
+
+	c := NewTestClient(r)
+	if res := c.Get("/api/x"); res.Status != http.StatusOK {
+		t.Fatalf("small headers: status = %d, want 200", res.Status)
+	}
+
+	c.Header.Set("X-Big", strings.Repeat("a", 1<<10))
+	res := c.Get("/api/x")
+	if res.Status != http.StatusRequestHeaderFieldsTooLarge {
+		t.Fatalf("oversized headers: status = %d, want 431", res.Status)
+	}
+
+	var body HTTPError
+	if err := res.JSON(&body); err != nil || body.Message == "" {
+		t.Errorf("body = %s, want an error message", res.String())
+	}
+}
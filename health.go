@@ -0,0 +1,148 @@
+package httpfly
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheck probes a dependency, e.g. by pinging a database. It should
+// return promptly once ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+// HealthConfig configures the health endpoints. Zero fields take their
+// defaults.
+type HealthConfig struct {
+	// LivePath defaults to "/healthz".
+	LivePath string
+	// ReadyPath defaults to "/readyz".
+	ReadyPath string
+	// Timeout bounds each check and defaults to 2 seconds.
+	Timeout time.Duration
+	// Checks are run by the readiness endpoint, keyed by name.
+	Checks map[string]HealthCheck
+}
+
+type healthReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type healthState struct {
+	mu      sync.RWMutex
+	checks  map[string]HealthCheck
+	timeout time.Duration
+}
+
+// UseHealthChecks maps the health endpoints on the default router. See
+// Router.UseHealthChecks.
+func UseHealthChecks(cfg HealthConfig) {
+	defaultRouter.UseHealthChecks(cfg)
+}
+
+// UseHealthChecks maps a liveness endpoint that answers 200 while the
+// process serves, and a readiness endpoint that runs every check
+// concurrently and answers 200 when all pass, or 503 when one fails, times
+// out, or the server is shutting down. Both report JSON such as
+// {"status":"fail","checks":{"db":"connection refused"}}.
+func (r *Router) UseHealthChecks(cfg HealthConfig) {
+	if cfg.LivePath == "" {
+		cfg.LivePath = "/healthz"
+	}
+	if cfg.ReadyPath == "" {
+		cfg.ReadyPath = "/readyz"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+
+	state := &healthState{checks: map[string]HealthCheck{}, timeout: cfg.Timeout}
+	for name, check := range cfg.Checks {
+		state.checks[name] = check
+	}
+	r.health = state
+
+	r.MapGet(cfg.LivePath, NoAuth, func(rb *RequestBody) {
+		rb.JSON(http.StatusOK, healthReport{Status: "ok"})
+	})
+
+	r.MapGet(cfg.ReadyPath, NoAuth, func(rb *RequestBody) {
+		if r.draining.Load() {
+			rb.JSON(http.StatusServiceUnavailable, healthReport{Status: "shutting down"})
+			return
+		}
+
+		report := state.run(rb.Context())
+		status := http.StatusOK
+		if report.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+		rb.JSON(status, report)
+	})
+}
+
+// AddHealthCheck adds a readiness check to the default router.
+func AddHealthCheck(name string, check HealthCheck) {
+	defaultRouter.AddHealthCheck(name, check)
+}
+
+// AddHealthCheck adds a readiness check. It panics if UseHealthChecks has
+// not been called.
+func (r *Router) AddHealthCheck(name string, check HealthCheck) {
+	if r.health == nil {
+		panic("httpfly: AddHealthCheck called before UseHealthChecks")
+	}
+
+	r.health.mu.Lock()
+	defer r.health.mu.Unlock()
+
+	r.health.checks[name] = check
+}
+
+// run runs all checks concurrently.
+func (s *healthState) run(ctx context.Context) healthReport {
+	s.mu.RLock()
+	checks := make(map[string]HealthCheck, len(s.checks))
+	for name, check := range s.checks {
+		checks[name] = check
+	}
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	report := healthReport{Status: "ok", Checks: map[string]string{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			errc := make(chan error, 1)
+			go func() { errc <- check(ctx) }()
+
+			var err error
+			select {
+			case err = <-errc:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				report.Status = "fail"
+				report.Checks[name] = err.Error()
+			} else {
+				report.Checks[name] = "ok"
+			}
+		}()
+	}
+
+	wg.Wait()
+	return report
+}
@@ -0,0 +1,46 @@
+package httpfly
+
+import "time"
+
+// AfterResponseFunc observes the outcome of a request. The error that ended
+// the request, if any, is available through RequestBody.Err and what was
+// written through RequestBody.Recorder.
+type AfterResponseFunc func(rb *RequestBody, status int, duration time.Duration)
+
+// AfterResponse registers a hook that runs once every request has been
+// handled, including requests ended by middleware, rejected by the router or
+// whose handler panicked. A panicking request is reported with status 500
+// unless a status had already been written.
+func AfterResponse(f AfterResponseFunc) {
+	defaultRouter.AfterResponse(f)
+}
+
+// AfterResponse registers a hook that runs once every request of the router
+// has been handled.
+func (r *Router) AfterResponse(f AfterResponseFunc) {
+	r.afterResponseHooks = append(r.afterResponseHooks, f)
+}
+
+// OnFinish registers f to run once the request has been handled, after the
+// after-response hooks of the router, e.g. to release resources acquired by
+// middleware or to log the outcome of a single route.
+func (r *RequestBody) OnFinish(f AfterResponseFunc) {
+	r.finish = append(r.finish, f)
+}
+
+// Err returns the error that was handed to the error handler or the panic
+// that was recovered while handling the request, or nil.
+func (r *RequestBody) Err() error {
+	return r.err
+}
+
+// runAfterResponse calls the after-response hooks in registration order,
+// then the OnFinish functions of the request.
+func (r *Router) runAfterResponse(rb *RequestBody, status int, duration time.Duration) {
+	for _, f := range r.afterResponseHooks {
+		f(rb, status, duration)
+	}
+	for _, f := range rb.finish {
+		f(rb, status, duration)
+	}
+}
@@ -0,0 +1,51 @@
+package httpfly
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAfterResponsePaths(t *testing.T) {
+	type outcome struct {
+		status  int
+		aborted bool
+		err     bool
+		finish  bool
+	}
+	seen := map[string]*outcome{}
+
+	r := NewRouter()
+	r.AfterResponse(func(rb *RequestBody, status int, duration time.Duration) {
+		seen[rb.Request().URL.Path] = &outcome{status: status, aborted: rb.Aborted(), err: rb.Err() != nil}
+	})
+	r.AddMiddleware(func(rb *RequestBody, w http.ResponseWriter, req *http.Request) {
+		rb.OnFinish(func(rb *RequestBody, status int, duration time.Duration) {
+			seen[req.URL.Path].finish = true
+		})
+		if req.URL.Path == "/api/aborted" {
+			rb.Abort(http.StatusForbidden, "")
+		}
+	})
+	r.MapGet("/ok", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusCreated, "ok") })
+	r.MapGet("/aborted", NoAuth, func(rb *RequestBody) { t.Error("aborted handler ran") })
+	r.MapGet("/panic", NoAuth, func(rb *RequestBody) { panic("boom") })
+
+	c := NewTestClient(r)
+	want := map[string]outcome{
+		"/api/ok":      {http.StatusCreated, false, false, true},
+		"/api/aborted": {http.StatusForbidden, true, true, true},
+		"/api/panic":   {http.StatusInternalServerError, false, true, true},
+	}
+	for path, w := range want {
+		c.Get(path)
+		got := seen[path]
+		if got == nil {
+			t.Errorf("%s: hook did not run", path)
+			continue
+		}
+		if *got != w {
+			t.Errorf("%s: got %+v, want %+v", path, *got, w)
+		}
+	}
+}
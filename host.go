@@ -0,0 +1,79 @@
+package httpfly
+
+import (
+	"net"
+	"strings"
+)
+
+// Host creates a route group of the default router restricted to requests
+// for hosts matching pattern. See Router.Host.
+func Host(pattern string, opts ...GroupOption) *RouteGroup {
+	return defaultRouter.Host(pattern, opts...)
+}
+
+// Host creates a route group whose routes only serve requests for hosts
+// matching pattern, such as "api.example.com" or "{tenant}.example.com".
+// Labels in braces capture the label of the request host into Params, so
+// Host("{tenant}.example.com").MapGet(...) serves every tenant subdomain
+// and RequestBody.Tenant returns it. Hosts compare case-insensitively and
+// without the port. For the same path and method, a route restricted to a
+// host is preferred over one mapped for any host.
+func (r *Router) Host(pattern string, opts ...GroupOption) *RouteGroup {
+	g := r.Group("", opts...)
+	g.host = pattern
+	return g
+}
+
+// WithHost restricts a route to requests for hosts matching pattern. See
+// Router.Host.
+func WithHost(pattern string) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.Host = pattern
+		ri.hostLabels = strings.Split(pattern, ".")
+	}
+}
+
+// Tenant returns the "tenant" parameter captured from the host, as in
+// Host("{tenant}.example.com"), or "".
+func (r *RequestBody) Tenant() string {
+	return string(r.Params["tenant"])
+}
+
+// hostParams reports whether host matches the host pattern of the route,
+// storing the captured labels in params unless it is nil. Routes for any
+// host match every host.
+func (ri *RouteInfo) hostParams(host string, params Parameters) bool {
+	if ri.Host == "" {
+		return true
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(host, ".")), ".")
+	if len(labels) != len(ri.hostLabels) {
+		return false
+	}
+
+	for i, p := range ri.hostLabels {
+		if name, ok := strings.CutPrefix(p, "{"); ok && strings.HasSuffix(name, "}") {
+			if labels[i] == "" {
+				return false
+			}
+			continue
+		}
+		if !strings.EqualFold(p, labels[i]) {
+			return false
+		}
+	}
+
+	if params != nil {
+		for i, p := range ri.hostLabels {
+			if name, ok := strings.CutPrefix(p, "{"); ok && strings.HasSuffix(name, "}") {
+				params[strings.TrimSuffix(name, "}")] = []byte(labels[i])
+			}
+		}
+	}
+
+	return true
+}
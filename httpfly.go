@@ -1,21 +1,60 @@
 package httpfly
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
 )
 
-// RoutePrefix is the prefix for all routes.
-var RoutePrefix = "/api"
+// RoutePrefix is the prefix for all routes of the default router. It is
+// read when a route or group is mapped, so it should be set before; groups
+// keep the value they were created with. Other routers use their Prefix
+// option, and GroupRoutePrefix gives a group its own.
+var RoutePrefix = DefaultRoutePrefix
+
+// BufferResponses makes handlers of the default router write into an
+// in-memory buffer that is flushed after the handler returns, so the status
+// and headers can still be changed after the body has been written.
+var BufferResponses = false
 
 // MiddlewareFunc defines the type for middleware functions.
 type MiddlewareFunc func(rb *RequestBody, response http.ResponseWriter, request *http.Request)
 
-var middlewares []MiddlewareFunc
+// DefaultMiddlewarePriority is the priority used by AddMiddleware.
+const DefaultMiddlewarePriority = 0
+
+type middlewareEntry struct {
+	priority int
+	f        MiddlewareFunc
+}
 
 // AddMiddleware adds a new middleware to the handler.
 func AddMiddleware(f MiddlewareFunc) {
-	middlewares = append(middlewares, f)
+	defaultRouter.AddMiddleware(f)
+}
+
+// AddMiddlewarePriority adds a new middleware with the given priority. Lower
+// priorities run earlier, regardless of registration order.
+func AddMiddlewarePriority(priority int, f MiddlewareFunc) {
+	defaultRouter.AddMiddlewarePriority(priority, f)
+}
+
+// ChainMiddleware wraps the handling of a request. It calls next to continue
+// with the remaining middleware and the handler, and can skip it to end the
+// request or run code after it returns.
+type ChainMiddleware func(rb *RequestBody, next Handler)
+
+// Use adds chain middleware. Chain middleware runs in registration order,
+// after authentication and before any MiddlewareFunc.
+func Use(m ...ChainMiddleware) {
+	defaultRouter.Use(m...)
 }
 
 // AuthRequire defines whether authentication is required for a route.
@@ -34,125 +73,189 @@ type RouteInfo struct {
 	Method       RequestMethod
 	AuthRequired bool
 	HandlerF     Handler
+	Meta         RouteMeta
+	// Source is the file:line of the Map call that registered the route.
+	Source string
+	// Version is the API version served by the route, empty for the
+	// default route.
+	Version string
+	// Host is the host pattern the route is restricted to, such as
+	// "{tenant}.example.com", empty for any host.
+	Host string
+
+	segments    []string
+	hostLabels  []string
+	body        bodyPolicy
+	maxBody     int64
+	streamBody  bool
+	mediaTypes  []string
+	middlewares []MiddlewareFunc
+	cors        *CORSConfig
+	secure      *SecureConfig
+	headers     map[string]string
+	sanitize    *SanitizeConfig
+	name        string
+	timeout     time.Duration
+	cacheTTL    time.Duration
+	audited     bool
+	noAutoHead  bool
+	bulkhead    *bulkhead
+
+	versionPolicy *versionPolicy
+
+	authProvider AuthProvider
+	roles        []string
 }
 
-// Routers
-var routes []*RouteInfo
-
-// MapGet maps a GET route.
-func MapGet(path string, auth AuthRequire, f func(r *RequestBody)) {
-	routes = append(routes, &RouteInfo{RoutePrefix + path, get, bool(auth), f})
+// RouteMeta holds descriptive information about a route used by docs.
+type RouteMeta struct {
+	Examples []Example
+	Doc      RouteDoc
 }
 
-// MapPost maps a POST route.
-func MapPost(path string, auth AuthRequire, f func(r *RequestBody)) {
-	routes = append(routes, &RouteInfo{RoutePrefix + path, post, bool(auth), f})
+// Example is a sample request/response pair attached to a route.
+type Example struct {
+	Name     string
+	Request  any
+	Response any
 }
 
-// MapPut maps a PUT route.
-func MapPut(path string, auth AuthRequire, f func(r *RequestBody)) {
-	routes = append(routes, &RouteInfo{RoutePrefix + path, put, bool(auth), f})
-}
+// RouteOption configures a route at registration.
+type RouteOption func(*RouteInfo)
 
-// MapDelete maps a DELETE route.
-func MapDelete(path string, auth AuthRequire, f func(r *RequestBody)) {
-	routes = append(routes, &RouteInfo{RoutePrefix + path, delete, bool(auth), f})
+// WithExample attaches an example request and response payload to a route.
+func WithExample(name string, request, response any) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.Meta.Examples = append(ri.Meta.Examples, Example{name, request, response})
+	}
 }
 
-// StartHTTPServer starts the HTTP server.
-func StartHTTPServer(listen string) {
-	http.HandleFunc("/", handle)
-	http.ListenAndServe(listen, nil)
+// WithMiddleware attaches middleware to a single route. It runs after the
+// global and group middleware, in the given order.
+func WithMiddleware(m ...MiddlewareFunc) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.middlewares = append(ri.middlewares, m...)
+	}
 }
 
-// StartHTTPServerTLS starts the HTTPS server.
-func StartHTTPServerTLS(listen string, certFile string, keyFile string) {
-	http.HandleFunc("/", handle)
-	http.ListenAndServeTLS(listen, certFile, keyFile, nil)
-}
+// newRoute builds a route and applies its options.
+func newRoute(prefix string, method RequestMethod, path string, auth AuthRequire, f Handler, opts []RouteOption) *RouteInfo {
+	ri := &RouteInfo{Endpoint: prefix + path, Method: method, AuthRequired: bool(auth), HandlerF: f}
+	ri.Source = callerLocation()
+	ri.segments = strings.Split(ri.Endpoint, "/")
 
-func handle(resw http.ResponseWriter, req *http.Request) {
-	for _, v := range routes {
-		if req.URL.Path == v.Endpoint {
-			if req.Method != string(v.Method) {
-				resw.WriteHeader(http.StatusNotFound)
-				return
-			}
+	for _, opt := range opts {
+		opt(ri)
+	}
 
-			rqbody := &RequestBody{}
+	return ri
+}
 
-			params, err := extractParams(req.URL.Path, v.Endpoint)
+// callerLocation returns the file:line of the first caller outside of this
+// package's sources, i.e. the user code that registered a route.
+func callerLocation() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
 
-			if err != nil {
-				resw.WriteHeader(http.StatusBadRequest)
-				resw.Write([]byte(err.Error()))
-				return
-			}
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePath+".") || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
 
-			rqbody.Params = Parameters(params)
+// packagePath is the import path of this package.
+const packagePath = "github.com/burakturkerdev/httpfly"
 
-			req.Body.Read(rqbody.JsonData)
+// MapGet maps a GET route.
+func MapGet(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapGet(path, auth, f, opts...)
+}
 
-			for _, m := range middlewares {
-				m(rqbody, resw, req)
-			}
+// MapPost maps a POST route.
+func MapPost(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapPost(path, auth, f, opts...)
+}
 
-			rqbody.ResponseW = resw
+// MapPut maps a PUT route.
+func MapPut(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapPut(path, auth, f, opts...)
+}
 
-			v.HandlerF(rqbody)
-			return
-		}
-	}
+// MapDelete maps a DELETE route.
+func MapDelete(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapDelete(path, auth, f, opts...)
+}
 
-	// If no matching route is found, return 404
-	resw.WriteHeader(http.StatusNotFound)
+// MapPatch maps a PATCH route.
+func MapPatch(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapPatch(path, auth, f, opts...)
 }
 
-// extractParams extracts parameters from the URL path.
-func extractParams(path string, locPath string) (map[string][]byte, error) {
-	result := map[string][]byte{}
+// MapOptions maps an OPTIONS route.
+func MapOptions(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapOptions(path, auth, f, opts...)
+}
 
-	p := paramExtractAlg(path)
-	lp := paramExtractAlg(locPath)
+// MapHead maps a HEAD route.
+func MapHead(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapHead(path, auth, f, opts...)
+}
 
-	if len(p) != len(lp) {
-		return nil, errors.New("invalid URL params")
-	}
+// Map maps a route for any request method, including nonstandard ones.
+func Map(method RequestMethod, path string, auth AuthRequire, f Handler, opts ...RouteOption) *RouteInfo {
+	return defaultRouter.Map(method, path, auth, f, opts...)
+}
 
-	for i := 0; i < len(p); i++ {
-		result[lp[i]] = []byte(p[i])
-	}
+// StartHTTPServer runs the startup hooks and starts the HTTP server.
+func StartHTTPServer(listen string) error {
+	return defaultRouter.Start(listen)
+}
 
-	return result, nil
+// StartHTTPServerTLS runs the startup hooks and starts the HTTPS server.
+func StartHTTPServerTLS(listen string, certFile string, keyFile string) error {
+	return defaultRouter.StartTLS(listen, certFile, keyFile)
 }
 
-// paramExtractAlg extracts parameters from a path.
-func paramExtractAlg(input string) []string {
-	var res []string
-	var buildStr []rune
+// StartHTTPServerWithConfig runs the startup hooks and starts the HTTP
+// server with the given protocol settings, e.g. ServerConfig{EnableH2C: true}.
+func StartHTTPServerWithConfig(listen string, cfg ServerConfig) error {
+	s := NewServer(listen, defaultRouter)
+	s.ServerConfig = cfg
+	return s.Start(context.Background())
+}
 
-	for _, c := range input {
-		switch c {
-		case '{':
-			buildStr = nil
-		case '}':
-			res = append(res, string(buildStr))
-		default:
-			buildStr = append(buildStr, c)
-		}
-	}
+// Serve runs the startup hooks and serves the default router on an existing
+// listener, e.g. from ActivatedListeners.
+func Serve(l net.Listener) error {
+	return defaultRouter.Serve(l)
+}
 
-	return res
+// HTTPHandler returns the default router as an http.Handler, to plug it
+// into an existing http.Server, a test harness or middleware of other
+// libraries. Startup hooks only run by themselves in the Start and Serve
+// functions; call RunStartupHooks before serving the handler otherwise.
+func HTTPHandler() http.Handler {
+	return defaultRouter
 }
 
 // RequestMethod represents an HTTP request method.
 type RequestMethod string
 
+// Common request methods. Any other method can be used by converting its
+// name, e.g. RequestMethod("PURGE"), or checked with NewRequestMethod.
 const (
-	get    RequestMethod = "GET"
-	post   RequestMethod = "POST"
-	put    RequestMethod = "PUT"
-	delete RequestMethod = "DELETE"
+	MethodGet     RequestMethod = "GET"
+	MethodPost    RequestMethod = "POST"
+	MethodPut     RequestMethod = "PUT"
+	MethodDelete  RequestMethod = "DELETE"
+	MethodPatch   RequestMethod = "PATCH"
+	MethodOptions RequestMethod = "OPTIONS"
+	MethodHead    RequestMethod = "HEAD"
 )
 
 // Parameters represents parameters extracted from a request.
@@ -162,8 +265,26 @@ type Parameters map[string][]byte
 type RequestBody struct {
 	JsonData  []byte
 	Params    Parameters
-	Claims    map[string]string
+	Claims    Claims
 	ResponseW http.ResponseWriter
+
+	req    *http.Request
+	router *Router
+	values map[any]any
+	locals map[string]any
+	form   url.Values
+	done   []func()
+	route  *RouteInfo
+	w      *ResponseRecorder
+
+	multipart *multipart.Form
+	deferred  []Task
+	finish    []AfterResponseFunc
+	err       error
+	locale    string
+	stream    io.Reader
+	raw       []byte
+	aborted   bool
 }
 
 // Handler defines the type for request handlers.
@@ -0,0 +1,256 @@
+// Package httpflytest generates contract tests for the documented routes of
+// an httpfly router. Routes whose request or response types are known,
+// through MapTyped or WithDoc, are exercised in memory with a valid
+// payload, with each required field missing and with a method they are not
+// mapped for.
+//
+//	func TestContract(t *testing.T) {
+//		r := httpfly.NewRouter()
+//		registerRoutes(r)
+//		httpflytest.Contract(t, r, httpflytest.Config{})
+//	}
+package httpflytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/burakturkerdev/httpfly"
+)
+
+// Config configures Contract.
+type Config struct {
+	// Header is sent with every request, e.g. an Authorization header for
+	// routes that require authentication.
+	Header http.Header
+	// PathParams gives values for path parameters by name. Other
+	// parameters get a value matching their type, such as "1" for {id:int}.
+	PathParams map[string]string
+	// Skip excludes routes from the tests.
+	Skip func(ri httpfly.RouteInfo) bool
+}
+
+// probeMethods are tried, in order, for the wrong method check.
+var probeMethods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodPost, http.MethodGet}
+
+// Contract runs a subtest for every route of r that documents a request or
+// response type:
+//
+//   - "valid payload" sends an example request, from WithExample or
+//     generated from the request type to satisfy its validate tags, and
+//     expects a documented status, or any 2xx status if none is, whose body
+//     decodes into the documented response type;
+//   - "missing <field>" sends the payload without each required field and
+//     expects 422 Unprocessable Entity;
+//   - "wrong method" sends a method the path is not mapped for and expects
+//     405 Method Not Allowed.
+//
+// Routes restricted to a host are skipped.
+func Contract(t *testing.T, r *httpfly.Router, cfg Config) {
+	t.Helper()
+
+	routes := r.Routes()
+
+	methods := map[string]map[string]bool{}
+	for _, ri := range routes {
+		if methods[ri.Endpoint] == nil {
+			methods[ri.Endpoint] = map[string]bool{}
+		}
+		methods[ri.Endpoint][string(ri.Method)] = true
+		if ri.Method == httpfly.MethodGet {
+			methods[ri.Endpoint][http.MethodHead] = true
+		}
+	}
+
+	for _, ri := range routes {
+		doc := ri.Meta.Doc
+		if doc.Request == nil && len(doc.Responses) == 0 {
+			continue
+		}
+		if cfg.Skip != nil && cfg.Skip(ri) {
+			continue
+		}
+
+		t.Run(string(ri.Method)+" "+ri.Endpoint, func(t *testing.T) {
+			if ri.Host != "" {
+				t.Skip("route is restricted to host " + ri.Host)
+			}
+
+			c := contract{router: r, cfg: cfg, route: ri, path: concretePath(ri.Endpoint, cfg.PathParams)}
+			c.run(t, methods[ri.Endpoint])
+		})
+	}
+}
+
+// contract tests a single route.
+type contract struct {
+	router *httpfly.Router
+	cfg    Config
+	route  httpfly.RouteInfo
+	path   string
+}
+
+func (c contract) run(t *testing.T, mapped map[string]bool) {
+	var payload any
+	if req := c.route.Meta.Doc.Request; req != nil {
+		var err error
+		if payload, err = examplePayload(c.route); err != nil {
+			t.Fatalf("cannot build a valid payload, add one with WithExample: %v", err)
+		}
+	}
+
+	t.Run("valid payload", func(t *testing.T) {
+		resp := c.send(string(c.route.Method), payload)
+		c.checkResponse(t, resp)
+	})
+
+	if payload != nil {
+		for _, field := range requiredFields(c.route.Meta.Doc.Request) {
+			t.Run("missing "+field, func(t *testing.T) {
+				body, ok := without(payload, field)
+				if !ok {
+					t.Skip("payload is not a JSON object")
+				}
+
+				resp := c.send(string(c.route.Method), body)
+				if resp.Code != http.StatusUnprocessableEntity {
+					t.Errorf("status = %d, want %d; body: %s", resp.Code, http.StatusUnprocessableEntity, resp.Body)
+				}
+			})
+		}
+	}
+
+	t.Run("wrong method", func(t *testing.T) {
+		for _, m := range probeMethods {
+			if mapped[m] {
+				continue
+			}
+
+			resp := c.send(m, nil)
+			if resp.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s: status = %d, want %d", m, resp.Code, http.StatusMethodNotAllowed)
+			}
+			return
+		}
+		t.Skip("path is mapped for every method")
+	})
+}
+
+// send sends a request to the route with body encoded as JSON.
+func (c contract) send(method string, body any) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	req := httptest.NewRequest(method, c.path, bytes.NewReader(data))
+	for k, v := range c.cfg.Header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// checkResponse checks the status and body of a response to a valid
+// request against the documented responses.
+func (c contract) checkResponse(t *testing.T, resp *httptest.ResponseRecorder) {
+	documented := c.route.Meta.Doc.Responses
+
+	sample, ok := documented[resp.Code]
+	if len(documented) > 0 && !ok {
+		t.Fatalf("status %d is not documented; body: %s", resp.Code, resp.Body)
+	}
+	if len(documented) == 0 && (resp.Code < 200 || resp.Code > 299) {
+		t.Fatalf("status = %d, want 2xx; body: %s", resp.Code, resp.Body)
+	}
+
+	if sample == nil || resp.Body.Len() == 0 {
+		return
+	}
+
+	v := newOf(sample)
+	if err := json.Unmarshal(resp.Body.Bytes(), v); err != nil {
+		t.Errorf("body does not decode into %T: %v; body: %s", sample, err, resp.Body)
+	}
+}
+
+// concretePath fills the parameters of a route pattern with values.
+func concretePath(endpoint string, values map[string]string) string {
+	segments := strings.Split(endpoint, "/")
+
+	for i, s := range segments {
+		switch {
+		case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}"):
+			name, typ, _ := strings.Cut(s[1:len(s)-1], ":")
+			if v, ok := values[name]; ok {
+				segments[i] = v
+			} else {
+				segments[i] = paramValue(typ)
+			}
+
+		case len(s) > 1 && s[0] == '*':
+			if v, ok := values[s[1:]]; ok {
+				segments[i] = v
+			} else {
+				segments[i] = "test"
+			}
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// paramValue returns a value satisfying a path parameter type.
+func paramValue(typ string) string {
+	switch typ {
+	case "int", "uint":
+		return "1"
+	case "bool":
+		return "true"
+	case "uuid":
+		return "00000000-0000-4000-8000-000000000001"
+	}
+	return "test"
+}
+
+// without returns the JSON object of payload without field.
+func without(payload any, field string) (map[string]any, bool) {
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false
+	}
+
+	var obj map[string]any
+	if json.Unmarshal(out, &obj) != nil {
+		return nil, false
+	}
+
+	delete(obj, field)
+	return obj, true
+}
+
+// examplePayload returns the first request example of the route, or a
+// generated valid value of its request type.
+func examplePayload(ri httpfly.RouteInfo) (any, error) {
+	for _, ex := range ri.Meta.Examples {
+		if ex.Request != nil {
+			return ex.Request, nil
+		}
+	}
+
+	v := generate(ri.Meta.Doc.Request)
+	if err := httpfly.Validate(v); err != nil {
+		return nil, fmt.Errorf("generated %T: %w", ri.Meta.Doc.Request, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,105 @@
+package httpflytest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/burakturkerdev/httpfly"
+)
+
+type newUser struct {
+	Name  string   `json:"name" validate:"required,min=6"`
+	Email string   `json:"email" validate:"required,email"`
+	Role  string   `json:"role" validate:"oneof=admin member"`
+	Age   int      `json:"age" validate:"min=18,max=130"`
+	Tags  []string `json:"tags" validate:"min=2"`
+	Note  string   `json:"-"`
+}
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestContractOnConformingRoutes(t *testing.T) {
+	var created []newUser
+
+	r := httpfly.NewRouter()
+	httpfly.MapTyped(r, httpfly.MethodPost, "/orgs/{org:int}/users", httpfly.NoAuth, func(rb *httpfly.RequestBody, body newUser) error {
+		if rb.Header("Authorization") != "Bearer t" || string(rb.Params["org"]) != "42" {
+			return &httpfly.HTTPError{Status: http.StatusForbidden, Message: "forbidden"}
+		}
+		created = append(created, body)
+		return rb.JSON(http.StatusCreated, user{ID: 1, Name: body.Name})
+	}, httpfly.WithDoc(httpfly.RouteDoc{Responses: map[int]any{http.StatusCreated: user{}}}))
+	r.MapGet("/users/{id}", httpfly.NoAuth, func(rb *httpfly.RequestBody) {
+		rb.JSON(http.StatusOK, user{ID: 1})
+	}, httpfly.WithDoc(httpfly.RouteDoc{Responses: map[int]any{http.StatusOK: user{}}}))
+	r.MapGet("/undocumented", httpfly.NoAuth, func(rb *httpfly.RequestBody) {
+		t.Error("undocumented route was exercised")
+	})
+	r.MapGet("/skipped", httpfly.NoAuth, func(rb *httpfly.RequestBody) {
+		t.Error("skipped route was exercised")
+	}, httpfly.WithDoc(httpfly.RouteDoc{Responses: map[int]any{http.StatusOK: nil}}))
+
+	Contract(t, r, Config{
+		Header:     http.Header{"Authorization": {"Bearer t"}},
+		PathParams: map[string]string{"org": "42"},
+		Skip:       func(ri httpfly.RouteInfo) bool { return ri.Endpoint == "/api/skipped" },
+	})
+
+	if len(created) != 1 {
+		t.Fatalf("handler created %d users, want only the valid payload", len(created))
+	}
+	if err := httpfly.Validate(&created[0]); err != nil {
+		t.Errorf("generated payload %+v is invalid: %v", created[0], err)
+	}
+}
+
+func TestContractPrefersExamples(t *testing.T) {
+	var got newUser
+
+	r := httpfly.NewRouter()
+	example := newUser{Name: "example", Email: "e@example.com", Role: "admin", Age: 40, Tags: []string{"a", "b"}}
+	httpfly.MapTyped(r, httpfly.MethodPost, "/users", httpfly.NoAuth, func(rb *httpfly.RequestBody, body newUser) error {
+		got = body
+		rb.NoContent(http.StatusNoContent)
+		return nil
+	}, httpfly.WithExample("alice", example, nil))
+
+	Contract(t, r, Config{})
+
+	if got.Name != "example" || got.Age != 40 {
+		t.Errorf("valid payload = %+v, want the attached example", got)
+	}
+}
+
+func TestRequiredFields(t *testing.T) {
+	got := requiredFields(&newUser{})
+	if len(got) != 2 || got[0] != "name" || got[1] != "email" {
+		t.Errorf("required fields = %v, want [name email]", got)
+	}
+	if got := requiredFields("text"); got != nil {
+		t.Errorf("required fields of a string = %v, want none", got)
+	}
+}
+
+func TestConcretePath(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		values   map[string]string
+		want     string
+	}{
+		{"/api/users/{id:int}", nil, "/api/users/1"},
+		{"/api/users/{id}", map[string]string{"id": "alice"}, "/api/users/alice"},
+		{"/api/flags/{on:bool}/{ref:uuid}", nil, "/api/flags/true/00000000-0000-4000-8000-000000000001"},
+		{"/api/files/*path", nil, "/api/files/test"},
+		{"/api/files/*path", map[string]string{"path": "a/b.txt"}, "/api/files/a/b.txt"},
+	}
+
+	for _, tt := range tests {
+		if got := concretePath(tt.endpoint, tt.values); got != tt.want {
+			t.Errorf("concretePath(%q) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
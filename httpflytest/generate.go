@@ -0,0 +1,233 @@
+package httpflytest
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDepth bounds the nesting of generated values.
+const maxDepth = 8
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// newOf returns a pointer to a new zero value of the type of sample.
+func newOf(sample any) any {
+	t := reflect.TypeOf(sample)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return reflect.New(t).Interface()
+}
+
+// generate returns a pointer to a value of the type of sample whose fields
+// satisfy their validate tags, as far as the built-in rules go.
+func generate(sample any) any {
+	t := reflect.TypeOf(sample)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	v := reflect.New(t)
+	fill(v.Elem(), "", 0)
+	return v.Interface()
+}
+
+// requiredFields returns the JSON names of the top-level fields of the type
+// of sample that are tagged required.
+func requiredFields(sample any) []string {
+	t := reflect.TypeOf(sample)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		rules := parseRules(f.Tag.Get("validate"))
+		if _, ok := rules["required"]; ok {
+			if name := jsonName(f); name != "" {
+				fields = append(fields, name)
+			}
+		}
+	}
+	return fields
+}
+
+// fill sets v to a value satisfying the rules of tag.
+func fill(v reflect.Value, tag string, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	rules := parseRules(tag)
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), tag, depth+1)
+
+	case reflect.Struct:
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(time.Now().UTC().Truncate(time.Second)))
+			return
+		}
+
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.IsExported() && jsonName(f) != "" {
+				fill(v.Field(i), f.Tag.Get("validate"), depth+1)
+			}
+		}
+
+	case reflect.String:
+		v.SetString(sampleString(rules))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(sampleNumber(rules)))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(sampleNumber(rules)))
+
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(sampleNumber(rules))
+
+	case reflect.Bool:
+		v.SetBool(true)
+
+	case reflect.Slice:
+		n := sampleLen(rules)
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			fill(s.Index(i), "", depth+1)
+		}
+		v.Set(s)
+
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		if n := sampleLen(rules); n > 0 && v.Type().Key().Kind() == reflect.String {
+			for i := 0; i < n; i++ {
+				key := reflect.New(v.Type().Key()).Elem()
+				key.SetString("key" + strconv.Itoa(i))
+				val := reflect.New(v.Type().Elem()).Elem()
+				fill(val, "", depth+1)
+				m.SetMapIndex(key, val)
+			}
+		}
+		v.Set(m)
+	}
+}
+
+// sampleString returns a string satisfying the string rules.
+func sampleString(rules map[string]string) string {
+	if opts, ok := rules["oneof"]; ok {
+		if f := strings.Fields(opts); len(f) > 0 {
+			return f[0]
+		}
+	}
+	if _, ok := rules["email"]; ok {
+		return "user@example.com"
+	}
+
+	s := "test"
+	if n, ok := ruleInt(rules, "len"); ok {
+		return strings.Repeat("a", n)
+	}
+	if n, ok := ruleInt(rules, "min"); ok && n > len(s) {
+		s = strings.Repeat("a", n)
+	}
+	if n, ok := ruleInt(rules, "max"); ok && n < len(s) {
+		s = s[:max(n, 0)]
+	}
+	return s
+}
+
+// sampleNumber returns a number satisfying the numeric rules.
+func sampleNumber(rules map[string]string) float64 {
+	if opts, ok := rules["oneof"]; ok {
+		if f := strings.Fields(opts); len(f) > 0 {
+			if n, err := strconv.ParseFloat(f[0], 64); err == nil {
+				return n
+			}
+		}
+	}
+
+	n := 1.0
+	if min, ok := ruleFloat(rules, "min"); ok && min > n {
+		n = min
+	}
+	if max, ok := ruleFloat(rules, "max"); ok && max < n {
+		n = max
+	}
+	if l, ok := ruleFloat(rules, "len"); ok {
+		n = l
+	}
+	return n
+}
+
+// sampleLen returns a collection length satisfying the rules, at least one.
+func sampleLen(rules map[string]string) int {
+	if n, ok := ruleInt(rules, "len"); ok {
+		return n
+	}
+
+	n := 1
+	if min, ok := ruleInt(rules, "min"); ok && min > n {
+		n = min
+	}
+	if max, ok := ruleInt(rules, "max"); ok && max < n {
+		n = max
+	}
+	return n
+}
+
+// parseRules splits a validate tag into rules and their parameters.
+func parseRules(tag string) map[string]string {
+	rules := map[string]string{}
+	if tag == "" || tag == "-" {
+		return rules
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name != "" {
+			rules[name] = param
+		}
+	}
+	return rules
+}
+
+func ruleInt(rules map[string]string, name string) (int, bool) {
+	n, ok := ruleFloat(rules, name)
+	return int(n), ok
+}
+
+func ruleFloat(rules map[string]string, name string) (float64, bool) {
+	param, ok := rules[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(param, 64)
+	return n, err == nil
+}
+
+// jsonName returns the JSON name of a struct field, or "" if it is not
+// encoded.
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
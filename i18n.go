@@ -0,0 +1,257 @@
+package httpfly
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// I18nConfig configures localization, see UseI18n.
+type I18nConfig struct {
+	// Dir holds one message catalog per locale, named after the locale:
+	// en.json, de-DE.toml, ... Nested JSON objects and TOML tables become
+	// dotted keys such as "errors.not_found".
+	Dir string
+	// DefaultLocale is used when nothing the client asks for is available,
+	// and for keys missing from the negotiated catalog. It defaults to "en".
+	DefaultLocale string
+	// QueryParam and Cookie name a query parameter and a cookie that
+	// override Accept-Language, e.g. "lang". Empty disables them.
+	QueryParam string
+	Cookie     string
+}
+
+// i18n holds the loaded catalogs of a router.
+type i18n struct {
+	cfg      I18nConfig
+	catalogs map[string]map[string]string
+}
+
+// UseI18n loads the message catalogs of the default router. See
+// Router.UseI18n.
+func UseI18n(cfg I18nConfig) error {
+	return defaultRouter.UseI18n(cfg)
+}
+
+// UseI18n loads the message catalogs in cfg.Dir. Handlers translate with
+// RequestBody.T into the locale negotiated from the query parameter, the
+// cookie or Accept-Language, in that order; templates rendered with Render
+// can call {{T "key" args...}}.
+func (r *Router) UseI18n(cfg I18nConfig) error {
+	if cfg.DefaultLocale == "" {
+		cfg.DefaultLocale = "en"
+	}
+
+	files, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return err
+	}
+
+	catalogs := map[string]map[string]string{}
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(cfg.Dir, f.Name()))
+		if err != nil {
+			return err
+		}
+
+		messages := map[string]string{}
+		if ext == ".json" {
+			err = parseJSONMessages(data, messages)
+		} else {
+			err = parseTOMLMessages(data, messages)
+		}
+		if err != nil {
+			return fmt.Errorf("httpfly: %s: %w", f.Name(), err)
+		}
+
+		catalogs[normalizeLocale(strings.TrimSuffix(f.Name(), ext))] = messages
+	}
+
+	cfg.DefaultLocale = normalizeLocale(cfg.DefaultLocale)
+	r.i18n = &i18n{cfg: cfg, catalogs: catalogs}
+	return nil
+}
+
+// Locale returns the locale negotiated for the request, or "" if
+// localization is not enabled.
+func (r *RequestBody) Locale() string {
+	if r.locale != "" || r.router == nil || r.router.i18n == nil {
+		return r.locale
+	}
+
+	r.locale = r.router.i18n.negotiate(r)
+	return r.locale
+}
+
+// T returns the message called key in the locale of the request, formatted
+// with args by fmt.Sprintf when given. Missing keys fall back to the default
+// locale, then to the key itself.
+func (r *RequestBody) T(key string, args ...any) string {
+	msg := key
+	if loc := r.Locale(); loc != "" {
+		msg = r.router.i18n.lookup(loc, key)
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// negotiate picks the best available locale for the request.
+func (c *i18n) negotiate(rb *RequestBody) string {
+	if c.cfg.QueryParam != "" {
+		if loc, ok := c.match(rb.Query(c.cfg.QueryParam)); ok {
+			return loc
+		}
+	}
+
+	if c.cfg.Cookie != "" {
+		if cookie, err := rb.Cookie(c.cfg.Cookie); err == nil {
+			if loc, ok := c.match(cookie.Value); ok {
+				return loc
+			}
+		}
+	}
+
+	if header := rb.Header("Accept-Language"); header != "" {
+		for _, lang := range parseAccept(header) {
+			if lang.q <= 0 {
+				continue
+			}
+			if loc, ok := c.match(lang.mediaType); ok {
+				return loc
+			}
+		}
+	}
+
+	return c.cfg.DefaultLocale
+}
+
+// match finds the catalog for a language tag, trying the tag itself and
+// then its base language, e.g. "de" for "de-AT".
+func (c *i18n) match(tag string) (string, bool) {
+	tag = normalizeLocale(tag)
+	if tag == "" {
+		return "", false
+	}
+	if _, ok := c.catalogs[tag]; ok {
+		return tag, true
+	}
+
+	base, _, _ := strings.Cut(tag, "-")
+	if _, ok := c.catalogs[base]; ok {
+		return base, true
+	}
+
+	for loc := range c.catalogs {
+		if strings.HasPrefix(loc, base+"-") {
+			return loc, true
+		}
+	}
+	return "", false
+}
+
+// lookup returns the message for key in loc or the default locale.
+func (c *i18n) lookup(loc, key string) string {
+	if msg, ok := c.catalogs[loc][key]; ok {
+		return msg
+	}
+	if msg, ok := c.catalogs[c.cfg.DefaultLocale][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// normalizeLocale lower-cases a language tag and uses "-" as separator.
+func normalizeLocale(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// parseJSONMessages flattens a JSON catalog into dotted keys.
+func parseJSONMessages(data []byte, out map[string]string) error {
+	var v map[string]any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	flattenMessages("", v, out)
+	return nil
+}
+
+func flattenMessages(prefix string, v map[string]any, out map[string]string) {
+	for k, val := range v {
+		switch val := val.(type) {
+		case map[string]any:
+			flattenMessages(prefix+k+".", val, out)
+		case string:
+			out[prefix+k] = val
+		default:
+			b, _ := json.Marshal(val)
+			out[prefix+k] = string(b)
+		}
+	}
+}
+
+// parseTOMLMessages reads the subset of TOML used by message catalogs:
+// tables and key = "string" pairs, with comments.
+func parseTOMLMessages(data []byte, out map[string]string) error {
+	var table string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			table = strings.Trim(strings.TrimSpace(line[1:len(line)-1]), `"`) + "."
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", n)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+
+		s, err := tomlString(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		out[table+key] = s
+	}
+	return sc.Err()
+}
+
+// tomlString parses a basic or literal TOML string followed by an optional
+// comment.
+func tomlString(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		for i := 1; i < len(v); i++ {
+			if v[i] == '\\' {
+				i++
+				continue
+			}
+			if v[i] == '"' {
+				return strconv.Unquote(v[:i+1])
+			}
+		}
+	case strings.HasPrefix(v, "'"):
+		if end := strings.IndexByte(v[1:], '\''); end >= 0 {
+			return v[1 : end+1], nil
+		}
+	}
+	return "", fmt.Errorf("expected a string, got %q", v)
+}
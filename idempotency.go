@@ -0,0 +1,109 @@
+package httpfly
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the header clients send to make a request
+// idempotent.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Errors answered by idempotent routes.
+var (
+	ErrIdempotencyInFlight = &HTTPError{Status: http.StatusConflict, Code: "idempotency_in_flight", Message: "a request with this idempotency key is in progress"}
+	ErrIdempotencyMismatch = &HTTPError{Status: http.StatusUnprocessableEntity, Code: "idempotency_mismatch", Message: "idempotency key reused with a different request"}
+)
+
+// idempotentResponse is a stored response with the fingerprint of the
+// request that produced it.
+type idempotentResponse struct {
+	Fingerprint string         `json:"fingerprint"`
+	Response    cachedResponse `json:"response"`
+}
+
+// WithIdempotency makes retries of a route safe. When a request carries an
+// Idempotency-Key header, its response is stored for ttl and replayed,
+// marked Idempotent-Replayed: true, to later requests with the same key
+// instead of running the handler again. Keys are scoped to the route and
+// the "sub" claim. A key reused with a different body is answered with
+// ErrIdempotencyMismatch, and one whose first request is still running
+// with ErrIdempotencyInFlight. 5xx responses are not stored, so those
+// requests can be retried. A nil store means an in-process store.
+func WithIdempotency(store CacheStore, ttl time.Duration) RouteOption {
+	if store == nil {
+		store = NewMemoryCacheStore()
+	}
+
+	var mu sync.Mutex
+	inFlight := map[string]bool{}
+
+	return func(ri *RouteInfo) {
+		next := ri.HandlerF
+		endpoint := string(ri.Method) + " " + ri.Endpoint
+
+		ri.HandlerF = func(rb *RequestBody) {
+			idemKey := rb.Header(IdempotencyKeyHeader)
+			if idemKey == "" {
+				next(rb)
+				return
+			}
+
+			key := "idem:" + endpoint + "\x00" + rb.ClaimString("sub") + "\x00" + idemKey
+			sum := sha256.Sum256(rb.JsonData)
+			fingerprint := hex.EncodeToString(sum[:])
+
+			mu.Lock()
+			if inFlight[key] {
+				mu.Unlock()
+				rb.Fail(ErrIdempotencyInFlight)
+				return
+			}
+			inFlight[key] = true
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				delete(inFlight, key)
+				mu.Unlock()
+			}()
+
+			if data, found, err := store.Get(key); err == nil && found {
+				var stored idempotentResponse
+				if json.Unmarshal(data, &stored) == nil {
+					if stored.Fingerprint != fingerprint {
+						rb.Fail(ErrIdempotencyMismatch)
+						return
+					}
+					rb.ResponseW.Header().Set("Idempotent-Replayed", "true")
+					stored.Response.replay(rb.ResponseW)
+					return
+				}
+			}
+
+			dst := rb.ResponseW
+			buf := newResponseBuffer()
+			rb.ResponseW = buf
+
+			next(rb)
+
+			rb.ResponseW = dst
+
+			if status := buf.Status(); status < http.StatusInternalServerError {
+				stored := idempotentResponse{
+					Fingerprint: fingerprint,
+					Response:    cachedResponse{Status: status, Header: buf.header.Clone(), Body: buf.body.Bytes(), Stored: time.Now()},
+				}
+				if data, err := json.Marshal(stored); err == nil {
+					store.Set(key, data, ttl)
+				}
+			}
+
+			buf.flushTo(dst)
+		}
+	}
+}
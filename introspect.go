@@ -0,0 +1,101 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Routes returns a copy of the routes of the default router, ordered as
+// they are matched.
+func Routes() []RouteInfo {
+	return defaultRouter.Routes()
+}
+
+// Routes returns a copy of the routes of the router.
+func (r *Router) Routes() []RouteInfo {
+	table := r.currentRoutes()
+
+	out := make([]RouteInfo, len(table.routes))
+	for i, v := range table.routes {
+		out[i] = *v
+	}
+	return out
+}
+
+// RouteName returns the name given to the route with Name, or "".
+func (ri *RouteInfo) RouteName() string {
+	return ri.name
+}
+
+// routeDump is the debug representation of a route.
+type routeDump struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Version     string   `json:"version,omitempty"`
+	Host        string   `json:"host,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Auth        bool     `json:"auth"`
+	Roles       []string `json:"roles,omitempty"`
+	Middlewares []string `json:"middlewares,omitempty"`
+	Handler     string   `json:"handler"`
+	Source      string   `json:"source"`
+}
+
+// ServeRoutes maps a debug endpoint at path that lists the route table of
+// the default router as JSON. It exposes the structure of the API, so only
+// enable it in development or behind auth.
+func ServeRoutes(path string, opts ...RouteOption) {
+	defaultRouter.ServeRoutes(path, opts...)
+}
+
+// ServeRoutes maps the route listing endpoint on the router. opts apply to
+// the endpoint itself, e.g. WithAuthProvider.
+func (r *Router) ServeRoutes(path string, opts ...RouteOption) {
+	r.MapGet(path, NoAuth, func(rb *RequestBody) {
+		routes := r.currentRoutes().routes
+		dump := make([]routeDump, len(routes))
+
+		for i, v := range routes {
+			d := routeDump{
+				Method:  string(v.Method),
+				Path:    v.Endpoint,
+				Version: v.Version,
+				Host:    v.Host,
+				Name:    v.name,
+				Auth:    v.AuthRequired,
+				Roles:   v.roles,
+				Handler: funcName(v.HandlerF),
+				Source:  v.Source,
+			}
+			for _, m := range v.middlewares {
+				d.Middlewares = append(d.Middlewares, funcName(m))
+			}
+			dump[i] = d
+		}
+
+		out, _ := json.MarshalIndent(dump, "", "  ")
+		rb.ResponseW.Header().Set("Content-Type", "application/json")
+		rb.ResponseW.Write(out)
+	}, opts...)
+}
+
+// funcName returns the name of the function f, without the module path.
+func funcName(f any) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
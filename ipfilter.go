@@ -0,0 +1,64 @@
+package httpfly
+
+import (
+	"net/http"
+	"net/netip"
+)
+
+// ErrIPDenied is the error answered to clients rejected by an IP filter.
+var ErrIPDenied = &HTTPError{Status: http.StatusForbidden, Code: "ip_denied", Message: "access denied"}
+
+// IPFilterConfig configures an IP filter. Entries are CIDRs such as
+// "10.0.0.0/8" or single addresses.
+type IPFilterConfig struct {
+	// Allow lists the clients that may call the routes. Empty allows every
+	// client that is not denied.
+	Allow []string
+	// Deny lists rejected clients. It takes precedence over Allow.
+	Deny []string
+	// TrustedProxies lists the proxies whose forwarding header is believed.
+	// Empty means the trusted proxies of the router, see SetTrustedProxies.
+	TrustedProxies []string
+	// ClientIPHeader is the forwarding header set by TrustedProxies:
+	// "X-Forwarded-For" (the default) or "X-Real-IP".
+	ClientIPHeader string
+}
+
+// UseIPFilter restricts every route of the default router by client IP.
+// See IPFilter.
+func UseIPFilter(allowCIDRs, denyCIDRs []string) {
+	defaultRouter.UseIPFilter(allowCIDRs, denyCIDRs)
+}
+
+// UseIPFilter restricts every route of the router by client IP.
+func (r *Router) UseIPFilter(allowCIDRs, denyCIDRs []string) {
+	r.AddMiddleware(IPFilter(IPFilterConfig{Allow: allowCIDRs, Deny: denyCIDRs}))
+}
+
+// IPFilter returns middleware that answers requests from clients outside
+// cfg.Allow or inside cfg.Deny with ErrIPDenied. Attach it to single routes
+// with WithMiddleware, e.g. for internal-only endpoints. It panics on a
+// malformed entry.
+func IPFilter(cfg IPFilterConfig) MiddlewareFunc {
+	allow := mustParsePrefixes(cfg.Allow)
+	deny := mustParsePrefixes(cfg.Deny)
+	proxies := trustedProxies{prefixes: mustParsePrefixes(cfg.TrustedProxies), header: cfg.ClientIPHeader}
+
+	return func(rb *RequestBody, response http.ResponseWriter, request *http.Request) {
+		var ip netip.Addr
+		if len(proxies.prefixes) > 0 {
+			ip = proxies.clientIP(request)
+		} else {
+			ip = parseAddr(rb.ClientIP())
+		}
+
+		denied := containsAddr(deny, ip)
+		if len(allow) > 0 && !containsAddr(allow, ip) {
+			denied = true
+		}
+
+		if denied {
+			rb.Fail(ErrIPDenied)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package httpfly
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by JWTProvider.
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// JWTConfig configures a JWTProvider. At least one of HMACSecret and
+// RSAPublicKey must be set; tokens signed with an algorithm whose key is not
+// configured are rejected.
+type JWTConfig struct {
+	// HMACSecret verifies HS256, HS384 and HS512 tokens.
+	HMACSecret []byte
+	// RSAPublicKey verifies RS256, RS384 and RS512 tokens.
+	RSAPublicKey *rsa.PublicKey
+	// Issuer, when set, must equal the "iss" claim.
+	Issuer string
+	// Audience, when set, must be contained in the "aud" claim.
+	Audience string
+	// Leeway is the clock skew tolerated when checking "exp" and "nbf".
+	Leeway time.Duration
+}
+
+// JWTProvider is an AuthProvider that accepts bearer JSON Web Tokens.
+type JWTProvider struct {
+	cfg JWTConfig
+}
+
+// NewJWTProvider creates a JWT auth provider.
+func NewJWTProvider(cfg JWTConfig) *JWTProvider {
+	return &JWTProvider{cfg: cfg}
+}
+
+// Authenticate implements AuthProvider. Claims that are not strings are
+// converted to their JSON text, except numbers which keep their literal form.
+func (p *JWTProvider) Authenticate(req *http.Request) (map[string]string, error) {
+	claims, err := p.AuthenticateClaims(req)
+	if err != nil {
+		return nil, err
+	}
+	return claims.Strings(), nil
+}
+
+// AuthenticateClaims implements ClaimsProvider. Numbers are kept as
+// json.Number.
+func (p *JWTProvider) AuthenticateClaims(req *http.Request) (Claims, error) {
+	token, ok := bearerToken(req)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return p.Verify(token)
+}
+
+// Verify checks the signature and registered claims of a token obtained
+// other than from a request header, e.g. an OpenID Connect ID token, and
+// returns its claims. Numbers are kept as json.Number.
+func (p *JWTProvider) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := p.verify(header.Alg, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims := make(Claims, len(raw))
+	for k, v := range raw {
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+
+		var val any
+		if err := dec.Decode(&val); err != nil {
+			return nil, ErrInvalidToken
+		}
+		claims[k] = val
+	}
+
+	if err := p.validate(claims.Strings(), raw["aud"]); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// jwtHashes maps the supported signing algorithms to their hash.
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+}
+
+// verify checks the signature of signed with the key for alg.
+func (p *JWTProvider) verify(alg string, signed string, sig []byte) error {
+	hash, ok := jwtHashes[alg]
+
+	switch {
+	case ok && strings.HasPrefix(alg, "HS") && p.cfg.HMACSecret != nil:
+		mac := hmac.New(hash.New, p.cfg.HMACSecret)
+		mac.Write([]byte(signed))
+
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrInvalidToken
+		}
+		return nil
+
+	case ok && strings.HasPrefix(alg, "RS") && p.cfg.RSAPublicKey != nil:
+		h := hash.New()
+		h.Write([]byte(signed))
+
+		if rsa.VerifyPKCS1v15(p.cfg.RSAPublicKey, hash, h.Sum(nil), sig) != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+}
+
+// validate checks the registered time, issuer and audience claims.
+func (p *JWTProvider) validate(claims map[string]string, aud json.RawMessage) error {
+	now := time.Now()
+
+	if exp, ok := claims["exp"]; ok {
+		t, err := strconv.ParseFloat(exp, 64)
+		if err != nil {
+			return ErrInvalidToken
+		}
+		if now.After(time.Unix(int64(t), 0).Add(p.cfg.Leeway)) {
+			return ErrTokenExpired
+		}
+	}
+
+	if nbf, ok := claims["nbf"]; ok {
+		t, err := strconv.ParseFloat(nbf, 64)
+		if err != nil {
+			return ErrInvalidToken
+		}
+		if now.Add(p.cfg.Leeway).Before(time.Unix(int64(t), 0)) {
+			return fmt.Errorf("%w: token not valid yet", ErrInvalidToken)
+		}
+	}
+
+	if p.cfg.Issuer != "" && claims["iss"] != p.cfg.Issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+
+	if p.cfg.Audience != "" {
+		var list []string
+		var single string
+
+		if json.Unmarshal(aud, &single) == nil {
+			list = []string{single}
+		} else {
+			json.Unmarshal(aud, &list)
+		}
+
+		for _, a := range list {
+			if a == p.cfg.Audience {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// bearerToken returns the token of a "Bearer" Authorization header.
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v.
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
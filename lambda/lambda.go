@@ -0,0 +1,230 @@
+// Package lambda runs an httpfly router, or any http.Handler, as an AWS
+// Lambda function behind API Gateway (REST and HTTP APIs) or an
+// Application Load Balancer. The same route definitions serve both a
+// long-lived server and a Lambda function:
+//
+//	func main() {
+//		r := routes()
+//		if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+//			log.Fatal(lambda.Start(r))
+//		}
+//		log.Fatal(r.Start(":8080"))
+//	}
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Request is an API Gateway or ALB event. It covers the fields of REST API
+// (payload 1.0), HTTP API (payload 2.0) and ALB events used to build an
+// http.Request.
+type Request struct {
+	Version string `json:"version"`
+
+	// Payload 1.0 and ALB.
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+
+	// Payload 2.0.
+	RawPath        string   `json:"rawPath"`
+	RawQueryString string   `json:"rawQueryString"`
+	Cookies        []string `json:"cookies"`
+
+	Body            string         `json:"body"`
+	IsBase64Encoded bool           `json:"isBase64Encoded"`
+	RequestContext  RequestContext `json:"requestContext"`
+}
+
+// RequestContext is the request context of an event.
+type RequestContext struct {
+	RequestID  string `json:"requestId"`
+	DomainName string `json:"domainName"`
+	HTTP       struct {
+		Method   string `json:"method"`
+		Path     string `json:"path"`
+		SourceIP string `json:"sourceIp"`
+	} `json:"http"`
+	Identity struct {
+		SourceIP string `json:"sourceIp"`
+	} `json:"identity"`
+	ELB *struct {
+		TargetGroupArn string `json:"targetGroupArn"`
+	} `json:"elb"`
+}
+
+// Response is the reply to an API Gateway or ALB event.
+type Response struct {
+	StatusCode        int                 `json:"statusCode"`
+	StatusDescription string              `json:"statusDescription,omitempty"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Cookies           []string            `json:"cookies,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// isV2 reports whether the event uses payload format 2.0.
+func (e *Request) isV2() bool {
+	return e.Version == "2.0"
+}
+
+// isALB reports whether the event comes from a load balancer.
+func (e *Request) isALB() bool {
+	return e.RequestContext.ELB != nil
+}
+
+// Handle serves a single event with h.
+func Handle(ctx context.Context, h http.Handler, event *Request) (*Response, error) {
+	req, err := NewRequest(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return newResponse(event, rec), nil
+}
+
+// NewRequest converts an event into an http.Request.
+func NewRequest(ctx context.Context, e *Request) (*http.Request, error) {
+	method, path, rawQuery := e.HTTPMethod, e.Path, ""
+
+	if e.isV2() {
+		method, path, rawQuery = e.RequestContext.HTTP.Method, e.RawPath, e.RawQueryString
+	} else {
+		q := url.Values{}
+		for k, vs := range e.MultiValueQueryStringParameters {
+			q[k] = vs
+		}
+		for k, v := range e.QueryStringParameters {
+			if _, ok := q[k]; !ok {
+				q.Set(k, v)
+			}
+		}
+		rawQuery = q.Encode()
+	}
+
+	body := []byte(e.Body)
+	if e.IsBase64Encoded {
+		b, err := base64.StdEncoding.DecodeString(e.Body)
+		if err != nil {
+			return nil, fmt.Errorf("lambda: decode body: %w", err)
+		}
+		body = b
+	}
+
+	target := path
+	if target == "" {
+		target = "/"
+	}
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.RequestURI = target
+
+	for k, vs := range e.MultiValueHeaders {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	for k, v := range e.Headers {
+		if req.Header.Get(k) == "" {
+			req.Header.Set(k, v)
+		}
+	}
+	for _, c := range e.Cookies {
+		req.Header.Add("Cookie", c)
+	}
+
+	req.Host = req.Header.Get("Host")
+	if req.Host == "" {
+		req.Host = e.RequestContext.DomainName
+	}
+	if req.Header.Get("X-Request-ID") == "" && e.RequestContext.RequestID != "" {
+		req.Header.Set("X-Request-ID", e.RequestContext.RequestID)
+	}
+
+	ip := e.RequestContext.HTTP.SourceIP
+	if ip == "" {
+		ip = e.RequestContext.Identity.SourceIP
+	}
+	if ip != "" {
+		req.RemoteAddr = ip + ":0"
+	}
+
+	return req, nil
+}
+
+// newResponse converts a recorded response into the reply to e.
+func newResponse(e *Request, rec *httptest.ResponseRecorder) *Response {
+	resp := &Response{StatusCode: rec.Code}
+
+	body := rec.Body.Bytes()
+	if utf8.Valid(body) {
+		resp.Body = string(body)
+	} else {
+		resp.Body = base64.StdEncoding.EncodeToString(body)
+		resp.IsBase64Encoded = true
+	}
+
+	header := rec.Result().Header
+
+	switch {
+	case e.isV2():
+		resp.Headers = map[string]string{}
+		for k, vs := range header {
+			if k == "Set-Cookie" {
+				resp.Cookies = vs
+				continue
+			}
+			resp.Headers[k] = strings.Join(vs, ",")
+		}
+
+	case len(e.MultiValueHeaders) > 0 || !e.isALB():
+		resp.MultiValueHeaders = header
+
+	default:
+		resp.Headers = map[string]string{}
+		for k, vs := range header {
+			resp.Headers[k] = vs[len(vs)-1]
+		}
+	}
+
+	if e.isALB() {
+		resp.StatusDescription = fmt.Sprintf("%d %s", rec.Code, http.StatusText(rec.Code))
+	}
+	return resp
+}
+
+// HandleJSON serves a raw JSON event with h and returns the JSON reply.
+func HandleJSON(ctx context.Context, h http.Handler, event []byte) ([]byte, error) {
+	var e Request
+	if err := json.Unmarshal(event, &e); err != nil {
+		return nil, fmt.Errorf("lambda: decode event: %w", err)
+	}
+
+	resp, err := Handle(ctx, h, &e)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp)
+}
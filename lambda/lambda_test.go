@@ -0,0 +1,199 @@
+package lambda
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/burakturkerdev/httpfly"
+)
+
+// echoRouter answers /api/echo/{id} with the request as the handler sees it.
+func echoRouter() *httpfly.Router {
+	r := httpfly.NewRouter()
+	r.MapPost("/echo/{id}", httpfly.NoAuth, func(rb *httpfly.RequestBody) {
+		var session string
+		if c, err := rb.Cookie("session"); err == nil {
+			session = c.Value
+		}
+		rb.ResponseHeader().Add("Set-Cookie", "a=1")
+		rb.ResponseHeader().Add("Set-Cookie", "b=2")
+		rb.JSON(http.StatusCreated, map[string]string{
+			"id":     string(rb.Params["id"]),
+			"q":      rb.Query("q"),
+			"header": rb.Header("X-Test"),
+			"cookie": session,
+			"body":   string(rb.JsonData),
+			"ip":     rb.RemoteIP(),
+			"reqid":  rb.Header(httpfly.RequestIDHeader),
+		})
+	})
+	return r
+}
+
+func decodeBody(t *testing.T, resp *Response) map[string]string {
+	t.Helper()
+
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		body, _ = base64.StdEncoding.DecodeString(resp.Body)
+	}
+
+	var out map[string]string
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("body %q: %v", resp.Body, err)
+	}
+	return out
+}
+
+func TestHandleRESTEvent(t *testing.T) {
+	e := &Request{
+		HTTPMethod:            http.MethodPost,
+		Path:                  "/api/echo/7",
+		Headers:               map[string]string{"X-Test": "yes", "Cookie": "session=s1"},
+		QueryStringParameters: map[string]string{"q": "a b"},
+		Body:                  base64.StdEncoding.EncodeToString([]byte(`{"n":1}`)),
+		IsBase64Encoded:       true,
+	}
+	e.RequestContext.RequestID = "aws-1"
+	e.RequestContext.Identity.SourceIP = "198.51.100.7"
+
+	resp, err := Handle(context.Background(), echoRouter(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+
+	want := map[string]string{"id": "7", "q": "a b", "header": "yes", "cookie": "s1", "body": `{"n":1}`, "ip": "198.51.100.7", "reqid": "aws-1"}
+	for k, v := range want {
+		if got := decodeBody(t, resp)[k]; got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if got := resp.MultiValueHeaders["Set-Cookie"]; len(got) != 2 {
+		t.Errorf("Set-Cookie = %v, want both cookies as multi-value headers", got)
+	}
+}
+
+func TestHandleHTTPAPIEvent(t *testing.T) {
+	e := &Request{
+		Version:        "2.0",
+		RawPath:        "/api/echo/9",
+		RawQueryString: "q=x",
+		Cookies:        []string{"session=s2"},
+		Body:           `{"n":2}`,
+	}
+	e.RequestContext.HTTP.Method = http.MethodPost
+	e.RequestContext.HTTP.SourceIP = "203.0.113.9"
+
+	resp, err := Handle(context.Background(), echoRouter(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := decodeBody(t, resp)
+	if got["id"] != "9" || got["q"] != "x" || got["cookie"] != "s2" || got["ip"] != "203.0.113.9" {
+		t.Errorf("handler saw %v", got)
+	}
+	if strings.Join(resp.Cookies, ";") != "a=1;b=2" || resp.Headers["Set-Cookie"] != "" {
+		t.Errorf("cookies = %v, headers = %v; want Set-Cookie in Cookies", resp.Cookies, resp.Headers)
+	}
+}
+
+func TestHandleALBEvent(t *testing.T) {
+	e := &Request{HTTPMethod: http.MethodGet, Path: "/api/missing"}
+	e.RequestContext.ELB = &struct {
+		TargetGroupArn string `json:"targetGroupArn"`
+	}{TargetGroupArn: "arn:tg"}
+
+	resp, err := Handle(context.Background(), echoRouter(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound || resp.StatusDescription != "404 Not Found" {
+		t.Errorf("status %d %q, want 404 with a description", resp.StatusCode, resp.StatusDescription)
+	}
+	if resp.Headers["Content-Type"] == "" || resp.MultiValueHeaders != nil {
+		t.Errorf("headers = %v, multi-value = %v; want single-value headers", resp.Headers, resp.MultiValueHeaders)
+	}
+}
+
+func TestHandleBinaryResponse(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte{0xff, 0x00, 0xfe})
+	})
+
+	resp, err := Handle(context.Background(), h, &Request{HTTPMethod: http.MethodGet, Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsBase64Encoded || resp.Body != base64.StdEncoding.EncodeToString([]byte{0xff, 0x00, 0xfe}) {
+		t.Errorf("body = %q (base64 %v), want the bytes base64-encoded", resp.Body, resp.IsBase64Encoded)
+	}
+}
+
+func TestStartServesRuntimeEvents(t *testing.T) {
+	event, _ := json.Marshal(&Request{HTTPMethod: http.MethodPost, Path: "/api/echo/1", Body: "{}"})
+	events := [][]byte{event, []byte("not json")}
+
+	var mu sync.Mutex
+	posted := map[string]string{}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		const prefix = "/" + runtimeAPIVersion + "/runtime/invocation/"
+
+		switch {
+		case req.URL.Path == prefix+"next" && len(events) > 0:
+			w.Header().Set("Lambda-Runtime-Aws-Request-Id", "inv-"+strconv.Itoa(len(events)))
+			w.Write(events[0])
+			events = events[1:]
+		case req.URL.Path == prefix+"next":
+			// Dropping the connection ends Start.
+			panic(http.ErrAbortHandler)
+		case req.Method == http.MethodPost && strings.HasPrefix(req.URL.Path, prefix):
+			b, _ := io.ReadAll(req.Body)
+			posted[strings.TrimPrefix(req.URL.Path, prefix)] = string(b)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			t.Errorf("unexpected runtime API call %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+
+	t.Setenv("AWS_LAMBDA_RUNTIME_API", strings.TrimPrefix(api.URL, "http://"))
+
+	if err := Start(echoRouter()); err == nil {
+		t.Error("Start returned nil after the runtime API failed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var resp Response
+	if err := json.Unmarshal([]byte(posted["inv-2/response"]), &resp); err != nil || resp.StatusCode != http.StatusCreated {
+		t.Errorf("reply = %q (%v), want a 201 response", posted["inv-2/response"], err)
+	}
+	if !strings.Contains(posted["inv-1/error"], "errorMessage") {
+		t.Errorf("error report = %q, want an errorMessage for the undecodable event", posted["inv-1/error"])
+	}
+}
+
+func TestStartOutsideLambda(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_RUNTIME_API", "")
+	if err := Start(echoRouter()); err == nil {
+		t.Error("Start succeeded without AWS_LAMBDA_RUNTIME_API")
+	}
+}
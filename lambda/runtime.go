@@ -0,0 +1,86 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// runtimeAPIVersion is the version of the Lambda runtime API.
+const runtimeAPIVersion = "2018-06-01"
+
+// Start serves the events of the Lambda runtime with h until the runtime
+// API fails. It implements the runtime API directly, so no AWS library is
+// needed; it must run inside Lambda, where AWS_LAMBDA_RUNTIME_API is set.
+func Start(h http.Handler) error {
+	api := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if api == "" {
+		return errors.New("lambda: AWS_LAMBDA_RUNTIME_API is not set")
+	}
+
+	base := "http://" + api + "/" + runtimeAPIVersion + "/runtime/invocation/"
+	client := &http.Client{}
+
+	for {
+		resp, err := client.Get(base + "next")
+		if err != nil {
+			return err
+		}
+
+		event, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		id := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		ctx, cancel := invocationContext(resp.Header)
+
+		reply, err := HandleJSON(ctx, h, event)
+		cancel()
+
+		if err != nil {
+			err = post(client, base+id+"/error", errorPayload(err))
+		} else {
+			err = post(client, base+id+"/response", reply)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// invocationContext returns a context ending at the invocation deadline.
+func invocationContext(h http.Header) (context.Context, context.CancelFunc) {
+	ms, err := strconv.ParseInt(h.Get("Lambda-Runtime-Deadline-Ms"), 10, 64)
+	if err != nil {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), time.UnixMilli(ms))
+}
+
+func errorPayload(err error) []byte {
+	b, _ := json.Marshal(map[string]string{"errorMessage": err.Error(), "errorType": fmt.Sprintf("%T", err)})
+	return b
+}
+
+func post(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("lambda: runtime API answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package httpfly
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// OnStartup registers a hook that runs before the server starts accepting
+// connections. Hooks run in registration order; the first error aborts
+// startup and is returned from the Start function.
+func OnStartup(f func(ctx context.Context) error) {
+	defaultRouter.OnStartup(f)
+}
+
+// OnStartup registers a hook that runs before the router starts serving.
+func (r *Router) OnStartup(f func(ctx context.Context) error) {
+	r.startupHooks = append(r.startupHooks, f)
+}
+
+// RunStartupHooks runs the startup hooks of the default router. See
+// Router.RunStartupHooks.
+func RunStartupHooks(ctx context.Context) error {
+	return defaultRouter.RunStartupHooks(ctx)
+}
+
+// RunStartupHooks runs the startup hooks of the router, for routers served
+// as an http.Handler rather than through Start or Serve.
+func (r *Router) RunStartupHooks(ctx context.Context) error {
+	return r.runStartupHooks(ctx)
+}
+
+// OnStart registers a hook that runs once a server of the default router
+// is listening. See Router.OnStart.
+func OnStart(f func(addr net.Addr)) {
+	defaultRouter.OnStart(f)
+}
+
+// OnStart registers a hook that runs once a server of the router has bound
+// its listener, after the startup hooks, with the address it listens on,
+// e.g. to register the service with a discovery system. Hooks run in
+// registration order before the server accepts connections.
+func (r *Router) OnStart(f func(addr net.Addr)) {
+	r.startHooks = append(r.startHooks, f)
+}
+
+// OnShutdown registers a hook that runs when a server of the default
+// router starts shutting down gracefully. See Router.OnShutdown.
+func OnShutdown(f func(ctx context.Context)) {
+	defaultRouter.OnShutdown(f)
+}
+
+// OnShutdown registers a hook that runs when a server of the router starts
+// a graceful shutdown, before open requests are drained, e.g. to deregister
+// the service or flush metrics. ctx carries the shutdown deadline. Hooks
+// run in reverse registration order.
+func (r *Router) OnShutdown(f func(ctx context.Context)) {
+	r.shutdownHooks = append(r.shutdownHooks, f)
+}
+
+// RunShutdownHooks runs the shutdown hooks of the default router. See
+// Router.RunShutdownHooks.
+func RunShutdownHooks(ctx context.Context) {
+	defaultRouter.RunShutdownHooks(ctx)
+}
+
+// RunShutdownHooks runs the shutdown hooks of the router, for routers
+// served as an http.Handler rather than through Start or Serve.
+func (r *Router) RunShutdownHooks(ctx context.Context) {
+	for i := len(r.shutdownHooks) - 1; i >= 0; i-- {
+		r.shutdownHooks[i](ctx)
+	}
+}
+
+// OnRouteRegistered registers a hook that runs after each route mapped on
+// the default router. See Router.OnRouteRegistered.
+func OnRouteRegistered(f func(ri *RouteInfo)) {
+	defaultRouter.OnRouteRegistered(f)
+}
+
+// OnRouteRegistered registers a hook that runs after each route is mapped
+// on the router, including the routes of ReplaceRoutes, e.g. to build a
+// route catalog or pre-create metrics. Routes mapped before the hook was
+// registered are not replayed; Routes lists them.
+func (r *Router) OnRouteRegistered(f func(ri *RouteInfo)) {
+	r.routeHooks = append(r.routeHooks, f)
+}
+
+// OnServerError registers a hook that runs when a server of the default
+// router fails to listen or stops serving because of an error. See
+// Router.OnServerError.
+func OnServerError(f func(err error)) {
+	defaultRouter.OnServerError(f)
+}
+
+// OnServerError registers a hook that runs when a server of the router
+// fails to listen, after any retries, or stops serving because of an error,
+// e.g. to alert or exit the process. The error is still returned by the
+// Start or Serve function. Graceful shutdowns are not errors.
+func (r *Router) OnServerError(f func(err error)) {
+	r.serverErrorHooks = append(r.serverErrorHooks, f)
+}
+
+// SetListenRetry makes the Start functions of the default router retry
+// binding their address. See Router.SetListenRetry.
+func SetListenRetry(retries int, backoff time.Duration) {
+	defaultRouter.SetListenRetry(retries, backoff)
+}
+
+// SetListenRetry makes the Start functions of the router retry binding
+// their address up to retries times after a listen error, such as a port
+// still held by a previous process, waiting backoff before the first retry
+// and doubling it up to MaxListenBackoff. Zero backoff means
+// DefaultListenBackoff. Server.ListenRetries overrides it.
+func (r *Router) SetListenRetry(retries int, backoff time.Duration) {
+	r.listenRetries, r.listenBackoff = retries, backoff
+}
+
+// serverError runs the server error hooks.
+func (r *Router) serverError(err error) {
+	if r.logger != nil {
+		r.logger.Error("server error", "error", err.Error())
+	}
+	for _, f := range r.serverErrorHooks {
+		f(err)
+	}
+}
+
+// routeRegistered runs the route hooks for ri.
+func (r *Router) routeRegistered(ri *RouteInfo) {
+	for _, f := range r.routeHooks {
+		f(ri)
+	}
+}
+
+// runStartupHooks runs all registered startup hooks in order.
+func (r *Router) runStartupHooks(ctx context.Context) error {
+	for i, f := range r.startupHooks {
+		if err := f(ctx); err != nil {
+			return fmt.Errorf("startup hook %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package httpfly
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestFailingStartupHookStopsServing(t *testing.T) {
+	errDB := errors.New("database unreachable")
+	var ran []int
+	started := false
+
+	r := NewRouter()
+	r.OnStartup(func(ctx context.Context) error { ran = append(ran, 1); return errDB })
+	r.OnStartup(func(ctx context.Context) error { ran = append(ran, 2); return nil })
+	r.OnStart(func(addr net.Addr) { started = true })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewServer("", r).Serve(context.Background(), ln); !errors.Is(err, errDB) {
+		t.Fatalf("Serve = %v, want the hook error", err)
+	}
+	if len(ran) != 1 || started {
+		t.Errorf("hooks run %v, started %v; want only the failing hook", ran, started)
+	}
+	if conn, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("listener still accepts connections")
+	}
+
+	if err := NewServer("127.0.0.1:0", r).Start(context.Background()); !errors.Is(err, errDB) {
+		t.Errorf("Start = %v, want the hook error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package httpfly
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Logger receives the access and error logs of a router. *slog.Logger
+// implements it; adapters for other loggers only need these two methods.
+type Logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// slogDefault logs to whatever slog.Default returns at the time of logging.
+type slogDefault struct{}
+
+func (slogDefault) Info(msg string, args ...any)  { slog.Info(msg, args...) }
+func (slogDefault) Error(msg string, args ...any) { slog.Error(msg, args...) }
+
+// SetLogger sets the logger of the default router. The default logs through
+// slog.Default; nil disables logging.
+func SetLogger(l Logger) {
+	defaultRouter.SetLogger(l)
+}
+
+// SetLogger sets the logger of the router. Nil disables logging.
+func (r *Router) SetLogger(l Logger) {
+	r.logger = l
+}
+
+// DefaultLogBodySize is the default number of body bytes LogBodies adds to
+// the access log.
+const DefaultLogBodySize = 4 << 10
+
+// BodyLogConfig configures LogBodies.
+type BodyLogConfig struct {
+	// Redact lists JSON fields to mask, e.g. "password" or
+	// "user.card.number". See RedactJSON.
+	Redact []string
+	// MaxSize is the number of bytes logged, after redaction. Defaults to
+	// DefaultLogBodySize.
+	MaxSize int
+}
+
+// LogBodies adds request bodies to the access log of the default router.
+// See Router.LogBodies.
+func LogBodies(cfg BodyLogConfig) {
+	defaultRouter.LogBodies(cfg)
+}
+
+// LogBodies adds the request body to the access line of every request of
+// the router, with the fields in cfg.Redact masked. Streamed bodies are
+// not logged.
+func (r *Router) LogBodies(cfg BodyLogConfig) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultLogBodySize
+	}
+	r.bodyLog = &cfg
+}
+
+// logAccess writes the access line of a handled request.
+func (r *Router) logAccess(rb *RequestBody, status int, size int, duration time.Duration) {
+	if r.logger == nil {
+		return
+	}
+
+	args := []any{
+		"method", rb.req.Method,
+		"path", rb.req.URL.Path,
+		"status", status,
+		"duration", duration,
+		"bytes", size,
+		"remote", rb.req.RemoteAddr,
+	}
+
+	if len(r.proxies.prefixes) > 0 {
+		args = append(args, "client_ip", r.clientIP(rb.req))
+	}
+
+	if id := rb.RequestID(); id != "" {
+		args = append(args, "request_id", id)
+	}
+
+	if cfg := r.bodyLog; cfg != nil && len(rb.JsonData) > 0 {
+		body := RedactJSON(rb.JsonData, cfg.Redact)
+		args = append(args, "body", string(body[:min(len(body), cfg.MaxSize)]))
+	}
+
+	r.logger.Info("request", args...)
+}
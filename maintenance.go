@@ -0,0 +1,144 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaintenanceRetryAfter is the Retry-After of maintenance responses
+// when MaintenanceConfig.RetryAfter is zero.
+const DefaultMaintenanceRetryAfter = 5 * time.Minute
+
+// ErrMaintenance is returned for requests refused in maintenance mode.
+var ErrMaintenance = &HTTPError{Status: http.StatusServiceUnavailable, Code: "maintenance", Message: "service under maintenance"}
+
+// MaintenanceConfig configures the response to requests refused in
+// maintenance mode. Without a body, requests get ErrMaintenance through the
+// router's error handler.
+type MaintenanceConfig struct {
+	RetryAfter time.Duration
+	// JSON is marshaled as the body for clients that do not prefer HTML.
+	JSON any
+	// HTML is the body for clients whose Accept header prefers text/html.
+	HTML string
+}
+
+// maintenanceState is replaced as a whole, so it can be toggled while
+// serving.
+type maintenanceState struct {
+	enabled bool
+	exempt  []string
+	cfg     MaintenanceConfig
+}
+
+// SetMaintenanceMode switches maintenance mode of the default router. See
+// Router.SetMaintenanceMode.
+func SetMaintenanceMode(enabled bool, exempt []string) {
+	defaultRouter.SetMaintenanceMode(enabled, exempt)
+}
+
+// SetMaintenanceMode switches maintenance mode on or off, e.g. around a
+// deploy window. While on, every request is answered with 503 Service
+// Unavailable and a Retry-After header, except for paths in exempt. These
+// are route paths without the router prefix, such as "/healthz", or path
+// prefixes ending in "*", such as "/admin/*". It is safe to call while
+// serving.
+func (r *Router) SetMaintenanceMode(enabled bool, exempt []string) {
+	r.maintenanceMu.Lock()
+	defer r.maintenanceMu.Unlock()
+
+	state := maintenanceState{enabled: enabled, exempt: append([]string(nil), exempt...)}
+	if cur := r.maintenance.Load(); cur != nil {
+		state.cfg = cur.cfg
+	}
+	r.maintenance.Store(&state)
+}
+
+// SetMaintenanceResponse configures the maintenance response of the default
+// router.
+func SetMaintenanceResponse(cfg MaintenanceConfig) {
+	defaultRouter.SetMaintenanceResponse(cfg)
+}
+
+// SetMaintenanceResponse configures the response of the router in
+// maintenance mode. It is safe to call while serving.
+func (r *Router) SetMaintenanceResponse(cfg MaintenanceConfig) {
+	r.maintenanceMu.Lock()
+	defer r.maintenanceMu.Unlock()
+
+	var state maintenanceState
+	if cur := r.maintenance.Load(); cur != nil {
+		state = *cur
+	}
+	state.cfg = cfg
+	r.maintenance.Store(&state)
+}
+
+// MaintenanceMode reports whether the default router is in maintenance
+// mode.
+func MaintenanceMode() bool {
+	return defaultRouter.MaintenanceMode()
+}
+
+// MaintenanceMode reports whether the router is in maintenance mode.
+func (r *Router) MaintenanceMode() bool {
+	state := r.maintenance.Load()
+	return state != nil && state.enabled
+}
+
+// inMaintenance answers the request if maintenance mode refuses it and
+// reports whether it did.
+func (r *Router) inMaintenance(rb *RequestBody, w *ResponseRecorder, req *http.Request, prefix string) bool {
+	state := r.maintenance.Load()
+	if state == nil || !state.enabled || state.exempts(req.URL.Path, prefix) {
+		return false
+	}
+
+	cfg := state.cfg
+	retry := cfg.RetryAfter
+	if retry <= 0 {
+		retry = DefaultMaintenanceRetryAfter
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())))
+
+	if cfg.HTML != "" {
+		if t, _ := negotiateType(req.Header.Get("Accept"), []string{"application/json", "text/html"}); t == "text/html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(cfg.HTML))
+			return true
+		}
+	}
+
+	if cfg.JSON != nil {
+		out, err := json.Marshal(cfg.JSON)
+		if err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write(out)
+			return true
+		}
+	}
+
+	rb.ResponseW = w
+	r.handleError(rb, ErrMaintenance)
+	return true
+}
+
+// exempts reports whether path, which carries the router prefix, is exempt
+// from maintenance mode.
+func (s *maintenanceState) exempts(path, prefix string) bool {
+	for _, e := range s.exempt {
+		if p, ok := strings.CutSuffix(e, "*"); ok {
+			if strings.HasPrefix(path, prefix+p) {
+				return true
+			}
+		} else if path == prefix+e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package httpfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RouteManifest is a route table declared in a file, loaded with
+// LoadRouteManifest. Routes are keyed by method and path:
+//
+//	routes:
+//	  "GET /users/{id}":
+//	    handler: users.get
+//	    auth: true
+//	    roles: [admin, support]
+//	    rate_limit:
+//	      rps: 5
+//	      burst: 10
+//	    timeout: 2s
+type RouteManifest struct {
+	Routes map[string]ManifestRoute `json:"routes"`
+}
+
+// ManifestRoute is a route of a RouteManifest.
+type ManifestRoute struct {
+	// Handler names a handler registered with RegisterHandler.
+	Handler string `json:"handler"`
+	Name    string `json:"name"`
+	Auth    bool   `json:"auth"`
+	// Roles require authentication and one of the roles, see RequireRoles.
+	Roles     []string `json:"roles"`
+	RateLimit RateFile `json:"rate_limit"`
+	Timeout   Duration `json:"timeout"`
+	MaxBody   int64    `json:"max_body"`
+}
+
+// RegisterHandler makes h available to route manifests of the default
+// router under name.
+func RegisterHandler(name string, h Handler) {
+	defaultRouter.RegisterHandler(name, h)
+}
+
+// RegisterHandler makes h available to route manifests under name, e.g.
+// "users.get". Registering a name twice panics.
+func (r *Router) RegisterHandler(name string, h Handler) {
+	if _, ok := r.handlers[name]; ok {
+		panic(fmt.Sprintf("httpfly: handler %q registered twice", name))
+	}
+	if r.handlers == nil {
+		r.handlers = map[string]Handler{}
+	}
+	r.handlers[name] = h
+}
+
+// LoadRouteManifest reads a route manifest from a JSON or YAML file, in the
+// YAML subset of LoadConfig. Unknown fields are errors, so typos do not go
+// unnoticed.
+func LoadRouteManifest(path string) (*RouteManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	case ".yaml", ".yml":
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("httpfly: %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("httpfly: unsupported manifest format %q", filepath.Ext(path))
+	}
+
+	m := &RouteManifest{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("httpfly: %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// MapManifest maps the routes of a manifest on the default router. See
+// Router.MapManifest.
+func MapManifest(m *RouteManifest) error {
+	return defaultRouter.MapManifest(m)
+}
+
+// MapManifest validates every route of m, then maps them all with their
+// registered handlers. Unknown handlers, malformed keys, invalid limits
+// and routes that are already mapped are reported together, and nothing
+// is mapped if there is any, so a bad manifest fails at startup.
+func (r *Router) MapManifest(m *RouteManifest) error {
+	type entry struct {
+		method RequestMethod
+		path   string
+		route  ManifestRoute
+	}
+
+	keys := make([]string, 0, len(m.Routes))
+	for key := range m.Routes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	prefix := r.options().Prefix
+	mapped := map[string]bool{}
+	for _, v := range r.currentRoutes().routes {
+		if v.Version == "" && v.Host == "" {
+			mapped[string(v.Method)+" "+v.Endpoint] = true
+		}
+	}
+
+	var entries []entry
+	var errs []error
+
+	for _, key := range keys {
+		mr := m.Routes[key]
+		fail := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("route %q: %s", key, fmt.Sprintf(format, args...)))
+		}
+
+		method, path, ok := strings.Cut(strings.TrimSpace(key), " ")
+		method, path = strings.ToUpper(method), strings.TrimSpace(path)
+		if !ok || method == "" || !strings.HasPrefix(path, "/") {
+			fail(`key must be "METHOD /path"`)
+			continue
+		}
+		if mapped[method+" "+prefix+path] {
+			fail("already mapped")
+			continue
+		}
+		mapped[method+" "+prefix+path] = true
+
+		if r.handlers[mr.Handler] == nil {
+			fail("unknown handler %q", mr.Handler)
+		}
+		if mr.RateLimit.RPS < 0 || mr.RateLimit.Burst < 0 {
+			fail("negative rate limit")
+		}
+		if mr.Timeout < 0 || mr.MaxBody < 0 {
+			fail("negative timeout or max_body")
+		}
+
+		entries = append(entries, entry{RequestMethod(method), path, mr})
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("httpfly: invalid route manifest: %w", errors.Join(errs...))
+	}
+
+	for _, e := range entries {
+		var opts []RouteOption
+		if len(e.route.Roles) > 0 {
+			opts = append(opts, RequireRoles(e.route.Roles...))
+		}
+		if e.route.RateLimit.RPS > 0 {
+			burst := e.route.RateLimit.Burst
+			if burst <= 0 {
+				burst = int(e.route.RateLimit.RPS) + 1
+			}
+			opts = append(opts, WithMiddleware(RateLimit(RateLimitConfig{RPS: e.route.RateLimit.RPS, Burst: burst})))
+		}
+		if e.route.Timeout > 0 {
+			opts = append(opts, WithTimeout(time.Duration(e.route.Timeout)))
+		}
+		if e.route.MaxBody > 0 {
+			opts = append(opts, WithMaxBody(e.route.MaxBody))
+		}
+
+		ri := r.addRoute(e.method, e.path, AuthRequire(e.route.Auth), r.handlers[e.route.Handler], opts)
+		if e.route.Name != "" {
+			ri.Name(e.route.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package httpfly
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Request methods that are rejected by a MethodPolicy unless allowed.
+const (
+	MethodConnect RequestMethod = "CONNECT"
+	MethodTrace   RequestMethod = "TRACE"
+)
+
+// NewRequestMethod returns the request method called name, failing unless
+// name is a valid method token, e.g. "PURGE" or "PROPFIND". Methods are
+// case-sensitive.
+func NewRequestMethod(name string) (RequestMethod, error) {
+	if name == "" {
+		return "", errors.New("httpfly: empty request method")
+	}
+	for i := 0; i < len(name); i++ {
+		if !isTokenChar(name[i]) {
+			return "", fmt.Errorf("httpfly: invalid request method %q", name)
+		}
+	}
+	return RequestMethod(name), nil
+}
+
+// MustRequestMethod is like NewRequestMethod but panics on an invalid
+// name.
+func MustRequestMethod(name string) RequestMethod {
+	m, err := NewRequestMethod(name)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// isTokenChar reports whether c may appear in an RFC 9110 token.
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// MethodPolicy decides which request methods reach the routes of a
+// router. GET, HEAD, POST, PUT, DELETE, PATCH and OPTIONS always do.
+type MethodPolicy struct {
+	// AllowConnect and AllowTrace let CONNECT and TRACE requests through.
+	AllowConnect bool
+	AllowTrace   bool
+	// Allow lists nonstandard methods to let through, e.g. "PURGE".
+	Allow []RequestMethod
+	// AllowNonstandard lets every other method through.
+	AllowNonstandard bool
+	// Status answers rejected requests. Defaults to 501 Not Implemented.
+	Status int
+}
+
+// SetMethodPolicy makes the default router reject requests by method. See
+// Router.SetMethodPolicy.
+func SetMethodPolicy(p MethodPolicy) {
+	defaultRouter.SetMethodPolicy(p)
+}
+
+// SetMethodPolicy makes the router reject requests whose method p does not
+// allow before routing, with p.Status and a framework error body. Without
+// a policy every method is routed, so requests with methods no route is
+// mapped for get 404 or 405.
+func (r *Router) SetMethodPolicy(p MethodPolicy) {
+	if p.Status == 0 {
+		p.Status = http.StatusNotImplemented
+	}
+	r.methodPolicy = &p
+}
+
+// allows reports whether the policy lets method through.
+func (p *MethodPolicy) allows(method string) bool {
+	switch RequestMethod(method) {
+	case MethodGet, MethodHead, MethodPost, MethodPut, MethodDelete, MethodPatch, MethodOptions:
+		return true
+	case MethodConnect:
+		return p.AllowConnect
+	case MethodTrace:
+		return p.AllowTrace
+	}
+	return p.AllowNonstandard || slices.Contains(p.Allow, RequestMethod(method))
+}
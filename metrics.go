@@ -0,0 +1,233 @@
+package httpfly
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsCollector receives request metrics from a router. Implement it to
+// feed another metrics library; PrometheusMetrics is the built-in one.
+type MetricsCollector interface {
+	// InFlight is called with +1 when a request starts and -1 when it ends.
+	InFlight(delta int)
+	// ObserveRequest records a finished request. route is the matched route
+	// pattern, or "" if no route matched.
+	ObserveRequest(method, route string, status int, duration time.Duration, size int)
+}
+
+// UseMetrics sets the metrics collector of the default router.
+func UseMetrics(c MetricsCollector) {
+	defaultRouter.UseMetrics(c)
+}
+
+// UseMetrics sets the metrics collector of the router.
+func (r *Router) UseMetrics(c MetricsCollector) {
+	r.metrics = c
+}
+
+// EnableMetrics collects Prometheus metrics for the default router and
+// serves them at path.
+func EnableMetrics(path string) *PrometheusMetrics {
+	return defaultRouter.EnableMetrics(path)
+}
+
+// EnableMetrics collects Prometheus metrics for the router and serves them
+// at path.
+func (r *Router) EnableMetrics(path string) *PrometheusMetrics {
+	m := NewPrometheusMetrics()
+	r.UseMetrics(m)
+	r.MapGet(path, NoAuth, func(rb *RequestBody) {
+		m.ServeHTTP(rb.ResponseW, rb.req)
+	})
+	return m
+}
+
+// Default histogram buckets of PrometheusMetrics.
+var (
+	DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	DefaultSizeBuckets    = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+)
+
+// PrometheusMetrics collects request metrics and serves them in the
+// Prometheus text format:
+//
+//	httpfly_requests_total{method,route,status}
+//	httpfly_request_duration_seconds{method,route}
+//	httpfly_response_size_bytes{method,route}
+//	httpfly_requests_in_flight
+//
+// It also keeps the recent durations of each route for SlowestRoutes.
+type PrometheusMetrics struct {
+	inFlight atomic.Int64
+	// latencies holds a *latencyRing per routeKey. It is updated without
+	// taking mu.
+	latencies sync.Map
+
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[routeKey]*histogram
+	sizes     map[routeKey]*histogram
+}
+
+type requestKey struct {
+	method, route string
+	status        int
+}
+
+type routeKey struct {
+	method, route string
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// NewPrometheusMetrics creates an empty collector.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		requests:  map[requestKey]uint64{},
+		durations: map[routeKey]*histogram{},
+		sizes:     map[routeKey]*histogram{},
+	}
+}
+
+// InFlight implements MetricsCollector.
+func (m *PrometheusMetrics) InFlight(delta int) {
+	m.inFlight.Add(int64(delta))
+}
+
+// ObserveRequest implements MetricsCollector.
+func (m *PrometheusMetrics) ObserveRequest(method, route string, status int, duration time.Duration, size int) {
+	rk := routeKey{method, route}
+
+	if route != "" {
+		ring, ok := m.latencies.Load(rk)
+		if !ok {
+			ring, _ = m.latencies.LoadOrStore(rk, &latencyRing{})
+		}
+		ring.(*latencyRing).add(duration)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{method, route, status}]++
+
+	observe(m.durations, rk, DefaultLatencyBuckets, duration.Seconds())
+	observe(m.sizes, rk, DefaultSizeBuckets, float64(size))
+}
+
+// SlowestRoutes implements LatencyReporter.
+func (m *PrometheusMetrics) SlowestRoutes(n int) []RouteLatency {
+	return slowestRoutes(&m.latencies, n)
+}
+
+func observe(hs map[routeKey]*histogram, k routeKey, buckets []float64, v float64) {
+	h, ok := hs[k]
+	if !ok {
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		hs[k] = h
+	}
+	h.observe(v)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(bw, "# HELP httpfly_requests_total Total number of handled requests.")
+	fmt.Fprintln(bw, "# TYPE httpfly_requests_total counter")
+
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	for _, k := range keys {
+		fmt.Fprintf(bw, "httpfly_requests_total{method=%s,route=%s,status=\"%d\"} %d\n",
+			promLabel(k.method), promLabel(k.route), k.status, m.requests[k])
+	}
+
+	writeHistograms(bw, "httpfly_request_duration_seconds", "Request duration in seconds.", m.durations)
+	writeHistograms(bw, "httpfly_response_size_bytes", "Response body size in bytes.", m.sizes)
+
+	fmt.Fprintln(bw, "# HELP httpfly_requests_in_flight Number of requests being handled.")
+	fmt.Fprintln(bw, "# TYPE httpfly_requests_in_flight gauge")
+	fmt.Fprintf(bw, "httpfly_requests_in_flight %d\n", m.inFlight.Load())
+}
+
+func writeHistograms(bw *bufio.Writer, name, help string, hs map[routeKey]*histogram) {
+	fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	keys := make([]routeKey, 0, len(hs))
+	for k := range hs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	for _, k := range keys {
+		h := hs[k]
+		labels := "method=" + promLabel(k.method) + ",route=" + promLabel(k.route)
+
+		for i, b := range h.buckets {
+			fmt.Fprintf(bw, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(b, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(bw, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(bw, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(bw, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+// promLabel quotes a Prometheus label value.
+func promLabel(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(v) + `"`
+}
+
+// routePattern returns the pattern of the matched route, or "".
+func (r *RequestBody) routePattern() string {
+	if r.route == nil {
+		return ""
+	}
+	return r.route.Endpoint
+}
@@ -0,0 +1,62 @@
+package httpfly
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mountMethods are the methods routed to mounted handlers.
+var mountMethods = []RequestMethod{MethodGet, MethodHead, MethodPost, MethodPut, MethodPatch, MethodDelete, MethodOptions}
+
+// RequireAuth requires authentication for the route with the router's auth
+// provider, like mapping it with UseAuth. It is meant for Mount.
+func RequireAuth() RouteOption {
+	return func(ri *RouteInfo) {
+		ri.AuthRequired = true
+	}
+}
+
+// Mount serves h below prefix on the default router. See Router.Mount.
+func Mount(prefix string, h http.Handler, opts ...RouteOption) {
+	defaultRouter.Mount(prefix, h, opts...)
+}
+
+// Mount serves h for every request below prefix, e.g. net/http/pprof, a
+// gRPC gateway or an admin UI. The router's middleware and the route
+// options, such as RequireAuth or WithMiddleware, run in front of h. h sees
+// the path with the route prefix and prefix stripped, like with
+// http.StripPrefix, and the request body as read by the router.
+func (r *Router) Mount(prefix string, h http.Handler, opts ...RouteOption) {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	handler := func(rb *RequestBody) {
+		req := rb.req.Clone(rb.Context())
+		req.Body = io.NopCloser(bytes.NewReader(rb.JsonData))
+		req.ContentLength = int64(len(rb.JsonData))
+
+		rest := "/" + string(rb.Params["mountpath"])
+		base := strings.TrimSuffix(rb.route.Endpoint, "/*mountpath")
+		if escaped := req.URL.EscapedPath(); strings.HasPrefix(escaped, base) {
+			rest = "/" + strings.TrimPrefix(strings.TrimPrefix(escaped, base), "/")
+		}
+
+		if path, err := url.PathUnescape(rest); err == nil {
+			req.URL.Path, req.URL.RawPath = path, rest
+		} else {
+			req.URL.Path, req.URL.RawPath = rest, ""
+		}
+		req.RequestURI = req.URL.RequestURI()
+
+		h.ServeHTTP(rb.ResponseW, req)
+	}
+
+	for _, m := range mountMethods {
+		r.addRoute(m, prefix+"/*mountpath", NoAuth, handler, opts)
+		if prefix != "" {
+			r.addRoute(m, prefix, NoAuth, handler, opts)
+		}
+	}
+}
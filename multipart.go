@@ -0,0 +1,52 @@
+package httpfly
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+)
+
+// DefaultMaxMultipartParts is the default limit on multipart parts.
+const DefaultMaxMultipartParts = 1000
+
+// MaxMultipartParts is the maximum number of parts accepted in a
+// multipart request body by the default router. Requests with more parts
+// are rejected with 400 Bad Request before reaching the handler. Zero
+// disables the limit.
+var MaxMultipartParts = DefaultMaxMultipartParts
+
+// errTooManyParts is returned when a multipart body has too many parts.
+var errTooManyParts = errors.New("too many multipart parts")
+
+// checkMultipartParts counts the parts of a multipart body without keeping
+// their contents.
+func checkMultipartParts(contentType string, body []byte, max int) error {
+	if max <= 0 {
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+
+	for n := 0; ; n++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		part.Close()
+
+		if n >= max {
+			return errTooManyParts
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package httpfly
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// multipartBody returns a form with n fields and its content type.
+func multipartBody(n int) ([]byte, string) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for i := 0; i < n; i++ {
+		mw.WriteField("f"+strconv.Itoa(i), "v")
+	}
+	mw.Close()
+	return buf.Bytes(), mw.FormDataContentType()
+}
+
+func TestMultipartPartLimit(t *testing.T) {
+	r := NewRouter()
+	r.MaxMultipartParts = 3
+	r.MapPost("/upload", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "ok") })
+
+	c := NewTestClient(r)
+	for _, tt := range []struct {
+		parts, status int
+	}{
+		{1, http.StatusOK},
+		{3, http.StatusOK},
+		{4, http.StatusBadRequest},
+		{50, http.StatusBadRequest},
+	} {
+		body, contentType := multipartBody(tt.parts)
+		c.Header = http.Header{"Content-Type": {contentType}}
+		if got := c.Post("/api/upload", body).Status; got != tt.status {
+			t.Errorf("%d parts: status %d, want %d", tt.parts, got, tt.status)
+		}
+	}
+
+	r.MaxMultipartParts = 0
+	body, contentType := multipartBody(50)
+	c.Header = http.Header{"Content-Type": {contentType}}
+	if got := c.Post("/api/upload", body).Status; got != http.StatusOK {
+		t.Errorf("no limit: status %d, want 200", got)
+	}
+}
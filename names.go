@@ -0,0 +1,94 @@
+package httpfly
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Name names the route for URLFor and returns it. Names should be unique
+// within a router.
+func (ri *RouteInfo) Name(name string) *RouteInfo {
+	ri.name = name
+	return ri
+}
+
+// URLFor builds the path of the named route of the default router. params
+// alternates parameter names and values, e.g. URLFor("user.show", "id", 42).
+// Parameters that are not in the route pattern are added as query
+// parameters.
+func URLFor(name string, params ...any) (string, error) {
+	return defaultRouter.URLFor(name, params...)
+}
+
+// URLFor builds the path of a named route of the router.
+func (r *Router) URLFor(name string, params ...any) (string, error) {
+	var ri *RouteInfo
+	for _, v := range r.currentRoutes().routes {
+		if v.name == name {
+			ri = v
+			break
+		}
+	}
+
+	if ri == nil {
+		return "", fmt.Errorf("httpfly: no route named %q", name)
+	}
+
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("httpfly: odd number of parameters for route %q", name)
+	}
+
+	values := map[string]string{}
+	var order []string
+	for i := 0; i < len(params); i += 2 {
+		key := fmt.Sprint(params[i])
+		if _, ok := values[key]; !ok {
+			order = append(order, key)
+		}
+		values[key] = fmt.Sprint(params[i+1])
+	}
+
+	segments := append([]string(nil), ri.segments...)
+	for i, s := range segments {
+		var key string
+		switch {
+		case isParamSegment(s):
+			key, _ = paramSegment(s)
+		case isCatchAllSegment(s):
+			key = s[1:]
+		default:
+			continue
+		}
+
+		v, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("httpfly: missing parameter %q for route %q", key, name)
+		}
+		delete(values, key)
+
+		if isCatchAllSegment(s) {
+			parts := strings.Split(v, "/")
+			for j, p := range parts {
+				parts[j] = url.PathEscape(p)
+			}
+			segments[i] = strings.Join(parts, "/")
+		} else {
+			segments[i] = url.PathEscape(v)
+		}
+	}
+
+	path := strings.Join(segments, "/")
+
+	query := url.Values{}
+	for _, key := range order {
+		if v, ok := values[key]; ok {
+			query.Set(key, v)
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	return path, nil
+}
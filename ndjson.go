@@ -0,0 +1,143 @@
+package httpfly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// NDJSONContentType is the media type of newline-delimited JSON.
+const NDJSONContentType = "application/x-ndjson"
+
+// NDJSONStream writes newline-delimited JSON records to the client. It is
+// safe for concurrent use.
+type NDJSONStream struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	ctx context.Context
+
+	mu sync.Mutex
+}
+
+// NDJSONStream starts a newline-delimited JSON response, e.g. for exports
+// or long-running queries whose results are sent as they are produced. It
+// writes the headers and a 200 status right away.
+func (r *RequestBody) NDJSONStream() *NDJSONStream {
+	h := r.ResponseW.Header()
+	h.Set("Content-Type", NDJSONContentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+
+	s := &NDJSONStream{
+		w:   r.ResponseW,
+		rc:  http.NewResponseController(r.ResponseW),
+		ctx: r.Context(),
+	}
+
+	r.ResponseW.WriteHeader(http.StatusOK)
+	s.rc.Flush()
+
+	return s
+}
+
+// Done is closed when the client disconnects.
+func (s *NDJSONStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// SendLine writes v encoded as JSON on a line of its own and flushes it.
+// It returns the context error once the client has disconnected.
+func (s *NDJSONStream) SendLine(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(line); err != nil {
+		return err
+	}
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// NDJSONDecoder reads newline-delimited JSON records from a request body.
+type NDJSONDecoder struct {
+	dec    *json.Decoder
+	record int
+}
+
+// NDJSONDecoder returns a decoder over the records of the request body.
+// With StreamBody on the route, records are decoded as they arrive, so
+// bulk imports need not buffer the whole body.
+//
+//	dec := r.NDJSONDecoder()
+//	for {
+//		var item Item
+//		if err := dec.Decode(&item); err == io.EOF {
+//			break
+//		} else if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (r *RequestBody) NDJSONDecoder() *NDJSONDecoder {
+	return &NDJSONDecoder{dec: json.NewDecoder(r.Body())}
+}
+
+// More reports whether another record follows.
+func (d *NDJSONDecoder) More() bool {
+	return d.dec.More()
+}
+
+// Decode decodes the next record into v and validates it. It returns
+// io.EOF after the last record. Malformed records are answered with 400 and
+// invalid ones with 422 when the error is returned to the error handler;
+// both errors name the 1-based record number.
+func (d *NDJSONDecoder) Decode(v any) error {
+	if !d.dec.More() {
+		if _, err := d.dec.Token(); err != nil && err != io.EOF {
+			return d.malformed(err)
+		}
+		return io.EOF
+	}
+	d.record++
+
+	if err := d.dec.Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return &HTTPError{Status: http.StatusRequestEntityTooLarge, Message: "request body too large"}
+		}
+		return d.malformed(err)
+	}
+
+	var verrs ValidationErrors
+	if err := Validate(v); errors.As(err, &verrs) {
+		for i := range verrs {
+			verrs[i].Field = fmt.Sprintf("[%d].%s", d.record, verrs[i].Field)
+		}
+		return verrs
+	}
+	return nil
+}
+
+// Record returns the number of records decoded so far.
+func (d *NDJSONDecoder) Record() int {
+	return d.record
+}
+
+func (d *NDJSONDecoder) malformed(err error) error {
+	return &HTTPError{Status: http.StatusBadRequest, Code: "invalid_record", Message: fmt.Sprintf("record %d: %v", d.record, err)}
+}
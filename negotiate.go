@@ -0,0 +1,171 @@
+package httpfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// acceptRange is a single media range of an Accept header.
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses an Accept header into media ranges ordered by
+// preference. An empty header is treated as "*/*".
+func parseAccept(header string) []acceptRange {
+	if strings.TrimSpace(header) == "" {
+		return []acceptRange{{"*/*", 1}}
+	}
+
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		r := acceptRange{strings.ToLower(strings.TrimSpace(mediaType)), 1}
+
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "q") {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+
+		if r.mediaType != "" {
+			ranges = append(ranges, r)
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	return ranges
+}
+
+// matches reports whether the range covers the media type.
+func (r acceptRange) matches(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+
+	if r.mediaType == "*/*" || r.mediaType == mediaType {
+		return true
+	}
+
+	prefix, ok := strings.CutSuffix(r.mediaType, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// negotiateType returns the offered media type the Accept header prefers,
+// or false if none of them is acceptable.
+func negotiateType(header string, offers []string) (string, bool) {
+	for _, r := range parseAccept(header) {
+		if r.q <= 0 {
+			continue
+		}
+
+		for _, offer := range offers {
+			if r.matches(offer) && !excluded(header, offer) {
+				return offer, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// excluded reports whether the header explicitly refuses the media type
+// with q=0.
+func excluded(header string, mediaType string) bool {
+	for _, r := range parseAccept(header) {
+		if r.q <= 0 && r.mediaType == strings.ToLower(mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAccept returns a middleware that responds with 406 Not Acceptable
+// unless the request's Accept header allows one of the given media types. A
+// missing Accept header accepts anything.
+func RequireAccept(types ...string) MiddlewareFunc {
+	return func(rb *RequestBody, response http.ResponseWriter, request *http.Request) {
+		if _, ok := negotiateType(request.Header.Get("Accept"), types); !ok {
+			rb.router.frameworkError(response, request, http.StatusNotAcceptable, "")
+		}
+	}
+}
+
+// Encoder writes v to w in a particular media type.
+type Encoder func(w io.Writer, v any) error
+
+// ErrNotAcceptable is returned by Negotiate when no registered encoder
+// matches the Accept header.
+var ErrNotAcceptable = &HTTPError{Status: http.StatusNotAcceptable, Code: "not_acceptable", Message: "no acceptable representation"}
+
+var (
+	encodersMu sync.RWMutex
+	// encoderTypes keeps registration order, which breaks ties between
+	// equally acceptable media types.
+	encoderTypes = []string{"application/json", "application/xml", "application/msgpack", "text/plain"}
+	encoders     = map[string]Encoder{
+		"application/json":    func(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) },
+		"application/xml":     func(w io.Writer, v any) error { return xml.NewEncoder(w).Encode(v) },
+		"application/msgpack": encodeMsgpack,
+		"text/plain": func(w io.Writer, v any) error {
+			_, err := fmt.Fprint(w, v)
+			return err
+		},
+	}
+)
+
+// RegisterEncoder adds or replaces the encoder used by Negotiate for the
+// media type, e.g. for protobuf or CBOR.
+func RegisterEncoder(mediaType string, enc Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	mediaType = strings.ToLower(mediaType)
+	if _, ok := encoders[mediaType]; !ok {
+		encoderTypes = append(encoderTypes, mediaType)
+	}
+	encoders[mediaType] = enc
+}
+
+// Negotiate writes v with the given status, encoded in the registered media
+// type the Accept header prefers: JSON, XML, MessagePack and plain text are
+// built in. It returns ErrNotAcceptable without writing when nothing
+// matches.
+func (r *RequestBody) Negotiate(status int, v any) error {
+	encodersMu.RLock()
+	mediaType, ok := negotiateType(r.req.Header.Get("Accept"), encoderTypes)
+	enc := encoders[mediaType]
+	encodersMu.RUnlock()
+
+	if !ok {
+		return ErrNotAcceptable
+	}
+
+	var buf bytes.Buffer
+	if err := enc(&buf, v); err != nil {
+		return err
+	}
+
+	h := r.ResponseW.Header()
+	if strings.HasPrefix(mediaType, "text/") {
+		h.Set("Content-Type", mediaType+"; charset=utf-8")
+	} else {
+		h.Set("Content-Type", mediaType)
+	}
+	h.Add("Vary", "Accept")
+
+	r.writeHeader(status)
+	_, err := r.ResponseW.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,75 @@
+package httpfly
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	r := NewRouter()
+	r.MapGet("/user", NoAuth, func(rb *RequestBody) {
+		if err := rb.Negotiate(http.StatusOK, user{Name: "alice"}); err != nil {
+			rb.Fail(err)
+		}
+	})
+
+	c := NewTestClient(r)
+	for _, tt := range []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"", http.StatusOK, "application/json"},
+		{"*/*", http.StatusOK, "application/json"},
+		{"application/xml", http.StatusOK, "application/xml"},
+		{"text/html;q=0.9, application/xml;q=0.5, application/json;q=0.8", http.StatusOK, "application/json"},
+		{"application/*;q=0.5, application/json;q=0", http.StatusOK, "application/xml"},
+		{"image/png", http.StatusNotAcceptable, ""},
+		{"*/*;q=0", http.StatusNotAcceptable, ""},
+	} {
+		c.Header = http.Header{}
+		if tt.accept != "" {
+			c.Header.Set("Accept", tt.accept)
+		}
+		resp := c.Get("/api/user")
+		if resp.Status != tt.status {
+			t.Errorf("Accept %q: status %d, want %d", tt.accept, resp.Status, tt.status)
+			continue
+		}
+		if tt.contentType != "" && resp.Header.Get("Content-Type") != tt.contentType {
+			t.Errorf("Accept %q: Content-Type %q, want %q", tt.accept, resp.Header.Get("Content-Type"), tt.contentType)
+		}
+		if tt.status == http.StatusOK && !strings.Contains(resp.String(), "alice") {
+			t.Errorf("Accept %q: body %q", tt.accept, resp.String())
+		}
+	}
+}
+
+func TestRequireAccept(t *testing.T) {
+	r := NewRouter()
+	r.AddMiddleware(RequireAccept("application/json"))
+	r.MapGet("/user", NoAuth, func(rb *RequestBody) { rb.JSON(http.StatusOK, "alice") })
+
+	c := NewTestClient(r)
+	for accept, status := range map[string]int{
+		"":                          http.StatusOK,
+		"application/json":          http.StatusOK,
+		"application/*":             http.StatusOK,
+		"text/html, */*;q=0.1":      http.StatusOK,
+		"text/html":                 http.StatusNotAcceptable,
+		"*/*, application/json;q=0": http.StatusNotAcceptable,
+	} {
+		c.Header = http.Header{}
+		if accept != "" {
+			c.Header.Set("Accept", accept)
+		}
+		if got := c.Get("/api/user").Status; got != status {
+			t.Errorf("Accept %q: status %d, want %d", accept, got, status)
+		}
+	}
+}
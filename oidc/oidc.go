@@ -0,0 +1,474 @@
+// Package oidc signs users in to an httpfly application with the OpenID
+// Connect authorization code flow, against any provider that publishes a
+// discovery document (Google, Microsoft Entra ID, Okta, Auth0, Keycloak,
+// ...). After the callback the user has a server-side session, and routes
+// mapped with UseAuth get the claims of the ID token in RequestBody.Claims.
+//
+//	p, err := oidc.New(ctx, oidc.Config{
+//		Issuer:       "https://accounts.example.com",
+//		ClientID:     "my-app",
+//		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+//		RedirectURL:  "https://app.example.com/api/auth/callback",
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	p.Register(r, "/auth")
+//
+// Browsers are sent to /api/auth/login, optionally with a return_to path,
+// and sign out at /api/auth/logout.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/burakturkerdev/httpfly"
+)
+
+// Defaults of Config.
+const (
+	DefaultCookieName = "oidc_session"
+	DefaultMaxAge     = 24 * time.Hour
+)
+
+// loginTTL bounds the time between login and callback.
+const loginTTL = 10 * time.Minute
+
+// stateCookie binds a login to the browser that started it.
+const stateCookie = "oidc_state"
+
+// Errors of the login flow.
+var (
+	ErrInvalidState = errors.New("oidc: invalid or expired login state")
+	ErrInvalidNonce = errors.New("oidc: ID token nonce does not match")
+)
+
+// Config configures a Provider.
+type Config struct {
+	// Issuer is the URL of the OpenID provider. Its discovery document is
+	// read from Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the absolute URL of the callback endpoint mapped by
+	// Register, as registered with the provider.
+	RedirectURL string
+	// Scopes are requested in addition to "openid". Defaults to "profile"
+	// and "email".
+	Scopes []string
+
+	// Store keeps sessions and pending logins. It must keep server-side
+	// state, e.g. a MemorySessionStore (the default) or a
+	// RedisSessionStore.
+	Store httpfly.SessionStore
+	// CookieName names the session cookie. Defaults to "oidc_session".
+	CookieName string
+	// MaxAge is the lifetime of a session. Defaults to 24 hours.
+	MaxAge time.Duration
+	// AfterLogin is where users go after signing in without a return_to
+	// path, and AfterLogout after signing out when the provider has no
+	// end_session_endpoint or PostLogoutURL is empty. Both default to "/".
+	AfterLogin  string
+	AfterLogout string
+	// PostLogoutURL, when set, is sent to the end_session_endpoint of the
+	// provider as post_logout_redirect_uri.
+	PostLogoutURL string
+
+	// HTTPClient talks to the provider. Defaults to a client with a 10
+	// second timeout.
+	HTTPClient *http.Client
+}
+
+// Provider runs the authorization code flow for one OpenID provider. It is
+// an httpfly.AuthProvider that authenticates requests by their session
+// cookie.
+type Provider struct {
+	cfg      Config
+	endpoint discovery
+	keys     *keySet
+	secure   bool
+}
+
+// discovery holds the fields of the discovery document used here.
+type discovery struct {
+	Issuer        string `json:"issuer"`
+	Authorization string `json:"authorization_endpoint"`
+	Token         string `json:"token_endpoint"`
+	JWKS          string `json:"jwks_uri"`
+	EndSession    string `json:"end_session_endpoint"`
+}
+
+// tokenResponse is the answer of the token endpoint.
+type tokenResponse struct {
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// New reads the discovery document of cfg.Issuer and returns a provider.
+func New(ctx context.Context, cfg Config) (*Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc: Issuer, ClientID and RedirectURL are required")
+	}
+	redirect, err := url.Parse(cfg.RedirectURL)
+	if err != nil || !redirect.IsAbs() {
+		return nil, fmt.Errorf("oidc: RedirectURL %q is not an absolute URL", cfg.RedirectURL)
+	}
+
+	if cfg.Scopes == nil {
+		cfg.Scopes = []string{"profile", "email"}
+	}
+	if cfg.Store == nil {
+		cfg.Store = httpfly.NewMemorySessionStore()
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultCookieName
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = DefaultMaxAge
+	}
+	if cfg.AfterLogin == "" {
+		cfg.AfterLogin = "/"
+	}
+	if cfg.AfterLogout == "" {
+		cfg.AfterLogout = "/"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	p := &Provider{cfg: cfg, secure: redirect.Scheme == "https"}
+
+	wellKnown := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, cfg.HTTPClient, wellKnown, &p.endpoint); err != nil {
+		return nil, err
+	}
+	if p.endpoint.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("oidc: discovery document is for issuer %q, not %q", p.endpoint.Issuer, cfg.Issuer)
+	}
+	if p.endpoint.Authorization == "" || p.endpoint.Token == "" || p.endpoint.JWKS == "" {
+		return nil, errors.New("oidc: discovery document lacks an authorization, token or jwks endpoint")
+	}
+
+	p.keys = &keySet{uri: p.endpoint.JWKS, client: cfg.HTTPClient}
+	return p, nil
+}
+
+// Register maps the login, callback and logout endpoints under path on r
+// and makes the provider authenticate the UseAuth routes of r. The
+// callback must be reachable at Config.RedirectURL.
+func (p *Provider) Register(r *httpfly.Router, path string) {
+	path = strings.TrimSuffix(path, "/")
+
+	r.MapGet(path+"/login", httpfly.NoAuth, p.Login)
+	r.MapGet(path+"/callback", httpfly.NoAuth, p.Callback)
+	r.MapGet(path+"/logout", httpfly.NoAuth, p.Logout)
+	r.SetAuthProvider(p)
+}
+
+// Login redirects the browser to the provider. A local return_to query
+// parameter is where the callback sends the user once signed in.
+func (p *Provider) Login(rb *httpfly.RequestBody) {
+	state, nonce, verifier := randomToken(), randomToken(), randomToken()
+
+	returnTo := rb.Query("return_to")
+	if !localPath(returnTo) {
+		returnTo = p.cfg.AfterLogin
+	}
+
+	pending := map[string]any{"nonce": nonce, "verifier": verifier, "return_to": returnTo}
+	if err := p.cfg.Store.Save(loginKey(state), pending, loginTTL); err != nil {
+		rb.Fail(err)
+		return
+	}
+	p.setCookie(rb, stateCookie, state, loginTTL)
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	rb.Redirect(http.StatusFound, withQuery(p.endpoint.Authorization, q))
+}
+
+// Callback completes a login: it checks the state, exchanges the code for
+// tokens, verifies the ID token and starts a session holding its claims.
+func (p *Provider) Callback(rb *httpfly.RequestBody) {
+	if e := rb.Query("error"); e != "" {
+		rb.Fail(&httpfly.HTTPError{Status: http.StatusUnauthorized, Code: e, Message: rb.Query("error_description")})
+		return
+	}
+
+	state := rb.Query("state")
+	c, err := rb.Cookie(stateCookie)
+	if state == "" || err != nil || c.Value != state {
+		rb.Fail(&httpfly.HTTPError{Status: http.StatusBadRequest, Message: ErrInvalidState.Error()})
+		return
+	}
+	p.setCookie(rb, stateCookie, "", -1)
+
+	pending, ok, err := p.cfg.Store.Load(loginKey(state))
+	if err != nil {
+		rb.Fail(err)
+		return
+	}
+	p.cfg.Store.Delete(loginKey(state))
+	if !ok {
+		rb.Fail(&httpfly.HTTPError{Status: http.StatusBadRequest, Message: ErrInvalidState.Error()})
+		return
+	}
+
+	claims, err := p.exchange(rb.Context(), rb.Query("code"), stringValue(pending["verifier"]), stringValue(pending["nonce"]))
+	if err != nil {
+		rb.Fail(&httpfly.HTTPError{Status: http.StatusUnauthorized, Message: err.Error()})
+		return
+	}
+
+	id := randomToken()
+	if err := p.cfg.Store.Save(id, claims, p.cfg.MaxAge); err != nil {
+		rb.Fail(err)
+		return
+	}
+	p.setCookie(rb, p.cfg.CookieName, id, p.cfg.MaxAge)
+
+	returnTo := stringValue(pending["return_to"])
+	if !localPath(returnTo) {
+		returnTo = p.cfg.AfterLogin
+	}
+	rb.Redirect(http.StatusFound, returnTo)
+}
+
+// Logout ends the session and, when the provider supports it and
+// PostLogoutURL is set, signs the user out at the provider too.
+func (p *Provider) Logout(rb *httpfly.RequestBody) {
+	var idToken string
+
+	if c, err := rb.Cookie(p.cfg.CookieName); err == nil {
+		if values, ok, _ := p.cfg.Store.Load(c.Value); ok {
+			idToken = stringValue(values[idTokenClaim])
+		}
+		p.cfg.Store.Delete(c.Value)
+	}
+	p.setCookie(rb, p.cfg.CookieName, "", -1)
+
+	if p.endpoint.EndSession == "" || p.cfg.PostLogoutURL == "" {
+		rb.Redirect(http.StatusFound, p.cfg.AfterLogout)
+		return
+	}
+
+	q := url.Values{"client_id": {p.cfg.ClientID}, "post_logout_redirect_uri": {p.cfg.PostLogoutURL}}
+	if idToken != "" {
+		q.Set("id_token_hint", idToken)
+	}
+	rb.Redirect(http.StatusFound, withQuery(p.endpoint.EndSession, q))
+}
+
+// Authenticate implements httpfly.AuthProvider.
+func (p *Provider) Authenticate(req *http.Request) (map[string]string, error) {
+	claims, err := p.AuthenticateClaims(req)
+	if err != nil {
+		return nil, err
+	}
+	return claims.Strings(), nil
+}
+
+// AuthenticateClaims implements httpfly.ClaimsProvider. The claims are
+// those of the ID token the session was started with; the raw token is not
+// included.
+func (p *Provider) AuthenticateClaims(req *http.Request) (httpfly.Claims, error) {
+	c, err := req.Cookie(p.cfg.CookieName)
+	if err != nil || c.Value == "" {
+		return nil, httpfly.ErrNoCredentials
+	}
+
+	values, ok, err := p.cfg.Store.Load(c.Value)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, httpfly.ErrTokenExpired
+	}
+
+	claims := make(httpfly.Claims, len(values))
+	for k, v := range values {
+		if k != idTokenClaim {
+			claims[k] = v
+		}
+	}
+	return claims, nil
+}
+
+// idTokenClaim keeps the raw ID token in the session, for logout.
+const idTokenClaim = "oidc:id_token"
+
+// exchange redeems an authorization code and returns the verified claims of
+// the ID token, with the raw token under idTokenClaim.
+func (p *Provider) exchange(ctx context.Context, code, verifier, nonce string) (map[string]any, error) {
+	if code == "" {
+		return nil, errors.New("oidc: missing authorization code")
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {p.cfg.ClientID},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint.Token, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tok tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oidc: token response: %w", err)
+	}
+	if tok.Error != "" {
+		return nil, fmt.Errorf("oidc: token request: %s %s", tok.Error, tok.Description)
+	}
+	if resp.StatusCode != http.StatusOK || tok.IDToken == "" {
+		return nil, fmt.Errorf("oidc: token request: status %d without an ID token", resp.StatusCode)
+	}
+
+	claims, err := p.verify(ctx, tok.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.String("nonce") != nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	values := make(map[string]any, len(claims)+1)
+	for k, v := range claims {
+		values[k] = v
+	}
+	values[idTokenClaim] = tok.IDToken
+	return values, nil
+}
+
+// verify checks the signature, issuer, audience and lifetime of an ID
+// token.
+func (p *Provider) verify(ctx context.Context, token string) (httpfly.Claims, error) {
+	var header struct {
+		Kid string `json:"kid"`
+	}
+	head, _, _ := strings.Cut(token, ".")
+	if b, err := base64.RawURLEncoding.DecodeString(head); err != nil || json.Unmarshal(b, &header) != nil {
+		return nil, httpfly.ErrInvalidToken
+	}
+
+	key, err := p.keys.get(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	jwt := httpfly.NewJWTProvider(httpfly.JWTConfig{
+		RSAPublicKey: key,
+		Issuer:       p.endpoint.Issuer,
+		Audience:     p.cfg.ClientID,
+		Leeway:       time.Minute,
+	})
+	return jwt.Verify(token)
+}
+
+// setCookie sets or, with a negative maxAge, expires a cookie.
+func (p *Provider) setCookie(rb *httpfly.RequestBody, name, value string, maxAge time.Duration) {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(maxAge / time.Second),
+		Secure:   p.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if maxAge < 0 {
+		c.MaxAge = -1
+	}
+	http.SetCookie(rb.ResponseW, c)
+}
+
+// getJSON decodes the JSON document at u into v.
+func getJSON(ctx context.Context, client *http.Client, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s: status %d", u, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("oidc: GET %s: %w", u, err)
+	}
+	return nil
+}
+
+// loginKey is the store key of a pending login.
+func loginKey(state string) string {
+	return "oidc-login:" + state
+}
+
+// localPath reports whether s is a path on this site, so that return_to
+// cannot redirect to another one.
+func localPath(s string) bool {
+	return strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") && !strings.HasPrefix(s, "/\\")
+}
+
+// withQuery appends q to the query of endpoint.
+func withQuery(endpoint string, q url.Values) string {
+	if strings.Contains(endpoint, "?") {
+		return endpoint + "&" + q.Encode()
+	}
+	return endpoint + "?" + q.Encode()
+}
+
+// randomToken returns 256 random bits, base64url encoded.
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func stringValue(v any) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,310 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/burakturkerdev/httpfly"
+)
+
+// fakeProvider is an OpenID provider that signs ID tokens for the logins
+// passed to authorize.
+type fakeProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	kid    string
+	logins map[string]url.Values
+	// claims, when set, edits the claims of the next ID tokens.
+	claims func(map[string]any)
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fp := &fakeProvider{key: key, kid: "k1", logins: map[string]url.Values{}}
+	fp.Server = httptest.NewServer(http.HandlerFunc(fp.serve))
+	t.Cleanup(fp.Close)
+	return fp
+}
+
+func (fp *fakeProvider) serve(w http.ResponseWriter, req *http.Request) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch req.URL.Path {
+	case "/.well-known/openid-configuration":
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 fp.URL,
+			"authorization_endpoint": fp.URL + "/authorize",
+			"token_endpoint":         fp.URL + "/token",
+			"jwks_uri":               fp.URL + "/jwks",
+			"end_session_endpoint":   fp.URL + "/logout",
+		})
+
+	case "/jwks":
+		e := big.NewInt(int64(fp.key.E)).Bytes()
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "EC", "kid": "ignored"},
+			{"kty": "RSA", "use": "sig", "kid": fp.kid, "n": b64(fp.key.N.Bytes()), "e": b64(e)},
+		}})
+
+	case "/token":
+		login, ok := fp.logins[req.PostFormValue("code")]
+		delete(fp.logins, req.PostFormValue("code"))
+
+		user, pass, _ := req.BasicAuth()
+		challenge := sha256.Sum256([]byte(req.PostFormValue("code_verifier")))
+
+		if !ok || user != "app" || pass != "secret" || b64(challenge[:]) != login.Get("code_challenge") ||
+			req.PostFormValue("redirect_uri") != login.Get("redirect_uri") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		claims := map[string]any{
+			"iss":   fp.URL,
+			"aud":   "app",
+			"sub":   "u1",
+			"email": "ada@example.com",
+			"nonce": login.Get("nonce"),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+		if fp.claims != nil {
+			fp.claims(claims)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": fp.sign(claims)})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// authorize plays the user signing in at the provider and returns the
+// authorization code.
+func (fp *fakeProvider) authorize(t *testing.T, location string) string {
+	t.Helper()
+
+	u, err := url.Parse(location)
+	if err != nil || u.Path != "/authorize" {
+		t.Fatalf("login redirected to %q, want the authorization endpoint", location)
+	}
+
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	code := "code-" + u.Query().Get("state")
+	fp.logins[code] = u.Query()
+	return code
+}
+
+func (fp *fakeProvider) sign(claims map[string]any) string {
+	head, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": fp.kid})
+	body, _ := json.Marshal(claims)
+
+	signed := b64(head) + "." + b64(body)
+	h := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, fp.key, crypto.SHA256, h[:])
+	return signed + "." + b64(sig)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// newApp returns a router with the provider registered under /auth and a
+// /me route that needs a session.
+func newApp(t *testing.T, fp *fakeProvider) *httpfly.Router {
+	p, err := New(context.Background(), Config{
+		Issuer:        fp.URL,
+		ClientID:      "app",
+		ClientSecret:  "secret",
+		RedirectURL:   "https://app.example.com/api/auth/callback",
+		PostLogoutURL: "https://app.example.com/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httpfly.NewRouter()
+	p.Register(r, "/auth")
+	r.MapGet("/me", httpfly.UseAuth, func(rb *httpfly.RequestBody) {
+		if rb.Claim(idTokenClaim) != nil {
+			t.Error("the raw ID token leaked into the claims")
+		}
+		rb.Text(http.StatusOK, rb.ClaimString("email"))
+	})
+	return r
+}
+
+// cookie returns the value of the cookie name set by res.
+func cookie(res *httpfly.TestResponse, name string) *http.Cookie {
+	for _, c := range (&http.Response{Header: res.Header}).Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// login starts a login at returnTo, has the provider authorize it and
+// returns the callback response.
+func login(t *testing.T, fp *fakeProvider, r *httpfly.Router, returnTo string) *httpfly.TestResponse {
+	t.Helper()
+
+	c := httpfly.NewTestClient(r)
+	res := c.Get("/api/auth/login?return_to=" + url.QueryEscape(returnTo))
+	if res.Status != http.StatusFound {
+		t.Fatalf("login: status %d, want a redirect", res.Status)
+	}
+
+	code := fp.authorize(t, res.Header.Get("Location"))
+	state := cookie(res, stateCookie)
+	if state == nil || !state.HttpOnly || !state.Secure {
+		t.Fatalf("login state cookie = %v, want a secure HttpOnly cookie", state)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/callback?code="+code+"&state="+state.Value, nil)
+	req.AddCookie(state)
+	return c.Send(req)
+}
+
+func TestLoginAndLogout(t *testing.T) {
+	fp := newFakeProvider(t)
+	r := newApp(t, fp)
+	c := httpfly.NewTestClient(r)
+
+	if res := c.Get("/api/me"); res.Status != http.StatusUnauthorized {
+		t.Fatalf("/me without a session: status %d, want 401", res.Status)
+	}
+
+	res := login(t, fp, r, "/dashboard")
+	if res.Status != http.StatusFound || res.Header.Get("Location") != "/dashboard" {
+		t.Fatalf("callback: status %d to %q, want a redirect to /dashboard: %s", res.Status, res.Header.Get("Location"), res.String())
+	}
+	session := cookie(res, DefaultCookieName)
+	if session == nil {
+		t.Fatal("callback set no session cookie")
+	}
+
+	c.Header.Set("Cookie", session.String())
+	if res := c.Get("/api/me"); res.Status != http.StatusOK || res.String() != "ada@example.com" {
+		t.Fatalf("/me: status %d, body %q; want the email claim", res.Status, res.String())
+	}
+
+	res = c.Get("/api/auth/logout")
+	u, _ := url.Parse(res.Header.Get("Location"))
+	if u == nil || u.Path != "/logout" || u.Query().Get("id_token_hint") == "" || u.Query().Get("post_logout_redirect_uri") != "https://app.example.com/" {
+		t.Errorf("logout redirected to %q, want the end session endpoint with a hint", res.Header.Get("Location"))
+	}
+	if res := c.Get("/api/me"); res.Status != http.StatusUnauthorized {
+		t.Errorf("/me after logout: status %d, want 401", res.Status)
+	}
+}
+
+func TestCallbackRejectsForeignState(t *testing.T) {
+	fp := newFakeProvider(t)
+	r := newApp(t, fp)
+	c := httpfly.NewTestClient(r)
+
+	res := c.Get("/api/auth/login")
+	code := fp.authorize(t, res.Header.Get("Location"))
+	state := cookie(res, stateCookie)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/callback?code="+code+"&state="+state.Value, nil)
+	req.AddCookie(&http.Cookie{Name: stateCookie, Value: "another-browser"})
+	if res := c.Send(req); res.Status != http.StatusBadRequest {
+		t.Errorf("state of another browser: status %d, want 400", res.Status)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/auth/callback?error=access_denied&error_description=no", nil)
+	if res := c.Send(req); res.Status != http.StatusUnauthorized {
+		t.Errorf("provider error: status %d, want 401", res.Status)
+	}
+}
+
+func TestCallbackRejectsBadTokens(t *testing.T) {
+	tests := map[string]func(map[string]any){
+		"wrong nonce":    func(c map[string]any) { c["nonce"] = "replayed" },
+		"wrong audience": func(c map[string]any) { c["aud"] = "other-app" },
+		"wrong issuer":   func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"expired":        func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+	}
+
+	for name, edit := range tests {
+		t.Run(name, func(t *testing.T) {
+			fp := newFakeProvider(t)
+			fp.claims = edit
+
+			res := login(t, fp, newApp(t, fp), "/")
+			if res.Status != http.StatusUnauthorized || cookie(res, DefaultCookieName) != nil {
+				t.Errorf("status %d, want 401 without a session", res.Status)
+			}
+		})
+	}
+}
+
+func TestReturnToStaysOnSite(t *testing.T) {
+	fp := newFakeProvider(t)
+	r := newApp(t, fp)
+
+	for _, returnTo := range []string{"https://evil.example.com", "//evil.example.com", "/\\evil.example.com"} {
+		if res := login(t, fp, r, returnTo); res.Header.Get("Location") != "/" {
+			t.Errorf("return_to %q redirected to %q, want /", returnTo, res.Header.Get("Location"))
+		}
+	}
+}
+
+func TestNewChecksDiscoveryIssuer(t *testing.T) {
+	fp := newFakeProvider(t)
+
+	_, err := New(context.Background(), Config{
+		Issuer:      fp.URL + "/tenant",
+		ClientID:    "app",
+		RedirectURL: "https://app.example.com/api/auth/callback",
+	})
+	if err == nil {
+		t.Error("New accepted a discovery document of another issuer")
+	}
+}
+
+func TestKeySetReloadsAfterRotation(t *testing.T) {
+	fp := newFakeProvider(t)
+	s := &keySet{uri: fp.URL + "/jwks", client: fp.Client()}
+
+	if key, err := s.get(context.Background(), "k1"); err != nil || key.N.Cmp(fp.key.N) != 0 {
+		t.Fatalf("get(k1) = %v, %v", key, err)
+	}
+
+	fp.mu.Lock()
+	fp.kid = "k2"
+	fp.mu.Unlock()
+
+	if _, err := s.get(context.Background(), "k2"); err == nil {
+		t.Error("the key set reloaded within minRefresh")
+	}
+
+	s.fetched = time.Now().Add(-minRefresh)
+	if _, err := s.get(context.Background(), "k2"); err != nil {
+		t.Errorf("get(k2) after minRefresh = %v, want the rotated key", err)
+	}
+}
@@ -0,0 +1,333 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RouteDoc describes a route in the generated OpenAPI document.
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// Request is a sample value of the request body; its type becomes the
+	// request schema.
+	Request any
+	// Responses maps status codes to sample values of the response body.
+	// A nil value documents a response without a body.
+	Responses map[int]any
+}
+
+// WithDoc attaches OpenAPI documentation to a route.
+func WithDoc(doc RouteDoc) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.Meta.Doc = doc
+	}
+}
+
+// OpenAPI returns an OpenAPI 3 document describing the routes of the default
+// router, encoded as JSON. JSON documents are also valid YAML.
+func OpenAPI(title, version string) ([]byte, error) {
+	return defaultRouter.OpenAPI(title, version)
+}
+
+// OpenAPI returns an OpenAPI 3 document describing the routes of the router.
+func (r *Router) OpenAPI(title, version string) ([]byte, error) {
+	return json.MarshalIndent(r.openAPISpec(title, version), "", "  ")
+}
+
+// ServeDocs maps the OpenAPI document of the default router at
+// path/openapi.json and a Swagger UI page at path.
+func ServeDocs(path, title, version string) {
+	defaultRouter.ServeDocs(path, title, version)
+}
+
+// ServeDocs maps the OpenAPI document and a Swagger UI page on the router.
+// The document is generated on each request, so it includes routes mapped
+// later.
+func (r *Router) ServeDocs(path, title, version string) {
+	specPath := r.options().Prefix + path + "/openapi.json"
+
+	r.MapGet(path+"/openapi.json", NoAuth, func(rb *RequestBody) {
+		out, err := r.OpenAPI(title, version)
+		if err != nil {
+			rb.Fail(&HTTPError{Status: http.StatusInternalServerError, Message: err.Error()})
+			return
+		}
+
+		rb.ResponseW.Header().Set("Content-Type", "application/json")
+		rb.ResponseW.Write(out)
+	})
+
+	r.MapGet(path, NoAuth, func(rb *RequestBody) {
+		rb.ResponseW.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rb.ResponseW.Write([]byte(strings.ReplaceAll(swaggerUIPage, "{{spec}}", specPath)))
+	})
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>API docs</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "{{spec}}", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+type (
+	openAPIDoc struct {
+		OpenAPI    string                                  `json:"openapi"`
+		Info       openAPIInfo                             `json:"info"`
+		Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+		Components openAPIComponents                       `json:"components"`
+	}
+
+	openAPIInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+
+	openAPIComponents struct {
+		SecuritySchemes map[string]any `json:"securitySchemes"`
+	}
+
+	openAPIOperation struct {
+		Summary     string                      `json:"summary,omitempty"`
+		Description string                      `json:"description,omitempty"`
+		Tags        []string                    `json:"tags,omitempty"`
+		Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+		RequestBody *openAPIBody                `json:"requestBody,omitempty"`
+		Responses   map[string]*openAPIResponse `json:"responses"`
+		Security    []map[string][]string       `json:"security,omitempty"`
+	}
+
+	openAPIParameter struct {
+		Name     string         `json:"name"`
+		In       string         `json:"in"`
+		Required bool           `json:"required"`
+		Schema   map[string]any `json:"schema"`
+	}
+
+	openAPIBody struct {
+		Content map[string]openAPIMedia `json:"content"`
+	}
+
+	openAPIResponse struct {
+		Description string                  `json:"description"`
+		Content     map[string]openAPIMedia `json:"content,omitempty"`
+	}
+
+	openAPIMedia struct {
+		Schema   map[string]any            `json:"schema,omitempty"`
+		Examples map[string]openAPIExample `json:"examples,omitempty"`
+	}
+
+	openAPIExample struct {
+		Value any `json:"value"`
+	}
+)
+
+// openAPISpec builds the document from the current route table.
+func (r *Router) openAPISpec(title, version string) *openAPIDoc {
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{title, version},
+		Paths:   map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{SecuritySchemes: map[string]any{
+			"bearerAuth": map[string]string{"type": "http", "scheme": "bearer"},
+		}},
+	}
+
+	for _, ri := range r.currentRoutes().routes {
+		path, params := openAPIPath(ri.Endpoint)
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openAPIOperation{}
+		}
+
+		op := &openAPIOperation{
+			Summary:     ri.Meta.Doc.Summary,
+			Description: ri.Meta.Doc.Description,
+			Tags:        ri.Meta.Doc.Tags,
+			Responses:   map[string]*openAPIResponse{},
+		}
+
+		op.Parameters = append(op.Parameters, params...)
+
+		if ri.AuthRequired {
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+		}
+
+		if req := ri.Meta.Doc.Request; req != nil || hasRequestExamples(ri.Meta.Examples) {
+			media := openAPIMedia{Schema: schemaOf(reflect.TypeOf(req))}
+			for _, ex := range ri.Meta.Examples {
+				if ex.Request != nil {
+					media.addExample(ex.Name, ex.Request)
+				}
+			}
+			op.RequestBody = &openAPIBody{Content: map[string]openAPIMedia{"application/json": media}}
+		}
+
+		for status, sample := range ri.Meta.Doc.Responses {
+			res := &openAPIResponse{Description: http.StatusText(status)}
+			if sample != nil {
+				res.Content = map[string]openAPIMedia{"application/json": {Schema: schemaOf(reflect.TypeOf(sample))}}
+			}
+			op.Responses[strconv.Itoa(status)] = res
+		}
+
+		if len(op.Responses) == 0 {
+			op.Responses["200"] = &openAPIResponse{Description: http.StatusText(http.StatusOK)}
+		}
+
+		for _, ex := range ri.Meta.Examples {
+			if ex.Response == nil {
+				continue
+			}
+
+			res := op.Responses["200"]
+			if res == nil {
+				continue
+			}
+			if res.Content == nil {
+				res.Content = map[string]openAPIMedia{"application/json": {Schema: schemaOf(reflect.TypeOf(ex.Response))}}
+			}
+
+			media := res.Content["application/json"]
+			media.addExample(ex.Name, ex.Response)
+			res.Content["application/json"] = media
+		}
+
+		doc.Paths[path][strings.ToLower(string(ri.Method))] = op
+	}
+
+	return doc
+}
+
+func (m *openAPIMedia) addExample(name string, value any) {
+	if m.Examples == nil {
+		m.Examples = map[string]openAPIExample{}
+	}
+	m.Examples[name] = openAPIExample{value}
+}
+
+func hasRequestExamples(examples []Example) bool {
+	for _, ex := range examples {
+		if ex.Request != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIPath converts a route pattern to an OpenAPI path and returns its
+// parameters. Catch-all segments become plain parameters, and typed
+// parameters get the schema of their type.
+func openAPIPath(endpoint string) (string, []openAPIParameter) {
+	segments := strings.Split(endpoint, "/")
+	var params []openAPIParameter
+
+	for i, s := range segments {
+		switch {
+		case isParamSegment(s):
+			name, typ := paramSegment(s)
+			params = append(params, openAPIParameter{name, "path", true, paramSchema(typ)})
+			segments[i] = "{" + name + "}"
+		case isCatchAllSegment(s):
+			params = append(params, openAPIParameter{s[1:], "path", true, map[string]any{"type": "string"}})
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// paramSchema returns the schema of a path parameter type.
+func paramSchema(typ string) map[string]any {
+	switch typ {
+	case "int":
+		return map[string]any{"type": "integer"}
+	case "uint":
+		return map[string]any{"type": "integer", "minimum": 0}
+	case "bool":
+		return map[string]any{"type": "boolean"}
+	case "uuid":
+		return map[string]any{"type": "string", "format": "uuid"}
+	case "alpha":
+		return map[string]any{"type": "string", "pattern": "^[A-Za-z]+$"}
+	}
+	return map[string]any{"type": "string"}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf derives a JSON schema from a Go type.
+func schemaOf(t reflect.Type) map[string]any {
+	if t == nil {
+		return nil
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		var required []string
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || f.Tag.Get("json") == "-" {
+				continue
+			}
+
+			name := fieldName(f)
+			props[name] = schemaOf(f.Type)
+
+			for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+				if strings.TrimSpace(rule) == "required" {
+					required = append(required, name)
+				}
+			}
+		}
+
+		schema := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+
+	return map[string]any{}
+}
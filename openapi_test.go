@@ -0,0 +1,50 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOpenAPIExamples(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+
+	r := NewRouter()
+	r.MapPost("/users", NoAuth, func(rb *RequestBody) {},
+		WithExample("alice", user{Name: "alice"}, map[string]any{"id": 1, "name": "alice"}))
+
+	out, err := r.OpenAPI("test", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			RequestBody struct {
+				Content map[string]struct {
+					Examples map[string]struct{ Value map[string]any }
+				}
+			}
+			Responses map[string]struct {
+				Content map[string]struct {
+					Examples map[string]struct{ Value map[string]any }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	op := doc.Paths["/api/users"]["post"]
+	req := op.RequestBody.Content["application/json"].Examples["alice"].Value
+	res := op.Responses["200"].Content["application/json"].Examples["alice"].Value
+
+	if req["name"] != "alice" {
+		t.Errorf("request example = %v, want the attached payload", req)
+	}
+	if res["id"] != float64(1) || res["name"] != "alice" {
+		t.Errorf("response example = %v, want the attached payload", res)
+	}
+}
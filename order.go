@@ -0,0 +1,63 @@
+package httpfly
+
+import "strings"
+
+// routeLess reports whether a is more specific than b. Segments are compared
+// left to right: static segments come before typed parameters, typed
+// parameters before other parameters, those before a catch-all, and static
+// segments are ordered lexically. Routes that share every segment are
+// ordered by method, version and host.
+func routeLess(a, b *RouteInfo) bool {
+	as := strings.Split(strings.Trim(a.Endpoint, "/"), "/")
+	bs := strings.Split(strings.Trim(b.Endpoint, "/"), "/")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		ak, bk := segmentKind(as[i]), segmentKind(bs[i])
+
+		switch {
+		case ak != bk:
+			return ak < bk
+		case ak == 0 && as[i] != bs[i]:
+			return as[i] < bs[i]
+		}
+	}
+
+	if len(as) != len(bs) {
+		return len(as) > len(bs)
+	}
+
+	if a.Method != b.Method {
+		return a.Method < b.Method
+	}
+
+	if a.Version != b.Version {
+		return a.Version < b.Version
+	}
+
+	return a.Host < b.Host
+}
+
+// segmentKind ranks a pattern segment: 0 for static, 1 for a typed
+// parameter, 2 for other parameters and 3 for a catch-all.
+func segmentKind(s string) int {
+	switch {
+	case isCatchAllSegment(s):
+		return 3
+	case isParamSegment(s):
+		if _, typ := paramSegment(s); typ != "" {
+			return 1
+		}
+		return 2
+	}
+	return 0
+}
+
+// isCatchAllSegment reports whether a path segment is a *catch-all.
+func isCatchAllSegment(s string) bool {
+	return len(s) > 1 && s[0] == '*'
+}
+
+// isParamSegment reports whether a path segment is a {placeholder}.
+func isParamSegment(s string) bool {
+	return strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}")
+}
@@ -0,0 +1,75 @@
+package httpfly
+
+import (
+	"math/rand"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestRouteOrderIndependent(t *testing.T) {
+	patterns := []string{
+		"/files/*rest",
+		"/users/{id}",
+		"/users/{id:int}",
+		"/users/me",
+		"/users/{id}/posts",
+		"/users/me/posts",
+	}
+	requests := map[string]string{
+		"/api/users/me":        "/users/me",
+		"/api/users/42":        "/users/{id:int}",
+		"/api/users/bob":       "/users/{id}",
+		"/api/users/me/posts":  "/users/me/posts",
+		"/api/users/bob/posts": "/users/{id}/posts",
+		"/api/files/users/me":  "/files/*rest",
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for range 20 {
+		order := append([]string(nil), patterns...)
+		rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+
+		r := NewRouter()
+		for _, p := range order {
+			r.MapGet(p, NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, p) })
+		}
+
+		c := NewTestClient(r)
+		for path, want := range requests {
+			if got := c.Get(path).String(); got != want {
+				t.Fatalf("registered as %v: %s matched %s, want %s", order, path, got, want)
+			}
+		}
+	}
+}
+
+func TestMiddlewarePriority(t *testing.T) {
+	var calls []string
+	mark := func(name string) MiddlewareFunc {
+		return func(rb *RequestBody, w http.ResponseWriter, req *http.Request) {
+			calls = append(calls, name)
+			if name == "outer" && req.URL.Query().Has("stop") {
+				w.WriteHeader(http.StatusTeapot)
+			}
+		}
+	}
+
+	r := NewRouter()
+	r.AddMiddleware(mark("a"))
+	r.AddMiddlewarePriority(10, mark("late"))
+	r.AddMiddleware(mark("b"))
+	r.AddMiddlewarePriority(-100, mark("outer"))
+	r.MapGet("/x", NoAuth, func(rb *RequestBody) { calls = append(calls, "handler") })
+
+	c := NewTestClient(r)
+	c.Get("/api/x")
+	if want := []string{"outer", "a", "b", "late", "handler"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	calls = nil
+	if res := c.Get("/api/x?stop"); res.Status != http.StatusTeapot || !slices.Equal(calls, []string{"outer"}) {
+		t.Errorf("status %d, calls %v; want the outermost middleware to end the request", res.Status, calls)
+	}
+}
@@ -0,0 +1,131 @@
+package httpfly
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// Bounds of ParsePagination.
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// Pagination is the page of a list requested by a client.
+type Pagination struct {
+	Page    int
+	PerPage int
+}
+
+// Offset returns the number of items before the page.
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// Page is the envelope written by Paginated.
+type Page struct {
+	Data  any       `json:"data"`
+	Meta  PageMeta  `json:"meta"`
+	Links PageLinks `json:"links"`
+}
+
+// PageMeta describes the position of a page in the list.
+type PageMeta struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// PageLinks are the URLs of the neighbouring pages. Next and Prev are empty
+// on the last and first page.
+type PageLinks struct {
+	Self  string `json:"self"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+// ParsePagination reads the page and limit query parameters, defaulting
+// to the first page of DefaultPerPage items. Limits above MaxPerPage are
+// lowered to it; other values that are not positive integers fail with 400
+// Bad Request.
+func (r *RequestBody) ParsePagination() (Pagination, error) {
+	p := Pagination{Page: 1, PerPage: DefaultPerPage}
+	query := r.Queries()
+
+	for _, field := range []struct {
+		name string
+		dst  *int
+	}{{"page", &p.Page}, {"limit", &p.PerPage}} {
+		s := query.Get(field.name)
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return Pagination{}, &HTTPError{Status: http.StatusBadRequest, Code: "invalid_pagination", Message: fmt.Sprintf("%s must be a positive integer", field.name)}
+		}
+		*field.dst = n
+	}
+
+	p.PerPage = min(p.PerPage, MaxPerPage)
+	return p, nil
+}
+
+// Paginated writes items, one page of a list of total items, as a JSON
+// Page envelope with status 200. The links keep the other query parameters
+// of the request and are built with URLFor when the route is named.
+func (r *RequestBody) Paginated(items any, page, perPage, total int) error {
+	page, perPage = max(page, 1), max(perPage, 1)
+	pages := (total + perPage - 1) / perPage
+
+	if v := reflect.ValueOf(items); !v.IsValid() || (v.Kind() == reflect.Slice && v.IsNil()) {
+		items = []any{}
+	}
+
+	body := Page{
+		Data: items,
+		Meta: PageMeta{Page: page, PerPage: perPage, Total: total, TotalPages: pages},
+		Links: PageLinks{
+			Self:  r.pageURL(page, perPage),
+			First: r.pageURL(1, perPage),
+			Last:  r.pageURL(max(pages, 1), perPage),
+		},
+	}
+	if page < pages {
+		body.Links.Next = r.pageURL(page+1, perPage)
+	}
+	if page > 1 {
+		body.Links.Prev = r.pageURL(min(page-1, max(pages, 1)), perPage)
+	}
+
+	return r.JSON(http.StatusOK, body)
+}
+
+// pageURL returns the URL of a page of the current list.
+func (r *RequestBody) pageURL(page, perPage int) string {
+	if r.req == nil {
+		return ""
+	}
+	path := r.req.URL.Path
+
+	if r.route != nil && r.route.name != "" && r.router != nil {
+		params := make([]any, 0, 2*len(r.Params))
+		for k, v := range r.Params {
+			params = append(params, k, string(v))
+		}
+		if u, err := r.router.URLFor(r.route.name, params...); err == nil {
+			path = u
+		}
+	}
+
+	query := r.req.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(perPage))
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,179 @@
+package httpfly
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// paramTypes are the constraints of typed path parameters such as
+// {id:int}. A segment that does not satisfy the constraint does not match
+// the route.
+var paramTypes = map[string]func(string) bool{
+	"int": func(s string) bool {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	},
+	"uint": func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err == nil
+	},
+	"bool": func(s string) bool {
+		_, err := strconv.ParseBool(s)
+		return err == nil
+	},
+	"uuid":  isUUID,
+	"alpha": isAlpha,
+}
+
+// paramSegment splits a {name} or {name:type} segment.
+func paramSegment(s string) (name, typ string) {
+	name, typ, _ = strings.Cut(s[1:len(s)-1], ":")
+	return name, typ
+}
+
+// checkParamTypes panics if a pattern segment names an unknown parameter
+// type.
+func checkParamTypes(ri *RouteInfo) {
+	for _, s := range ri.segments {
+		if !isParamSegment(s) {
+			continue
+		}
+		if _, typ := paramSegment(s); typ != "" && paramTypes[typ] == nil {
+			panic(fmt.Sprintf("httpfly: unknown parameter type %q in %s", typ, ri.Endpoint))
+		}
+	}
+}
+
+// paramsMatch reports whether the decoded path segments satisfy the typed
+// parameters of ri.
+func paramsMatch(ri *RouteInfo, segments []string) bool {
+	for i, s := range ri.segments {
+		if i >= len(segments) || !isParamSegment(s) {
+			continue
+		}
+		if _, typ := paramSegment(s); typ != "" && !paramTypes[typ](segments[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterParamTypes returns the routes whose typed parameters accept
+// segments.
+func filterParamTypes(routes []*RouteInfo, segments []string) []*RouteInfo {
+	var out []*RouteInfo
+	for _, ri := range routes {
+		if paramsMatch(ri, segments) {
+			out = append(out, ri)
+		}
+	}
+	return out
+}
+
+// String returns the value of a path parameter, or "".
+func (p Parameters) String(name string) string {
+	return string(p[name])
+}
+
+// Int returns a path parameter as an int. A missing or malformed value is
+// a 400 *HTTPError.
+func (p Parameters) Int(name string) (int, error) {
+	v, err := p.Int64(name)
+	return int(v), err
+}
+
+// Int64 returns a path parameter as an int64. A missing or malformed value
+// is a 400 *HTTPError.
+func (p Parameters) Int64(name string) (int64, error) {
+	s, err := p.lookup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, paramError(name, "must be an integer")
+	}
+	return v, nil
+}
+
+// Bool returns a path parameter as a bool, accepting the forms of
+// strconv.ParseBool. A missing or malformed value is a 400 *HTTPError.
+func (p Parameters) Bool(name string) (bool, error) {
+	s, err := p.lookup(name)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, paramError(name, "must be a boolean")
+	}
+	return v, nil
+}
+
+// UUID returns a path parameter that must be a UUID in its canonical
+// textual form, lowercased. A missing or malformed value is a 400
+// *HTTPError.
+func (p Parameters) UUID(name string) (string, error) {
+	s, err := p.lookup(name)
+	if err != nil {
+		return "", err
+	}
+
+	if !isUUID(s) {
+		return "", paramError(name, "must be a UUID")
+	}
+	return strings.ToLower(s), nil
+}
+
+func (p Parameters) lookup(name string) (string, error) {
+	v, ok := p[name]
+	if !ok {
+		return "", paramError(name, "is missing")
+	}
+	return string(v), nil
+}
+
+func paramError(name, msg string) *HTTPError {
+	return &HTTPError{Status: http.StatusBadRequest, Code: "invalid_parameter", Message: fmt.Sprintf("parameter %q %s", name, msg)}
+}
+
+// isUUID reports whether s is a UUID such as
+// 123e4567-e89b-12d3-a456-426614174000.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isAlpha reports whether s is a non-empty run of ASCII letters.
+func isAlpha(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
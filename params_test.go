@@ -0,0 +1,48 @@
+package httpfly
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCatchAllWithQuery(t *testing.T) {
+	var rest, query string
+
+	r := NewRouter()
+	r.MapGet("/proxy/*rest", NoAuth, func(rb *RequestBody) {
+		rest = string(rb.Params["rest"])
+		query = rb.RawQuery()
+	})
+
+	res := NewTestClient(r).Get("/api/proxy/v1/a%20b/c?x=1&y=two%20words")
+	if res.Status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", res.Status)
+	}
+	if rest != "v1/a b/c" {
+		t.Errorf("captured path = %q, want the decoded path without the query", rest)
+	}
+	if query != "x=1&y=two%20words" {
+		t.Errorf("raw query = %q", query)
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	var id string
+
+	r := NewRouter()
+	r.MapGet("/users/{id}", NoAuth, func(rb *RequestBody) { id = string(rb.Params["id"]) })
+
+	c := NewTestClient(r)
+	if res := c.Get("/api/users/a%20b"); res.Status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", res.Status)
+	}
+	if id != "a b" {
+		t.Errorf("id = %q, want the decoded segment", id)
+	}
+
+	for _, path := range []string{"/api/users/", "/api/users/1/extra"} {
+		if res := c.Get(path); res.Status != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", path, res.Status)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package httpfly
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RedirectTrailingSlash makes the default router redirect requests that
+// only match a route after adding or removing a trailing slash to the
+// canonical path. The redirect uses 308 so the method and body are
+// preserved, and keeps the query string.
+var RedirectTrailingSlash = false
+
+// SetStrictSlash sets Options.StrictSlash of the default router.
+func SetStrictSlash(strict bool) {
+	defaultRouter.StrictSlash = strict
+}
+
+// SetCaseInsensitivePaths sets Options.CaseInsensitivePaths of the default
+// router.
+func SetCaseInsensitivePaths(enabled bool) {
+	defaultRouter.CaseInsensitivePaths = enabled
+}
+
+// toggleSlash adds or removes the trailing slash of an escaped path.
+func toggleSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path + "/"
+}
+
+// matchToggledSlash matches the request path with its trailing slash
+// toggled.
+func matchToggledSlash(table *routeTable, method string, req *http.Request, fold bool) (*RouteInfo, Parameters) {
+	path := req.URL.EscapedPath()
+	if path == "/" {
+		return nil, nil
+	}
+
+	if fold {
+		return table.matchFold(method, toggleSlash(path), requestVersion(req), req.Host)
+	}
+	return table.match(method, toggleSlash(path), requestVersion(req), req.Host)
+}
+
+// matchMethod matches the path of req for method, falling back to
+// case-insensitive and slash-toggled matches as opts allow.
+func matchMethod(table *routeTable, method string, req *http.Request, opts Options) (*RouteInfo, Parameters) {
+	v, params := table.match(method, req.URL.EscapedPath(), requestVersion(req), req.Host)
+
+	if v == nil && opts.CaseInsensitivePaths {
+		v, params = table.matchFold(method, req.URL.EscapedPath(), requestVersion(req), req.Host)
+	}
+
+	if v == nil && !opts.StrictSlash && !opts.RedirectTrailingSlash {
+		v, params = matchToggledSlash(table, method, req, opts.CaseInsensitivePaths)
+	}
+
+	return v, params
+}
+
+// trailingSlashTarget returns the canonical URL for a request whose path
+// matches a route once its trailing slash is toggled.
+func trailingSlashTarget(table *routeTable, req *http.Request) (string, bool) {
+	path := req.URL.EscapedPath()
+	if path == "/" {
+		return "", false
+	}
+
+	path = toggleSlash(path)
+
+	if v, _ := table.match(req.Method, path, requestVersion(req), req.Host); v == nil {
+		return "", false
+	}
+
+	target := path
+	if req.URL.RawQuery != "" {
+		target += "?" + req.URL.RawQuery
+	}
+
+	return target, true
+}
+
+// checkPathEncoding validates the percent-encoding of the path as sent by
+// the client, before any matching or parameter extraction happens.
+func checkPathEncoding(req *http.Request) error {
+	raw := req.URL.RawPath
+
+	if req.RequestURI != "" {
+		raw, _, _ = strings.Cut(req.RequestURI, "?")
+	}
+
+	_, err := url.PathUnescape(raw)
+	return err
+}
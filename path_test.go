@@ -0,0 +1,39 @@
+package httpfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectTrailingSlashKeepsQuery(t *testing.T) {
+	r := NewRouter()
+	r.RedirectTrailingSlash = true
+	r.MapPost("/orders", NoAuth, func(rb *RequestBody) {})
+
+	res := NewTestClient(r).Post("/api/orders/?dry_run=1&tag=a%20b", `{}`)
+	if res.Status != http.StatusPermanentRedirect {
+		t.Fatalf("status = %d, want 308", res.Status)
+	}
+	if loc := res.Header.Get("Location"); loc != "/api/orders?dry_run=1&tag=a%20b" {
+		t.Errorf("Location = %q, want the canonical path with the query", loc)
+	}
+}
+
+func TestInvalidPercentEncoding(t *testing.T) {
+	r := NewRouter()
+	r.MapGet("/files/{name}", NoAuth, func(rb *RequestBody) {})
+
+	for uri, want := range map[string]int{
+		"/api/files/a%20b": http.StatusOK,
+		"/api/files/a%zzb": http.StatusBadRequest,
+		"/api/files/a%2":   http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/files/x", nil)
+		req.RequestURI = uri
+
+		if res := NewTestClient(r).Send(req); res.Status != want {
+			t.Errorf("%s: status = %d, want %d", uri, res.Status, want)
+		}
+	}
+}
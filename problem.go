@@ -0,0 +1,204 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. It implements error, so
+// handlers can return it to have it written as the response.
+type Problem struct {
+	// Type is a URI identifying the problem type; empty means "about:blank".
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// Extensions are additional members of the problem object.
+	Extensions map[string]any
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + ": " + p.Detail
+	}
+	return p.Title
+}
+
+// MarshalJSON implements json.Marshaler, flattening the extensions into the
+// problem object.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// NewProblem creates a problem with the given status, using the status
+// text as title when title is empty.
+func NewProblem(status int, title, detail string) *Problem {
+	if title == "" {
+		title = http.StatusText(status)
+	}
+	return &Problem{Title: title, Status: status, Detail: detail}
+}
+
+// UseProblemDetails makes the default router answer errors with problem
+// details. See Router.UseProblemDetails.
+func UseProblemDetails() {
+	defaultRouter.UseProblemDetails()
+}
+
+// UseProblemDetails makes the router answer its own errors, such as 400,
+// 401, 403, 404, 405, 413, 415 and 500 responses, and the errors rendered
+// by DefaultErrorHandler with application/problem+json bodies. HTTPError
+// codes become a "code" member, validation errors an "errors" member.
+func (r *Router) UseProblemDetails() {
+	r.problems = true
+}
+
+// Problem writes an application/problem+json response with the given
+// status, title and detail. An empty title means the status text.
+func (r *RequestBody) Problem(status int, title, detail string) error {
+	p := NewProblem(status, title, detail)
+	if r.req != nil {
+		p.Instance = r.req.URL.Path
+	}
+	return r.WriteProblem(p)
+}
+
+// WriteProblem writes p as an application/problem+json response.
+func (r *RequestBody) WriteProblem(p *Problem) error {
+	writeProblem(r.ResponseW, p)
+	return nil
+}
+
+// writeProblem writes p with its status.
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	out, _ := json.Marshal(p)
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	w.Write(out)
+}
+
+// problemFor converts an error rendered by DefaultErrorHandler into a
+// problem. It returns nil for errors that become 500 responses.
+func problemFor(err error) *Problem {
+	var p *Problem
+	var httpErr *HTTPError
+	var verrs ValidationErrors
+
+	switch {
+	case errors.As(err, &p):
+		return p
+	case errors.As(err, &verrs):
+		p = NewProblem(http.StatusUnprocessableEntity, "validation failed", "")
+		p.Extensions = map[string]any{"errors": verrs}
+		return p
+	case errors.As(err, &httpErr):
+		p = NewProblem(httpErr.Status, "", httpErr.Message)
+		if httpErr.Code != "" {
+			p.Extensions = map[string]any{"code": httpErr.Code}
+		}
+		return p
+	}
+	return nil
+}
+
+// ErrorEncoder writes an error response the router generates itself, such
+// as a 404, 405 or 413.
+type ErrorEncoder func(w http.ResponseWriter, req *http.Request, e *HTTPError)
+
+// SetErrorEncoder sets the encoder of the errors the default router
+// generates. See Router.SetErrorEncoder.
+func SetErrorEncoder(f ErrorEncoder) {
+	defaultRouter.SetErrorEncoder(f)
+}
+
+// SetErrorEncoder replaces how the router writes the errors it generates
+// itself, which by default are JSON bodies like those of HTTPError, e.g.
+// {"code":"not_found","message":"Not Found"}, or problems with
+// UseProblemDetails. The code is the status text in snake case. A panic in
+// f is logged and the default body sent instead. 500 responses of internal
+// errors keep their body, which carries the error id. nil restores the
+// default.
+func (r *Router) SetErrorEncoder(f ErrorEncoder) {
+	r.errorEncoder = f
+}
+
+// frameworkError answers a request the router rejects itself through the
+// error encoder. An empty detail means the status text. r may be nil.
+func (r *Router) frameworkError(w http.ResponseWriter, req *http.Request, status int, detail string) {
+	e := &HTTPError{Status: status, Code: statusCode(status), Message: detail}
+	if detail == "" {
+		e.Message = http.StatusText(status)
+	}
+
+	if r != nil && r.errorEncoder != nil && r.encodeError(w, req, e) {
+		return
+	}
+
+	if r != nil && r.problems {
+		p := NewProblem(status, "", detail)
+		p.Instance = req.URL.Path
+		p.Extensions = map[string]any{"code": e.Code}
+		writeProblem(w, p)
+		return
+	}
+
+	writeJSONError(w, status, e)
+}
+
+// encodeError runs the custom error encoder, reporting false if it panicked
+// before writing a response.
+func (r *Router) encodeError(w http.ResponseWriter, req *http.Request, e *HTTPError) (ok bool) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.logger.Error("error encoder panicked", "method", req.Method, "path", req.URL.Path, "error", fmt.Sprint(rec))
+			rw, isRecorder := w.(*ResponseRecorder)
+			ok = isRecorder && rw.written()
+		}
+	}()
+
+	r.errorEncoder(w, req, e)
+	return true
+}
+
+// statusCode returns the status text of status in snake case, e.g.
+// "method_not_allowed".
+func statusCode(status int) string {
+	text := strings.ToLower(http.StatusText(status))
+	if text == "" {
+		return "error"
+	}
+
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			return c
+		case c == ' ', c == '-':
+			return '_'
+		}
+		return -1
+	}, text)
+}
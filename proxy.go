@@ -0,0 +1,138 @@
+package httpfly
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// ProxyOption configures a proxy route.
+type ProxyOption func(*proxyConfig)
+
+type proxyConfig struct {
+	auth        AuthRequire
+	stripPrefix bool
+	headers     http.Header
+	rewrite     func(*httputil.ProxyRequest)
+	transport   http.RoundTripper
+	routeOpts   []RouteOption
+}
+
+// ProxyAuth sets the auth requirement of the proxy routes.
+func ProxyAuth(auth AuthRequire) ProxyOption {
+	return func(c *proxyConfig) {
+		c.auth = auth
+	}
+}
+
+// ProxyStripPrefix removes the route path from the forwarded request path,
+// so /gateway/users is forwarded to target/users.
+func ProxyStripPrefix() ProxyOption {
+	return func(c *proxyConfig) {
+		c.stripPrefix = true
+	}
+}
+
+// ProxySetHeader sets a header on forwarded requests.
+func ProxySetHeader(key, value string) ProxyOption {
+	return func(c *proxyConfig) {
+		if c.headers == nil {
+			c.headers = http.Header{}
+		}
+		c.headers.Set(key, value)
+	}
+}
+
+// ProxyRewrite lets f modify the outgoing request after the default
+// rewriting, e.g. to remove headers or change the path.
+func ProxyRewrite(f func(*httputil.ProxyRequest)) ProxyOption {
+	return func(c *proxyConfig) {
+		c.rewrite = f
+	}
+}
+
+// ProxyTransport sets the transport used to reach the target.
+func ProxyTransport(t http.RoundTripper) ProxyOption {
+	return func(c *proxyConfig) {
+		c.transport = t
+	}
+}
+
+// ProxyRouteOptions applies route options, such as WithMiddleware or
+// RequireRoles, to the proxy routes.
+func ProxyRouteOptions(opts ...RouteOption) ProxyOption {
+	return func(c *proxyConfig) {
+		c.routeOpts = append(c.routeOpts, opts...)
+	}
+}
+
+// proxyMethods are the methods mapped by MapProxy.
+var proxyMethods = []RequestMethod{MethodGet, MethodHead, MethodPost, MethodPut, MethodPatch, MethodDelete, MethodOptions}
+
+// MapProxy forwards requests to path and everything below it to target on
+// the default router. See Router.MapProxy.
+func MapProxy(path string, target *url.URL, opts ...ProxyOption) []*RouteInfo {
+	return defaultRouter.MapProxy(path, target, opts...)
+}
+
+// MapProxy forwards requests to path and everything below it to target
+// through httputil.ReverseProxy. The routes run auth, middleware and the
+// other request checks like any route, so the router can act as an API
+// gateway. X-Forwarded-* headers are set on forwarded requests.
+func (r *Router) MapProxy(path string, target *url.URL, opts ...ProxyOption) []*RouteInfo {
+	var cfg proxyConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	path = strings.TrimSuffix(path, "/")
+	prefix := r.options().Prefix + path
+
+	proxy := &httputil.ReverseProxy{
+		Transport: cfg.transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if cfg.stripPrefix {
+				pr.Out.URL.Path = strings.TrimPrefix(pr.In.URL.Path, prefix)
+				pr.Out.URL.RawPath = ""
+			}
+
+			rest := pr.Out.URL.Path
+			pr.SetURL(target)
+			if rest == "" && target.Path != "" {
+				pr.Out.URL.Path, pr.Out.URL.RawPath = target.Path, target.RawPath
+			}
+			pr.SetXForwarded()
+			pr.Out.Host = target.Host
+
+			for k, v := range cfg.headers {
+				pr.Out.Header[k] = v
+			}
+
+			if cfg.rewrite != nil {
+				cfg.rewrite(pr)
+			}
+		},
+	}
+
+	handler := func(rb *RequestBody) {
+		// The body has already been read by the router.
+		req := rb.req.Clone(rb.Context())
+		req.Body = io.NopCloser(bytes.NewReader(rb.JsonData))
+		req.ContentLength = int64(len(rb.JsonData))
+
+		proxy.ServeHTTP(rb.ResponseW, req)
+	}
+
+	var routes []*RouteInfo
+	for _, method := range proxyMethods {
+		routes = append(routes,
+			r.addRoute(method, path, cfg.auth, handler, cfg.routeOpts),
+			r.addRoute(method, path+"/*proxypath", cfg.auth, handler, cfg.routeOpts),
+		)
+	}
+
+	return routes
+}
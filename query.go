@@ -0,0 +1,113 @@
+package httpfly
+
+import (
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// QueryMap groups query parameters written in bracket notation under the
+// given prefix. For "filter[status]=active&filter[tag]=x", QueryMap("filter")
+// returns {"status": "active", "tag": "x"}. Deeper nesting such as
+// "filter[user][name]" is flattened to the key "user.name". Only the first
+// value of a repeated key is kept.
+func (r *RequestBody) QueryMap(prefix string) map[string]string {
+	result := map[string]string{}
+
+	if r.req == nil {
+		return result
+	}
+
+	for k, v := range r.req.URL.Query() {
+		if len(v) == 0 || !strings.HasPrefix(k, prefix+"[") || !strings.HasSuffix(k, "]") {
+			continue
+		}
+
+		key := k[len(prefix)+1 : len(k)-1]
+		if key == "" {
+			continue
+		}
+
+		result[strings.ReplaceAll(key, "][", ".")] = v[0]
+	}
+
+	return result
+}
+
+// RawQuery returns the encoded query string of the request without the
+// leading '?'. Together with a captured path it lets proxy handlers rebuild
+// the full target URL.
+func (r *RequestBody) RawQuery() string {
+	if r.req == nil {
+		return ""
+	}
+	return r.req.URL.RawQuery
+}
+
+// Queries returns the parsed query parameters of the request.
+func (r *RequestBody) Queries() url.Values {
+	if r.req == nil {
+		return url.Values{}
+	}
+	return r.req.URL.Query()
+}
+
+// Query returns the first value of the named query parameter, or "".
+func (r *RequestBody) Query(name string) string {
+	return r.Queries().Get(name)
+}
+
+// QueryInt returns the named query parameter as an int. It returns def if
+// the parameter is missing, and def with the parse error if it is not an
+// integer.
+func (r *RequestBody) QueryInt(name string, def int) (int, error) {
+	v := r.Query(name)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def, err
+	}
+
+	return n, nil
+}
+
+// FormValue returns the first value of the named field of a URL-encoded or
+// multipart form body, falling back to the query string.
+func (r *RequestBody) FormValue(name string) string {
+	if r.form == nil {
+		r.form = r.parseForm()
+	}
+
+	if v, ok := r.form[name]; ok && len(v) > 0 {
+		return v[0]
+	}
+
+	return r.Query(name)
+}
+
+// parseForm parses the form fields of the request body.
+func (r *RequestBody) parseForm() url.Values {
+	if r.req == nil {
+		return url.Values{}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.req.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if form, err := url.ParseQuery(string(r.JsonData)); err == nil {
+			return form
+		}
+
+	case "multipart/form-data":
+		if form, err := r.multipartForm(); err == nil {
+			return form.Value
+		}
+	}
+
+	return url.Values{}
+}
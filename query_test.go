@@ -0,0 +1,26 @@
+package httpfly
+
+import (
+	"maps"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryMap(t *testing.T) {
+	tests := []struct {
+		query string
+		want  map[string]string
+	}{
+		{"filter[a]=1&filter[b]=2", map[string]string{"a": "1", "b": "2"}},
+		{"filter[user][name]=bob&sort=name&filterx[a]=3&filter[]=4", map[string]string{"user.name": "bob"}},
+		{"filter[a]=1&filter[a]=2", map[string]string{"a": "1"}},
+		{"", map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		rb := &RequestBody{req: httptest.NewRequest("GET", "/?"+tt.query, nil)}
+		if got := rb.QueryMap("filter"); !maps.Equal(got, tt.want) {
+			t.Errorf("%q: QueryMap = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package httpfly
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is the error answered once a caller has used up its
+// quota.
+var ErrQuotaExceeded = &HTTPError{Status: http.StatusTooManyRequests, Code: "quota_exceeded", Message: "request quota exceeded"}
+
+// QuotaPeriod is the period after which quotas reset.
+type QuotaPeriod int
+
+// Quota periods. Periods start at midnight UTC.
+const (
+	QuotaDaily QuotaPeriod = iota
+	QuotaMonthly
+)
+
+// bounds returns the period containing t.
+func (p QuotaPeriod) bounds(t time.Time) (start, end time.Time) {
+	t = t.UTC()
+	if p == QuotaMonthly {
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// QuotaStore counts the requests of a quota key. Counters expire at the
+// end of their period.
+type QuotaStore interface {
+	// Increment adds one to the counter of key and returns the new count.
+	Increment(key string, expires time.Time) (int64, error)
+}
+
+// QuotaConfig configures request quotas.
+type QuotaConfig struct {
+	// Limit is the number of requests allowed per key and period.
+	Limit  int64
+	Period QuotaPeriod
+	// KeyFunc returns the identity quotas are kept for. It defaults to the
+	// "sub" claim, or the client IP for unauthenticated requests. Requests
+	// for which it returns "" are not counted.
+	KeyFunc func(rb *RequestBody) string
+	// Store keeps the counters. It defaults to an in-process store; use
+	// RedisQuotaStore to share quotas between instances.
+	Store QuotaStore
+}
+
+// UseQuota enforces request quotas on every route of the default router.
+// See Quota.
+func UseQuota(cfg QuotaConfig) {
+	defaultRouter.UseQuota(cfg)
+}
+
+// UseQuota enforces request quotas on every route of the router.
+func (r *Router) UseQuota(cfg QuotaConfig) {
+	r.AddMiddleware(Quota(cfg))
+}
+
+// Quota returns middleware that counts requests per caller and period.
+// Responses carry X-Quota-Limit, X-Quota-Remaining and X-Quota-Reset, the
+// Unix time the quota resets; requests beyond the limit are answered with
+// ErrQuotaExceeded and a Retry-After header. Store errors let requests
+// through. Being middleware, it runs after authentication, so claims are
+// available to KeyFunc.
+func Quota(cfg QuotaConfig) MiddlewareFunc {
+	keyFunc := cfg.KeyFunc
+	if keyFunc == nil {
+		keyFunc = func(rb *RequestBody) string {
+			if sub := rb.ClaimString("sub"); sub != "" {
+				return "sub:" + sub
+			}
+			return "ip:" + rb.ClientIP()
+		}
+	}
+
+	store := cfg.Store
+	if store == nil {
+		store = NewMemoryQuotaStore()
+	}
+
+	return func(rb *RequestBody, response http.ResponseWriter, request *http.Request) {
+		key := keyFunc(rb)
+		if key == "" {
+			return
+		}
+
+		now := time.Now()
+		start, end := cfg.Period.bounds(now)
+
+		count, err := store.Increment(key+":"+strconv.FormatInt(start.Unix(), 10), end)
+		if err != nil {
+			if rb.router != nil && rb.router.logger != nil {
+				rb.router.logger.Error("quota store failed", "error", err.Error())
+			}
+			return
+		}
+
+		h := response.Header()
+		h.Set("X-Quota-Limit", strconv.FormatInt(cfg.Limit, 10))
+		h.Set("X-Quota-Remaining", strconv.FormatInt(max(cfg.Limit-count, 0), 10))
+		h.Set("X-Quota-Reset", strconv.FormatInt(end.Unix(), 10))
+
+		if count > cfg.Limit {
+			h.Set("Retry-After", strconv.Itoa(int(end.Sub(now).Seconds())+1))
+			rb.Fail(ErrQuotaExceeded)
+		}
+	}
+}
+
+// MemoryQuotaStore is an in-process QuotaStore.
+type MemoryQuotaStore struct {
+	mu       sync.Mutex
+	counters map[string]memoryQuota
+	created  int
+}
+
+type memoryQuota struct {
+	count   int64
+	expires time.Time
+}
+
+// NewMemoryQuotaStore creates an empty in-process store.
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{counters: map[string]memoryQuota{}}
+}
+
+// Increment implements QuotaStore. Expired counters are swept every 1024
+// new counters.
+func (s *MemoryQuotaStore) Increment(key string, expires time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok {
+		c.expires = expires
+
+		s.created++
+		if s.created%1024 == 0 {
+			now := time.Now()
+			for k, c := range s.counters {
+				if now.After(c.expires) {
+					delete(s.counters, k)
+				}
+			}
+		}
+	}
+
+	c.count++
+	s.counters[key] = c
+	return c.count, nil
+}
+
+// RedisQuotaStore keeps quota counters in Redis, so they are shared
+// between instances.
+type RedisQuotaStore struct {
+	RedisConfig
+	// Prefix is prepended to quota keys; default "quota:".
+	Prefix string
+
+	pool redisPool
+}
+
+// NewRedisQuotaStore creates a store for the Redis server at addr.
+func NewRedisQuotaStore(addr string) *RedisQuotaStore {
+	return &RedisQuotaStore{RedisConfig: RedisConfig{Addr: addr}}
+}
+
+// Increment implements QuotaStore.
+func (s *RedisQuotaStore) Increment(key string, expires time.Time) (int64, error) {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "quota:"
+	}
+	key = prefix + key
+
+	reply, err := s.pool.do(s.RedisConfig, "INCR", key)
+	if err != nil {
+		return 0, err
+	}
+
+	count, _ := reply.(int64)
+	if count == 1 {
+		if _, err := s.pool.do(s.RedisConfig, "PEXPIREAT", key, strconv.FormatInt(expires.UnixMilli(), 10)); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,13 @@
+package httpfly
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
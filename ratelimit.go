@@ -0,0 +1,167 @@
+package httpfly
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token-bucket limiter refilled at rate tokens per second
+// up to burst tokens.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take consumes a token if one is available. Otherwise it returns the time
+// until the next token is available.
+func (b *tokenBucket) take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// GlobalRateLimit returns a middleware that limits the total request rate of
+// the server to rps requests per second with bursts of up to burst requests,
+// regardless of the client. Requests over the limit get 429 Too Many
+// Requests with a Retry-After header. Like every middleware it runs once
+// the route is matched, the caller authenticated and the body read; use
+// UseGlobalRateLimit to reject requests before any of that. It panics
+// unless rps and burst are positive.
+func GlobalRateLimit(rps, burst int) MiddlewareFunc {
+	checkRate("GlobalRateLimit", float64(rps), burst)
+	bucket := newTokenBucket(float64(rps), burst)
+
+	return func(rb *RequestBody, response http.ResponseWriter, request *http.Request) {
+		if ok, wait := bucket.take(); !ok {
+			rb.router.tooManyRequests(rb.ResponseW, rb.req, wait)
+		}
+	}
+}
+
+// UseGlobalRateLimit limits the total request rate of the default router.
+// See Router.UseGlobalRateLimit.
+func UseGlobalRateLimit(rps, burst int) {
+	defaultRouter.UseGlobalRateLimit(rps, burst)
+}
+
+// UseGlobalRateLimit limits the total request rate of the router to rps
+// requests per second with bursts of up to burst requests, as
+// GlobalRateLimit does, but checks requests before routing, so requests
+// over the limit are answered with 429 without authenticating the caller
+// or reading the body. It panics unless rps and burst are positive.
+func (r *Router) UseGlobalRateLimit(rps, burst int) {
+	checkRate("UseGlobalRateLimit", float64(rps), burst)
+	r.globalLimit = newTokenBucket(float64(rps), burst)
+}
+
+// checkRate panics unless a limiter with rps and burst lets requests
+// through.
+func checkRate(name string, rps float64, burst int) {
+	if rps <= 0 || burst < 1 {
+		panic(fmt.Sprintf("httpfly: %s needs a positive rate and burst, got %v and %d", name, rps, burst))
+	}
+}
+
+// tooManyRequests writes a 429 response asking the client to retry after
+// wait, rounded up to whole seconds.
+func (r *Router) tooManyRequests(w http.ResponseWriter, req *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	r.frameworkError(w, req, http.StatusTooManyRequests, "")
+}
+
+// RateLimitConfig configures per-client rate limiting.
+type RateLimitConfig struct {
+	// RPS is the sustained number of requests per second allowed per key.
+	RPS float64
+	// Burst is the number of requests a key can make at once.
+	Burst int
+	// KeyFunc returns the key requests are limited by. It defaults to
+	// ClientIP; returning e.g. an API key from Claims limits per caller.
+	KeyFunc func(rb *RequestBody) string
+}
+
+// rateLimitSweep is how often idle buckets are dropped.
+const rateLimitSweep = time.Minute
+
+// UseRateLimit limits every route of the default router per client.
+func UseRateLimit(cfg RateLimitConfig) {
+	defaultRouter.UseRateLimit(cfg)
+}
+
+// UseRateLimit limits every route of the router per client.
+func (r *Router) UseRateLimit(cfg RateLimitConfig) {
+	r.AddMiddleware(RateLimit(cfg))
+}
+
+// RateLimit returns a token-bucket middleware keyed by cfg.KeyFunc. Requests
+// over the limit get 429 Too Many Requests with a Retry-After header. Attach
+// it to single routes with WithMiddleware or to groups with GroupMiddleware;
+// each call creates an independent set of buckets. It panics unless
+// cfg.RPS and cfg.Burst are positive.
+func RateLimit(cfg RateLimitConfig) MiddlewareFunc {
+	checkRate("RateLimit", cfg.RPS, cfg.Burst)
+
+	keyFunc := cfg.KeyFunc
+	if keyFunc == nil {
+		keyFunc = (*RequestBody).ClientIP
+	}
+
+	var mu sync.Mutex
+	buckets := map[string]*tokenBucket{}
+	lastSweep := time.Now()
+
+	return func(rb *RequestBody, response http.ResponseWriter, request *http.Request) {
+		key := keyFunc(rb)
+
+		mu.Lock()
+		if now := time.Now(); now.Sub(lastSweep) > rateLimitSweep {
+			for k, b := range buckets {
+				if b.idle(now) {
+					delete(buckets, k)
+				}
+			}
+			lastSweep = now
+		}
+
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = newTokenBucket(cfg.RPS, cfg.Burst)
+			buckets[key] = bucket
+		}
+		mu.Unlock()
+
+		if ok, wait := bucket.take(); !ok {
+			rb.router.tooManyRequests(rb.ResponseW, rb.req, wait)
+		}
+	}
+}
+
+// idle reports whether the bucket would be full at now, so dropping it does
+// not change the limit.
+func (b *tokenBucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
@@ -0,0 +1,79 @@
+package httpfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGlobalRateLimitAcrossClients(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(r *Router)
+	}{
+		{"middleware", func(r *Router) { r.AddMiddleware(GlobalRateLimit(1, 3)) }},
+		{"before routing", func(r *Router) { r.UseGlobalRateLimit(1, 3) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter()
+			tt.setup(r)
+			r.MapGet("/ping", NoAuth, func(rb *RequestBody) {})
+
+			c := NewTestClient(r)
+			for i, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "198.51.100.7", "203.0.113.9"} {
+				req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
+				req.RemoteAddr = ip + ":1234"
+
+				want := http.StatusOK
+				if i >= 3 {
+					want = http.StatusTooManyRequests
+				}
+				res := c.Send(req)
+				if res.Status != want {
+					t.Errorf("request %d from %s: status = %d, want %d", i, ip, res.Status, want)
+				}
+				if want == http.StatusTooManyRequests && res.Header.Get("Retry-After") == "" {
+					t.Errorf("request %d: missing Retry-After", i)
+				}
+			}
+		})
+	}
+}
+
+func TestUseGlobalRateLimitRunsBeforeAuth(t *testing.T) {
+	var authCalls int
+	r := NewRouter()
+	r.UseGlobalRateLimit(1, 1)
+	r.SetAuthProvider(tokenProvider("secret"))
+	r.AuditHook = func(AuditEvent) { authCalls++ }
+	r.MapGet("/private", UseAuth, func(rb *RequestBody) {})
+
+	c := NewTestClient(r)
+	c.Get("/api/private")
+	if res := c.Get("/api/private"); res.Status != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", res.Status)
+	}
+	if authCalls != 1 {
+		t.Errorf("auth ran %d times, want 1", authCalls)
+	}
+}
+
+func TestRateLimitRejectsInvalidRate(t *testing.T) {
+	for name, f := range map[string]func(){
+		"zero rps":   func() { GlobalRateLimit(0, 1) },
+		"zero burst": func() { GlobalRateLimit(1, 0) },
+		"router":     func() { NewRouter().UseGlobalRateLimit(-1, 1) },
+		"per client": func() { RateLimit(RateLimitConfig{Burst: 1}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,57 @@
+package httpfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// RedactedValue replaces redacted fields.
+const RedactedValue = "***"
+
+// RedactJSON returns a copy of a JSON document with the named fields
+// replaced by RedactedValue. Fields are dot separated paths such as
+// "user.password"; paths are applied to every element of arrays they pass
+// through. Bodies that are not valid JSON are returned unchanged.
+func RedactJSON(body []byte, fields []string) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return append([]byte(nil), body...)
+	}
+
+	for _, f := range fields {
+		redactPath(doc, strings.Split(f, "."))
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return append([]byte(nil), body...)
+	}
+
+	return out
+}
+
+// redactPath replaces the value at path inside doc.
+func redactPath(doc any, path []string) {
+	switch v := doc.(type) {
+	case map[string]any:
+		child, ok := v[path[0]]
+		if !ok {
+			return
+		}
+
+		if len(path) == 1 {
+			v[path[0]] = RedactedValue
+			return
+		}
+
+		redactPath(child, path[1:])
+	case []any:
+		for _, item := range v {
+			redactPath(item, path)
+		}
+	}
+}
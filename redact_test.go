@@ -0,0 +1,79 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	body := []byte(`{"password":"hunter2","name":"alice","user":{"password":"x","card":{"number":"4111"}},"items":[{"token":"a"},{"token":"b"}]}`)
+	got := RedactJSON(body, []string{"password", "user.card.number", "items.token", "missing.field"})
+
+	var doc map[string]any
+	if err := json.Unmarshal(got, &doc); err != nil {
+		t.Fatal(err)
+	}
+	user := doc["user"].(map[string]any)
+	items := doc["items"].([]any)
+
+	checks := map[string]any{
+		"top-level":      doc["password"],
+		"nested":         user["card"].(map[string]any)["number"],
+		"array elements": items[1].(map[string]any)["token"],
+	}
+	for name, v := range checks {
+		if v != RedactedValue {
+			t.Errorf("%s field = %v, want %q", name, v, RedactedValue)
+		}
+	}
+	if doc["name"] != "alice" || user["password"] != "x" {
+		t.Errorf("unnamed fields changed: %s", got)
+	}
+
+	if string(RedactJSON([]byte("not json"), []string{"a"})) != "not json" {
+		t.Error("invalid JSON was changed")
+	}
+}
+
+func TestLogBodiesRedacts(t *testing.T) {
+	log := &recordLogger{}
+	r := NewRouter()
+	r.SetLogger(log)
+	r.LogBodies(BodyLogConfig{Redact: []string{"password", "user.secret"}})
+	r.MapPost("/login", NoAuth, func(rb *RequestBody) {})
+
+	NewTestClient(r).Post("/api/login", map[string]any{"password": "p", "user": map[string]any{"name": "alice", "secret": "s"}})
+
+	line := log.find("request")
+	if line == nil {
+		t.Fatal("request was not logged")
+	}
+	if want := `{"password":"***","user":{"name":"alice","secret":"***"}}`; line["body"] != want {
+		t.Errorf("body = %v, want %s", line["body"], want)
+	}
+}
+
+func TestAuditLogRedacts(t *testing.T) {
+	var recs []AuditRecord
+	r := NewRouter()
+	r.UseAuditLog(AuditLogConfig{
+		Sink:       AuditSinkFunc(func(rec AuditRecord) error { recs = append(recs, rec); return nil }),
+		AllRoutes:  true,
+		RecordBody: true,
+		Redact:     []string{"card.number"},
+	})
+	r.MapPost("/pay", NoAuth, func(rb *RequestBody) {})
+
+	NewTestClient(r).Post("/api/pay", map[string]any{"amount": 5, "card": map[string]any{"number": "4111"}})
+
+	if len(recs) != 1 {
+		t.Fatalf("got %d audit records, want 1", len(recs))
+	}
+	if want := `{"amount":5,"card":{"number":"***"}}`; string(recs[0].Body) != want {
+		t.Errorf("body = %s, want %s", recs[0].Body, want)
+	}
+	if recs[0].BodyDigest == "" || recs[0].Status != http.StatusOK {
+		t.Errorf("record = %+v", recs[0])
+	}
+}
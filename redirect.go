@@ -0,0 +1,72 @@
+package httpfly
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// acmeChallengePath is where ACME HTTP-01 challenges are requested.
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// StartHTTPSWithRedirect runs the startup hooks and serves the default
+// router over HTTPS on httpsAddr, with a companion HTTP listener on
+// httpAddr that redirects to it. See Router.StartTLSWithRedirect.
+func StartHTTPSWithRedirect(httpsAddr, httpAddr, certFile, keyFile string) error {
+	return defaultRouter.StartTLSWithRedirect(httpsAddr, httpAddr, certFile, keyFile)
+}
+
+// StartTLSWithRedirect serves the router over HTTPS on httpsAddr and
+// redirects plain HTTP requests on httpAddr, e.g. ":80", to it. Use a
+// Server with RedirectAddr and ACMEWebroot to also answer ACME challenges
+// of an external client such as certbot.
+func (r *Router) StartTLSWithRedirect(httpsAddr, httpAddr, certFile, keyFile string) error {
+	s := NewServer(httpsAddr, r)
+	s.CertFile, s.KeyFile = certFile, keyFile
+	s.RedirectAddr = httpAddr
+	return s.Start(context.Background())
+}
+
+// httpsRedirect redirects requests to the same host and path over HTTPS
+// on the port of httpsAddr, serving ACME challenge files from webroot if
+// it is set. GET and HEAD requests get 301 Moved Permanently, others 308
+// Permanent Redirect so clients repeat them with their body.
+func httpsRedirect(httpsAddr, webroot string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	if port == "443" {
+		port = ""
+	}
+
+	var challenges http.Handler
+	if webroot != "" {
+		dir := http.Dir(filepath.Join(webroot, filepath.FromSlash(acmeChallengePath)))
+		challenges = http.StripPrefix(acmeChallengePath, http.FileServer(dir))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if challenges != nil && strings.HasPrefix(req.URL.Path, acmeChallengePath) {
+			challenges.ServeHTTP(w, req)
+			return
+		}
+
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if port != "" {
+			host += ":" + port
+		}
+
+		status := http.StatusMovedPermanently
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), status)
+	})
+}
@@ -0,0 +1,144 @@
+package httpfly
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedisConfig tells the Redis-backed stores how to reach the server. They
+// speak the Redis protocol directly and need no client library.
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	// DialTimeout defaults to 5 seconds.
+	DialTimeout time.Duration
+}
+
+// redisPool keeps idle connections for reuse.
+type redisPool struct {
+	once sync.Once
+	idle chan *redisConn
+}
+
+// do runs a command on a pooled connection. Connections that fail are
+// dropped.
+func (p *redisPool) do(cfg RedisConfig, args ...string) (any, error) {
+	p.once.Do(func() { p.idle = make(chan *redisConn, 8) })
+
+	var c *redisConn
+	select {
+	case c = <-p.idle:
+	default:
+		var err error
+		if c, err = cfg.dial(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := c.do(args...)
+
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		c.Close()
+		return nil, err
+	}
+
+	select {
+	case p.idle <- c:
+	default:
+		c.Close()
+	}
+
+	return reply, err
+}
+
+func (cfg RedisConfig) dial() (*redisConn, error) {
+	timeout := cfg.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	conn, err := net.DialTimeout("tcp", cfg.Addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &redisConn{Conn: conn, r: bufio.NewReader(conn)}
+
+	if cfg.Password != "" {
+		if _, err := c.do("AUTH", cfg.Password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if cfg.DB != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(cfg.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// do sends a command and reads its reply. Bulk replies are returned as
+// string, nil bulk replies as nil, integers as int64.
+func (c *redisConn) do(args ...string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+
+	if _, err := io.WriteString(c.Conn, b.String()); err != nil {
+		return nil, err
+	}
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	}
+
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,165 @@
+package httpfly
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNoTemplates is returned by Render when no templates have been set.
+var ErrNoTemplates = errors.New("httpfly: no templates set, see SetTemplates")
+
+// templateSet holds the parsed pages of a router.
+type templateSet struct {
+	glob   string
+	funcs  template.FuncMap
+	reload bool
+
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+}
+
+// SetTemplates loads the HTML templates of the default router. See
+// Router.SetTemplates.
+func SetTemplates(glob string, funcs template.FuncMap) error {
+	return defaultRouter.SetTemplates(glob, funcs)
+}
+
+// SetTemplates loads the HTML templates matching glob, e.g.
+// "templates/*.html", for Render. Files whose name starts with "_" are
+// layouts and partials shared by every page; each other file is a page,
+// parsed with its own copy of them, so pages can fill the same blocks of a
+// layout:
+//
+//	_layout.html:  <html><body>{{block "content" .}}{{end}}</body></html>
+//	home.html:     {{template "_layout.html" .}}{{define "content"}}Hi {{.Name}}{{end}}
+//
+// Templates are parsed once and cached unless SetTemplateReload is
+// enabled.
+func (r *Router) SetTemplates(glob string, funcs template.FuncMap) error {
+	set := &templateSet{glob: glob, funcs: funcs}
+	if r.templates != nil {
+		set.reload = r.templates.reload
+	}
+
+	if err := set.load(); err != nil {
+		return err
+	}
+
+	r.templates = set
+	return nil
+}
+
+// SetTemplateReload makes the default router re-parse its templates on
+// every Render.
+func SetTemplateReload(enabled bool) {
+	defaultRouter.SetTemplateReload(enabled)
+}
+
+// SetTemplateReload makes the router re-parse its templates on every
+// Render, so edits show up without a restart. Meant for development.
+func (r *Router) SetTemplateReload(enabled bool) {
+	if r.templates == nil {
+		r.templates = &templateSet{}
+	}
+	r.templates.reload = enabled
+}
+
+// load parses the templates matching the glob.
+func (s *templateSet) load() error {
+	files, err := filepath.Glob(s.glob)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("httpfly: no templates match %q", s.glob)
+	}
+
+	var shared, pages []string
+	for _, f := range files {
+		if strings.HasPrefix(filepath.Base(f), "_") {
+			shared = append(shared, f)
+		} else {
+			pages = append(pages, f)
+		}
+	}
+
+	// T is replaced per request in Render; this one lets pages parse.
+	base := template.New("").Funcs(template.FuncMap{"T": fmt.Sprintf}).Funcs(s.funcs)
+	if len(shared) > 0 {
+		if base, err = base.ParseFiles(shared...); err != nil {
+			return err
+		}
+	}
+
+	parsed := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if t, err = t.ParseFiles(page); err != nil {
+			return err
+		}
+		parsed[filepath.Base(page)] = t
+	}
+
+	s.mu.Lock()
+	s.pages = parsed
+	s.mu.Unlock()
+	return nil
+}
+
+// lookup returns the page template called name.
+func (s *templateSet) lookup(name string) (*template.Template, error) {
+	if s.reload {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("httpfly: template %q not found", name)
+	}
+	return t.Lookup(name), nil
+}
+
+// Render executes the page template called name, the base name of its
+// file, with data and writes it as an HTML response with the given status.
+// The page is rendered completely before anything is written, so a
+// template error can still be answered with an error response.
+func (r *RequestBody) Render(status int, name string, data any) error {
+	if r.router == nil || r.router.templates == nil || r.router.templates.glob == "" {
+		return ErrNoTemplates
+	}
+
+	t, err := r.router.templates.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	if r.router.i18n != nil {
+		if t, err = t.Clone(); err != nil {
+			return err
+		}
+		t.Funcs(template.FuncMap{"T": r.T})
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	r.ResponseW.Header().Set("Content-Type", "text/html; charset=utf-8")
+	r.writeHeader(status)
+	_, err = r.ResponseW.Write(buf.Bytes())
+	return err
+}
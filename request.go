@@ -0,0 +1,72 @@
+package httpfly
+
+import (
+	"net"
+	"net/http"
+)
+
+// Proto returns the HTTP protocol version of the request.
+func (r *RequestBody) Proto() (major, minor int) {
+	if r.req == nil {
+		return 0, 0
+	}
+	return r.req.ProtoMajor, r.req.ProtoMinor
+}
+
+// ProtoString returns the protocol of the request as sent in the request
+// line, e.g. "HTTP/1.1".
+func (r *RequestBody) ProtoString() string {
+	if r.req == nil {
+		return ""
+	}
+	return r.req.Proto
+}
+
+// RequestLine reconstructs the request line, e.g. "GET /api/users HTTP/1.1".
+func (r *RequestBody) RequestLine() string {
+	if r.req == nil {
+		return ""
+	}
+
+	uri := r.req.RequestURI
+	if uri == "" {
+		uri = r.req.URL.RequestURI()
+	}
+
+	return r.req.Method + " " + uri + " " + r.req.Proto
+}
+
+// Request returns the underlying HTTP request.
+func (r *RequestBody) Request() *http.Request {
+	return r.req
+}
+
+// Header returns the first value of the named request header, or "".
+func (r *RequestBody) Header(name string) string {
+	if r.req == nil {
+		return ""
+	}
+	return r.req.Header.Get(name)
+}
+
+// Cookie returns the named request cookie, or http.ErrNoCookie.
+func (r *RequestBody) Cookie(name string) (*http.Cookie, error) {
+	if r.req == nil {
+		return nil, http.ErrNoCookie
+	}
+	return r.req.Cookie(name)
+}
+
+// RemoteIP returns the IP address of the connection peer, without the port.
+func (r *RequestBody) RemoteIP() string {
+	if r.req == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(r.req.RemoteAddr)
+	if err != nil {
+		return r.req.RemoteAddr
+	}
+
+	return host
+}
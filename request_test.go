@@ -0,0 +1,42 @@
+package httpfly
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestLineAndProto(t *testing.T) {
+	r := NewRouter()
+	r.MapGet("/line", NoAuth, func(rb *RequestBody) {
+		major, minor := rb.Proto()
+		rb.Text(http.StatusOK, fmt.Sprintf("%d.%d|%s|%s", major, minor, rb.ProtoString(), rb.RequestLine()))
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for _, proto := range []string{"HTTP/1.1", "HTTP/1.0"} {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fmt.Fprintf(conn, "GET /api/line?q=a%%20b %s\r\nHost: example.com\r\nConnection: close\r\n\r\n", proto)
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		conn.Close()
+
+		want := fmt.Sprintf("1.%c|%s|GET /api/line?q=a%%20b %s", proto[len(proto)-1], proto, proto)
+		if string(body) != want {
+			t.Errorf("%s: got %q, want %q", proto, body, want)
+		}
+	}
+}
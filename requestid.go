@@ -0,0 +1,88 @@
+package httpfly
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// RequestIDHeader is the header that carries request IDs.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// UseRequestID assigns a request ID to every request of the default router.
+// See Router.UseRequestID.
+func UseRequestID() {
+	defaultRouter.UseRequestID()
+}
+
+// UseRequestID assigns a request ID to every request of the router, before
+// routing. The ID is taken from the X-Request-ID header when it is a safe
+// token, and generated as a random UUID otherwise. It is echoed in the
+// response header, added to the access and error logs, and available
+// through RequestID and RequestIDFromContext.
+func (r *Router) UseRequestID() {
+	r.requestID = true
+}
+
+// RequestID returns the ID of the request, or "" if request IDs are not
+// enabled.
+func (r *RequestBody) RequestID() string {
+	if r.req == nil {
+		return ""
+	}
+	return RequestIDFromContext(r.req.Context())
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "". Pass it
+// on in the X-Request-ID header of calls to other services.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// assignRequestID stores the request ID in the context of rb and sets the
+// response header.
+func assignRequestID(rb *RequestBody, w http.ResponseWriter) {
+	id := rb.req.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = newUUID()
+	}
+
+	w.Header().Set(RequestIDHeader, id)
+	rb.WithValue(requestIDKey{}, id)
+}
+
+// validRequestID reports whether a client-supplied ID is short and only
+// contains characters that are safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,69 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// JSON writes v as a JSON response with the given status.
+func (r *RequestBody) JSON(status int, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	r.ResponseW.Header().Set("Content-Type", "application/json")
+	r.writeHeader(status)
+	_, err = r.ResponseW.Write(b)
+	return err
+}
+
+// Text writes s as a plain text response with the given status.
+func (r *RequestBody) Text(status int, s string) error {
+	r.ResponseW.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	r.writeHeader(status)
+	_, err := r.ResponseW.Write([]byte(s))
+	return err
+}
+
+// NoContent writes a response with the given status and no body.
+func (r *RequestBody) NoContent(status int) {
+	r.writeHeader(status)
+}
+
+// Status writes the response status unless one has been written already,
+// and returns r for chaining, e.g. r.Status(http.StatusAccepted).
+func (r *RequestBody) Status(code int) *RequestBody {
+	r.writeHeader(code)
+	return r
+}
+
+// Redirect redirects the request to url with a 3xx status, e.g.
+// http.StatusFound or http.StatusPermanentRedirect.
+func (r *RequestBody) Redirect(status int, url string) {
+	if r.w != nil && r.w.written() {
+		return
+	}
+	http.Redirect(r.ResponseW, r.req, url, status)
+}
+
+// ResponseHeader returns the header map of the response. Request headers
+// are read with Header.
+func (r *RequestBody) ResponseHeader() http.Header {
+	return r.ResponseW.Header()
+}
+
+// SetHeader sets a response header and returns r for chaining.
+func (r *RequestBody) SetHeader(key, value string) *RequestBody {
+	r.ResponseW.Header().Set(key, value)
+	return r
+}
+
+// writeHeader writes status once; later calls through the response helpers
+// are ignored instead of triggering a superfluous WriteHeader.
+func (r *RequestBody) writeHeader(status int) {
+	if r.w != nil && r.w.written() {
+		return
+	}
+	r.ResponseW.WriteHeader(status)
+}
@@ -0,0 +1,581 @@
+package httpfly
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRoutePrefix is the route prefix of new routers.
+const DefaultRoutePrefix = "/api"
+
+// Options configures a Router.
+type Options struct {
+	// Prefix is prepended to every route path at registration.
+	Prefix string
+	// BufferResponses makes handlers write into an in-memory buffer that is
+	// flushed after the handler returns, so the status and headers can still
+	// be changed after the body has been written.
+	BufferResponses bool
+	// MaxHeaderBytes is the maximum size of the request line and headers.
+	// Larger requests are answered with 431.
+	MaxHeaderBytes int
+	// RedirectTrailingSlash redirects requests that only match a route after
+	// toggling their trailing slash. See the RedirectTrailingSlash variable.
+	RedirectTrailingSlash bool
+	// StrictSlash makes a trailing slash significant, so "/users/" does not
+	// match a "/users" route. When false, a request that only matches after
+	// toggling its trailing slash is served by that route, unless
+	// RedirectTrailingSlash redirects it. NewRouter enables it.
+	StrictSlash bool
+	// CaseInsensitivePaths lets static path segments match regardless of
+	// case when no route matches exactly. Parameter values keep their case.
+	CaseInsensitivePaths bool
+	// MaxMultipartParts limits the number of parts of multipart bodies.
+	// Zero disables the limit.
+	MaxMultipartParts int
+	// SniffBodies lets Bind pick a decoder from the body when the request
+	// has no usable Content-Type.
+	SniffBodies bool
+	// Production hides error details from clients. NewRouter turns it on.
+	Production bool
+	// AuditHook, when set, receives an event for every auth decision.
+	AuditHook func(AuditEvent)
+	// MaxBodySize limits the size of request bodies in bytes. Larger bodies
+	// are answered with 413. Zero means DefaultMaxBodySize; a negative
+	// value disables the limit.
+	MaxBodySize int64
+	// MaxUploadMemory is the part of a multipart body kept in memory; the
+	// rest of the uploaded files is stored in temporary files. Zero means
+	// DefaultMaxUploadMemory.
+	MaxUploadMemory int64
+	// MaxFileSize limits the size of a single uploaded file. Larger files
+	// are reported as ErrFileTooLarge. Zero disables the limit.
+	MaxFileSize int64
+	// MaxDecompressedSize limits the size of a compressed request body once
+	// decompressed, guarding against zip bombs. Zero means
+	// DefaultMaxDecompressedSize; a negative value disables the limit.
+	MaxDecompressedSize int64
+	// MaxBatchSize limits the number of sub-requests of a batch call.
+	// Larger batches are answered with 413. Zero means DefaultMaxBatchSize;
+	// a negative value disables the limit.
+	MaxBatchSize int
+}
+
+// Router is an independent set of routes, middleware and hooks. The
+// package-level functions operate on a default Router.
+//
+// Routes and middleware can be registered and removed while the router is
+// serving. Other settings, such as the auth provider, error handler and
+// logger, must be configured before the server starts.
+type Router struct {
+	Options
+
+	// routesMu serializes writers of the route table. Readers load the
+	// current table without locking.
+	routesMu sync.Mutex
+	routes   atomic.Pointer[routeTable]
+
+	// middlewareMu serializes writers of the middleware set. Like the route
+	// table, the set is replaced rather than modified, so registering
+	// middleware while serving is safe.
+	middlewareMu sync.Mutex
+	middlewares  atomic.Pointer[middlewareSet]
+	cors         *CORSConfig
+	secure       *SecureConfig
+	headers      map[string]string
+	sanitize     *SanitizeConfig
+	slow         *slowRequests
+	requestID    bool
+	timeout      time.Duration
+	globalLimit  *tokenBucket
+	proxies      trustedProxies
+
+	errorHandler     ErrorHandlerFunc
+	notFound         Handler
+	methodNotAllowed Handler
+	metrics          MetricsCollector
+	health           *healthState
+	templates        *templateSet
+	// draining is set once a server shutdown has started.
+	draining atomic.Bool
+
+	authProvider       AuthProvider
+	authorizer         Authorizer
+	startupHooks       []func(ctx context.Context) error
+	startHooks         []func(addr net.Addr)
+	shutdownHooks      []func(ctx context.Context)
+	routeHooks         []func(ri *RouteInfo)
+	serverErrorHooks   []func(err error)
+	handlers           map[string]Handler
+	listenRetries      int
+	listenBackoff      time.Duration
+	afterResponseHooks []AfterResponseFunc
+	panicHooks         []func(rb *RequestBody, recovered any)
+	requestBodyHooks   []BodyHook
+	responseBodyHooks  []BodyHook
+	logger             Logger
+	bodyLog            *BodyLogConfig
+	workers            workerPool
+	i18n               *i18n
+	dev                *DevConfig
+	problems           bool
+	methodPolicy       *MethodPolicy
+	wsOrigins          *WSOriginPolicy
+	errorEncoder       ErrorEncoder
+	maintenanceMu      sync.Mutex
+	maintenance        atomic.Pointer[maintenanceState]
+	cookieKeys         []cookieKey
+}
+
+// NewRouter creates a Router with default options.
+func NewRouter() *Router {
+	return &Router{
+		Options: Options{
+			Prefix:            DefaultRoutePrefix,
+			MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+			MaxMultipartParts: DefaultMaxMultipartParts,
+			StrictSlash:       true,
+			Production:        true,
+		},
+		logger: slogDefault{},
+	}
+}
+
+var defaultRouter = NewRouter()
+
+// options returns the effective options of the router. The default router
+// follows the package-level variables so that code setting them keeps
+// working.
+func (r *Router) options() Options {
+	o := r.Options
+
+	if r == defaultRouter {
+		o.Prefix = RoutePrefix
+		o.BufferResponses = BufferResponses
+		o.MaxHeaderBytes = MaxHeaderBytes
+		o.RedirectTrailingSlash = RedirectTrailingSlash
+		o.MaxMultipartParts = MaxMultipartParts
+		o.SniffBodies = SniffBodies
+		o.Production = Production
+		o.AuditHook = AuditHook
+	}
+
+	if r.dev != nil && r.dev.VerboseErrors {
+		o.Production = false
+	}
+
+	return o
+}
+
+// AddMiddleware adds a new middleware to the router.
+func (r *Router) AddMiddleware(f MiddlewareFunc) {
+	r.AddMiddlewarePriority(DefaultMiddlewarePriority, f)
+}
+
+// AddMiddlewarePriority adds a new middleware with the given priority. Lower
+// priorities run earlier, regardless of registration order.
+func (r *Router) AddMiddlewarePriority(priority int, f MiddlewareFunc) {
+	r.middlewareMu.Lock()
+	defer r.middlewareMu.Unlock()
+
+	set := r.currentMiddlewares()
+	entries := set.entries
+
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].priority > priority
+	})
+
+	next := make([]middlewareEntry, 0, len(entries)+1)
+	next = append(next, entries[:i]...)
+	next = append(next, middlewareEntry{priority, f})
+	next = append(next, entries[i:]...)
+
+	r.middlewares.Store(&middlewareSet{entries: next, chain: set.chain})
+}
+
+// Use adds chain middleware to the router.
+func (r *Router) Use(m ...ChainMiddleware) {
+	r.middlewareMu.Lock()
+	defer r.middlewareMu.Unlock()
+
+	set := r.currentMiddlewares()
+	chain := append(append([]ChainMiddleware(nil), set.chain...), m...)
+
+	r.middlewares.Store(&middlewareSet{entries: set.entries, chain: chain})
+}
+
+// middlewareSet is an immutable snapshot of the middleware of a router.
+type middlewareSet struct {
+	// entries is sorted by priority; entries with equal priority keep their
+	// registration order.
+	entries []middlewareEntry
+	chain   []ChainMiddleware
+}
+
+// currentMiddlewares returns the middleware new requests run through.
+func (r *Router) currentMiddlewares() *middlewareSet {
+	if set := r.middlewares.Load(); set != nil {
+		return set
+	}
+	return &middlewareSet{}
+}
+
+// addRoute registers a route.
+func (r *Router) addRoute(method RequestMethod, path string, auth AuthRequire, f Handler, opts []RouteOption) *RouteInfo {
+	return r.addRouteAt(r.options().Prefix, method, path, auth, f, opts)
+}
+
+// addRouteAt registers a route at prefix + path.
+func (r *Router) addRouteAt(prefix string, method RequestMethod, path string, auth AuthRequire, f Handler, opts []RouteOption) *RouteInfo {
+	ri := newRoute(prefix, method, path, auth, f, opts)
+
+	r.routesMu.Lock()
+	r.routes.Store(r.currentRoutes().withRoute(ri))
+	r.routesMu.Unlock()
+
+	r.routeRegistered(ri)
+	return ri
+}
+
+// MapGet maps a GET route.
+func (r *Router) MapGet(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodGet, path, auth, f, opts)
+}
+
+// MapPost maps a POST route.
+func (r *Router) MapPost(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodPost, path, auth, f, opts)
+}
+
+// MapPut maps a PUT route.
+func (r *Router) MapPut(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodPut, path, auth, f, opts)
+}
+
+// MapDelete maps a DELETE route.
+func (r *Router) MapDelete(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodDelete, path, auth, f, opts)
+}
+
+// MapPatch maps a PATCH route.
+func (r *Router) MapPatch(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodPatch, path, auth, f, opts)
+}
+
+// MapOptions maps an OPTIONS route.
+func (r *Router) MapOptions(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodOptions, path, auth, f, opts)
+}
+
+// MapHead maps a HEAD route.
+func (r *Router) MapHead(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodHead, path, auth, f, opts)
+}
+
+// NoAutoHead stops a GET route from also serving HEAD requests.
+func NoAutoHead() RouteOption {
+	return func(ri *RouteInfo) {
+		ri.noAutoHead = true
+	}
+}
+
+// Map maps a route for any request method, including nonstandard ones. It
+// panics if method is not a valid method token.
+func (r *Router) Map(method RequestMethod, path string, auth AuthRequire, f Handler, opts ...RouteOption) *RouteInfo {
+	if _, err := NewRequestMethod(string(method)); err != nil {
+		panic(err)
+	}
+	return r.addRoute(method, path, auth, f, opts)
+}
+
+// Start runs the startup hooks and starts the HTTP server.
+func (r *Router) Start(listen string) error {
+	return NewServer(listen, r).Start(context.Background())
+}
+
+// StartTLS runs the startup hooks and starts the HTTPS server.
+func (r *Router) StartTLS(listen string, certFile string, keyFile string) error {
+	s := NewServer(listen, r)
+	s.CertFile, s.KeyFile = certFile, keyFile
+	return s.Start(context.Background())
+}
+
+// Serve runs the startup hooks and serves the router on l.
+func (r *Router) Serve(l net.Listener) error {
+	return NewServer("", r).Serve(context.Background(), l)
+}
+
+// checkBody runs the body hooks and checks of the route on a buffered
+// request body, answering the request if it fails them.
+func (r *Router) checkBody(v *RouteInfo, rqbody *RequestBody, w *ResponseRecorder, req *http.Request, opts Options) bool {
+	var err error
+	if rqbody.JsonData, err = r.transformRequestBody(rqbody.JsonData); err != nil {
+		rqbody.ResponseW = w
+		rqbody.w = w
+		r.handleError(rqbody, err)
+		return false
+	}
+
+	if rqbody.JsonData, err = r.sanitizeBody(v, req, rqbody.JsonData); err != nil {
+		r.frameworkError(w, req, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	if err := v.body.check(rqbody.JsonData); err != nil {
+		r.frameworkError(w, req, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	if err := checkMultipartParts(req.Header.Get("Content-Type"), rqbody.JsonData, opts.MaxMultipartParts); err != nil {
+		r.frameworkError(w, req, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	return true
+}
+
+// ServeHTTP dispatches a request to the matching route.
+func (r *Router) ServeHTTP(resw http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	opts := r.options()
+
+	w := &ResponseRecorder{ResponseWriter: resw}
+	rqbody := &RequestBody{req: req, router: r, w: w}
+	defer r.startDeferred(rqbody)
+
+	if opts.BufferResponses || len(r.responseBodyHooks) > 0 {
+		buf := newResponseBuffer()
+		defer r.flushResponse(buf, resw, req, opts.Production)
+		w.ResponseWriter = buf
+	}
+
+	if r.requestID {
+		assignRequestID(rqbody, w)
+		req = rqbody.req
+	}
+
+	defer func() { rqbody.locals = nil }()
+
+	metrics := r.metrics
+	if metrics != nil {
+		metrics.InFlight(1)
+	}
+
+	var head *headWriter
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.recoverPanic(rqbody, w, rec, debug.Stack(), opts.Production)
+		}
+
+		if head != nil {
+			head.finish()
+		}
+
+		status := w.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		duration := time.Since(start)
+		r.logAccess(rqbody, status, w.size, duration)
+
+		if metrics != nil {
+			metrics.InFlight(-1)
+			metrics.ObserveRequest(req.Method, rqbody.routePattern(), status, duration, w.size)
+		}
+
+		r.runAfterResponse(rqbody, status, duration)
+	}()
+
+	defer rqbody.runDone()
+
+	if b := r.globalLimit; b != nil {
+		if ok, wait := b.take(); !ok {
+			r.tooManyRequests(w, req, wait)
+			return
+		}
+	}
+
+	if headerSize(req) > opts.MaxHeaderBytes {
+		r.frameworkError(w, req, http.StatusRequestHeaderFieldsTooLarge, "request header fields too large")
+		return
+	}
+
+	if p := r.methodPolicy; p != nil && !p.allows(req.Method) {
+		r.frameworkError(w, req, p.Status, "method "+req.Method+" is not supported")
+		return
+	}
+
+	if err := checkPathEncoding(req); err != nil {
+		r.frameworkError(w, req, http.StatusBadRequest, "malformed percent-encoding in path")
+		return
+	}
+
+	if r.inMaintenance(rqbody, w, req, opts.Prefix) {
+		return
+	}
+
+	table := r.currentRoutes()
+	v, params := matchMethod(table, req.Method, req, opts)
+
+	// HEAD requests are served by the GET route unless the route opted out.
+	if v == nil && req.Method == http.MethodHead {
+		if v, params = matchMethod(table, http.MethodGet, req, opts); v != nil && v.noAutoHead {
+			v, params = nil, nil
+		}
+		if v != nil {
+			head = &headWriter{ResponseWriter: w.ResponseWriter}
+			w.ResponseWriter = head
+		}
+	}
+
+	r.setSecureHeaders(v, w, req)
+	r.setDefaultHeaders(v, w)
+	if v != nil && v.versionPolicy != nil {
+		v.versionPolicy.setHeaders(w.Header())
+	}
+
+	if r.handleCORS(table, v, w, req) {
+		return
+	}
+
+	if v == nil && opts.RedirectTrailingSlash {
+		if target, ok := trailingSlashTarget(table, req); ok {
+			http.Redirect(w, req, target, http.StatusPermanentRedirect)
+			return
+		}
+	}
+
+	// If the path exists for other methods, return 405; otherwise 404
+	if v == nil {
+		rqbody.ResponseW = w
+		rqbody.w = w
+
+		if allowed := table.allowed(req.URL.EscapedPath(), req.Host); len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			if r.methodNotAllowed != nil {
+				r.methodNotAllowed(rqbody)
+			}
+			if !w.written() {
+				r.frameworkError(w, req, http.StatusMethodNotAllowed, "")
+			}
+			return
+		}
+
+		if r.notFound != nil {
+			r.notFound(rqbody)
+		}
+		if !w.written() {
+			r.frameworkError(w, req, http.StatusNotFound, "")
+		}
+		return
+	}
+
+	rqbody.route = v
+	rqbody.Params = params
+
+	if !v.acceptsMediaType(req) {
+		r.frameworkError(w, req, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType.Error())
+		return
+	}
+
+	maxBody := opts.MaxBodySize
+	if v.maxBody != 0 {
+		maxBody = v.maxBody
+	}
+
+	var err error
+	if v.streamBody {
+		rqbody.stream, err = bodyReader(w, req, maxBody, opts.MaxDecompressedSize)
+	} else {
+		rqbody.JsonData, err = readBody(w, req, maxBody, opts.MaxDecompressedSize)
+		rqbody.raw = rqbody.JsonData
+	}
+
+	if errors.As(err, new(*http.MaxBytesError)) {
+		r.frameworkError(w, req, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	if errors.Is(err, ErrUnsupportedEncoding) {
+		r.frameworkError(w, req, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	if err != nil {
+		r.frameworkError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !v.streamBody && !r.checkBody(v, rqbody, w, req, opts) {
+		return
+	}
+	rqbody.rewindBody()
+
+	if !r.authenticate(v, rqbody, w, req, opts.AuditHook) {
+		return
+	}
+
+	if !r.authorize(v, rqbody, w, req, opts.AuditHook) {
+		return
+	}
+
+	rqbody.ResponseW = w
+	rqbody.w = w
+
+	if v.bulkhead != nil {
+		if !v.bulkhead.acquire(req.Context()) {
+			w.Header().Set("Retry-After", "1")
+			r.handleError(rqbody, ErrOverloaded)
+			return
+		}
+		defer v.bulkhead.release()
+	}
+
+	// Chain middleware wraps the rest of the pipeline.
+	mws := r.currentMiddlewares()
+
+	next := func(rb *RequestBody) { r.runTimed(v, mws, rb, w) }
+	for i := len(mws.chain) - 1; i >= 0; i-- {
+		m, inner := mws.chain[i], next
+		next = func(rb *RequestBody) { m(rb, inner) }
+	}
+
+	next(rqbody)
+}
+
+// runRoute runs the global and route middleware, then the route handler.
+func (r *Router) runRoute(v *RouteInfo, mws *middlewareSet, rqbody *RequestBody, w *ResponseRecorder) {
+	if rqbody.aborted {
+		return
+	}
+
+	// A middleware that writes a response or aborts ends the request.
+	for _, m := range mws.entries {
+		m.f(rqbody, w, rqbody.req)
+
+		if w.written() || rqbody.aborted {
+			return
+		}
+	}
+
+	for _, m := range v.middlewares {
+		m(rqbody, w, rqbody.req)
+
+		if w.written() || rqbody.aborted {
+			return
+		}
+	}
+
+	rqbody.rewindBody()
+	r.runHandler(v, rqbody)
+}
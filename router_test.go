@@ -0,0 +1,57 @@
+package httpfly
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestRouteSourceIsCallSite(t *testing.T) {
+	r := NewRouter()
+
+	ri := r.MapGet("/a", NoAuth, func(rb *RequestBody) {})
+	_, file, line, _ := runtime.Caller(0)
+	if want := fmt.Sprintf("%s:%d", file, line-1); ri.Source != want {
+		t.Errorf("Source = %q, want %q", ri.Source, want)
+	}
+
+	gri := r.Group("/g").MapGet("/b", NoAuth, func(rb *RequestBody) {})
+	_, _, line, _ = runtime.Caller(0)
+	if want := fmt.Sprintf("%s:%d", file, line-1); gri.Source != want {
+		t.Errorf("group route Source = %q, want %q", gri.Source, want)
+	}
+
+	var rri *RouteInfo
+	r.ReplaceRoutes(func(reg *Registrar) {
+		rri = reg.MapGet("/c", NoAuth, func(rb *RequestBody) {})
+	})
+	_, _, line, _ = runtime.Caller(0)
+	if want := fmt.Sprintf("%s:%d", file, line-2); rri.Source != want {
+		t.Errorf("replaced route Source = %q, want %q", rri.Source, want)
+	}
+}
+
+func TestRoutersAreIndependent(t *testing.T) {
+	a, b := NewRouter(), NewRouter()
+	b.Prefix = "/v2"
+	a.MapGet("/ping", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "a") })
+	b.MapGet("/ping", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "b") })
+
+	for _, tc := range []struct {
+		r      *Router
+		path   string
+		status int
+		body   string
+	}{
+		{a, "/api/ping", http.StatusOK, "a"},
+		{a, "/v2/ping", http.StatusNotFound, ""},
+		{b, "/v2/ping", http.StatusOK, "b"},
+		{b, "/api/ping", http.StatusNotFound, ""},
+	} {
+		res := NewTestClient(tc.r).Get(tc.path)
+		if res.Status != tc.status || (tc.body != "" && res.String() != tc.body) {
+			t.Errorf("%s: got %d %q, want %d %q", tc.path, res.Status, res.String(), tc.status, tc.body)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package httpfly
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+)
+
+// DefaultListenAddr is the address Run listens on when none is given.
+const DefaultListenAddr = ":8080"
+
+// Run serves the default router as configured by the command line. See
+// Router.Run.
+//
+//	func main() {
+//		httpfly.MapGet("/hello", httpfly.NoAuth, hello)
+//		log.Fatal(httpfly.Run())
+//	}
+func Run() error {
+	return defaultRouter.Run()
+}
+
+// Run parses the command line flags
+//
+//	--listen        address to listen on (default ":8080")
+//	--tls-cert      certificate file, serving HTTPS with --tls-key
+//	--tls-key       key file
+//	--log-level     "info", "error" or "off"
+//	--route-prefix  prefix of every route (default the router prefix)
+//
+// with the environment variables of LoadConfig (HTTPFLY_ADDR,
+// HTTPFLY_TLS_CERT_FILE, HTTPFLY_TLS_KEY_FILE, HTTPFLY_LOG_LEVEL,
+// HTTPFLY_ROUTE_PREFIX, ...) as defaults, applies the configuration,
+// prints the route table to stderr and serves until SIGINT or SIGTERM,
+// which shut the server down gracefully. Call it after mapping routes; the
+// route prefix is applied to the routes mapped so far.
+func (r *Router) Run() error {
+	return r.run(os.Args[0], os.Args[1:], os.Stderr)
+}
+
+func (r *Router) run(name string, args []string, out io.Writer) error {
+	cfg, err := LoadConfig("")
+	if err != nil {
+		return err
+	}
+
+	prefix := r.options().Prefix
+	if cfg.RoutePrefix != nil {
+		prefix = *cfg.RoutePrefix
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.StringVar(&cfg.Addr, "listen", cfg.Addr, "address to listen on (default \""+DefaultListenAddr+"\")")
+	fs.StringVar(&cfg.TLS.CertFile, "tls-cert", cfg.TLS.CertFile, "TLS certificate file")
+	fs.StringVar(&cfg.TLS.KeyFile, "tls-key", cfg.TLS.KeyFile, "TLS key file")
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "log level: info, error or off")
+	fs.StringVar(&prefix, "route-prefix", prefix, "prefix of every route")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultListenAddr
+	}
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return fmt.Errorf("httpfly: --tls-cert and --tls-key must be given together")
+	}
+
+	cfg.RoutePrefix = nil
+	cfg.Apply(r)
+	r.setPrefix(prefix)
+
+	if lvl := strings.ToLower(cfg.Log.Level); lvl != "off" && lvl != "none" {
+		scheme := "http"
+		if cfg.TLS.CertFile != "" {
+			scheme = "https"
+		}
+		fmt.Fprintf(out, "serving %s on %s\n", scheme, cfg.Addr)
+		printRoutes(out, r.currentRoutes().routes)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return cfg.Server(r).Start(ctx)
+}
+
+// setPrefix makes prefix the route prefix of the router, moving the routes
+// mapped so far under it.
+func (r *Router) setPrefix(prefix string) {
+	old := r.options().Prefix
+	if r == defaultRouter {
+		RoutePrefix = prefix
+	} else {
+		r.Prefix = prefix
+	}
+	if old == prefix {
+		return
+	}
+
+	r.routesMu.Lock()
+	defer r.routesMu.Unlock()
+
+	routes := r.currentRoutes().routes
+	for _, ri := range routes {
+		if strings.HasPrefix(ri.Endpoint, old) {
+			ri.Endpoint = prefix + strings.TrimPrefix(ri.Endpoint, old)
+			ri.segments = strings.Split(ri.Endpoint, "/")
+		}
+	}
+	r.routes.Store(newRouteTable(routes))
+}
+
+// printRoutes writes a route table to w.
+func printRoutes(w io.Writer, routes []*RouteInfo) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tAUTH\tHANDLER")
+
+	for _, v := range routes {
+		path := v.Endpoint
+		if v.Host != "" {
+			path = v.Host + path
+		}
+		if v.Version != "" {
+			path += " (" + v.Version + ")"
+		}
+
+		auth := "-"
+		if v.AuthRequired {
+			auth = "yes"
+			if len(v.roles) > 0 {
+				auth = strings.Join(v.roles, ",")
+			}
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Method, path, auth, funcName(v.HandlerF))
+	}
+	tw.Flush()
+}
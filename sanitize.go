@@ -0,0 +1,184 @@
+package httpfly
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Defaults of SanitizeConfig.
+const (
+	DefaultMaxJSONDepth = 32
+	DefaultMaxJSONKeys  = 10000
+)
+
+// SanitizeConfig configures the request body sanitizer.
+type SanitizeConfig struct {
+	// MaxDepth bounds the nesting of objects and arrays in JSON bodies.
+	// Zero means DefaultMaxJSONDepth, a negative value no limit.
+	MaxDepth int
+	// MaxKeys bounds the number of object keys in a JSON body, counted
+	// over all objects. Zero means DefaultMaxJSONKeys, a negative value no
+	// limit.
+	MaxKeys int
+	// RejectNullBytes answers bodies containing null bytes with 400
+	// instead of removing them.
+	RejectNullBytes bool
+}
+
+// UseSanitizer sanitizes the request bodies of every route of the default
+// router. See Router.UseSanitizer.
+func UseSanitizer(cfg SanitizeConfig) {
+	defaultRouter.UseSanitizer(cfg)
+}
+
+// UseSanitizer checks the textual request bodies (JSON, text, XML and URL
+// encoded forms) of every route of the router before the handler runs:
+// bodies that are not valid UTF-8, and JSON bodies nested deeper or with
+// more keys than the limits, are answered with 400 Bad Request; null
+// bytes, raw or escaped as \u0000 in JSON strings, are removed. Binary and
+// multipart bodies and routes with StreamBody are not checked. Routes can
+// override the configuration with WithSanitizer.
+func (r *Router) UseSanitizer(cfg SanitizeConfig) {
+	r.sanitize = &cfg
+}
+
+// WithSanitizer sanitizes the request bodies of a single route, replacing
+// the configuration given to UseSanitizer.
+func WithSanitizer(cfg SanitizeConfig) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.sanitize = &cfg
+	}
+}
+
+// sanitizeBody applies the sanitizer of route v, if any, to body.
+func (r *Router) sanitizeBody(v *RouteInfo, req *http.Request, body []byte) ([]byte, error) {
+	cfg := r.sanitize
+	if v.sanitize != nil {
+		cfg = v.sanitize
+	}
+	if cfg == nil || len(body) == 0 {
+		return body, nil
+	}
+
+	text, isJSON := textMediaType(req.Header.Get("Content-Type"))
+	if !text {
+		return body, nil
+	}
+
+	if !utf8.Valid(body) {
+		return nil, errors.New("request body is not valid UTF-8")
+	}
+
+	if bytes.IndexByte(body, 0) >= 0 {
+		if cfg.RejectNullBytes {
+			return nil, errors.New("request body contains null bytes")
+		}
+		body = bytes.ReplaceAll(body, []byte{0}, nil)
+	}
+
+	if isJSON {
+		return cfg.sanitizeJSON(body)
+	}
+	return body, nil
+}
+
+// sanitizeJSON checks the depth and key count of a JSON document and
+// removes \u0000 escapes from its strings. Syntax errors are left to the
+// decoder.
+func (cfg *SanitizeConfig) sanitizeJSON(body []byte) ([]byte, error) {
+	maxDepth, maxKeys := cfg.MaxDepth, cfg.MaxKeys
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxJSONDepth
+	}
+	if maxKeys == 0 {
+		maxKeys = DefaultMaxJSONKeys
+	}
+
+	var out []byte // copy of body, made at the first escape to remove
+	depth, keys := 0, 0
+	inString := false
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+
+		if inString {
+			if c == '\\' && i+1 < len(body) {
+				if bytes.HasPrefix(body[i+1:], []byte("u0000")) {
+					if cfg.RejectNullBytes {
+						return nil, errors.New("request body contains null bytes")
+					}
+					if out == nil {
+						out = append(make([]byte, 0, len(body)), body[:i]...)
+					}
+					i += 5
+					continue
+				}
+				if out != nil {
+					out = append(out, c, body[i+1])
+				}
+				i++
+				continue
+			}
+
+			if c == '"' {
+				inString = false
+			}
+			if out != nil {
+				out = append(out, c)
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if maxDepth > 0 && depth > maxDepth {
+				return nil, fmt.Errorf("request body nests deeper than %d levels", maxDepth)
+			}
+		case '}', ']':
+			depth--
+		case ':':
+			keys++
+			if maxKeys > 0 && keys > maxKeys {
+				return nil, fmt.Errorf("request body has more than %d keys", maxKeys)
+			}
+		}
+		if out != nil {
+			out = append(out, c)
+		}
+	}
+
+	if out != nil {
+		return out, nil
+	}
+	return body, nil
+}
+
+// textMediaType reports whether a body of the content type is text, and
+// whether it is JSON. Bodies without a content type are taken as JSON.
+func textMediaType(contentType string) (text, isJSON bool) {
+	if contentType == "" {
+		return true, true
+	}
+
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false, false
+	}
+
+	switch {
+	case mt == "application/json" || strings.HasSuffix(mt, "+json") || mt == NDJSONContentType:
+		return true, true
+	case strings.HasPrefix(mt, "text/"), mt == "application/xml", strings.HasSuffix(mt, "+xml"),
+		mt == "application/x-www-form-urlencoded":
+		return true, false
+	}
+	return false, false
+}
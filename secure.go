@@ -0,0 +1,102 @@
+package httpfly
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults of SecureConfig.
+const (
+	DefaultHSTSMaxAge            = 365 * 24 * time.Hour
+	DefaultContentSecurityPolicy = "default-src 'self'"
+	DefaultFrameOptions          = "DENY"
+	DefaultReferrerPolicy        = "strict-origin-when-cross-origin"
+)
+
+// SecureConfig configures security response headers. Empty fields take
+// the defaults above; "-" omits a header.
+type SecureConfig struct {
+	// HSTSMaxAge is the max-age of Strict-Transport-Security, which is only
+	// sent over HTTPS. A negative value omits the header.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	ContentSecurityPolicy string
+	FrameOptions          string
+	ReferrerPolicy        string
+	// AllowSniffing omits X-Content-Type-Options: nosniff.
+	AllowSniffing bool
+}
+
+// UseSecureHeaders adds security headers to every response of the default
+// router. See Router.UseSecureHeaders.
+func UseSecureHeaders(cfg SecureConfig) {
+	defaultRouter.UseSecureHeaders(cfg)
+}
+
+// UseSecureHeaders adds Strict-Transport-Security, Content-Security-Policy,
+// X-Content-Type-Options, X-Frame-Options and Referrer-Policy to every
+// response of the router, including 404s. Routes can override the
+// configuration with WithSecureHeaders, and handlers can still replace
+// single headers.
+func (r *Router) UseSecureHeaders(cfg SecureConfig) {
+	r.secure = &cfg
+}
+
+// WithSecureHeaders sets the security headers of a single route, replacing
+// the configuration given to UseSecureHeaders.
+func WithSecureHeaders(cfg SecureConfig) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.secure = &cfg
+	}
+}
+
+// setSecureHeaders adds the security headers that apply to a request
+// matched to v.
+func (r *Router) setSecureHeaders(v *RouteInfo, w http.ResponseWriter, req *http.Request) {
+	cfg := r.secure
+	if v != nil && v.secure != nil {
+		cfg = v.secure
+	}
+	if cfg == nil {
+		return
+	}
+
+	h := w.Header()
+
+	if cfg.HSTSMaxAge >= 0 && r.isHTTPS(req) {
+		maxAge := cfg.HSTSMaxAge
+		if maxAge == 0 {
+			maxAge = DefaultHSTSMaxAge
+		}
+
+		value := "max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+		if cfg.HSTSIncludeSubdomains {
+			value += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			value += "; preload"
+		}
+		h.Set("Strict-Transport-Security", value)
+	}
+
+	setSecureHeader(h, "Content-Security-Policy", cfg.ContentSecurityPolicy, DefaultContentSecurityPolicy)
+	setSecureHeader(h, "X-Frame-Options", cfg.FrameOptions, DefaultFrameOptions)
+	setSecureHeader(h, "Referrer-Policy", cfg.ReferrerPolicy, DefaultReferrerPolicy)
+
+	if !cfg.AllowSniffing {
+		h.Set("X-Content-Type-Options", "nosniff")
+	}
+}
+
+func setSecureHeader(h http.Header, name, value, def string) {
+	switch value {
+	case "-":
+		return
+	case "":
+		value = def
+	}
+	h.Set(name, value)
+}
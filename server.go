@@ -0,0 +1,326 @@
+package httpfly
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long Server.Start waits for open requests to
+// finish after its context is canceled.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Backoff between listen attempts of Server.Start.
+const (
+	DefaultListenBackoff = 500 * time.Millisecond
+	MaxListenBackoff     = 10 * time.Second
+)
+
+// Server serves a Router and controls its lifecycle.
+type Server struct {
+	// Addr is the TCP address to listen on, e.g. ":8080".
+	Addr string
+	// Router is the router to serve. Nil means the default router.
+	Router *Router
+
+	// CertFile and KeyFile, when both set, make the server use TLS.
+	CertFile string
+	KeyFile  string
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout bounds the graceful shutdown started by canceling the
+	// context of Start. Zero means DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	// ListenRetries is how often Start retries binding Addr after a listen
+	// error, e.g. while a previous process still holds the port. The delay
+	// starts at ListenBackoff and doubles up to MaxListenBackoff. Zero
+	// values take the settings of Router.SetListenRetry.
+	ListenRetries int
+	ListenBackoff time.Duration
+
+	ServerConfig
+
+	// TLSConfig, when set, makes the server use TLS with this configuration
+	// instead of CertFile and KeyFile.
+	TLSConfig *tls.Config
+	// CertManager, when set, provides certificates automatically. See
+	// StartHTTPServerAutoTLS.
+	CertManager CertManager
+	// ChallengeAddr is where the CertManager answers HTTP-01 challenges and
+	// redirects other requests to HTTPS. Defaults to ":80".
+	ChallengeAddr string
+	// RedirectAddr, when set on a TLS server without a CertManager, is
+	// served over plain HTTP by a companion listener redirecting every
+	// request to HTTPS, e.g. ":80".
+	RedirectAddr string
+	// ACMEWebroot, when set, makes the RedirectAddr listener serve
+	// /.well-known/acme-challenge/ from this directory, for ACME clients
+	// using webroot validation.
+	ACMEWebroot string
+
+	mu        sync.Mutex
+	srv       *http.Server
+	ln        net.Listener
+	challenge *http.Server
+}
+
+// ServerConfig selects the HTTP protocols of a Server, tunes HTTP/2 and
+// limits connections. Zero values keep the net/http defaults.
+type ServerConfig struct {
+	// EnableH2C serves HTTP/2 over cleartext connections (prior knowledge),
+	// e.g. behind a TLS-terminating proxy or for gRPC-style clients.
+	EnableH2C bool
+	// DisableHTTP2 restricts TLS connections to HTTP/1.1.
+	DisableHTTP2 bool
+	// DisableKeepAlives closes HTTP/1.1 connections after every response.
+	DisableKeepAlives bool
+	// MaxConnections bounds the connections served at once. Further
+	// connections wait in the listen backlog until one closes. Zero means
+	// no limit.
+	MaxConnections int
+
+	MaxConcurrentStreams          int
+	MaxReadFrameSize              int
+	MaxReceiveBufferPerConnection int
+	MaxReceiveBufferPerStream     int
+	// SendPingTimeout sends a ping after a connection has been idle this
+	// long; PingTimeout closes it if the ping is not answered in time.
+	SendPingTimeout time.Duration
+	PingTimeout     time.Duration
+}
+
+// apply configures the protocols and HTTP/2 settings of srv.
+func (c ServerConfig) apply(srv *http.Server) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(!c.DisableHTTP2)
+	protocols.SetUnencryptedHTTP2(c.EnableH2C)
+	srv.Protocols = &protocols
+
+	if c.DisableKeepAlives {
+		srv.SetKeepAlivesEnabled(false)
+	}
+
+	srv.HTTP2 = &http.HTTP2Config{
+		MaxConcurrentStreams:          c.MaxConcurrentStreams,
+		MaxReadFrameSize:              c.MaxReadFrameSize,
+		MaxReceiveBufferPerConnection: c.MaxReceiveBufferPerConnection,
+		MaxReceiveBufferPerStream:     c.MaxReceiveBufferPerStream,
+		SendPingTimeout:               c.SendPingTimeout,
+		PingTimeout:                   c.PingTimeout,
+	}
+}
+
+// NewServer creates a server for r listening on addr.
+func NewServer(addr string, r *Router) *Server {
+	return &Server{Addr: addr, Router: r}
+}
+
+// Start runs the startup hooks, listens on Addr and serves until ctx is
+// canceled or serving fails. Cancelling ctx shuts the server down
+// gracefully, in which case Start returns nil once open requests have
+// finished. Listen errors are retried as ListenRetries says and then
+// returned, after the server error hooks have run. A process started by
+// Upgrade serves on the listener inherited from its parent instead.
+func (s *Server) Start(ctx context.Context) error {
+	r := s.router()
+
+	if err := r.runStartupHooks(ctx); err != nil {
+		return err
+	}
+
+	ln, err := inheritedListener()
+	if ln == nil && err == nil {
+		ln, err = s.listen(ctx, r)
+	}
+	if err != nil {
+		r.serverError(err)
+		return err
+	}
+
+	return s.serve(ctx, r, ln)
+}
+
+// listen binds Addr, retrying with backoff.
+func (s *Server) listen(ctx context.Context, r *Router) (net.Listener, error) {
+	retries, backoff := s.ListenRetries, s.ListenBackoff
+	if retries == 0 {
+		retries = r.listenRetries
+	}
+	if backoff <= 0 {
+		backoff = r.listenBackoff
+	}
+	if backoff <= 0 {
+		backoff = DefaultListenBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		ln, err := net.Listen("tcp", s.Addr)
+		if err == nil || attempt >= retries {
+			return ln, err
+		}
+
+		if r.logger != nil {
+			r.logger.Error("listen failed, retrying", "addr", s.Addr, "attempt", attempt+1, "retry_in", backoff.String(), "error", err.Error())
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff = min(2*backoff, MaxListenBackoff)
+	}
+}
+
+// Serve is like Start but serves on an existing listener, e.g. one from
+// ActivatedListeners or a test listener on an ephemeral port. Addr is
+// ignored. The listener is closed when Serve returns.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	r := s.router()
+
+	if err := r.runStartupHooks(ctx); err != nil {
+		ln.Close()
+		return err
+	}
+
+	return s.serve(ctx, r, ln)
+}
+
+// router returns the router to serve.
+func (s *Server) router() *Router {
+	if s.Router == nil {
+		return defaultRouter
+	}
+	return s.Router
+}
+
+// serve serves r on ln until ctx is canceled or serving fails.
+func (s *Server) serve(ctx context.Context, r *Router, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           r,
+		MaxHeaderBytes:    r.options().MaxHeaderBytes,
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+	}
+	s.ServerConfig.apply(srv)
+
+	errc := make(chan error, 2)
+
+	var challenge *http.Server
+	if s.CertManager != nil {
+		srv.TLSConfig = s.CertManager.TLSConfig()
+
+		addr := s.ChallengeAddr
+		if addr == "" {
+			addr = ":80"
+		}
+
+		challengeLn, err := net.Listen("tcp", addr)
+		if err != nil {
+			ln.Close()
+			r.serverError(err)
+			return err
+		}
+
+		challenge = &http.Server{Handler: s.CertManager.HTTPHandler(nil), ReadHeaderTimeout: 10 * time.Second}
+		go func() { errc <- challenge.Serve(challengeLn) }()
+	} else {
+		if s.TLSConfig != nil {
+			srv.TLSConfig = s.TLSConfig
+		}
+
+		if s.RedirectAddr != "" && (srv.TLSConfig != nil || (s.CertFile != "" && s.KeyFile != "")) {
+			redirectLn, err := net.Listen("tcp", s.RedirectAddr)
+			if err != nil {
+				ln.Close()
+				r.serverError(err)
+				return err
+			}
+
+			challenge = &http.Server{Handler: httpsRedirect(ln.Addr().String(), s.ACMEWebroot), ReadHeaderTimeout: 10 * time.Second}
+			go func() { errc <- challenge.Serve(redirectLn) }()
+		}
+	}
+
+	s.mu.Lock()
+	s.srv, s.ln, s.challenge = srv, ln, challenge
+	s.mu.Unlock()
+
+	for _, f := range r.startHooks {
+		f(ln.Addr())
+	}
+
+	// Upgrade passes on the bare listener kept in s.ln.
+	if s.MaxConnections > 0 {
+		ln = limitListener(ln, s.MaxConnections)
+	}
+
+	go func() {
+		if srv.TLSConfig != nil || (s.CertFile != "" && s.KeyFile != "") {
+			errc <- srv.ServeTLS(ln, s.CertFile, s.KeyFile)
+		} else {
+			errc <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		if challenge != nil {
+			challenge.Close()
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		srv.Close()
+		r.serverError(err)
+		return err
+
+	case <-ctx.Done():
+		timeout := s.ShutdownTimeout
+		if timeout <= 0 {
+			timeout = DefaultShutdownTimeout
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		return s.Shutdown(shutdownCtx)
+	}
+}
+
+// Shutdown stops accepting connections and waits for open requests and
+// the tasks they deferred to finish or ctx to expire. The readiness
+// endpoint reports the shutdown from then on. It is a no-op if the server
+// was not started.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	srv, challenge := s.srv, s.challenge
+	s.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	r := s.router()
+	r.draining.Store(true)
+	r.RunShutdownHooks(ctx)
+
+	if challenge != nil {
+		challenge.Shutdown(ctx)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		return err
+	}
+	return r.DrainWorkers(ctx)
+}
@@ -0,0 +1,57 @@
+package httpfly
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// h2cClient speaks only HTTP/2 with prior knowledge over cleartext.
+func h2cClient() *http.Client {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+}
+
+// serveConfig serves r with cfg on an ephemeral port until the test ends
+// and returns its URL.
+func serveConfig(t *testing.T, r *Router, cfg ServerConfig) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.Cleanup(func() { cancel(); <-done })
+
+	s := NewServer("", r)
+	s.ServerConfig = cfg
+	go func() { defer close(done); s.Serve(ctx, ln) }()
+
+	return "http://" + ln.Addr().String()
+}
+
+func TestServerConfigH2C(t *testing.T) {
+	r := NewRouter()
+	r.MapGet("/proto", NoAuth, func(rb *RequestBody) {
+		rb.Text(http.StatusOK, rb.Request().Proto)
+	})
+
+	res, err := h2cClient().Get(serveConfig(t, r, ServerConfig{EnableH2C: true}) + "/api/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.ProtoMajor != 2 || string(body) != "HTTP/2.0" {
+		t.Errorf("h2c request served as %q and answered over %s, want HTTP/2", body, res.Proto)
+	}
+
+	if res, err := h2cClient().Get(serveConfig(t, r, ServerConfig{}) + "/api/proto"); err == nil {
+		res.Body.Close()
+		t.Errorf("server without EnableH2C answered an h2c request over %s", res.Proto)
+	}
+}
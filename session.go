@@ -0,0 +1,308 @@
+package httpfly
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionStore persists session values by session id.
+type SessionStore interface {
+	// Load returns the values of a live session, or false if the session
+	// does not exist or has expired.
+	Load(id string) (map[string]any, bool, error)
+	// Save stores the values of a session and sets it to expire after ttl.
+	Save(id string, values map[string]any, ttl time.Duration) error
+	// Delete removes a session.
+	Delete(id string) error
+}
+
+// SessionEncoder is implemented by session stores that keep sessions in
+// the session cookie rather than server-side, such as CookieSessionStore.
+// Sessions encodes such sessions into the cookie instead of saving them
+// and decodes the cookie instead of loading them.
+type SessionEncoder interface {
+	// EncodeSession returns the cookie value carrying a session that
+	// expires after ttl.
+	EncodeSession(id string, values map[string]any, ttl time.Duration) (string, error)
+	// DecodeSession returns the session carried by a cookie value, or
+	// false if the value was altered or the session has expired.
+	DecodeSession(value string) (id string, values map[string]any, ok bool)
+}
+
+// SessionOptions configures the session cookie.
+type SessionOptions struct {
+	// CookieName defaults to "session_id".
+	CookieName string
+	// MaxAge is the idle lifetime of a session and defaults to 24 hours.
+	MaxAge   time.Duration
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// Session is the session of the current request. Changes are written to
+// the store immediately.
+type Session struct {
+	id     string
+	values map[string]any
+	store  SessionStore
+	opts   SessionOptions
+	w      http.ResponseWriter
+	mu     sync.Mutex
+}
+
+// ID returns the session id.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Get returns the value stored under key.
+func (s *Session) Get(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Set stores value under key and saves the session.
+func (s *Session) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+	return s.save()
+}
+
+// Delete removes key from the session and saves it.
+func (s *Session) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.values, key)
+	return s.save()
+}
+
+// Destroy deletes the session from the store and expires its cookie. Values
+// set afterwards start a new session.
+func (s *Session) Destroy() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.store.Delete(s.id)
+
+	s.id, s.values = newSessionID(), map[string]any{}
+	setCookie(s.w, &http.Cookie{
+		Name:     s.opts.CookieName,
+		Path:     s.opts.Path,
+		Domain:   s.opts.Domain,
+		MaxAge:   -1,
+		Secure:   s.opts.Secure,
+		HttpOnly: true,
+		SameSite: s.opts.SameSite,
+	})
+
+	return err
+}
+
+// save writes the session to its store and refreshes the session cookie.
+// A SessionEncoder keeps the encoded values in the cookie itself.
+func (s *Session) save() error {
+	value := s.id
+
+	if enc, ok := s.store.(SessionEncoder); ok {
+		encoded, err := enc.EncodeSession(s.id, s.values, s.opts.MaxAge)
+		if err != nil {
+			return err
+		}
+		value = encoded
+	} else if err := s.store.Save(s.id, s.values, s.opts.MaxAge); err != nil {
+		return err
+	}
+
+	setCookie(s.w, &http.Cookie{
+		Name:     s.opts.CookieName,
+		Value:    value,
+		Path:     s.opts.Path,
+		Domain:   s.opts.Domain,
+		MaxAge:   int(s.opts.MaxAge / time.Second),
+		Secure:   s.opts.Secure,
+		HttpOnly: true,
+		SameSite: s.opts.SameSite,
+	})
+
+	return nil
+}
+
+// setCookie sets c on w, replacing an earlier Set-Cookie for the same name.
+func setCookie(w http.ResponseWriter, c *http.Cookie) {
+	h := w.Header()
+	prefix := c.Name + "="
+
+	var kept []string
+	for _, v := range h.Values("Set-Cookie") {
+		if !strings.HasPrefix(v, prefix) {
+			kept = append(kept, v)
+		}
+	}
+
+	h.Del("Set-Cookie")
+	for _, v := range kept {
+		h.Add("Set-Cookie", v)
+	}
+
+	http.SetCookie(w, c)
+}
+
+var sessionKey = NewContextKey[*Session]("session")
+
+// Session returns the session loaded by the Sessions middleware, or nil if
+// the middleware is not in use.
+func (r *RequestBody) Session() *Session {
+	s, _ := sessionKey.Get(r)
+	return s
+}
+
+// UseSessions loads a session from store for every request of the default
+// router. See Sessions.
+func UseSessions(store SessionStore, opts ...SessionOptions) {
+	defaultRouter.UseSessions(store, opts...)
+}
+
+// UseSessions loads a session from store for every request of the router.
+// opts defaults to SessionOptions{}.
+func (r *Router) UseSessions(store SessionStore, opts ...SessionOptions) {
+	var o SessionOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	r.AddMiddleware(Sessions(store, o))
+}
+
+// Sessions returns a middleware that loads the session named by the session
+// cookie from store, starting a new one when the cookie is missing or the
+// session has expired. Requests of a live session renew it and its cookie;
+// a new session is only saved once a value is set, so requests without a
+// cookie leave nothing in the store. Requests whose session cannot be
+// saved fail with the store error.
+func Sessions(store SessionStore, opts SessionOptions) MiddlewareFunc {
+	if opts.CookieName == "" {
+		opts.CookieName = "session_id"
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 24 * time.Hour
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+
+	return func(rb *RequestBody, response http.ResponseWriter, request *http.Request) {
+		var id string
+		var values map[string]any
+
+		if c, err := request.Cookie(opts.CookieName); err == nil {
+			if enc, ok := store.(SessionEncoder); ok {
+				id, values, _ = enc.DecodeSession(c.Value)
+			} else if v, ok, err := store.Load(c.Value); err == nil && ok {
+				id, values = c.Value, v
+			}
+		}
+
+		s := &Session{id: id, values: values, store: store, opts: opts, w: response}
+		if id == "" {
+			s.id, s.values = newSessionID(), map[string]any{}
+		} else if err := s.save(); err != nil {
+			rb.router.handleError(rb, err)
+			return
+		}
+
+		sessionKey.Set(rb, s)
+	}
+}
+
+// newSessionID returns a random 128-bit hex session id.
+func newSessionID() string {
+	return randomHex(16)
+}
+
+// memorySessionSweep is how often a MemorySessionStore drops expired
+// sessions.
+const memorySessionSweep = time.Minute
+
+// MemorySessionStore is an in-process SessionStore. Expired sessions are
+// dropped when loaded and swept from the store every minute while it is
+// saved to.
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+type memorySession struct {
+	values  map[string]any
+	expires time.Time
+}
+
+// NewMemorySessionStore creates an empty in-memory session store.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]memorySession{}, lastSweep: time.Now()}
+}
+
+// Load implements SessionStore.
+func (m *MemorySessionStore) Load(id string) (map[string]any, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, false, nil
+	}
+
+	if time.Now().After(s.expires) {
+		delete(m.sessions, id)
+		return nil, false, nil
+	}
+
+	values := make(map[string]any, len(s.values))
+	for k, v := range s.values {
+		values[k] = v
+	}
+
+	return values, true, nil
+}
+
+// Save implements SessionStore.
+func (m *MemorySessionStore) Save(id string, values map[string]any, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	copied := make(map[string]any, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > memorySessionSweep {
+		for k, s := range m.sessions {
+			if now.After(s.expires) {
+				delete(m.sessions, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	m.sessions[id] = memorySession{copied, now.Add(ttl)}
+	return nil
+}
+
+// Delete implements SessionStore.
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
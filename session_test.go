@@ -0,0 +1,145 @@
+package httpfly
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// sessionCookie returns the value of the session cookie set by res.
+func sessionCookie(res *TestResponse) string {
+	for _, c := range (&http.Response{Header: res.Header}).Cookies() {
+		if c.Name == "session_id" {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func newSessionRouter(store SessionStore, opts SessionOptions) *Router {
+	r := NewRouter()
+	r.UseSessions(store, opts)
+	r.MapGet("/peek", NoAuth, func(rb *RequestBody) {
+		v, _ := rb.Session().Get("user")
+		rb.JSON(http.StatusOK, map[string]any{"user": v})
+	})
+	r.MapPost("/login", NoAuth, HandleError(func(rb *RequestBody) error {
+		return rb.Session().Set("user", "alice")
+	}))
+	return r
+}
+
+func TestSessionCreateAndPersist(t *testing.T) {
+	store := NewMemorySessionStore()
+	client := NewTestClient(newSessionRouter(store, SessionOptions{}))
+
+	res := client.Get("/api/peek")
+	if sessionCookie(res) != "" || len(store.sessions) != 0 {
+		t.Fatalf("request without values saved a session: cookie %q, %d stored", sessionCookie(res), len(store.sessions))
+	}
+
+	id := sessionCookie(client.Post("/api/login", nil))
+	if id == "" || len(store.sessions) != 1 {
+		t.Fatalf("Set did not save the session: cookie %q, %d stored", id, len(store.sessions))
+	}
+
+	client.Header.Set("Cookie", "session_id="+id)
+	var body struct{ User string }
+	res = client.Get("/api/peek")
+	if err := res.JSON(&body); err != nil || body.User != "alice" {
+		t.Fatalf("user = %q, %v, want alice", body.User, err)
+	}
+	if sessionCookie(res) != id {
+		t.Errorf("live session was not renewed")
+	}
+}
+
+func TestSessionExpire(t *testing.T) {
+	store := NewMemorySessionStore()
+	client := NewTestClient(newSessionRouter(store, SessionOptions{MaxAge: 10 * time.Millisecond}))
+
+	id := sessionCookie(client.Post("/api/login", nil))
+	time.Sleep(20 * time.Millisecond)
+
+	client.Header.Set("Cookie", "session_id="+id)
+	var body struct{ User *string }
+	if err := client.Get("/api/peek").JSON(&body); err != nil || body.User != nil {
+		t.Fatalf("expired session was loaded: %v, %v", body.User, err)
+	}
+	if _, ok := store.sessions[id]; ok {
+		t.Error("expired session was not dropped on load")
+	}
+}
+
+func TestMemorySessionStoreSweep(t *testing.T) {
+	store := NewMemorySessionStore()
+	store.Save("old", map[string]any{}, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	store.lastSweep = time.Now().Add(-2 * memorySessionSweep)
+	store.Save("new", map[string]any{}, time.Hour)
+
+	if _, ok := store.sessions["old"]; ok || len(store.sessions) != 1 {
+		t.Errorf("sessions = %v, want only the live one", store.sessions)
+	}
+}
+
+// failingStore fails every Save.
+type failingStore struct{ *MemorySessionStore }
+
+func (failingStore) Save(string, map[string]any, time.Duration) error {
+	return errors.New("store down")
+}
+
+func TestSessionSaveError(t *testing.T) {
+	store := failingStore{NewMemorySessionStore()}
+	store.sessions["abc"] = memorySession{map[string]any{}, time.Now().Add(time.Hour)}
+	client := NewTestClient(newSessionRouter(store, SessionOptions{}))
+
+	if res := client.Post("/api/login", nil); res.Status != http.StatusInternalServerError {
+		t.Errorf("failed Set: status = %d, want 500", res.Status)
+	}
+
+	client.Header.Set("Cookie", "session_id=abc")
+	if res := client.Get("/api/peek"); res.Status != http.StatusInternalServerError {
+		t.Errorf("failed renewal: status = %d, want 500", res.Status)
+	}
+}
+
+func TestCookieSessionStore(t *testing.T) {
+	old := NewCookieSessionStore([]byte("old secret"))
+	rotated := NewCookieSessionStore([]byte("new secret"), []byte("old secret"))
+
+	value := sessionCookie(NewTestClient(newSessionRouter(old, SessionOptions{})).Post("/api/login", nil))
+	if value == "" {
+		t.Fatal("Set did not encode the session into the cookie")
+	}
+
+	client := NewTestClient(newSessionRouter(rotated, SessionOptions{}))
+	client.Header.Set("Cookie", "session_id="+value)
+
+	var body struct{ User string }
+	res := client.Get("/api/peek")
+	if err := res.JSON(&body); err != nil || body.User != "alice" {
+		t.Fatalf("user = %q, %v; want the session of the rotated-out secret", body.User, err)
+	}
+	if _, _, ok := NewCookieSessionStore([]byte("old secret")).DecodeSession(sessionCookie(res)); ok {
+		t.Error("the renewed session was not encrypted with the new secret")
+	}
+
+	tampered := value[:len(value)-2] + "AA"
+	if _, _, ok := rotated.DecodeSession(tampered); ok {
+		t.Error("a tampered session was accepted")
+	}
+
+	// A cookie encrypted by SetCookie under the same secret is no session.
+	r := NewRouter()
+	r.SetCookieKeys([]byte("old secret"))
+	r.MapGet("/cookie", NoAuth, HandleError(func(rb *RequestBody) error {
+		return rb.SetCookie("session_id", `{"id":"x"}`, CookieOptions{Encrypt: true})
+	}))
+	if _, _, ok := old.DecodeSession(sessionCookie(NewTestClient(r).Get("/api/cookie"))); ok {
+		t.Error("an encrypted cookie passed for a session")
+	}
+}
@@ -0,0 +1,155 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrSessionTooLarge is returned when encoded session values do not fit in
+// a cookie.
+var ErrSessionTooLarge = errors.New("httpfly: session too large for a cookie")
+
+// maxCookieSession is the largest encoded session a cookie store writes.
+const maxCookieSession = 4000
+
+// CookieSessionStore keeps session values in the session cookie itself,
+// encrypted and authenticated like cookies set with CookieOptions.Encrypt.
+// Values are JSON encoded, so numbers read back as float64. Load, Save and
+// Delete are no-ops beyond decoding, since there is no server-side state.
+type CookieSessionStore struct {
+	keys []cookieKey
+}
+
+type cookieSession struct {
+	ID     string         `json:"id"`
+	Values map[string]any `json:"v"`
+}
+
+// cookieSessionName binds the encrypted sessions, so that the value of a
+// cookie set with SetCookie cannot pass for a session and vice versa.
+const cookieSessionName = "httpfly session"
+
+// NewCookieSessionStore creates a cookie store whose keys are derived from
+// secrets as by Router.SetCookieKeys: the first one encrypts new sessions
+// and all of them are tried when reading, so a secret can be rotated by
+// prepending its successor. It panics without a secret.
+func NewCookieSessionStore(secrets ...[]byte) *CookieSessionStore {
+	if len(secrets) == 0 {
+		panic("httpfly: NewCookieSessionStore needs a secret")
+	}
+
+	keys := make([]cookieKey, 0, len(secrets))
+	for _, s := range secrets {
+		keys = append(keys, newCookieKey(s))
+	}
+	return &CookieSessionStore{keys: keys}
+}
+
+// Load implements SessionStore by decoding a cookie value.
+func (c *CookieSessionStore) Load(value string) (map[string]any, bool, error) {
+	_, values, ok := c.DecodeSession(value)
+	return values, ok, nil
+}
+
+// Save implements SessionStore. The session cookie carries the values.
+func (c *CookieSessionStore) Save(id string, values map[string]any, ttl time.Duration) error {
+	return nil
+}
+
+// Delete implements SessionStore. Destroy expires the session cookie.
+func (c *CookieSessionStore) Delete(id string) error {
+	return nil
+}
+
+// EncodeSession implements SessionEncoder by sealing a session into a
+// cookie value.
+func (c *CookieSessionStore) EncodeSession(id string, values map[string]any, ttl time.Duration) (string, error) {
+	plain, err := json.Marshal(cookieSession{id, values})
+	if err != nil {
+		return "", err
+	}
+
+	out := encodeCookie(c.keys, cookieSessionName, plain, ttl, true)
+	if len(out) > maxCookieSession {
+		return "", ErrSessionTooLarge
+	}
+	return out, nil
+}
+
+// DecodeSession implements SessionEncoder, rejecting tampered and expired
+// sessions.
+func (c *CookieSessionStore) DecodeSession(value string) (string, map[string]any, bool) {
+	plain, err := decodeCookie(c.keys, cookieSessionName, value)
+	if err != nil {
+		return "", nil, false
+	}
+
+	var s cookieSession
+	if json.Unmarshal(plain, &s) != nil || s.ID == "" {
+		return "", nil, false
+	}
+
+	if s.Values == nil {
+		s.Values = map[string]any{}
+	}
+	return s.ID, s.Values, true
+}
+
+// RedisSessionStore keeps sessions in Redis, so they are shared between
+// instances. Values are JSON encoded, so numbers read back as float64. It
+// speaks the Redis protocol directly and needs no client library.
+type RedisSessionStore struct {
+	RedisConfig
+	// Prefix is prepended to session ids to form keys; default "session:".
+	Prefix string
+
+	pool redisPool
+}
+
+// NewRedisSessionStore creates a store for the Redis server at addr.
+func NewRedisSessionStore(addr string) *RedisSessionStore {
+	return &RedisSessionStore{RedisConfig: RedisConfig{Addr: addr}}
+}
+
+// Load implements SessionStore.
+func (s *RedisSessionStore) Load(id string) (map[string]any, bool, error) {
+	reply, err := s.pool.do(s.RedisConfig, "GET", s.key(id))
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+
+	var values map[string]any
+	if err := json.Unmarshal([]byte(reply.(string)), &values); err != nil {
+		return nil, false, err
+	}
+	if values == nil {
+		values = map[string]any{}
+	}
+	return values, true, nil
+}
+
+// Save implements SessionStore.
+func (s *RedisSessionStore) Save(id string, values map[string]any, ttl time.Duration) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.pool.do(s.RedisConfig, "SET", s.key(id), string(data), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Delete implements SessionStore.
+func (s *RedisSessionStore) Delete(id string) error {
+	_, err := s.pool.do(s.RedisConfig, "DEL", s.key(id))
+	return err
+}
+
+func (s *RedisSessionStore) key(id string) string {
+	if s.Prefix == "" {
+		return "session:" + id
+	}
+	return s.Prefix + id
+}
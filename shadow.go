@@ -0,0 +1,93 @@
+package httpfly
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Limits of shadow traffic sent by WithShadow.
+const (
+	DefaultShadowTimeout = 10 * time.Second
+	maxShadowInFlight    = 64
+)
+
+// ShadowHeader marks requests mirrored by WithShadow.
+const ShadowHeader = "X-Shadow-Request"
+
+// shadowHopHeaders are not copied to mirrored requests.
+var shadowHopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// WithShadow mirrors samplePercent percent of the requests of a route to
+// targetURL, e.g. a staging deployment of a new implementation. Mirrored
+// requests keep the method, path, query, headers and body, are marked with
+// X-Shadow-Request: 1 and are sent in the background; their responses and
+// errors are discarded and never affect the real response. At most 64 are
+// in flight per route, further ones are dropped. It panics if targetURL is
+// not an absolute URL.
+func WithShadow(targetURL string, samplePercent float64) RouteOption {
+	target, err := url.Parse(targetURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		panic("httpfly: invalid shadow target " + targetURL)
+	}
+
+	client := &http.Client{Timeout: DefaultShadowTimeout}
+	slots := make(chan struct{}, maxShadowInFlight)
+
+	return func(ri *RouteInfo) {
+		next := ri.HandlerF
+
+		ri.HandlerF = func(rb *RequestBody) {
+			if samplePercent > 0 && rand.Float64()*100 < samplePercent {
+				select {
+				case slots <- struct{}{}:
+					req := shadowRequest(rb, target)
+					go func() {
+						defer func() { <-slots }()
+						sendShadow(client, req, rb.router)
+					}()
+				default:
+				}
+			}
+
+			next(rb)
+		}
+	}
+}
+
+// shadowRequest copies the request of rb for target.
+func shadowRequest(rb *RequestBody, target *url.URL) *http.Request {
+	u := *target
+	u.Path = strings.TrimSuffix(target.Path, "/") + rb.req.URL.Path
+	u.RawPath = ""
+	u.RawQuery = rb.req.URL.RawQuery
+
+	req, _ := http.NewRequestWithContext(context.Background(), rb.req.Method, u.String(), bytes.NewReader(rb.JsonData))
+
+	req.Header = rb.req.Header.Clone()
+	for _, h := range shadowHopHeaders {
+		req.Header.Del(h)
+	}
+	req.Header.Set(ShadowHeader, "1")
+
+	return req
+}
+
+// sendShadow sends a mirrored request and discards the response.
+func sendShadow(client *http.Client, req *http.Request, r *Router) {
+	resp, err := client.Do(req)
+	if err != nil {
+		if r != nil && r.logger != nil {
+			r.logger.Info("shadow request failed", "method", req.Method, "url", req.URL.String(), "error", err.Error())
+		}
+		return
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
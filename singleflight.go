@@ -0,0 +1,87 @@
+package httpfly
+
+import (
+	"net/http"
+	"sync"
+)
+
+// flight is a handler execution shared by identical requests.
+type flight struct {
+	done chan struct{}
+	// resp is set once done is closed, unless the handler panicked.
+	resp *cachedResponse
+}
+
+// WithSingleflight coalesces concurrent identical GET and HEAD requests of
+// a route: while one runs the handler, requests for the same host, request
+// URI, Accept header and "sub" claim wait and receive a copy of its
+// response instead of running the handler themselves. Requests to UseAuth
+// routes whose claims carry no "sub" are never coalesced, since nothing
+// tells their callers apart. Waiters whose context ends get its error.
+// Responses that set cookies are not shared, and neither are those of a
+// handler that panicked; waiters then run the handler on their own.
+// Responses are buffered, so the option is not meant for streaming routes.
+func WithSingleflight() RouteOption {
+	var mu sync.Mutex
+	flights := map[string]*flight{}
+
+	return func(ri *RouteInfo) {
+		next := ri.HandlerF
+
+		ri.HandlerF = func(rb *RequestBody) {
+			if m := rb.req.Method; m != http.MethodGet && m != http.MethodHead {
+				next(rb)
+				return
+			}
+
+			sub := rb.ClaimString("sub")
+			if sub == "" && rb.route != nil && rb.route.AuthRequired {
+				next(rb)
+				return
+			}
+
+			key := rb.req.Method + " " + defaultCacheKey(rb) + "\x00" + sub
+
+			mu.Lock()
+			if f, ok := flights[key]; ok {
+				mu.Unlock()
+
+				select {
+				case <-f.done:
+				case <-rb.Context().Done():
+					rb.Fail(&HTTPError{Status: http.StatusServiceUnavailable, Message: rb.Context().Err().Error()})
+					return
+				}
+
+				if f.resp != nil && f.resp.Header.Get("Set-Cookie") == "" {
+					f.resp.replay(rb.ResponseW)
+					return
+				}
+				next(rb)
+				return
+			}
+
+			f := &flight{done: make(chan struct{})}
+			flights[key] = f
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				delete(flights, key)
+				mu.Unlock()
+				close(f.done)
+			}()
+
+			dst := rb.ResponseW
+			buf := newResponseBuffer()
+			rb.ResponseW = buf
+
+			next(rb)
+
+			rb.ResponseW = dst
+			f.resp = &cachedResponse{Status: buf.Status(), Header: buf.header.Clone(), Body: buf.body.Bytes()}
+
+			buf.flushTo(dst)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package httpfly
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// roleProvider authenticates every request with a "role" claim taken from
+// the Authorization header, and no "sub".
+type roleProvider struct{}
+
+func (roleProvider) Authenticate(req *http.Request) (map[string]string, error) {
+	return map[string]string{"role": req.Header.Get("Authorization")}, nil
+}
+
+// concurrentCalls sends n concurrent GETs to path, each with its own
+// Authorization header, and returns how often the handler ran.
+func concurrentCalls(t *testing.T, auth AuthRequire, n int) int32 {
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	r := NewRouter()
+	r.SetAuthProvider(roleProvider{})
+	r.MapGet("/report", auth, func(rb *RequestBody) {
+		calls.Add(1)
+		<-release
+		rb.JSON(http.StatusOK, map[string]string{"role": rb.ClaimString("role")})
+	}, WithSingleflight())
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := NewTestClient(r)
+			c.Header.Set("Authorization", string(rune('a'+i)))
+			if res := c.Get("/api/report"); res.Status != http.StatusOK {
+				t.Errorf("status = %d, want 200", res.Status)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	return calls.Load()
+}
+
+func TestSingleflightCoalescesAnonymous(t *testing.T) {
+	if calls := concurrentCalls(t, NoAuth, 5); calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestSingleflightSkipsAuthWithoutSubject(t *testing.T) {
+	if calls := concurrentCalls(t, UseAuth, 5); calls != 5 {
+		t.Errorf("handler ran %d times, want 5", calls)
+	}
+}
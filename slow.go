@@ -0,0 +1,183 @@
+package httpfly
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxStackDump bounds the buffer of a goroutine dump taken for a slow
+// request.
+const maxStackDump = 16 << 20
+
+// SlowRequest describes a handler that ran longer than the slow request
+// threshold.
+type SlowRequest struct {
+	Method string
+	// Route is the pattern of the route, e.g. "/api/users/{id}".
+	Route    string
+	Path     string
+	Duration time.Duration
+	// ParamsDigest is a short hash of the path parameters and query, so
+	// slow requests with the same inputs can be grouped without logging
+	// their values.
+	ParamsDigest string
+	// Stack is the stack of the handler goroutine when it crossed the
+	// threshold, if stack samples are enabled.
+	Stack []byte
+}
+
+// slowRequests is the slow request configuration of a router.
+type slowRequests struct {
+	threshold time.Duration
+	f         func(SlowRequest)
+	stacks    bool
+}
+
+// SetSlowRequestThreshold calls f for every handler of the default router
+// running longer than d. See Router.SetSlowRequestThreshold.
+func SetSlowRequestThreshold(d time.Duration, f func(SlowRequest)) {
+	defaultRouter.SetSlowRequestThreshold(d, f)
+}
+
+// SetSlowRequestThreshold calls f for every handler of the router that
+// runs longer than d, once it returns. Only the handler is timed, not the
+// middleware. f runs on the request goroutine, so it should hand expensive
+// work off. A non-positive d or nil f disables the check.
+func (r *Router) SetSlowRequestThreshold(d time.Duration, f func(SlowRequest)) {
+	if d <= 0 || f == nil {
+		r.slow = nil
+		return
+	}
+
+	stacks := r.slow != nil && r.slow.stacks
+	r.slow = &slowRequests{threshold: d, f: f, stacks: stacks}
+}
+
+// SetSlowRequestStacks enables stack samples of slow handlers of the
+// default router. See Router.SetSlowRequestStacks.
+func SetSlowRequestStacks(enabled bool) {
+	defaultRouter.SetSlowRequestStacks(enabled)
+}
+
+// SetSlowRequestStacks makes slow requests carry the stack of their
+// handler, sampled when it crosses the threshold, to show where it is
+// stuck. Sampling dumps every goroutine, which briefly stops the world, so
+// it only happens for slow requests. Call it after
+// SetSlowRequestThreshold.
+func (r *Router) SetSlowRequestStacks(enabled bool) {
+	if r.slow != nil {
+		slow := *r.slow
+		slow.stacks = enabled
+		r.slow = &slow
+	}
+}
+
+// runHandler runs the handler of v, reporting it if it is slow.
+func (r *Router) runHandler(v *RouteInfo, rqbody *RequestBody) {
+	slow := r.slow
+	if slow == nil {
+		v.HandlerF(rqbody)
+		return
+	}
+
+	start := time.Now()
+
+	var stack []byte
+	var timer *time.Timer
+	sampled := make(chan struct{})
+
+	if slow.stacks {
+		id := goroutineID()
+		timer = time.AfterFunc(slow.threshold, func() {
+			stack = goroutineStack(id)
+			close(sampled)
+		})
+	}
+
+	v.HandlerF(rqbody)
+	duration := time.Since(start)
+
+	if timer != nil && !timer.Stop() {
+		<-sampled
+	}
+
+	if duration >= slow.threshold {
+		slow.f(SlowRequest{
+			Method:       rqbody.req.Method,
+			Route:        v.Endpoint,
+			Path:         rqbody.req.URL.Path,
+			Duration:     duration,
+			ParamsDigest: paramsDigest(rqbody),
+			Stack:        stack,
+		})
+	}
+}
+
+// paramsDigest hashes the path parameters and query of a request.
+func paramsDigest(rb *RequestBody) string {
+	names := make([]string, 0, len(rb.Params))
+	for name := range rb.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write(rb.Params[name])
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(rb.req.URL.Query().Encode()))
+
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// goroutineID returns the id of the calling goroutine, from the header of
+// its stack trace.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// goroutineStack returns the stack of the goroutine with the given id, or
+// nil if it has exited.
+func goroutineStack(id uint64) []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackDump {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	header := []byte("goroutine " + strconv.FormatUint(id, 10) + " [")
+	for len(buf) > 0 {
+		block := buf
+		if i := bytes.Index(buf, []byte("\n\n")); i >= 0 {
+			block, buf = buf[:i+1], buf[i+2:]
+		} else {
+			buf = nil
+		}
+
+		if bytes.HasPrefix(block, header) {
+			return bytes.Clone(block)
+		}
+	}
+	return nil
+}
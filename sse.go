@@ -0,0 +1,135 @@
+package httpfly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSSEHeartbeat is how often an idle event stream sends a keep-alive
+// comment.
+const DefaultSSEHeartbeat = 15 * time.Second
+
+// SSEStream writes server-sent events to the client. It is safe for
+// concurrent use.
+type SSEStream struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	ctx context.Context
+
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+}
+
+// SSE starts a server-sent event stream on the response. It writes the
+// event-stream headers, then sends a keep-alive comment every
+// DefaultSSEHeartbeat while the handler runs. The stream is closed when the
+// handler returns.
+func (r *RequestBody) SSE() *SSEStream {
+	h := r.ResponseW.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+
+	s := &SSEStream{
+		w:    r.ResponseW,
+		rc:   http.NewResponseController(r.ResponseW),
+		ctx:  r.Context(),
+		stop: make(chan struct{}),
+	}
+
+	r.ResponseW.WriteHeader(http.StatusOK)
+	s.rc.Flush()
+
+	r.onDone(s.Close)
+	go s.heartbeat(DefaultSSEHeartbeat)
+
+	return s
+}
+
+// Done is closed when the client disconnects.
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes an event and flushes it. An empty event name sends an unnamed
+// "message" event. Multi-line data is split over several data lines.
+func (s *SSEStream) Send(event string, data string) error {
+	var b strings.Builder
+
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// SendJSON writes an event whose data is v encoded as JSON.
+func (s *SSEStream) SendJSON(event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Send(event, string(data))
+}
+
+// ErrStreamClosed is returned by sends on a closed event stream.
+var ErrStreamClosed = errors.New("httpfly: event stream closed")
+
+// Close stops the heartbeat. Later sends fail with ErrStreamClosed.
+func (s *SSEStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+}
+
+func (s *SSEStream) write(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, err := s.w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// heartbeat sends keep-alive comments until the stream is closed or the
+// client goes away.
+func (s *SSEStream) heartbeat(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if s.write(": ping\n\n") != nil {
+				return
+			}
+		case <-s.stop:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package httpfly
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticOption configures ServeStatic.
+type StaticOption func(*staticConfig)
+
+type staticConfig struct {
+	fallback string
+	maxAge   time.Duration
+	// etags caches content hashes of files without a modification time,
+	// such as those of an embed.FS.
+	etags *sync.Map
+}
+
+// SPAFallback serves the given file, relative to the static directory, for
+// paths that match no file, so client-side routes of a single-page app load
+// the app.
+func SPAFallback(index string) StaticOption {
+	return func(c *staticConfig) {
+		c.fallback = index
+	}
+}
+
+// StaticMaxAge lets clients cache the served files for d with a
+// Cache-Control: public, max-age header. Directory indexes and responses of
+// SPAFallback are always revalidated so new deployments load.
+func StaticMaxAge(d time.Duration) StaticOption {
+	return func(c *staticConfig) {
+		c.maxAge = d
+	}
+}
+
+// ServeStatic serves the files below dir at urlPrefix on the default router.
+// Like every route, urlPrefix is mapped below RoutePrefix. Content types
+// follow the file extension, responses carry ETag and Last-Modified headers
+// for conditional requests, and paths cannot escape dir. Directories serve
+// their index.html and are never listed.
+func ServeStatic(urlPrefix, dir string, opts ...StaticOption) {
+	defaultRouter.ServeStatic(urlPrefix, dir, opts...)
+}
+
+// ServeStatic serves the files below dir at urlPrefix on the router.
+func (r *Router) ServeStatic(urlPrefix, dir string, opts ...StaticOption) {
+	r.serveStaticRoot(urlPrefix, http.Dir(dir), staticConfig{}, opts)
+}
+
+// ServeStaticFS serves the files of fsys at urlPrefix on the default
+// router. See Router.ServeStaticFS.
+func ServeStaticFS(urlPrefix string, fsys fs.FS, opts ...StaticOption) {
+	defaultRouter.ServeStaticFS(urlPrefix, fsys, opts...)
+}
+
+// ServeStaticFS serves the files of fsys at urlPrefix on the router, like
+// ServeStatic. It is meant for assets embedded with go:embed; use fs.Sub to
+// serve a subdirectory:
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	sub, _ := fs.Sub(dist, "dist")
+//	r.ServeStaticFS("/", sub, httpfly.SPAFallback("index.html"), httpfly.StaticMaxAge(time.Hour))
+//
+// Files without a modification time, as in an embed.FS, get an ETag from
+// a hash of their contents, computed once, so fsys must not change.
+func (r *Router) ServeStaticFS(urlPrefix string, fsys fs.FS, opts ...StaticOption) {
+	r.serveStaticRoot(urlPrefix, http.FS(fsys), staticConfig{etags: &sync.Map{}}, opts)
+}
+
+// serveStaticRoot maps the routes serving root at urlPrefix.
+func (r *Router) serveStaticRoot(urlPrefix string, root http.FileSystem, cfg staticConfig, opts []StaticOption) {
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	prefix := strings.TrimSuffix(urlPrefix, "/")
+
+	h := func(rb *RequestBody) {
+		r.serveStatic(rb.ResponseW, rb.req, root, string(rb.Params["filepath"]), cfg)
+	}
+
+	for _, m := range []RequestMethod{MethodGet, MethodHead} {
+		r.addRoute(m, prefix+"/*filepath", NoAuth, h, nil)
+		if prefix != "" {
+			r.addRoute(m, prefix, NoAuth, h, nil)
+		}
+	}
+}
+
+// serveStatic serves name from root, falling back to cfg.fallback when set.
+func (r *Router) serveStatic(w http.ResponseWriter, req *http.Request, root http.FileSystem, name string, cfg staticConfig) {
+	err := serveFile(w, req, root, name, cfg, cfg.maxAge)
+
+	if errors.Is(err, fs.ErrNotExist) && cfg.fallback != "" {
+		err = serveFile(w, req, root, cfg.fallback, cfg, 0)
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist):
+		r.frameworkError(w, req, http.StatusNotFound, "")
+	case errors.Is(err, fs.ErrPermission):
+		r.frameworkError(w, req, http.StatusForbidden, "")
+	default:
+		r.frameworkError(w, req, http.StatusInternalServerError, "")
+	}
+}
+
+// serveFile writes a single file, or the index.html of a directory. A
+// positive maxAge lets clients cache it.
+func serveFile(w http.ResponseWriter, req *http.Request, root http.FileSystem, name string, cfg staticConfig, maxAge time.Duration) error {
+	name = path.Clean("/" + name)
+
+	f, err := root.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return serveIndex(w, req, root, name, cfg)
+	}
+
+	etag := fileETag(info)
+	if info.ModTime().IsZero() && cfg.etags != nil {
+		if etag, err = contentETag(cfg.etags, name, f); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("ETag", etag)
+	if maxAge > 0 {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge/time.Second)))
+	} else if cfg.maxAge > 0 {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	http.ServeContent(w, req, info.Name(), info.ModTime(), f)
+	return nil
+}
+
+// contentETag returns the cached content hash of the file called name,
+// hashing f the first time.
+func contentETag(cache *sync.Map, name string, f io.ReadSeeker) (string, error) {
+	if etag, ok := cache.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := fmt.Sprintf(`"%x"`, h.Sum(nil)[:16])
+	cache.Store(name, etag)
+	return etag, nil
+}
+
+// serveIndex serves the index.html of a directory.
+func serveIndex(w http.ResponseWriter, req *http.Request, root http.FileSystem, dir string, cfg staticConfig) error {
+	index := path.Join(dir, "index.html")
+
+	f, err := root.Open(index)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	return serveFile(w, req, root, index, cfg, 0)
+}
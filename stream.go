@@ -0,0 +1,124 @@
+package httpfly
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Stream writes a chunked response by calling step until it returns false,
+// flushing after every call. It stops early with the context error when the
+// client disconnects.
+func (r *RequestBody) Stream(step func(w io.Writer) bool) error {
+	rc := http.NewResponseController(r.ResponseW)
+	ctx := r.Context()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !step(r.ResponseW) {
+			return nil
+		}
+
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+}
+
+// SendFile writes the file at path. The content type is derived from the
+// file extension or contents, and conditional requests are answered with
+// 304 responses. Range requests, including multiple ranges, are answered
+// with 206 Partial Content, and the strong ETag derived from the file size
+// and modification time lets clients resume downloads with If-Range. A
+// missing file returns a 404 *HTTPError.
+func (r *RequestBody) SendFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &HTTPError{Status: http.StatusNotFound, Message: "file not found"}
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return &HTTPError{Status: http.StatusNotFound, Message: "file not found"}
+	}
+
+	if r.ResponseW.Header().Get("ETag") == "" {
+		r.ResponseW.Header().Set("ETag", fileETag(info))
+	}
+	http.ServeContent(r.ResponseW, r.req, filepath.Base(path), info.ModTime(), f)
+	return nil
+}
+
+// Download writes the file at path like SendFile, as an attachment saved
+// as filename. An empty filename means the base name of path.
+func (r *RequestBody) Download(path, filename string) error {
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+	r.Attachment(filename)
+	return r.SendFile(path)
+}
+
+// fileETag returns a strong entity tag for a file. It is strong so that
+// If-Range requests can match it.
+func fileETag(info fs.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+}
+
+// SendReader writes the contents of rd with the given content type. Readers
+// that implement io.Seeker support Range requests; for others, a size of
+// zero or more is sent as Content-Length and a negative size streams the
+// body chunked.
+func (r *RequestBody) SendReader(contentType string, rd io.Reader, size int64) error {
+	if contentType != "" {
+		r.ResponseW.Header().Set("Content-Type", contentType)
+	}
+
+	if rs, ok := rd.(io.ReadSeeker); ok {
+		http.ServeContent(r.ResponseW, r.req, "", time.Time{}, rs)
+		return nil
+	}
+
+	if size >= 0 {
+		r.ResponseW.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+
+	_, err := io.Copy(r.ResponseW, rd)
+	return err
+}
+
+// Attachment sets Content-Disposition so browsers download the response as
+// filename.
+func (r *RequestBody) Attachment(filename string) {
+	r.ResponseW.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+}
+
+// Inline sets Content-Disposition so browsers display the response, using
+// filename when it is saved.
+func (r *RequestBody) Inline(filename string) {
+	r.ResponseW.Header().Set("Content-Disposition", contentDisposition("inline", filename))
+}
+
+func contentDisposition(kind, filename string) string {
+	if filename == "" {
+		return kind
+	}
+	return mime.FormatMediaType(kind, map[string]string{"filename": filename})
+}
@@ -0,0 +1,294 @@
+package httpfly
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// routeTable is an immutable snapshot of the registered routes. The routes
+// are kept in specificity order for listing, and indexed by a segment trie
+// for lookup. Writers build a new table and swap it in.
+type routeTable struct {
+	routes []*RouteInfo
+	root   *node
+}
+
+// newRouteTable builds the lookup trie for routes, which must already be in
+// specificity order.
+func newRouteTable(routes []*RouteInfo) *routeTable {
+	t := &routeTable{routes: routes, root: &node{}}
+
+	for _, ri := range routes {
+		t.root.add(ri)
+	}
+
+	return t
+}
+
+// currentRoutes returns the route table requests are matched against.
+func (r *Router) currentRoutes() *routeTable {
+	if t := r.routes.Load(); t != nil {
+		return t
+	}
+	return newRouteTable(nil)
+}
+
+// withRoute returns a copy of the table with ri added. The trie is
+// extended rather than rebuilt.
+func (t *routeTable) withRoute(ri *RouteInfo) *routeTable {
+	routes := insertRoute(append([]*RouteInfo(nil), t.routes...), ri)
+	checkSegments(ri)
+	return &routeTable{routes: routes, root: t.root.with(ri, ri.segments)}
+}
+
+// insertRoute adds ri to routes at its specificity position. Registering the
+// same method, path, version and host twice panics.
+func insertRoute(routes []*RouteInfo, ri *RouteInfo) []*RouteInfo {
+	for _, v := range routes {
+		if v.Method == ri.Method && v.Endpoint == ri.Endpoint && v.Version == ri.Version && v.Host == ri.Host {
+			panic(fmt.Sprintf("httpfly: duplicate route %s %s registered at %s, previously registered at %s",
+				ri.Method, ri.Endpoint, ri.Source, v.Source))
+		}
+	}
+
+	i := sort.Search(len(routes), func(i int) bool {
+		return routeLess(ri, routes[i])
+	})
+
+	routes = append(routes, nil)
+	copy(routes[i+1:], routes[i:])
+	routes[i] = ri
+
+	return routes
+}
+
+// match returns the most specific route registered for the given method,
+// escaped path and host together with its path and host parameters, or nil.
+// A route registered for version is preferred over the default route.
+func (t *routeTable) match(method string, path string, version string, host string) (*RouteInfo, Parameters) {
+	return t.matchPath(method, path, version, host, false)
+}
+
+// matchFold is like match, but compares static path segments
+// case-insensitively.
+func (t *routeTable) matchFold(method string, path string, version string, host string) (*RouteInfo, Parameters) {
+	return t.matchPath(method, path, version, host, true)
+}
+
+func (t *routeTable) matchPath(method string, path string, version string, host string, fold bool) (*RouteInfo, Parameters) {
+	segments, ok := splitPath(path)
+	if !ok {
+		return nil, nil
+	}
+
+	ri := t.root.lookup(segments, fold, func(routes []*RouteInfo) *RouteInfo {
+		return selectRoute(filterParamTypes(routes, segments), method, version, host)
+	})
+
+	if ri == nil {
+		return nil, nil
+	}
+
+	params := Parameters{}
+	if ri.Host != "" {
+		ri.hostParams(host, params)
+	}
+	for i, p := range ri.segments {
+		switch {
+		case isParamSegment(p):
+			name, _ := paramSegment(p)
+			params[name] = []byte(segments[i])
+		case isCatchAllSegment(p):
+			params[p[1:]] = []byte(strings.Join(segments[i:], "/"))
+		}
+	}
+
+	return ri, params
+}
+
+// allowed returns the sorted methods registered for an escaped path and
+// host, across every route pattern matching it.
+func (t *routeTable) allowed(path string, host string) []string {
+	segments, ok := splitPath(path)
+	if !ok {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var methods []string
+
+	add := func(method string) {
+		if !seen[method] {
+			seen[method] = true
+			methods = append(methods, method)
+		}
+	}
+
+	t.root.walk(segments, func(routes []*RouteInfo) {
+		for _, v := range filterParamTypes(routes, segments) {
+			if !v.hostParams(host, nil) {
+				continue
+			}
+			add(string(v.Method))
+			if v.Method == MethodGet && !v.noAutoHead {
+				add(http.MethodHead)
+			}
+		}
+	})
+
+	sort.Strings(methods)
+	return methods
+}
+
+// splitPath splits an escaped path into decoded segments.
+func splitPath(path string) ([]string, bool) {
+	segments := strings.Split(path, "/")
+
+	for i, s := range segments {
+		decoded, err := url.PathUnescape(s)
+		if err != nil {
+			return nil, false
+		}
+		segments[i] = decoded
+	}
+
+	return segments, true
+}
+
+// selectRoute picks the route for method among routes sharing a path,
+// preferring a route restricted to host over one for any host, then the
+// one registered for version over the default route, and the most specific
+// one among equals.
+func selectRoute(routes []*RouteInfo, method string, version string, host string) *RouteInfo {
+	var best *RouteInfo
+	bestRank := -1
+
+	for _, v := range routes {
+		if method != string(v.Method) || (v.Version != version && v.Version != "") || !v.hostParams(host, nil) {
+			continue
+		}
+
+		rank := 0
+		if v.Host != "" {
+			rank += 2
+		}
+		if v.Version != "" {
+			rank++
+		}
+
+		if rank > bestRank {
+			best, bestRank = v, rank
+		}
+	}
+
+	return best
+}
+
+// Registrar collects routes for a new route table. See ReplaceRoutes.
+type Registrar struct {
+	prefix string
+	routes []*RouteInfo
+}
+
+func (r *Registrar) add(method RequestMethod, path string, auth AuthRequire, f Handler, opts []RouteOption) *RouteInfo {
+	ri := newRoute(r.prefix, method, path, auth, f, opts)
+	r.routes = insertRoute(r.routes, ri)
+	return ri
+}
+
+// MapGet maps a GET route in the new table.
+func (r *Registrar) MapGet(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.add(MethodGet, path, auth, f, opts)
+}
+
+// MapPost maps a POST route in the new table.
+func (r *Registrar) MapPost(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.add(MethodPost, path, auth, f, opts)
+}
+
+// MapPut maps a PUT route in the new table.
+func (r *Registrar) MapPut(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.add(MethodPut, path, auth, f, opts)
+}
+
+// MapDelete maps a DELETE route in the new table.
+func (r *Registrar) MapDelete(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.add(MethodDelete, path, auth, f, opts)
+}
+
+// MapPatch maps a PATCH route in the new table.
+func (r *Registrar) MapPatch(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.add(MethodPatch, path, auth, f, opts)
+}
+
+// MapOptions maps an OPTIONS route in the new table.
+func (r *Registrar) MapOptions(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.add(MethodOptions, path, auth, f, opts)
+}
+
+// MapHead maps a HEAD route in the new table.
+func (r *Registrar) MapHead(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.add(MethodHead, path, auth, f, opts)
+}
+
+// Map maps a route for any request method in the new table.
+func (r *Registrar) Map(method RequestMethod, path string, auth AuthRequire, f Handler, opts ...RouteOption) *RouteInfo {
+	return r.add(method, path, auth, f, opts)
+}
+
+// ReplaceRoutes builds a fresh route table with build and atomically swaps it
+// in for the current one. Requests already being handled finish with the
+// table they were matched against. It is safe to call while serving.
+func ReplaceRoutes(build func(r *Registrar)) {
+	defaultRouter.ReplaceRoutes(build)
+}
+
+// ReplaceRoutes atomically replaces the route table of the router. See the
+// package-level ReplaceRoutes.
+func (r *Router) ReplaceRoutes(build func(r *Registrar)) {
+	reg := &Registrar{prefix: r.options().Prefix}
+	build(reg)
+	table := newRouteTable(reg.routes)
+
+	r.routesMu.Lock()
+	r.routes.Store(table)
+	r.routesMu.Unlock()
+
+	for _, ri := range reg.routes {
+		r.routeRegistered(ri)
+	}
+}
+
+// UnmapRoute removes the routes of the default router registered for method
+// and path, in every API version. It reports whether a route was removed.
+func UnmapRoute(method RequestMethod, path string) bool {
+	return defaultRouter.UnmapRoute(method, path)
+}
+
+// UnmapRoute removes routes of the router. It is safe to call while serving;
+// requests already matched to a removed route still finish.
+func (r *Router) UnmapRoute(method RequestMethod, path string) bool {
+	endpoint := r.options().Prefix + path
+
+	r.routesMu.Lock()
+	defer r.routesMu.Unlock()
+
+	current := r.currentRoutes()
+	kept := make([]*RouteInfo, 0, len(current.routes))
+
+	for _, v := range current.routes {
+		if v.Method != method || v.Endpoint != endpoint {
+			kept = append(kept, v)
+		}
+	}
+
+	if len(kept) == len(current.routes) {
+		return false
+	}
+
+	r.routes.Store(newRouteTable(kept))
+	return true
+}
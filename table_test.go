@@ -0,0 +1,66 @@
+package httpfly
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestReplaceRoutesUnderLoad(t *testing.T) {
+	tables := []func(reg *Registrar){
+		func(reg *Registrar) {
+			reg.MapGet("/items", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "a") })
+			reg.MapGet("/items/{id}", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "a "+string(rb.Params["id"])) })
+		},
+		func(reg *Registrar) {
+			reg.MapGet("/items", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "b") })
+			reg.MapGet("/items/{id}", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "b "+string(rb.Params["id"])) })
+			reg.MapGet("/extra", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "b") })
+		},
+	}
+
+	r := NewRouter()
+	r.ReplaceRoutes(tables[0])
+	c := NewTestClient(r)
+
+	stop := make(chan struct{})
+	var swaps sync.WaitGroup
+	swaps.Add(1)
+	go func() {
+		defer swaps.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			r.ReplaceRoutes(tables[i%2])
+		}
+	}()
+
+	var clients sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			for i := 0; i < 200; i++ {
+				if resp := c.Get("/api/items"); resp.Status != http.StatusOK || (resp.String() != "a" && resp.String() != "b") {
+					t.Errorf("GET /api/items = %d %q", resp.Status, resp.String())
+					return
+				}
+				if resp := c.Get("/api/items/7"); resp.Status != http.StatusOK || (resp.String() != "a 7" && resp.String() != "b 7") {
+					t.Errorf("GET /api/items/7 = %d %q", resp.Status, resp.String())
+					return
+				}
+				if resp := c.Get("/api/extra"); resp.Status != http.StatusOK && resp.Status != http.StatusNotFound {
+					t.Errorf("GET /api/extra = %d", resp.Status)
+					return
+				}
+			}
+		}()
+	}
+
+	clients.Wait()
+	close(stop)
+	swaps.Wait()
+}
@@ -0,0 +1,118 @@
+package httpfly
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyWindow is the number of most recent samples kept per route.
+const latencyWindow = 1024
+
+// RouteLatency reports latency percentiles for a single route.
+type RouteLatency struct {
+	Route string
+	Count int
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// latencyRing is a fixed-size rolling window of request durations. Writers
+// claim a slot with an atomic counter, so recording never blocks.
+type latencyRing struct {
+	samples [latencyWindow]atomic.Int64
+	n       atomic.Uint64
+}
+
+func (l *latencyRing) add(d time.Duration) {
+	i := l.n.Add(1) - 1
+	l.samples[i%latencyWindow].Store(int64(d))
+}
+
+// snapshot returns the samples in the window in no particular order. Slots
+// claimed by a writer that has not stored its sample yet are skipped.
+func (l *latencyRing) snapshot() []time.Duration {
+	n := min(l.n.Load(), latencyWindow)
+	out := make([]time.Duration, 0, n)
+	for i := range n {
+		if d := l.samples[i].Load(); d != 0 {
+			out = append(out, time.Duration(d))
+		}
+	}
+	return out
+}
+
+// LatencyReporter is implemented by metrics collectors that keep a rolling
+// window of request durations per route, such as PrometheusMetrics.
+type LatencyReporter interface {
+	// SlowestRoutes returns up to n routes ordered by their p95 latency,
+	// slowest first. A non-positive n returns every route.
+	SlowestRoutes(n int) []RouteLatency
+}
+
+// SlowestRoutes returns up to n routes of the default router ordered by
+// their p95 latency, slowest first. See Router.SlowestRoutes.
+func SlowestRoutes(n int) []RouteLatency {
+	return defaultRouter.SlowestRoutes(n)
+}
+
+// SlowestRoutes returns up to n routes of the router ordered by their p95
+// latency, slowest first. Percentiles are computed by the metrics collector
+// over the most recent requests of each route, so the result is empty
+// unless the collector is a LatencyReporter, see UseMetrics. A non-positive
+// n returns every route.
+func (r *Router) SlowestRoutes(n int) []RouteLatency {
+	if lr, ok := r.metrics.(LatencyReporter); ok {
+		return lr.SlowestRoutes(n)
+	}
+	return nil
+}
+
+// slowestRoutes computes the report of SlowestRoutes from latency windows
+// keyed by routeKey.
+func slowestRoutes(latencies *sync.Map, n int) []RouteLatency {
+	var result []RouteLatency
+	latencies.Range(func(k, ring any) bool {
+		sorted := ring.(*latencyRing).snapshot()
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		rk := k.(routeKey)
+		result = append(result, RouteLatency{
+			Route: rk.method + " " + rk.route,
+			Count: len(sorted),
+			P50:   percentile(sorted, 50),
+			P95:   percentile(sorted, 95),
+			P99:   percentile(sorted, 99),
+		})
+		return true
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].P95 != result[j].P95 {
+			return result[i].P95 > result[j].P95
+		}
+		return result[i].Route < result[j].Route
+	})
+
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+
+	return result
+}
+
+// percentile returns the p-th percentile of sorted using nearest-rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
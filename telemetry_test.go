@@ -0,0 +1,78 @@
+package httpfly
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlowestRoutes(t *testing.T) {
+	m := NewPrometheusMetrics()
+
+	// 1ms to 100ms in shuffled order, and a route that is always fast.
+	for i := range 100 {
+		m.ObserveRequest("GET", "/api/slow", http.StatusOK, time.Duration((i*37)%100+1)*time.Millisecond, 0)
+		m.ObserveRequest("GET", "/api/fast", http.StatusOK, time.Millisecond, 0)
+	}
+
+	m.ObserveRequest("GET", "", http.StatusNotFound, time.Hour, 0)
+
+	got := m.SlowestRoutes(0)
+	if len(got) != 2 || got[0].Route != "GET /api/slow" {
+		t.Fatalf("routes = %+v, want the slow route first", got)
+	}
+
+	want := RouteLatency{Route: "GET /api/slow", Count: 100, P50: 50 * time.Millisecond, P95: 95 * time.Millisecond, P99: 99 * time.Millisecond}
+	if got[0] != want {
+		t.Errorf("slow route = %+v, want %+v", got[0], want)
+	}
+	if got[1].P99 != time.Millisecond {
+		t.Errorf("fast route p99 = %v, want 1ms", got[1].P99)
+	}
+
+	if top := m.SlowestRoutes(1); len(top) != 1 || top[0].Route != "GET /api/slow" {
+		t.Errorf("SlowestRoutes(1) = %+v", top)
+	}
+}
+
+func TestLatencyWindowRolls(t *testing.T) {
+	m := NewPrometheusMetrics()
+	for range latencyWindow {
+		m.ObserveRequest("GET", "/api/x", http.StatusOK, time.Second, 0)
+	}
+	for range latencyWindow {
+		m.ObserveRequest("GET", "/api/x", http.StatusOK, time.Millisecond, 0)
+	}
+
+	if got := m.SlowestRoutes(0)[0]; got.Count != latencyWindow || got.P99 != time.Millisecond {
+		t.Errorf("got %+v, want only the recent samples", got)
+	}
+}
+
+func TestLatenciesRecordedWithMetrics(t *testing.T) {
+	r := NewRouter()
+	r.MapGet("/x", NoAuth, func(rb *RequestBody) {})
+	c := NewTestClient(r)
+
+	c.Get("/api/x")
+	if got := r.SlowestRoutes(0); len(got) != 0 {
+		t.Errorf("without metrics: routes = %+v, want none", got)
+	}
+
+	r.UseMetrics(NewPrometheusMetrics())
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Get("/api/x")
+		}()
+	}
+	wg.Wait()
+
+	if got := r.SlowestRoutes(0); len(got) != 1 || got[0].Route != "GET /api/x" || got[0].Count != 20 {
+		t.Errorf("with metrics: routes = %+v, want 20 samples of GET /api/x", got)
+	}
+}
@@ -0,0 +1,103 @@
+package httpfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+)
+
+// TestClient sends requests through a router in memory, running the full
+// routing, auth and middleware pipeline without a network listener.
+type TestClient struct {
+	router *Router
+	// Header is added to every request, e.g. an Authorization header.
+	Header http.Header
+}
+
+// TestResponse is a recorded response.
+type TestResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// NewTestClient creates a test client for r. Nil means the default router.
+func NewTestClient(r *Router) *TestClient {
+	if r == nil {
+		r = defaultRouter
+	}
+	return &TestClient{router: r, Header: http.Header{}}
+}
+
+// Get sends a GET request.
+func (c *TestClient) Get(path string) *TestResponse {
+	return c.Do(http.MethodGet, path, nil)
+}
+
+// Post sends a POST request with body encoded as JSON.
+func (c *TestClient) Post(path string, body any) *TestResponse {
+	return c.Do(http.MethodPost, path, body)
+}
+
+// Put sends a PUT request with body encoded as JSON.
+func (c *TestClient) Put(path string, body any) *TestResponse {
+	return c.Do(http.MethodPut, path, body)
+}
+
+// Delete sends a DELETE request.
+func (c *TestClient) Delete(path string) *TestResponse {
+	return c.Do(http.MethodDelete, path, nil)
+}
+
+// Do sends a request. A body of type []byte, string or io.Reader is sent
+// as is; any other non-nil body is encoded as JSON.
+func (c *TestClient) Do(method, path string, body any) *TestResponse {
+	var reader io.Reader
+	isJSON := false
+
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(b)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	case io.Reader:
+		reader = b
+	default:
+		out, err := json.Marshal(b)
+		if err != nil {
+			panic("httpfly: encoding test request body: " + err.Error())
+		}
+		reader, isJSON = bytes.NewReader(out), true
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	if isJSON && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.Send(req)
+}
+
+// Send sends a prepared request.
+func (c *TestClient) Send(req *http.Request) *TestResponse {
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+
+	return &TestResponse{Status: rec.Code, Header: rec.Header(), Body: rec.Body.Bytes()}
+}
+
+// JSON decodes the response body into v.
+func (r *TestResponse) JSON(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// String returns the response body as a string.
+func (r *TestResponse) String() string {
+	return string(r.Body)
+}
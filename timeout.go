@@ -0,0 +1,166 @@
+package httpfly
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// UseTimeout limits how long handlers of the default router may run. See
+// Router.UseTimeout.
+func UseTimeout(d time.Duration) {
+	defaultRouter.UseTimeout(d)
+}
+
+// UseTimeout limits how long the middleware and handler of a route may run.
+// When d passes, the request context is canceled and the client gets 504
+// Gateway Timeout; whatever the handler writes afterwards is discarded.
+// Responses of timed routes are buffered until the handler returns, so they
+// cannot be streamed. Middleware and handlers of a timed route run on a copy
+// of the RequestBody; changes they make after the timeout, e.g. to Locals,
+// are not seen by after-response hooks. Zero disables the limit.
+func (r *Router) UseTimeout(d time.Duration) {
+	r.timeout = d
+}
+
+// WithTimeout overrides the router timeout for a single route. A negative
+// duration disables the timeout for the route.
+func WithTimeout(d time.Duration) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.timeout = d
+	}
+}
+
+// runTimed runs the route with the effective timeout of the route.
+func (r *Router) runTimed(v *RouteInfo, mws *middlewareSet, rb *RequestBody, w *ResponseRecorder) {
+	d := r.timeout
+	if v.timeout != 0 {
+		d = v.timeout
+	}
+
+	if d <= 0 {
+		r.runRoute(v, mws, rb, w)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(rb.Context(), d)
+	defer cancel()
+
+	dst, w := rb.ResponseW, rb.w
+	tw := &timeoutWriter{header: http.Header{}}
+	inner := &ResponseRecorder{ResponseWriter: tw}
+
+	// The handler runs on its own copy of the request body, so a handler
+	// that is still running after the timeout does not race the teardown
+	// of rb. The copy is taken back once the handler has finished in time.
+	tb := *rb
+	tb.req = rb.req.WithContext(ctx)
+	tb.ResponseW = inner
+	tb.w = inner
+	tb.locals = maps.Clone(rb.locals)
+	tb.values = maps.Clone(rb.values)
+	tb.done = slices.Clip(rb.done)
+	tb.deferred = slices.Clip(rb.deferred)
+	tb.finish = slices.Clip(rb.finish)
+
+	done := make(chan struct{})
+	panicked := make(chan any, 1)
+
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				panicked <- rec
+			}
+			close(done)
+		}()
+
+		r.runRoute(v, mws, &tb, inner)
+	}()
+
+	select {
+	case <-done:
+		*rb = tb
+		rb.ResponseW, rb.w = dst, w
+
+		select {
+		case rec := <-panicked:
+			panic(rec)
+		default:
+		}
+		tw.flushTo(dst)
+
+	case <-ctx.Done():
+		tw.expire()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r.frameworkError(dst, rb.req, http.StatusGatewayTimeout, "handler timeout")
+		}
+	}
+}
+
+// timeoutWriter buffers a response until the handler finishes, and rejects
+// writes once the request has timed out.
+type timeoutWriter struct {
+	mu      sync.Mutex
+	header  http.Header
+	buf     bytes.Buffer
+	status  int
+	expired bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.status == 0 && !tw.expired {
+		tw.status = status
+	}
+}
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(data)
+}
+
+// expire makes later writes fail with http.ErrHandlerTimeout.
+func (tw *timeoutWriter) expire() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.expired = true
+}
+
+// flushTo copies the buffered response to w.
+func (tw *timeoutWriter) flushTo(w http.ResponseWriter) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	h := w.Header()
+	for k, v := range tw.header {
+		h[k] = v
+	}
+
+	if tw.status == 0 {
+		return
+	}
+
+	w.WriteHeader(tw.status)
+	w.Write(tw.buf.Bytes())
+}
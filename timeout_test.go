@@ -0,0 +1,85 @@
+package httpfly
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTimeoutAbandonsSlowHandler(t *testing.T) {
+	finished := make(chan struct{})
+	seen := make(chan any, 1)
+
+	r := NewRouter()
+	r.UseTimeout(20 * time.Millisecond)
+	r.AfterResponse(func(rb *RequestBody, status int, duration time.Duration) {
+		seen <- rb.Locals()["late"]
+	})
+	r.MapGet("/slow", NoAuth, HandleError(func(rb *RequestBody) error {
+		defer close(finished)
+
+		<-rb.Context().Done()
+		time.Sleep(20 * time.Millisecond)
+
+		// Everything the handler touches after the timeout belongs to its
+		// own copy of the request body.
+		rb.Locals()["late"] = true
+		rb.SetHeader("X-Late", "1")
+		rb.Text(http.StatusOK, "too late")
+		rb.Abort(http.StatusConflict, "")
+		return rb.Context().Err()
+	}))
+
+	res := NewTestClient(r).Get("/api/slow")
+	if res.Status != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", res.Status)
+	}
+	if res.Header.Get("X-Late") != "" {
+		t.Error("a header set after the timeout reached the client")
+	}
+	if got := <-seen; got != nil {
+		t.Errorf("after-response hook saw %v, want nothing set after the timeout", got)
+	}
+
+	<-finished
+}
+
+func TestTimeoutKeepsStateOfFastHandler(t *testing.T) {
+	seen := make(chan error, 1)
+
+	r := NewRouter()
+	r.UseTimeout(time.Second)
+	r.AfterResponse(func(rb *RequestBody, status int, duration time.Duration) {
+		seen <- rb.Err()
+	})
+	r.MapGet("/fast", NoAuth, func(rb *RequestBody) {
+		rb.SetHeader("X-Fast", "1")
+		rb.Abort(http.StatusConflict, "taken")
+	})
+	r.MapGet("/unlimited", NoAuth, func(rb *RequestBody) {
+		time.Sleep(30 * time.Millisecond)
+		rb.Text(http.StatusOK, "done")
+	}, WithTimeout(-1))
+	r.MapGet("/short", NoAuth, func(rb *RequestBody) {
+		time.Sleep(30 * time.Millisecond)
+	}, WithTimeout(5*time.Millisecond))
+
+	c := NewTestClient(r)
+	res := c.Get("/api/fast")
+	if res.Status != http.StatusConflict || res.Header.Get("X-Fast") != "1" {
+		t.Errorf("status %d, X-Fast %q; want the handler's response", res.Status, res.Header.Get("X-Fast"))
+	}
+	if err := <-seen; err == nil {
+		t.Error("the error set by the handler was lost")
+	}
+
+	if res := c.Get("/api/unlimited"); res.Status != http.StatusOK || res.String() != "done" {
+		t.Errorf("WithTimeout(-1): status %d, body %q", res.Status, res.String())
+	}
+	<-seen
+
+	if res := c.Get("/api/short"); res.Status != http.StatusGatewayTimeout {
+		t.Errorf("WithTimeout: status = %d, want 504", res.Status)
+	}
+	<-seen
+}
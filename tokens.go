@@ -0,0 +1,416 @@
+package httpfly
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default lifetimes of issued tokens.
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// ErrTokenRevoked is returned for tokens whose session has been revoked.
+var ErrTokenRevoked = errors.New("token revoked")
+
+// ErrInvalidGrant is answered by the refresh route for refresh tokens that
+// are invalid, expired, reused or revoked.
+var ErrInvalidGrant = &HTTPError{Status: http.StatusUnauthorized, Code: "invalid_grant", Message: "invalid refresh token"}
+
+// Claims set by a TokenIssuer. "sid" identifies the session shared by the
+// tokens of a login and all tokens refreshed from them; "token_use" is
+// "access" or "refresh".
+const (
+	sessionClaim  = "sid"
+	tokenUseClaim = "token_use"
+)
+
+// RevocationList records revoked token and session ids until they expire.
+type RevocationList interface {
+	// Revoke adds id to the list until expires and reports whether it was
+	// added, i.e. was not on the list already. It must be atomic, since
+	// refresh token rotation relies on it.
+	Revoke(id string, expires time.Time) (bool, error)
+	// Revoked reports whether id is on the list.
+	Revoked(id string) (bool, error)
+}
+
+// TokenConfig configures a TokenIssuer. One of HMACSecret and
+// RSAPrivateKey must be set.
+type TokenConfig struct {
+	// HMACSecret signs tokens with HS256.
+	HMACSecret []byte
+	// RSAPrivateKey signs tokens with RS256.
+	RSAPrivateKey *rsa.PrivateKey
+	// Issuer and Audience, when set, become the "iss" and "aud" claims
+	// and are checked on verification.
+	Issuer   string
+	Audience string
+	// AccessTTL and RefreshTTL are the token lifetimes; they default to
+	// DefaultAccessTTL and DefaultRefreshTTL.
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// Revocations records used refresh tokens and revoked sessions. It
+	// defaults to an in-process list; use RedisRevocationList to share it
+	// between instances.
+	Revocations RevocationList
+}
+
+// TokenPair is an access token and the refresh token that renews it, in
+// the shape of an OAuth 2.0 token response.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn is the lifetime of the access token in seconds.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// TokenIssuer issues JSON Web Token pairs and verifies them. It is an
+// AuthProvider accepting its own access tokens, so it can be passed to
+// SetAuthProvider directly.
+//
+// Refresh tokens rotate: each one can be used once, and using it again,
+// e.g. after it was stolen, revokes the whole session.
+type TokenIssuer struct {
+	cfg      TokenConfig
+	verifier *JWTProvider
+}
+
+// NewTokenIssuer creates a token issuer. It panics if no signing key is
+// set.
+func NewTokenIssuer(cfg TokenConfig) *TokenIssuer {
+	if cfg.HMACSecret == nil && cfg.RSAPrivateKey == nil {
+		panic("httpfly: TokenConfig needs an HMACSecret or RSAPrivateKey")
+	}
+	if cfg.AccessTTL <= 0 {
+		cfg.AccessTTL = DefaultAccessTTL
+	}
+	if cfg.RefreshTTL <= 0 {
+		cfg.RefreshTTL = DefaultRefreshTTL
+	}
+	if cfg.Revocations == nil {
+		cfg.Revocations = NewMemoryRevocationList()
+	}
+
+	jwt := JWTConfig{Issuer: cfg.Issuer, Audience: cfg.Audience}
+	if cfg.RSAPrivateKey != nil {
+		jwt.RSAPublicKey = &cfg.RSAPrivateKey.PublicKey
+	} else {
+		jwt.HMACSecret = cfg.HMACSecret
+	}
+
+	return &TokenIssuer{cfg: cfg, verifier: NewJWTProvider(jwt)}
+}
+
+// IssueTokens starts a session for claims, typically after checking a
+// password, and returns its first token pair. Both tokens carry claims;
+// the registered claims "iss", "aud", "iat", "exp", "jti", "sid" and
+// "token_use" are set by the issuer.
+func (i *TokenIssuer) IssueTokens(claims Claims) (*TokenPair, error) {
+	return i.issue(claims, randomHex(16))
+}
+
+// Refresh exchanges a refresh token for a new pair in the same session. The
+// refresh token is used up; presenting it again revokes the session.
+func (i *TokenIssuer) Refresh(refreshToken string) (*TokenPair, error) {
+	claims, err := i.verify(refreshToken, "refresh")
+	if err != nil {
+		return nil, err
+	}
+
+	exp, _ := claims.Time("exp")
+	added, err := i.cfg.Revocations.Revoke(claims.String("jti"), exp)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		if err := i.RevokeSession(claims.String(sessionClaim)); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: refresh token reused", ErrTokenRevoked)
+	}
+
+	return i.issue(claims, claims.String(sessionClaim))
+}
+
+// Revoke revokes the session of a refresh token, e.g. on logout. Access
+// tokens of the session are rejected by the issuer from then on.
+func (i *TokenIssuer) Revoke(refreshToken string) error {
+	claims, err := i.verify(refreshToken, "refresh")
+	if err != nil {
+		return err
+	}
+	return i.RevokeSession(claims.String(sessionClaim))
+}
+
+// RevokeSession revokes the session with the given "sid" claim.
+func (i *TokenIssuer) RevokeSession(sid string) error {
+	_, err := i.cfg.Revocations.Revoke(sessionClaim+":"+sid, time.Now().Add(i.cfg.RefreshTTL))
+	return err
+}
+
+// Authenticate implements AuthProvider.
+func (i *TokenIssuer) Authenticate(req *http.Request) (map[string]string, error) {
+	claims, err := i.AuthenticateClaims(req)
+	if err != nil {
+		return nil, err
+	}
+	return claims.Strings(), nil
+}
+
+// AuthenticateClaims implements ClaimsProvider. It accepts bearer access
+// tokens of the issuer whose session has not been revoked.
+func (i *TokenIssuer) AuthenticateClaims(req *http.Request) (Claims, error) {
+	token, ok := bearerToken(req)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return i.verify(token, "access")
+}
+
+// verify checks a token of the issuer, its use and its session.
+func (i *TokenIssuer) verify(token, use string) (Claims, error) {
+	claims, err := i.verifier.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.String(tokenUseClaim) != use {
+		return nil, fmt.Errorf("%w: %s token expected", ErrInvalidToken, use)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := i.cfg.Revocations.Revoked(sessionClaim + ":" + claims.String(sessionClaim))
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// issue signs a token pair for claims in session sid.
+func (i *TokenIssuer) issue(claims Claims, sid string) (*TokenPair, error) {
+	now := time.Now()
+
+	access, err := i.sign(claims, sid, "access", now, i.cfg.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := i.sign(claims, sid, "refresh", now, i.cfg.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(i.cfg.AccessTTL / time.Second),
+	}, nil
+}
+
+// sign encodes and signs a token with claims and the registered claims of
+// the issuer.
+func (i *TokenIssuer) sign(claims Claims, sid, use string, now time.Time, ttl time.Duration) (string, error) {
+	payload := make(map[string]any, len(claims)+8)
+	for k, v := range claims {
+		payload[k] = v
+	}
+	delete(payload, "nbf")
+	if i.cfg.Issuer != "" {
+		payload["iss"] = i.cfg.Issuer
+	}
+	if i.cfg.Audience != "" {
+		payload["aud"] = i.cfg.Audience
+	}
+	payload["iat"] = now.Unix()
+	payload["exp"] = now.Add(ttl).Unix()
+	payload["jti"] = randomHex(16)
+	payload[sessionClaim] = sid
+	payload[tokenUseClaim] = use
+
+	alg := "HS256"
+	if i.cfg.RSAPrivateKey != nil {
+		alg = "RS256"
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+
+	var sig []byte
+	if i.cfg.RSAPrivateKey != nil {
+		h := crypto.SHA256.New()
+		h.Write([]byte(signed))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, i.cfg.RSAPrivateKey, crypto.SHA256, h.Sum(nil)); err != nil {
+			return "", err
+		}
+	} else {
+		mac := hmac.New(crypto.SHA256.New, i.cfg.HMACSecret)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// MapTokenRefresh maps the refresh route of i on the default router. See
+// Router.MapTokenRefresh.
+func MapTokenRefresh(path string, i *TokenIssuer, opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapTokenRefresh(path, i, opts...)
+}
+
+// MapTokenRefresh maps a POST route, e.g. "/auth/refresh", that exchanges
+// a refresh token for a new token pair. The token is read from a JSON body
+// {"refresh_token": "..."} or an OAuth 2.0 form body with
+// grant_type=refresh_token; the pair is answered as JSON. Invalid, reused
+// and revoked tokens are answered with ErrInvalidGrant.
+func (r *Router) MapTokenRefresh(path string, i *TokenIssuer, opts ...RouteOption) *RouteInfo {
+	return r.MapPost(path, NoAuth, func(rb *RequestBody) {
+		var req struct {
+			GrantType    string `json:"grant_type"`
+			RefreshToken string `json:"refresh_token"`
+		}
+
+		mt, _, _ := mime.ParseMediaType(rb.req.Header.Get("Content-Type"))
+		if mt == "application/x-www-form-urlencoded" {
+			form, err := url.ParseQuery(string(rb.JsonData))
+			if err != nil {
+				rb.Fail(err)
+				return
+			}
+			req.GrantType, req.RefreshToken = form.Get("grant_type"), form.Get("refresh_token")
+			if req.GrantType != "refresh_token" {
+				rb.Fail(&HTTPError{Status: http.StatusBadRequest, Code: "unsupported_grant_type", Message: "grant_type must be refresh_token"})
+				return
+			}
+		} else if err := json.Unmarshal(rb.JsonData, &req); err != nil {
+			rb.Fail(err)
+			return
+		}
+
+		pair, err := i.Refresh(req.RefreshToken)
+		if err != nil {
+			if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenRevoked) {
+				err = ErrInvalidGrant
+			}
+			rb.Fail(err)
+			return
+		}
+
+		rb.ResponseW.Header().Set("Cache-Control", "no-store")
+		rb.JSON(http.StatusOK, pair)
+	}, opts...)
+}
+
+// MemoryRevocationList is an in-process RevocationList.
+type MemoryRevocationList struct {
+	mu      sync.Mutex
+	ids     map[string]time.Time
+	created int
+}
+
+// NewMemoryRevocationList creates an empty in-process list.
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{ids: map[string]time.Time{}}
+}
+
+// Revoke implements RevocationList. Expired ids are swept every 1024
+// revocations.
+func (l *MemoryRevocationList) Revoke(id string, expires time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if exp, ok := l.ids[id]; ok && now.Before(exp) {
+		return false, nil
+	}
+
+	l.created++
+	if l.created%1024 == 0 {
+		for k, exp := range l.ids {
+			if now.After(exp) {
+				delete(l.ids, k)
+			}
+		}
+	}
+
+	l.ids[id] = expires
+	return true, nil
+}
+
+// Revoked implements RevocationList.
+func (l *MemoryRevocationList) Revoked(id string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	exp, ok := l.ids[id]
+	return ok && time.Now().Before(exp), nil
+}
+
+// RedisRevocationList keeps the revocation list in Redis, so it is shared
+// between instances.
+type RedisRevocationList struct {
+	RedisConfig
+	// Prefix is prepended to ids to form keys; default "revoked:".
+	Prefix string
+
+	pool redisPool
+}
+
+// NewRedisRevocationList creates a list for the Redis server at addr.
+func NewRedisRevocationList(addr string) *RedisRevocationList {
+	return &RedisRevocationList{RedisConfig: RedisConfig{Addr: addr}}
+}
+
+// Revoke implements RevocationList.
+func (l *RedisRevocationList) Revoke(id string, expires time.Time) (bool, error) {
+	ttl := time.Until(expires).Milliseconds()
+	if ttl <= 0 {
+		ttl = 1
+	}
+
+	reply, err := l.pool.do(l.RedisConfig, "SET", l.key(id), "1", "NX", "PX", strconv.FormatInt(ttl, 10))
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// Revoked implements RevocationList.
+func (l *RedisRevocationList) Revoked(id string) (bool, error) {
+	reply, err := l.pool.do(l.RedisConfig, "EXISTS", l.key(id))
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n > 0, nil
+}
+
+func (l *RedisRevocationList) key(id string) string {
+	if l.Prefix == "" {
+		return "revoked:" + id
+	}
+	return l.Prefix + id
+}
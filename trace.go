@@ -0,0 +1,192 @@
+package httpfly
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// Span is the server span of a request. Spans are created by the tracing
+// middleware and handed to the exporter when the request ends.
+type Span struct {
+	Name     string
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID // zero for root spans
+	Sampled  bool
+	Start    time.Time
+	End      time.Time
+	Status   int
+	Err      error
+
+	mu         sync.Mutex
+	attributes map[string]string
+}
+
+// SetAttribute records a key/value attribute on the span.
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attributes == nil {
+		s.attributes = map[string]string{}
+	}
+	s.attributes[key] = value
+}
+
+// Attributes returns a copy of the span attributes.
+func (s *Span) Attributes() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]string, len(s.attributes))
+	for k, v := range s.attributes {
+		out[k] = v
+	}
+	return out
+}
+
+// RecordError marks the span as failed.
+func (s *Span) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Err = err
+}
+
+// Traceparent returns the W3C traceparent header value for the span.
+func (s *Span) Traceparent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID.String() + "-" + s.SpanID.String() + "-" + flags
+}
+
+// SpanExporter receives finished spans, e.g. to forward them to an
+// OpenTelemetry collector.
+type SpanExporter interface {
+	ExportSpan(s *Span)
+}
+
+// SpanExporterFunc adapts a function to SpanExporter.
+type SpanExporterFunc func(s *Span)
+
+// ExportSpan implements SpanExporter.
+func (f SpanExporterFunc) ExportSpan(s *Span) { f(s) }
+
+type spanKey struct{}
+
+// SpanFromContext returns the request span stored in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// InjectTraceContext sets the traceparent header of an outgoing request
+// so that the callee joins the trace of ctx.
+func InjectTraceContext(ctx context.Context, h http.Header) {
+	if s := SpanFromContext(ctx); s != nil {
+		h.Set("traceparent", s.Traceparent())
+	}
+}
+
+// UseTracing traces the requests of the default router. See Tracing.
+func UseTracing(exporter SpanExporter) {
+	defaultRouter.UseTracing(exporter)
+}
+
+// UseTracing traces the requests of the router.
+func (r *Router) UseTracing(exporter SpanExporter) {
+	r.Use(Tracing(exporter))
+}
+
+// Tracing returns chain middleware that starts a span per request, named by
+// method and route pattern. An incoming W3C traceparent header makes the
+// span a child of the caller's span. The span is available through
+// SpanFromContext(r.Context()), records the response status and any error
+// passed to the error handler or panic, and is exported when the request
+// ends.
+func Tracing(exporter SpanExporter) ChainMiddleware {
+	return func(rb *RequestBody, next Handler) {
+		span := &Span{Name: rb.req.Method + " " + rb.routePattern(), Start: time.Now(), Sampled: true}
+
+		if traceID, parentID, sampled, ok := parseTraceparent(rb.Header("traceparent")); ok {
+			span.TraceID, span.ParentID, span.Sampled = traceID, parentID, sampled
+		} else {
+			rand.Read(span.TraceID[:])
+		}
+		rand.Read(span.SpanID[:])
+
+		span.SetAttribute("http.method", rb.req.Method)
+		span.SetAttribute("http.route", rb.routePattern())
+		span.SetAttribute("http.target", rb.req.URL.RequestURI())
+
+		rb.WithValue(spanKey{}, span)
+
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				span.RecordError(fmt.Errorf("panic: %v", rec))
+				span.Status = http.StatusInternalServerError
+			} else if rb.w != nil {
+				span.Status = rb.w.status
+				if span.Status == 0 {
+					span.Status = http.StatusOK
+				}
+			}
+
+			span.End = time.Now()
+			exporter.ExportSpan(span)
+
+			if rec != nil {
+				panic(rec)
+			}
+		}()
+
+		next(rb)
+	}
+}
+
+// parseTraceparent parses a version 00 W3C traceparent header.
+func parseTraceparent(h string) (traceID TraceID, parentID SpanID, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || parts[0] == "ff" || len(parts[0]) != 2 {
+		return
+	}
+
+	if parts[0] == "00" && len(parts) != 4 {
+		return
+	}
+
+	tid, err1 := hex.DecodeString(parts[1])
+	pid, err2 := hex.DecodeString(parts[2])
+	flags, err3 := hex.DecodeString(parts[3])
+
+	if err1 != nil || err2 != nil || err3 != nil || len(tid) != 16 || len(pid) != 8 || len(flags) != 1 {
+		return
+	}
+
+	copy(traceID[:], tid)
+	copy(parentID[:], pid)
+
+	if traceID == (TraceID{}) || parentID == (SpanID{}) {
+		return
+	}
+
+	return traceID, parentID, flags[0]&1 == 1, true
+}
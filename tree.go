@@ -0,0 +1,168 @@
+package httpfly
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// node is a node of the route trie. Each level corresponds to one path
+// segment; routes are stored on the node reached by their last segment.
+type node struct {
+	static   map[string]*node
+	param    *node
+	catchAll *node
+	routes   []*RouteInfo
+}
+
+// add inserts ri below n following its pattern segments. It modifies n, so
+// it is only used while building a trie that is not yet shared.
+func (n *node) add(ri *RouteInfo) {
+	checkSegments(ri)
+	cur := n
+
+	for _, seg := range ri.segments {
+		if isCatchAllSegment(seg) {
+			if cur.catchAll == nil {
+				cur.catchAll = &node{}
+			}
+			cur = cur.catchAll
+			continue
+		}
+
+		if isParamSegment(seg) {
+			if cur.param == nil {
+				cur.param = &node{}
+			}
+			cur = cur.param
+			continue
+		}
+
+		if cur.static == nil {
+			cur.static = map[string]*node{}
+		}
+
+		child, ok := cur.static[seg]
+		if !ok {
+			child = &node{}
+			cur.static[seg] = child
+		}
+		cur = child
+	}
+
+	cur.routes = append(cur.routes, ri)
+}
+
+// with returns a copy of n with ri inserted below it, sharing every subtree
+// off the path of ri, so a trie that is being read concurrently can grow
+// without being rebuilt. segments are the pattern segments of ri left to
+// follow; n may be nil.
+func (n *node) with(ri *RouteInfo, segments []string) *node {
+	c := &node{}
+	if n != nil {
+		*c = *n
+	}
+
+	if len(segments) == 0 {
+		i := sort.Search(len(c.routes), func(i int) bool {
+			return routeLess(ri, c.routes[i])
+		})
+		c.routes = slices.Insert(slices.Clip(c.routes), i, ri)
+		return c
+	}
+
+	switch seg := segments[0]; {
+	case isCatchAllSegment(seg):
+		c.catchAll = c.catchAll.with(ri, segments[1:])
+	case isParamSegment(seg):
+		c.param = c.param.with(ri, segments[1:])
+	default:
+		c.static = maps.Clone(c.static)
+		if c.static == nil {
+			c.static = map[string]*node{}
+		}
+		c.static[seg] = c.static[seg].with(ri, segments[1:])
+	}
+
+	return c
+}
+
+// checkSegments panics if the pattern of ri cannot be added to a trie.
+func checkSegments(ri *RouteInfo) {
+	checkParamTypes(ri)
+
+	for i, seg := range ri.segments {
+		if isCatchAllSegment(seg) && i != len(ri.segments)-1 {
+			panic(fmt.Sprintf("httpfly: catch-all %s must be the last segment of %s", seg, ri.Endpoint))
+		}
+	}
+}
+
+// lookup walks the decoded path segments, preferring static children over
+// parameters and parameters over a catch-all, and backtracking when a branch
+// yields no route. pick chooses a route among those registered on a matching
+// node. With fold, static segments also match case-insensitively.
+func (n *node) lookup(segments []string, fold bool, pick func([]*RouteInfo) *RouteInfo) *RouteInfo {
+	if len(segments) == 0 {
+		return pick(n.routes)
+	}
+
+	if child, ok := n.static[segments[0]]; ok {
+		if ri := child.lookup(segments[1:], fold, pick); ri != nil {
+			return ri
+		}
+	}
+
+	// Segments differing only in case are tried in sorted order, so the
+	// same request always matches the same route.
+	if fold {
+		var keys []string
+		for key := range n.static {
+			if key != segments[0] && strings.EqualFold(key, segments[0]) {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			if ri := n.static[key].lookup(segments[1:], fold, pick); ri != nil {
+				return ri
+			}
+		}
+	}
+
+	if n.param != nil && segments[0] != "" {
+		if ri := n.param.lookup(segments[1:], fold, pick); ri != nil {
+			return ri
+		}
+	}
+
+	if n.catchAll != nil {
+		return pick(n.catchAll.routes)
+	}
+
+	return nil
+}
+
+// walk calls fn with the routes of every node matching the decoded path
+// segments, static and parameter branches alike.
+func (n *node) walk(segments []string, fn func([]*RouteInfo)) {
+	if len(segments) == 0 {
+		fn(n.routes)
+		return
+	}
+
+	if child, ok := n.static[segments[0]]; ok {
+		child.walk(segments[1:], fn)
+	}
+
+	if n.param != nil && segments[0] != "" {
+		n.param.walk(segments[1:], fn)
+	}
+
+	if n.catchAll != nil {
+		fn(n.catchAll.routes)
+	}
+}
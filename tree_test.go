@@ -0,0 +1,131 @@
+package httpfly
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// benchRoutes returns n GET routes of various shapes.
+func benchRoutes(n int) []string {
+	paths := make([]string, 0, n)
+	for i := 0; len(paths) < n; i++ {
+		paths = append(paths,
+			fmt.Sprintf("/svc%d/items", i),
+			fmt.Sprintf("/svc%d/items/{id}", i),
+			fmt.Sprintf("/svc%d/items/{id}/parts/{part}", i),
+			fmt.Sprintf("/svc%d/files/*rest", i),
+		)
+	}
+	return paths[:n]
+}
+
+func newBenchRouter(paths []string) *Router {
+	r := NewRouter()
+	for _, p := range paths {
+		r.MapGet(p, NoAuth, func(rb *RequestBody) {})
+	}
+	return r
+}
+
+func TestIncrementalTrieMatchesRebuilt(t *testing.T) {
+	paths := benchRoutes(200)
+	rand.New(rand.NewSource(1)).Shuffle(len(paths), func(i, j int) { paths[i], paths[j] = paths[j], paths[i] })
+
+	grown := newBenchRouter(paths).currentRoutes()
+	rebuilt := newRouteTable(grown.routes)
+
+	for _, path := range []string{"/api/svc7/items", "/api/svc7/items/42", "/api/svc7/items/42/parts/x", "/api/svc7/files/a/b", "/api/svc7/nope"} {
+		got, _ := grown.match("GET", path, "", "")
+		want, _ := rebuilt.match("GET", path, "", "")
+		if got != want {
+			t.Errorf("%s: incremental trie matched %v, rebuilt %v", path, got, want)
+		}
+	}
+}
+
+func TestWithRouteLeavesTableUnchanged(t *testing.T) {
+	r := NewRouter()
+	r.MapGet("/a/{id}", NoAuth, func(rb *RequestBody) {})
+	before := r.currentRoutes()
+
+	r.MapGet("/a/{id}/b", NoAuth, func(rb *RequestBody) {})
+
+	if v, _ := before.match("GET", "/api/a/1/b", "", ""); v != nil {
+		t.Error("adding a route changed the previous table")
+	}
+	if v, _ := r.currentRoutes().match("GET", "/api/a/1/b", "", ""); v == nil {
+		t.Error("added route does not match")
+	}
+}
+
+func TestCaseInsensitiveMatchIsDeterministic(t *testing.T) {
+	r := NewRouter()
+	r.CaseInsensitivePaths = true
+	for _, p := range []string{"/Users/me", "/USERS/me", "/uSERS/me", "/UsErS/me"} {
+		r.MapGet(p, NoAuth, func(rb *RequestBody) {})
+	}
+
+	first, _ := r.currentRoutes().matchFold("GET", "/api/users/me", "", "")
+	if first == nil || first.Endpoint != "/api/USERS/me" {
+		t.Fatalf("matched %v, want the sorted first /api/USERS/me", first)
+	}
+	for range 100 {
+		if v, _ := r.currentRoutes().matchFold("GET", "/api/users/me", "", ""); v != first {
+			t.Fatalf("matched %s, then %s", first.Endpoint, v.Endpoint)
+		}
+	}
+}
+
+// linearMatch is the route scan the trie replaced, kept as a benchmark
+// baseline.
+func linearMatch(routes []*RouteInfo, path string) *RouteInfo {
+	segments, _ := splitPath(path)
+
+next:
+	for _, ri := range routes {
+		for i, seg := range ri.segments {
+			switch {
+			case isCatchAllSegment(seg):
+				return ri
+			case i >= len(segments):
+				continue next
+			case isParamSegment(seg):
+				if segments[i] == "" {
+					continue next
+				}
+			case seg != segments[i]:
+				continue next
+			}
+		}
+		if len(ri.segments) == len(segments) {
+			return ri
+		}
+	}
+	return nil
+}
+
+func BenchmarkMatch(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		table := newBenchRouter(benchRoutes(n)).currentRoutes()
+		path := fmt.Sprintf("/api/svc%d/items/42/parts/x", (n-1)/4)
+
+		b.Run(fmt.Sprintf("trie/%d", n), func(b *testing.B) {
+			for range b.N {
+				table.match("GET", path, "", "")
+			}
+		})
+		b.Run(fmt.Sprintf("linear/%d", n), func(b *testing.B) {
+			for range b.N {
+				linearMatch(table.routes, path)
+			}
+		})
+	}
+}
+
+func BenchmarkMapRoute(b *testing.B) {
+	paths := benchRoutes(1000)
+	for range b.N {
+		newBenchRouter(paths)
+	}
+}
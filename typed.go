@@ -0,0 +1,60 @@
+package httpfly
+
+import (
+	"errors"
+	"net/http"
+)
+
+// MapPostTyped maps a POST route on the default router whose handler
+// receives the decoded and validated request body. See MapTyped.
+//
+//	httpfly.MapPostTyped("/users", httpfly.NoAuth, func(r *httpfly.RequestBody, u CreateUser) error {
+//		return r.JSON(http.StatusCreated, store.Create(u))
+//	})
+func MapPostTyped[T any](path string, auth AuthRequire, f func(r *RequestBody, body T) error, opts ...RouteOption) *RouteInfo {
+	return MapTyped(defaultRouter, MethodPost, path, auth, f, opts...)
+}
+
+// MapTyped maps a route on r whose handler receives the request body
+// decoded into T with Bind and validated. A body without a Content-Type is
+// decoded as JSON. Malformed bodies are answered with 400, unsupported
+// media types with 415 and validation failures with 422; errors returned by
+// f go to the error handler. T becomes the request schema in the OpenAPI
+// document unless WithDoc sets one.
+func MapTyped[T any](r *Router, method RequestMethod, path string, auth AuthRequire, f func(r *RequestBody, body T) error, opts ...RouteOption) *RouteInfo {
+	opts = append(opts, func(ri *RouteInfo) {
+		if ri.Meta.Doc.Request == nil {
+			ri.Meta.Doc.Request = new(T)
+		}
+	})
+
+	return r.Map(method, path, auth, HandleError(func(rb *RequestBody) error {
+		var body T
+		if err := bindTyped(rb, &body); err != nil {
+			return err
+		}
+		return f(rb, body)
+	}), opts...)
+}
+
+// bindTyped decodes the request body into v and maps decoding failures to
+// HTTP errors.
+func bindTyped(rb *RequestBody, v any) error {
+	var err error
+	if rb.Header("Content-Type") == "" {
+		err = rb.BindJSON(v)
+	} else {
+		err = rb.Bind(v)
+	}
+
+	var verrs ValidationErrors
+	var httpErr *HTTPError
+
+	switch {
+	case err == nil, errors.As(err, &verrs), errors.As(err, &httpErr):
+		return err
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return &HTTPError{Status: http.StatusUnsupportedMediaType, Code: "unsupported_media_type", Message: err.Error()}
+	}
+	return &HTTPError{Status: http.StatusBadRequest, Code: "invalid_body", Message: err.Error()}
+}
@@ -0,0 +1,57 @@
+package httpfly
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// StartHTTPServerUnix runs the startup hooks and serves the default router
+// on a Unix domain socket, e.g. behind a reverse proxy on the same host.
+// See Router.StartUnix.
+func StartHTTPServerUnix(socketPath string, perm os.FileMode) error {
+	return defaultRouter.StartUnix(socketPath, perm)
+}
+
+// StartUnix serves the router on a Unix domain socket at socketPath with the
+// file mode perm. A stale socket file left by a previous process is
+// removed; a socket that still accepts connections is an error. The socket
+// file is removed when the server stops.
+func (r *Router) StartUnix(socketPath string, perm os.FileMode) error {
+	ln, err := listenUnix(socketPath, perm)
+	if err != nil {
+		r.serverError(err)
+		return err
+	}
+	return r.Serve(ln)
+}
+
+// listenUnix listens on socketPath after removing a stale socket file.
+func listenUnix(socketPath string, perm os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(socketPath); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("httpfly: %s exists and is not a socket", socketPath)
+		}
+
+		if c, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("httpfly: socket %s is in use", socketPath)
+		}
+
+		if err := os.Remove(socketPath); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(socketPath, perm); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
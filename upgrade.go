@@ -0,0 +1,98 @@
+package httpfly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// upgradeFDEnv names the inherited listener of a process started by
+// Server.Upgrade.
+const upgradeFDEnv = "HTTPFLY_LISTENER_FD"
+
+// Upgrade restarts the program without dropping connections: it starts the
+// current executable again with the same arguments, hands it the listening
+// socket, and then shuts the server down gracefully, returning once open
+// requests have finished or ctx expires. The new process picks the socket
+// up in Server.Start. Wire it to a signal for zero-downtime deploys of a
+// replaced binary:
+//
+//	hup := make(chan os.Signal, 1)
+//	signal.Notify(hup, syscall.SIGHUP)
+//	go func() {
+//		<-hup
+//		if err := srv.Upgrade(context.Background()); err != nil {
+//			log.Print(err)
+//		}
+//	}()
+//
+// It is not supported on Windows.
+func (s *Server) Upgrade(ctx context.Context) error {
+	s.mu.Lock()
+	ln := s.ln
+	s.mu.Unlock()
+
+	if ln == nil {
+		return errors.New("httpfly: upgrade of a server that is not running")
+	}
+
+	filer, ok := ln.(interface{ File() (*os.File, error) })
+	if !ok {
+		return fmt.Errorf("httpfly: cannot pass a %T to another process", ln)
+	}
+
+	f, err := filer.File()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), upgradeFDEnv+"=3")
+	cmd.ExtraFiles = []*os.File{f}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("httpfly: upgrade: %w", err)
+	}
+
+	// The new process serves the same socket file from now on.
+	if ul, ok := ln.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+
+	return s.Shutdown(ctx)
+}
+
+// inheritedListener returns the listener passed by the parent process in an
+// upgrade, or nil.
+func inheritedListener() (net.Listener, error) {
+	v, ok := os.LookupEnv(upgradeFDEnv)
+	if !ok {
+		return nil, nil
+	}
+	os.Unsetenv(upgradeFDEnv)
+
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("httpfly: invalid %s %q", upgradeFDEnv, v)
+	}
+
+	f := os.NewFile(uintptr(fd), "inherited listener")
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("httpfly: inherited listener: %w", err)
+	}
+	return ln, nil
+}
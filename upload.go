@@ -0,0 +1,168 @@
+package httpfly
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+)
+
+// DefaultMaxUploadMemory is the default in-memory part of multipart bodies.
+const DefaultMaxUploadMemory = 32 << 20
+
+var (
+	// ErrMissingFile is returned by File when the form has no such file.
+	ErrMissingFile = &HTTPError{Status: http.StatusBadRequest, Code: "missing_file", Message: "missing file"}
+	// ErrFileTooLarge is returned by File and Files when a file exceeds
+	// MaxFileSize.
+	ErrFileTooLarge = &HTTPError{Status: http.StatusRequestEntityTooLarge, Code: "file_too_large", Message: "uploaded file too large"}
+	// ErrNotMultipart is returned when the request is not multipart/form-data.
+	ErrNotMultipart = &HTTPError{Status: http.StatusUnsupportedMediaType, Code: "not_multipart", Message: "request is not multipart/form-data"}
+)
+
+// SetUploadLimits sets MaxUploadMemory and MaxFileSize of the default router.
+func SetUploadLimits(maxMemory, maxFileSize int64) {
+	defaultRouter.MaxUploadMemory = maxMemory
+	defaultRouter.MaxFileSize = maxFileSize
+}
+
+// UploadedFile is a file of a multipart/form-data request. Its temporary
+// storage is removed when the request ends.
+type UploadedFile struct {
+	// Filename is the name given by the client. Do not use it as a path
+	// without sanitizing it.
+	Filename string
+	Size     int64
+	Header   textproto.MIMEHeader
+	// ContentType is sniffed from the file contents, not taken from the
+	// client.
+	ContentType string
+
+	fh *multipart.FileHeader
+}
+
+// Open opens the file for reading.
+func (f *UploadedFile) Open() (multipart.File, error) {
+	return f.fh.Open()
+}
+
+// Save streams the file to path, creating or truncating it.
+func (f *UploadedFile) Save(path string) error {
+	src, err := f.fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// File returns the first file uploaded in field. Errors are *HTTPError
+// values, so they can be passed to Fail or returned from HandleError
+// handlers as they are.
+func (r *RequestBody) File(field string) (*UploadedFile, error) {
+	files, err := r.Files(field)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrMissingFile
+	}
+	return files[0], nil
+}
+
+// Files returns all files uploaded in field.
+func (r *RequestBody) Files(field string) ([]*UploadedFile, error) {
+	form, err := r.multipartForm()
+	if err != nil {
+		return nil, err
+	}
+
+	var maxSize int64
+	if r.router != nil {
+		maxSize = r.router.MaxFileSize
+	}
+
+	var files []*UploadedFile
+	for _, fh := range form.File[field] {
+		if maxSize > 0 && fh.Size > maxSize {
+			return nil, ErrFileTooLarge
+		}
+
+		f, err := newUploadedFile(fh)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+func newUploadedFile(fh *multipart.FileHeader) (*UploadedFile, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+
+	return &UploadedFile{
+		Filename:    fh.Filename,
+		Size:        fh.Size,
+		Header:      fh.Header,
+		ContentType: http.DetectContentType(head[:n]),
+		fh:          fh,
+	}, nil
+}
+
+// multipartForm parses the multipart body once per request. Temporary files
+// are removed when the request ends.
+func (r *RequestBody) multipartForm() (*multipart.Form, error) {
+	if r.multipart != nil {
+		return r.multipart, nil
+	}
+	if r.req == nil {
+		return nil, ErrNotMultipart
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(r.req.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return nil, ErrNotMultipart
+	}
+
+	maxMemory := int64(DefaultMaxUploadMemory)
+	if r.router != nil && r.router.MaxUploadMemory > 0 {
+		maxMemory = r.router.MaxUploadMemory
+	}
+
+	form, err := multipart.NewReader(bytes.NewReader(r.JsonData), params["boundary"]).ReadForm(maxMemory)
+	if errors.Is(err, multipart.ErrMessageTooLarge) {
+		return nil, ErrFileTooLarge
+	}
+	if err != nil {
+		return nil, &HTTPError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	r.multipart = form
+	r.onDone(func() { form.RemoveAll() })
+	return form, nil
+}
@@ -0,0 +1,218 @@
+package httpfly
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldError describes a field that failed a validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is returned by Validate and the Bind functions when
+// decoded values break their `validate` struct tags.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// validationErrorBody is the JSON body of a 422 response.
+type validationErrorBody struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
+}
+
+// ValidatorFunc reports whether value satisfies a rule. param is the text
+// after '=' in the tag, e.g. "3" for "min=3".
+type ValidatorFunc func(value any, param string) bool
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = map[string]ValidatorFunc{}
+)
+
+// RegisterValidator adds a custom rule usable in `validate` tags. Built-in
+// rules are required, email, min, max, len and oneof.
+func RegisterValidator(name string, fn ValidatorFunc) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+
+	validators[name] = fn
+}
+
+// Validate checks v, a struct or a slice of structs, against the rules in
+// its `validate` tags, e.g. `validate:"required,email"`. Nested structs are
+// checked too. It returns ValidationErrors or nil.
+func Validate(v any) error {
+	var errs ValidationErrors
+	validateValue(reflect.ValueOf(v), "", &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateValue(v reflect.Value, path string, errs *ValidationErrors) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			name := fieldName(f)
+			if path != "" {
+				name = path + "." + name
+			}
+
+			if tag := f.Tag.Get("validate"); tag != "" && tag != "-" {
+				validateField(v.Field(i), name, tag, errs)
+			}
+			validateValue(v.Field(i), name, errs)
+		}
+	}
+}
+
+// fieldName returns the JSON name of a struct field.
+func fieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
+
+func validateField(v reflect.Value, name, tag string, errs *ValidationErrors) {
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if rule == "" {
+			continue
+		}
+
+		if rule != "required" && isZero(v) {
+			continue
+		}
+
+		if ok, msg := checkRule(v, rule, param); !ok {
+			*errs = append(*errs, FieldError{Field: name, Rule: rule, Message: msg})
+		}
+	}
+}
+
+// isZero reports whether v is the zero value or a nil pointer.
+func isZero(v reflect.Value) bool {
+	return !v.IsValid() || v.IsZero()
+}
+
+func checkRule(v reflect.Value, rule, param string) (bool, string) {
+	switch rule {
+	case "required":
+		return !isZero(v), "is required"
+
+	case "email":
+		s := fmt.Sprint(v.Interface())
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s, "must be a valid email address"
+
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false, fmt.Sprintf("has an invalid %s rule", rule)
+		}
+
+		size, ok := measure(v)
+		if !ok {
+			return false, fmt.Sprintf("cannot be checked with %s", rule)
+		}
+
+		switch rule {
+		case "min":
+			return size >= n, "must be at least " + param
+		case "max":
+			return size <= n, "must be at most " + param
+		}
+		return size == n, "must have length " + param
+
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, opt := range strings.Fields(param) {
+			if s == opt {
+				return true, ""
+			}
+		}
+		return false, "must be one of " + strings.Join(strings.Fields(param), ", ")
+	}
+
+	validatorsMu.RLock()
+	fn, ok := validators[rule]
+	validatorsMu.RUnlock()
+
+	if !ok {
+		return false, fmt.Sprintf("has unknown rule %q", rule)
+	}
+	return fn(v.Interface(), param), "failed " + rule
+}
+
+// measure returns the length of strings and collections, or the value of
+// numbers.
+func measure(v reflect.Value) (float64, bool) {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// Fail answers the request for err through the router's error handler.
+// Errors other than *HTTPError and ValidationErrors, typically decoding
+// errors from Bind, are treated as 400 Bad Request.
+func (r *RequestBody) Fail(err error) {
+	var httpErr *HTTPError
+	var verrs ValidationErrors
+
+	if !errors.As(err, &httpErr) && !errors.As(err, &verrs) && !errors.Is(err, ErrHandled) {
+		err = &HTTPError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	r.router.handleError(r, err)
+}
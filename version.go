@@ -0,0 +1,211 @@
+package httpfly
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MapGetVersioned maps a GET route that only serves requests negotiating the
+// given API version. Requests without a version, or with a version that has
+// no handler, fall back to the unversioned route registered by MapGet.
+func MapGetVersioned(path string, version string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapGetVersioned(path, version, auth, f, opts...)
+}
+
+// MapGetVersioned maps a versioned GET route on the router.
+func (r *Router) MapGetVersioned(path string, version string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodGet, path, auth, f, append(opts, withVersion(version)))
+}
+
+// MapPostVersioned maps a versioned POST route. See MapGetVersioned.
+func MapPostVersioned(path string, version string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapPostVersioned(path, version, auth, f, opts...)
+}
+
+// MapPostVersioned maps a versioned POST route on the router.
+func (r *Router) MapPostVersioned(path string, version string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodPost, path, auth, f, append(opts, withVersion(version)))
+}
+
+// MapPutVersioned maps a versioned PUT route. See MapGetVersioned.
+func MapPutVersioned(path string, version string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapPutVersioned(path, version, auth, f, opts...)
+}
+
+// MapPutVersioned maps a versioned PUT route on the router.
+func (r *Router) MapPutVersioned(path string, version string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodPut, path, auth, f, append(opts, withVersion(version)))
+}
+
+// MapDeleteVersioned maps a versioned DELETE route. See MapGetVersioned.
+func MapDeleteVersioned(path string, version string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapDeleteVersioned(path, version, auth, f, opts...)
+}
+
+// MapDeleteVersioned maps a versioned DELETE route on the router.
+func (r *Router) MapDeleteVersioned(path string, version string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodDelete, path, auth, f, append(opts, withVersion(version)))
+}
+
+func withVersion(version string) RouteOption {
+	return func(ri *RouteInfo) {
+		ri.Version = normalizeVersion(version)
+	}
+}
+
+var vendorVersion = regexp.MustCompile(`^application/vnd\.[^.;+]+\.(v?\d+[\w.]*)(\+\w+)?$`)
+
+// requestVersion returns the API version requested through the
+// X-API-Version header or a vendor media type in Accept, such as
+// "application/vnd.myapp.v2+json".
+func requestVersion(req *http.Request) string {
+	if v := req.Header.Get("X-API-Version"); v != "" {
+		return normalizeVersion(v)
+	}
+
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+
+		if m := vendorVersion.FindStringSubmatch(strings.TrimSpace(mediaType)); m != nil {
+			return normalizeVersion(m[1])
+		}
+	}
+
+	return ""
+}
+
+// normalizeVersion makes "v2", "V2" and "2" compare equal.
+func normalizeVersion(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) > 1 && (v[0] == 'v' || v[0] == 'V') {
+		v = v[1:]
+	}
+	return v
+}
+
+// VersionGroup maps routes of one API version, see Version.
+type VersionGroup struct {
+	router  *Router
+	version string
+	policy  *versionPolicy
+}
+
+// VersionOption configures a VersionGroup.
+type VersionOption func(*versionPolicy)
+
+// versionPolicy holds the deprecation headers of a version.
+type versionPolicy struct {
+	deprecated bool
+	since      time.Time
+	sunset     time.Time
+	link       string
+}
+
+// Deprecated marks the version as deprecated: its responses carry a
+// Deprecation header, with the date since when it is deprecated unless
+// since is zero.
+func Deprecated(since time.Time) VersionOption {
+	return func(p *versionPolicy) {
+		p.deprecated = true
+		p.since = since
+	}
+}
+
+// Sunset announces when the version will stop being served in a Sunset
+// header on its responses.
+func Sunset(at time.Time) VersionOption {
+	return func(p *versionPolicy) {
+		p.sunset = at
+	}
+}
+
+// DeprecationLink points clients of a deprecated version to a migration
+// guide through a Link header with relation "deprecation".
+func DeprecationLink(url string) VersionOption {
+	return func(p *versionPolicy) {
+		p.link = url
+	}
+}
+
+// Version returns a group for mapping routes of an API version on the
+// default router. See Router.Version.
+func Version(version string, opts ...VersionOption) *VersionGroup {
+	return defaultRouter.Version(version, opts...)
+}
+
+// Version returns a group for mapping routes of an API version. Each route
+// is served both below the version path, e.g. /api/v2/users, and at the
+// unversioned path for requests negotiating the version through the
+// X-API-Version header or a vendor media type in Accept, like
+// MapGetVersioned.
+//
+//	v1 := r.Version("v1", httpfly.Deprecated(time.Time{}), httpfly.Sunset(eol))
+//	v1.MapGet("/users", httpfly.NoAuth, listUsersV1)
+//	r.Version("v2").MapGet("/users", httpfly.NoAuth, listUsers)
+func (r *Router) Version(version string, opts ...VersionOption) *VersionGroup {
+	var p versionPolicy
+	for _, opt := range opts {
+		opt(&p)
+	}
+
+	g := &VersionGroup{router: r, version: normalizeVersion(version)}
+	if p.deprecated || !p.sunset.IsZero() || p.link != "" {
+		g.policy = &p
+	}
+	return g
+}
+
+// Map maps a route of the version for any request method. It returns the
+// route negotiated by header; options apply to both routes.
+func (g *VersionGroup) Map(method RequestMethod, path string, auth AuthRequire, f Handler, opts ...RouteOption) *RouteInfo {
+	policy := g.policy
+	opts = append([]RouteOption{func(ri *RouteInfo) { ri.versionPolicy = policy }}, opts...)
+
+	g.router.addRoute(method, "/v"+g.version+path, auth, f, opts)
+	return g.router.addRoute(method, path, auth, f, append(opts, withVersion(g.version)))
+}
+
+// MapGet maps a GET route of the version.
+func (g *VersionGroup) MapGet(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.Map(MethodGet, path, auth, f, opts...)
+}
+
+// MapPost maps a POST route of the version.
+func (g *VersionGroup) MapPost(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.Map(MethodPost, path, auth, f, opts...)
+}
+
+// MapPut maps a PUT route of the version.
+func (g *VersionGroup) MapPut(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.Map(MethodPut, path, auth, f, opts...)
+}
+
+// MapDelete maps a DELETE route of the version.
+func (g *VersionGroup) MapDelete(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.Map(MethodDelete, path, auth, f, opts...)
+}
+
+// MapPatch maps a PATCH route of the version.
+func (g *VersionGroup) MapPatch(path string, auth AuthRequire, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return g.Map(MethodPatch, path, auth, f, opts...)
+}
+
+// setHeaders adds the deprecation headers of the version.
+func (p *versionPolicy) setHeaders(h http.Header) {
+	if p.deprecated {
+		if p.since.IsZero() {
+			h.Set("Deprecation", "true")
+		} else {
+			h.Set("Deprecation", "@"+strconv.FormatInt(p.since.Unix(), 10))
+		}
+	}
+	if !p.sunset.IsZero() {
+		h.Set("Sunset", p.sunset.UTC().Format(http.TimeFormat))
+	}
+	if p.link != "" {
+		h.Add("Link", "<"+p.link+`>; rel="deprecation"`)
+	}
+}
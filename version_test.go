@@ -0,0 +1,42 @@
+package httpfly
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	r := NewRouter()
+	r.MapGet("/users", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "unversioned") })
+	r.MapGetVersioned("/users", "v1", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "v1") })
+	r.MapGetVersioned("/users", "v2", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "v2") })
+	r.Version("v1").MapGet("/items", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "items v1") })
+	r.Version("v2").MapGet("/items", NoAuth, func(rb *RequestBody) { rb.Text(http.StatusOK, "items v2") })
+
+	c := NewTestClient(r)
+	for _, tt := range []struct {
+		path, header, accept, want string
+	}{
+		{"/api/users", "", "", "unversioned"},
+		{"/api/users", "v1", "", "v1"},
+		{"/api/users", "2", "", "v2"},
+		{"/api/users", "", "application/vnd.myapp.v2+json", "v2"},
+		{"/api/users", "v3", "", "unversioned"},
+		{"/api/v1/items", "", "", "items v1"},
+		{"/api/v2/items", "", "", "items v2"},
+		{"/api/items", "V1", "", "items v1"},
+		{"/api/items", "", "application/vnd.myapp.v2+json", "items v2"},
+	} {
+		c.Header = http.Header{}
+		if tt.header != "" {
+			c.Header.Set("X-API-Version", tt.header)
+		}
+		if tt.accept != "" {
+			c.Header.Set("Accept", tt.accept)
+		}
+		resp := c.Get(tt.path)
+		if resp.Status != http.StatusOK || resp.String() != tt.want {
+			t.Errorf("GET %s (version %q, accept %q) = %d %q, want %q", tt.path, tt.header, tt.accept, resp.Status, resp.String(), tt.want)
+		}
+	}
+}
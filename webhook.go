@@ -0,0 +1,127 @@
+package httpfly
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultWebhookTolerance is how old a signed webhook timestamp may be
+// before VerifyStripe rejects the request as a replay.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is the error answered to webhook requests whose
+// signature does not verify.
+var ErrInvalidSignature = &HTTPError{Status: http.StatusUnauthorized, Code: "invalid_signature", Message: "invalid webhook signature"}
+
+// WebhookVerifier checks the signature of a webhook request over its raw
+// body.
+type WebhookVerifier func(req *http.Request, body []byte) error
+
+// MapWebhook maps a POST webhook route on the default router. See
+// Router.MapWebhook.
+func MapWebhook(path string, verify WebhookVerifier, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapWebhook(path, verify, f, opts...)
+}
+
+// MapWebhook maps a POST route that receives webhooks. The route needs no
+// authentication; instead verify checks the signature of every request
+// before any other route middleware and the handler run, and failures are
+// answered with ErrInvalidSignature.
+//
+//	r.MapWebhook("/hooks/github", httpfly.VerifyHMAC(secret, "X-Hub-Signature-256"), onPush)
+func (r *Router) MapWebhook(path string, verify WebhookVerifier, f func(r *RequestBody), opts ...RouteOption) *RouteInfo {
+	check := func(rb *RequestBody, response http.ResponseWriter, request *http.Request) {
+		body, err := rb.RawBody()
+		if err != nil {
+			rb.Fail(err)
+			return
+		}
+
+		if err := verify(request, body); err != nil {
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) {
+				err = ErrInvalidSignature
+			}
+			rb.Fail(err)
+		}
+	}
+
+	opts = append([]RouteOption{WithMiddleware(check)}, opts...)
+	return r.addRoute(MethodPost, path, NoAuth, f, opts)
+}
+
+// VerifyHMAC verifies an HMAC-SHA256 signature of the body sent in header
+// as hex, optionally prefixed with "sha256=" as GitHub does.
+func VerifyHMAC(secret, header string) WebhookVerifier {
+	return func(req *http.Request, body []byte) error {
+		sig := strings.TrimPrefix(req.Header.Get(header), "sha256=")
+		if !validHMAC([]byte(secret), body, sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+}
+
+// VerifyStripe verifies Stripe-style signatures: the Stripe-Signature
+// header holds a timestamp t and one or more v1 HMAC-SHA256 signatures of
+// "t.body". Requests whose timestamp is more than tolerance away from now
+// are rejected to prevent replays. Zero tolerance means
+// DefaultWebhookTolerance.
+func VerifyStripe(secret string, tolerance time.Duration) WebhookVerifier {
+	if tolerance <= 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+
+	return func(req *http.Request, body []byte) error {
+		var ts string
+		var sigs []string
+
+		for _, part := range strings.Split(req.Header.Get("Stripe-Signature"), ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch k {
+			case "t":
+				ts = v
+			case "v1":
+				sigs = append(sigs, v)
+			}
+		}
+
+		secs, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return ErrInvalidSignature
+		}
+
+		age := time.Since(time.Unix(secs, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrInvalidSignature
+		}
+
+		payload := make([]byte, 0, len(ts)+1+len(body))
+		payload = append(append(append(payload, ts...), '.'), body...)
+
+		for _, sig := range sigs {
+			if validHMAC([]byte(secret), payload, sig) {
+				return nil
+			}
+		}
+		return ErrInvalidSignature
+	}
+}
+
+// validHMAC reports whether sig is the hex HMAC-SHA256 of msg.
+func validHMAC(secret, msg []byte, sig string) bool {
+	want, err := hex.DecodeString(sig)
+	if err != nil || len(want) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(msg)
+	return hmac.Equal(mac.Sum(nil), want)
+}
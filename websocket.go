@@ -0,0 +1,421 @@
+package httpfly
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// WebSocket message types.
+const (
+	WSText   = 1
+	WSBinary = 2
+)
+
+const (
+	wsContinuation = 0
+	wsClose        = 8
+	wsPing         = 9
+	wsPong         = 10
+)
+
+// WebSocket close codes.
+const (
+	WSCloseNormal        = 1000
+	WSCloseGoingAway     = 1001
+	WSCloseProtocolError = 1002
+	WSCloseTooBig        = 1009
+)
+
+// DefaultWSReadLimit is the default maximum size of a received message.
+const DefaultWSReadLimit = 16 << 20
+
+// wsGUID is the key suffix defined by RFC 6455.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WSCloseError is returned by WSConn reads once the peer has closed the
+// connection.
+type WSCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WSCloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with code %d %s", e.Code, e.Reason)
+}
+
+// errWSProtocol is returned for frames that violate RFC 6455.
+var errWSProtocol = errors.New("websocket: protocol error")
+
+// errWSOrigin is returned for handshakes rejected by the origin policy.
+var errWSOrigin = errors.New("websocket: origin not allowed")
+
+// WSConn is a server-side WebSocket connection. Reads must happen from a
+// single goroutine; writes may happen from several.
+type WSConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	writeMu sync.Mutex
+	closed  bool
+
+	readLimit int64
+	onPong    func(data string)
+}
+
+// WSOriginPolicy decides which pages may open WebSocket connections.
+// Browsers send cookies with cross-origin handshakes, so by default only
+// same-origin pages and clients without an Origin header are upgraded.
+type WSOriginPolicy struct {
+	// AllowedOrigins lists other origins to upgrade, e.g.
+	// "https://app.example.com". "*" allows every origin.
+	AllowedOrigins []string
+	// CheckOrigin, if set, decides instead of AllowedOrigins.
+	CheckOrigin func(req *http.Request) bool
+}
+
+// SetWSOriginPolicy sets the origin policy of the WebSocket routes of the
+// default router. See Router.SetWSOriginPolicy.
+func SetWSOriginPolicy(p WSOriginPolicy) {
+	defaultRouter.SetWSOriginPolicy(p)
+}
+
+// SetWSOriginPolicy sets the origin policy of the WebSocket routes of the
+// router. Handshakes from origins it rejects get 403 Forbidden.
+func (r *Router) SetWSOriginPolicy(p WSOriginPolicy) {
+	r.wsOrigins = &p
+}
+
+// allows reports whether the handshake req may be upgraded. A nil policy
+// allows same-origin requests only.
+func (p *WSOriginPolicy) allows(req *http.Request) bool {
+	if p != nil && p.CheckOrigin != nil {
+		return p.CheckOrigin(req)
+	}
+
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+	return p != nil && (slices.Contains(p.AllowedOrigins, "*") || slices.ContainsFunc(p.AllowedOrigins, func(o string) bool {
+		return strings.EqualFold(o, origin)
+	}))
+}
+
+// MapWebSocket maps a GET route that upgrades to a WebSocket connection and
+// calls f with it. Authentication and middleware run before the upgrade,
+// as does the origin check of the WSOriginPolicy; the connection is closed
+// when f returns.
+func MapWebSocket(path string, auth AuthRequire, f func(conn *WSConn, r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return defaultRouter.MapWebSocket(path, auth, f, opts...)
+}
+
+// MapWebSocket maps a WebSocket route on the router.
+func (r *Router) MapWebSocket(path string, auth AuthRequire, f func(conn *WSConn, r *RequestBody), opts ...RouteOption) *RouteInfo {
+	return r.addRoute(MethodGet, path, auth, func(rb *RequestBody) {
+		conn, err := r.upgradeWebSocket(rb.ResponseW, rb.req)
+		if err != nil {
+			return
+		}
+		defer conn.conn.Close()
+
+		f(conn, rb)
+		conn.Close()
+	}, opts)
+}
+
+// upgradeWebSocket performs the opening handshake. On failure it has
+// written an error response.
+func (r *Router) upgradeWebSocket(w http.ResponseWriter, req *http.Request) (*WSConn, error) {
+	if !headerContainsToken(req.Header, "Connection", "upgrade") || !headerContainsToken(req.Header, "Upgrade", "websocket") {
+		r.frameworkError(w, req, http.StatusBadRequest, "websocket: upgrade required")
+		return nil, errWSProtocol
+	}
+
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		r.frameworkError(w, req, http.StatusUpgradeRequired, "websocket: unsupported version")
+		return nil, errWSProtocol
+	}
+
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		r.frameworkError(w, req, http.StatusBadRequest, "websocket: missing key")
+		return nil, errWSProtocol
+	}
+
+	if !r.wsOrigins.allows(req) {
+		r.frameworkError(w, req, http.StatusForbidden, "websocket: origin not allowed")
+		return nil, errWSOrigin
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		r.frameworkError(w, req, http.StatusInternalServerError, "websocket: connection cannot be upgraded")
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &WSConn{conn: conn, br: rw.Reader, readLimit: DefaultWSReadLimit}, nil
+}
+
+// headerContainsToken reports whether a comma-separated header contains
+// token, case-insensitively.
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetReadLimit sets the maximum size of a received message. Larger messages
+// close the connection.
+func (c *WSConn) SetReadLimit(n int64) {
+	c.readLimit = n
+}
+
+// SetPongHandler sets a function called with the payload of received pongs.
+func (c *WSConn) SetPongHandler(f func(data string)) {
+	c.onPong = f
+}
+
+// RemoteAddr returns the address of the peer.
+func (c *WSConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage returns the next data message. Pings are answered and pongs
+// passed to the pong handler while waiting. After the peer closes the
+// connection it returns a *WSCloseError.
+func (c *WSConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+
+		case wsPong:
+			if c.onPong != nil {
+				c.onPong(string(payload))
+			}
+			continue
+
+		case wsClose:
+			// 1005 means no code was sent; it is never sent back.
+			closeErr := &WSCloseError{Code: 1005}
+			reply := WSCloseNormal
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+				reply = closeErr.Code
+			}
+			c.CloseWithCode(reply, "")
+			return 0, nil, closeErr
+
+		case WSText, WSBinary:
+			if messageType != 0 {
+				return 0, nil, c.fail(WSCloseProtocolError, errWSProtocol)
+			}
+			messageType = opcode
+
+		case wsContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(WSCloseProtocolError, errWSProtocol)
+			}
+
+		default:
+			return 0, nil, c.fail(WSCloseProtocolError, errWSProtocol)
+		}
+
+		if int64(len(data))+int64(len(payload)) > c.readLimit {
+			return 0, nil, c.fail(WSCloseTooBig, errors.New("websocket: message too big"))
+		}
+		data = append(data, payload...)
+
+		if fin {
+			return messageType, data, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (c *WSConn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends a single data message.
+func (c *WSConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != WSText && messageType != WSBinary {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// WriteJSON sends v encoded as JSON in a text message.
+func (c *WSConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(WSText, data)
+}
+
+// Ping sends a ping with the given payload of at most 125 bytes.
+func (c *WSConn) Ping(data []byte) error {
+	return c.writeFrame(wsPing, data)
+}
+
+// Close sends a normal close frame and closes the connection.
+func (c *WSConn) Close() error {
+	return c.CloseWithCode(WSCloseNormal, "")
+}
+
+// CloseWithCode sends a close frame with the given code and reason and
+// closes the connection. Closing twice is a no-op.
+func (c *WSConn) CloseWithCode(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	err := c.writeFrame(wsClose, payload)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// fail closes the connection with code and returns err.
+func (c *WSConn) fail(code int, err error) error {
+	c.CloseWithCode(code, "")
+	return err
+}
+
+// readFrame reads one frame. Client frames must be masked.
+func (c *WSConn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+
+	if head[0]&0x70 != 0 || !masked {
+		return false, 0, nil, c.fail(WSCloseProtocolError, errWSProtocol)
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if opcode >= wsClose && (length > 125 || !fin) {
+		return false, 0, nil, c.fail(WSCloseProtocolError, errWSProtocol)
+	}
+
+	if length < 0 || length > c.readLimit {
+		return false, 0, nil, c.fail(WSCloseTooBig, errors.New("websocket: message too big"))
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unmasked, final frame.
+func (c *WSConn) writeFrame(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+
+	frame := []byte{0x80 | byte(opcode)}
+
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	_, err := c.conn.Write(append(frame, payload...))
+	return err
+}
@@ -0,0 +1,74 @@
+package httpfly
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// wsHandshake sends an opening handshake with origin to srv and returns
+// the response status.
+func wsHandshake(t *testing.T, srv *httptest.Server, origin string) int {
+	t.Helper()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	r := NewRouter()
+	r.MapWebSocket("/ws", NoAuth, func(conn *WSConn, rb *RequestBody) {})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	self := srv.URL
+	tests := []struct {
+		name   string
+		policy *WSOriginPolicy
+		origin string
+		want   int
+	}{
+		{"no origin", nil, "", http.StatusSwitchingProtocols},
+		{"same origin", nil, self, http.StatusSwitchingProtocols},
+		{"cross origin", nil, "https://evil.example", http.StatusForbidden},
+		{"allowed origin", &WSOriginPolicy{AllowedOrigins: []string{"https://app.example"}}, "https://app.example", http.StatusSwitchingProtocols},
+		{"unlisted origin", &WSOriginPolicy{AllowedOrigins: []string{"https://app.example"}}, "https://evil.example", http.StatusForbidden},
+		{"wildcard", &WSOriginPolicy{AllowedOrigins: []string{"*"}}, "https://evil.example", http.StatusSwitchingProtocols},
+		{"check func", &WSOriginPolicy{CheckOrigin: func(req *http.Request) bool { return false }}, self, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.wsOrigins = tt.policy
+			if got := wsHandshake(t, srv, tt.origin); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
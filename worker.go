@@ -0,0 +1,144 @@
+package httpfly
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// Defaults of the worker pool running deferred tasks.
+const (
+	DefaultWorkers       = 8
+	DefaultWorkQueueSize = 1024
+)
+
+// Task is work deferred by a handler with Defer.
+type Task func(ctx context.Context)
+
+// workerPool runs deferred tasks in the background.
+type workerPool struct {
+	size int
+
+	once   sync.Once
+	mu     sync.RWMutex
+	closed bool
+	tasks  chan Task
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// SetWorkers sets the number of workers running the tasks deferred by
+// handlers of the default router. See Router.SetWorkers.
+func SetWorkers(n int) {
+	defaultRouter.SetWorkers(n)
+}
+
+// SetWorkers sets the number of workers running the tasks deferred by
+// handlers of the router. Zero means DefaultWorkers. Call it before the
+// first request.
+func (r *Router) SetWorkers(n int) {
+	r.workers.size = n
+}
+
+// Defer schedules f to run on the worker pool of the router once the
+// response has been written, so the handler can return without waiting for
+// slow work such as sending emails or webhooks. f receives a context that
+// is not tied to the request; it is canceled when the drain started by a
+// server shutdown times out. Panics in f are recovered and logged.
+func (r *RequestBody) Defer(f Task) {
+	r.deferred = append(r.deferred, f)
+}
+
+// startDeferred hands the tasks deferred by the request to the workers.
+func (r *Router) startDeferred(rb *RequestBody) {
+	for _, f := range rb.deferred {
+		r.workers.submit(r, f)
+	}
+}
+
+// DrainWorkers stops accepting deferred tasks and waits until the queued
+// ones have finished or ctx expires, in which case the context of the
+// running tasks is canceled and ctx.Err() is returned. Server.Shutdown
+// calls it once open requests have finished.
+func (r *Router) DrainWorkers(ctx context.Context) error {
+	return r.workers.drain(ctx)
+}
+
+// start launches the workers.
+func (p *workerPool) start(r *Router) {
+	n := p.size
+	if n <= 0 {
+		n = DefaultWorkers
+	}
+
+	p.tasks = make(chan Task, DefaultWorkQueueSize)
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	p.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer p.wg.Done()
+			for f := range p.tasks {
+				p.run(r, f)
+			}
+		}()
+	}
+}
+
+// submit queues f, blocking while the queue is full. Tasks submitted after
+// the pool has been drained are dropped.
+func (p *workerPool) submit(r *Router, f Task) {
+	p.once.Do(func() { p.start(r) })
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		if r.logger != nil {
+			r.logger.Error("deferred task dropped", "error", "workers are drained")
+		}
+		return
+	}
+	p.tasks <- f
+}
+
+// run calls f, recovering and logging panics.
+func (p *workerPool) run(r *Router, f Task) {
+	defer func() {
+		if rec := recover(); rec != nil && r.logger != nil {
+			r.logger.Error("deferred task panicked", "error", fmt.Sprint(rec), "stack", string(debug.Stack()))
+		}
+	}()
+
+	f(p.ctx)
+}
+
+// drain closes the queue and waits for the workers.
+func (p *workerPool) drain(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed || p.tasks == nil {
+		p.closed = true
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.tasks)
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
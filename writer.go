@@ -0,0 +1,136 @@
+package httpfly
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ResponseRecorder wraps the writer handed to middleware and handlers to
+// record what has been written. Chain middleware can inspect it after
+// calling next through RequestBody.Recorder.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status     int
+	size       int
+	firstWrite time.Time
+}
+
+// WriteHeader records the status and passes it on.
+func (w *ResponseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.firstWrite = time.Now()
+	}
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body size and passes the data on.
+func (w *ResponseRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+		w.firstWrite = time.Now()
+	}
+
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+// Status returns the status written so far, or 0.
+func (w *ResponseRecorder) Status() int {
+	return w.status
+}
+
+// Size returns the number of body bytes written so far.
+func (w *ResponseRecorder) Size() int {
+	return w.size
+}
+
+// FirstWrite returns when the status or the first body bytes were written,
+// or the zero time.
+func (w *ResponseRecorder) FirstWrite() time.Time {
+	return w.firstWrite
+}
+
+// Hijack implements http.Hijacker when the underlying writer does. A
+// hijacked response is recorded with status 101 Switching Protocols.
+func (w *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+		w.firstWrite = time.Now()
+	}
+	return conn, rw, err
+}
+
+// Flush implements http.Flusher when the underlying writer does.
+func (w *ResponseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// headWriter serves a HEAD request with a GET handler. It discards the
+// body and holds the status back until the handler is done, so the
+// Content-Length of the body that would have been sent can be reported.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+	done   bool
+}
+
+// WriteHeader records the status.
+func (w *headWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write counts and discards the data.
+func (w *headWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.size += len(data)
+	return len(data), nil
+}
+
+// finish sends the status, with the Content-Length of the discarded body
+// unless the handler set one.
+func (w *headWriter) finish() {
+	if w.done {
+		return
+	}
+	w.done = true
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.ResponseWriter.Header()
+	if h.Get("Content-Length") == "" && w.size > 0 {
+		h.Set("Content-Length", strconv.Itoa(w.size))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// written reports whether a status or body has been written.
+func (w *ResponseRecorder) written() bool {
+	return w.status != 0
+}
+
+// Recorder returns the recorder of the response, for middleware and
+// after-response hooks that inspect the status and size written by the
+// handler. It is nil for request bodies not created by a Router.
+func (r *RequestBody) Recorder() *ResponseRecorder {
+	return r.w
+}
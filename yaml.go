@@ -0,0 +1,170 @@
+package httpfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a significant line of a YAML document.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// unmarshalYAML decodes the YAML subset supported by LoadConfig into v by
+// way of JSON, so v uses its JSON field tags.
+func unmarshalYAML(data []byte, v any) error {
+	b, err := yamlToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// yamlToJSON converts a document of the supported YAML subset to JSON.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		text := stripYAMLComment(strings.TrimRight(raw, " \t\r"))
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{i + 1, len(text) - len(trimmed), trimmed})
+	}
+
+	if len(lines) == 0 {
+		return []byte("null"), nil
+	}
+
+	doc, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[next].num)
+	}
+
+	return json.Marshal(doc)
+}
+
+// parseYAMLBlock parses the mapping or list starting at lines[i] whose
+// entries are indented by indent.
+func parseYAMLBlock(lines []yamlLine, i, indent int) (any, int, error) {
+	if isYAMLListItem(lines[i].text) {
+		var list []any
+		for i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text) {
+			item := strings.TrimSpace(strings.TrimPrefix(lines[i].text, "-"))
+			if strings.Contains(item, ": ") || strings.HasSuffix(item, ":") {
+				return nil, i, fmt.Errorf("line %d: mappings in lists are not supported", lines[i].num)
+			}
+			list = append(list, parseYAMLScalar(item))
+			i++
+		}
+		return list, i, nil
+	}
+
+	m := map[string]any{}
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+
+		key, value, ok := strings.Cut(line.text, ": ")
+		if !ok {
+			if !strings.HasSuffix(line.text, ":") {
+				return nil, i, fmt.Errorf("line %d: expected key: value", line.num)
+			}
+			key = strings.TrimSuffix(line.text, ":")
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		value = strings.TrimSpace(value)
+		i++
+
+		switch {
+		case value != "":
+			m[key] = parseYAMLScalar(value)
+		case i < len(lines) && lines[i].indent > indent:
+			child, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, next, err
+			}
+			m[key], i = child, next
+		case i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text):
+			child, next, err := parseYAMLBlock(lines, i, indent)
+			if err != nil {
+				return nil, next, err
+			}
+			m[key], i = child, next
+		default:
+			m[key] = nil
+		}
+	}
+
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, i, fmt.Errorf("line %d: unexpected indentation", lines[i].num)
+	}
+	return m, i, nil
+}
+
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseYAMLScalar converts a scalar or flow list to its JSON equivalent.
+func parseYAMLScalar(s string) any {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"') {
+		if v, err := strconv.Unquote(s); err == nil {
+			return v
+		}
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+
+	if len(s) >= 2 && s[0] == '[' && s[len(s)-1] == ']' {
+		list := []any{}
+		if inner := strings.TrimSpace(s[1 : len(s)-1]); inner != "" {
+			for _, item := range strings.Split(inner, ",") {
+				list = append(list, parseYAMLScalar(strings.TrimSpace(item)))
+			}
+		}
+		return list
+	}
+
+	switch strings.ToLower(s) {
+	case "null", "~":
+		return nil
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	}
+
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n
+	}
+	return s
+}
+
+// stripYAMLComment removes a # comment that is not inside quotes.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimRight(s[:i], " \t")
+		}
+	}
+	return s
+}